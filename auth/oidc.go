@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+const (
+	SessionCookieName = "f2bd_session"
+	LoginCookieName   = "f2bd_login"
+	// loginTimeout is how long the provider may take until it redirects back
+	loginTimeout = 10 * time.Minute
+)
+
+// OIDCConfiguration is read from the oidc section of the config file
+type OIDCConfiguration struct {
+	Issuer        string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	Scopes        []string
+	UsernameClaim string
+	GroupsClaim   string
+	Roles         GroupRoles
+	SessionSecret string
+	SessionHours  int
+}
+
+// OIDC logs users in with the authorization code flow and PKCE, the user is kept in an encrypted session cookie
+type OIDC struct {
+	configuration OIDCConfiguration
+	provider      *oidc.Provider
+	verifier      *oidc.IDTokenVerifier
+	sealer        *sealer
+}
+
+// loginState is kept in a cookie between the redirect to the provider and the callback
+type loginState struct {
+	State       string `json:"state"`
+	Nonce       string `json:"nonce"`
+	Verifier    string `json:"verifier"`
+	RedirectURL string `json:"redirectURL"`
+}
+
+// NewOIDC reads the configuration of the provider from its discovery document
+func NewOIDC(ctx context.Context, configuration OIDCConfiguration) (*OIDC, error) {
+	if configuration.Issuer == "" || configuration.ClientID == "" {
+		return nil, errors.New("issuer and client id are required")
+	}
+	if len(configuration.Scopes) == 0 {
+		configuration.Scopes = []string{oidc.ScopeOpenID, "profile", "email"}
+	}
+	if !containsAny(configuration.Scopes, []string{oidc.ScopeOpenID}) {
+		configuration.Scopes = append([]string{oidc.ScopeOpenID}, configuration.Scopes...)
+	}
+	if configuration.UsernameClaim == "" {
+		configuration.UsernameClaim = "preferred_username"
+	}
+	if configuration.GroupsClaim == "" {
+		configuration.GroupsClaim = "groups"
+	}
+	if configuration.SessionHours < 1 {
+		configuration.SessionHours = 12
+	}
+
+	provider, err := oidc.NewProvider(ctx, configuration.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("discovery of %s failed: %w", configuration.Issuer, err)
+	}
+
+	sessionSealer, err := newSealer(configuration.SessionSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OIDC{
+		configuration: configuration,
+		provider:      provider,
+		verifier:      provider.Verifier(&oidc.Config{ClientID: configuration.ClientID}),
+		sealer:        sessionSealer,
+	}, nil
+}
+
+// RedirectURL is the configured callback, the fallback is used when none is configured
+func (o *OIDC) RedirectURL(fallback string) string {
+	if o.configuration.RedirectURL != "" {
+		return o.configuration.RedirectURL
+	}
+	return fallback
+}
+
+func (o *OIDC) oauth2Config(redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     o.configuration.ClientID,
+		ClientSecret: o.configuration.ClientSecret,
+		Endpoint:     o.provider.Endpoint(),
+		RedirectURL:  redirectURL,
+		Scopes:       o.configuration.Scopes,
+	}
+}
+
+// Start returns the URL of the provider to send the browser to and the sealed login state for the login cookie
+func (o *OIDC) Start(redirectURL string) (string, string, time.Time, error) {
+	login := loginState{
+		State:       randomToken(),
+		Nonce:       randomToken(),
+		Verifier:    oauth2.GenerateVerifier(),
+		RedirectURL: redirectURL,
+	}
+
+	expires := time.Now().Add(loginTimeout)
+	cookie, err := o.sealer.seal(LoginCookieName, login, expires)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+
+	authURL := o.oauth2Config(redirectURL).AuthCodeURL(login.State, oidc.Nonce(login.Nonce), oauth2.S256ChallengeOption(login.Verifier))
+	return authURL, cookie, expires, nil
+}
+
+// Finish exchanges the code of the callback and verifies the ID token, it returns the user and the sealed session
+func (o *OIDC) Finish(ctx context.Context, loginCookie string, state string, code string) (User, string, time.Time, error) {
+	var login loginState
+	if err := o.sealer.open(LoginCookieName, loginCookie, &login); err != nil {
+		return User{}, "", time.Time{}, fmt.Errorf("login state is missing or expired: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(login.State), []byte(state)) != 1 {
+		return User{}, "", time.Time{}, errors.New("login state does not match")
+	}
+
+	token, err := o.oauth2Config(login.RedirectURL).Exchange(ctx, code, oauth2.VerifierOption(login.Verifier))
+	if err != nil {
+		return User{}, "", time.Time{}, fmt.Errorf("code exchange failed: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return User{}, "", time.Time{}, errors.New("the provider did not return an ID token")
+	}
+
+	idToken, err := o.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return User{}, "", time.Time{}, fmt.Errorf("invalid ID token: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(login.Nonce)) != 1 {
+		return User{}, "", time.Time{}, errors.New("ID token nonce does not match")
+	}
+
+	var claims map[string]any
+	if err = idToken.Claims(&claims); err != nil {
+		return User{}, "", time.Time{}, err
+	}
+
+	user, err := o.userFromClaims(idToken.Subject, claims)
+	if err != nil {
+		return User{}, "", time.Time{}, err
+	}
+
+	expires := time.Now().Add(time.Duration(o.configuration.SessionHours) * time.Hour)
+	session, err := o.sealer.seal(SessionCookieName, user, expires)
+	if err != nil {
+		return User{}, "", time.Time{}, err
+	}
+	return user, session, expires, nil
+}
+
+// userFromClaims maps the groups claim to a role, the subject is the name when the username claim is missing
+func (o *OIDC) userFromClaims(subject string, claims map[string]any) (User, error) {
+	name, _ := claimValue(claims, o.configuration.UsernameClaim).(string)
+	if name == "" {
+		name = subject
+	}
+
+	role, ok := o.configuration.Roles.Role(claimStrings(claimValue(claims, o.configuration.GroupsClaim)))
+	if !ok {
+		return User{}, fmt.Errorf("user %s is in none of the configured groups", name)
+	}
+	return User{Name: name, Role: role}, nil
+}
+
+// Session returns the user of a valid session cookie
+func (o *OIDC) Session(cookie string) (User, bool) {
+	if cookie == "" {
+		return User{}, false
+	}
+	var user User
+	if err := o.sealer.open(SessionCookieName, cookie, &user); err != nil {
+		return User{}, false
+	}
+	return user, true
+}
+
+// claimValue reads a claim, nested claims like realm_access.roles of Keycloak are separated by dots
+func claimValue(claims map[string]any, name string) any {
+	var value any = claims
+	for _, part := range strings.Split(name, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[part]
+	}
+	return value
+}
+
+// claimStrings accepts a list of strings or a single string
+func claimStrings(value any) []string {
+	switch typed := value.(type) {
+	case string:
+		return []string{typed}
+	case []any:
+		result := make([]string, 0, len(typed))
+		for _, entry := range typed {
+			if text, ok := entry.(string); ok {
+				result = append(result, text)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
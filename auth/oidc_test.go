@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOIDC_userFromClaims(t *testing.T) {
+	tests := []struct {
+		name          string
+		configuration OIDCConfiguration
+		claims        map[string]any
+		expected      User
+		wantErr       bool
+	}{
+		{
+			name:          "admin group",
+			configuration: OIDCConfiguration{UsernameClaim: "preferred_username", GroupsClaim: "groups", Roles: GroupRoles{AdminGroups: []string{"admins"}}},
+			claims:        map[string]any{"preferred_username": "alice", "groups": []any{"dev", "admins"}},
+			expected:      User{Name: "alice", Role: RoleAdmin},
+		},
+		{
+			name:          "subject without username",
+			configuration: OIDCConfiguration{UsernameClaim: "preferred_username", GroupsClaim: "groups", Roles: GroupRoles{AdminGroups: []string{"admins"}}},
+			claims:        map[string]any{},
+			expected:      User{Name: "subject", Role: RoleViewer},
+		},
+		{
+			name:          "nested roles",
+			configuration: OIDCConfiguration{UsernameClaim: "email", GroupsClaim: "realm_access.roles", Roles: GroupRoles{AdminGroups: []string{"f2b-admin"}}},
+			claims:        map[string]any{"email": "alice@example.com", "realm_access": map[string]any{"roles": []any{"f2b-admin"}}},
+			expected:      User{Name: "alice@example.com", Role: RoleAdmin},
+		},
+		{
+			name:          "single group",
+			configuration: OIDCConfiguration{UsernameClaim: "preferred_username", GroupsClaim: "groups", Roles: GroupRoles{ViewerGroups: []string{"ops"}}},
+			claims:        map[string]any{"preferred_username": "bob", "groups": "ops"},
+			expected:      User{Name: "bob", Role: RoleViewer},
+		},
+		{
+			name:          "not in a viewer group",
+			configuration: OIDCConfiguration{UsernameClaim: "preferred_username", GroupsClaim: "groups", Roles: GroupRoles{ViewerGroups: []string{"ops"}}},
+			claims:        map[string]any{"preferred_username": "mallory", "groups": []any{"dev"}},
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &OIDC{configuration: tt.configuration}
+			user, err := provider.userFromClaims("subject", tt.claims)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("userFromClaims() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(user, tt.expected) {
+				t.Errorf("userFromClaims() = %+v, want %+v", user, tt.expected)
+			}
+		})
+	}
+}
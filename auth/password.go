@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// dummyHash is compared for unknown users, so they take as long as a wrong password
+const dummyHash = "$2a$10$fUq/lV2KIFjulq6e5nFUXOLUx8SIwY6jmGANyPlRjx1.Y9N.hc2zi"
+
+var errUnsupportedHash = errors.New("unsupported password hash, use bcrypt ($2a$, $2b$ or $2y$) or argon2 ($argon2id$ or $argon2i$)")
+
+// verifier checks a password against a stored hash
+type verifier func(password string) bool
+
+// newVerifier parses the hash once, so invalid hashes are found at startup and not on the first login
+func newVerifier(hash string) (verifier, error) {
+	switch {
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("invalid bcrypt hash: %w", err)
+		}
+		return func(password string) bool {
+			return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+		}, nil
+	case strings.HasPrefix(hash, "$argon2id$"), strings.HasPrefix(hash, "$argon2i$"):
+		return newArgon2Verifier(hash)
+	}
+	return nil, errUnsupportedHash
+}
+
+// newArgon2Verifier reads hashes in the PHC format of the argon2 reference implementation,
+// e.g. $argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash> with unpadded base64 salt and hash
+func newArgon2Verifier(hash string) (verifier, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return nil, errors.New("invalid argon2 hash, expected $<variant>$v=<version>$m=<memory>,t=<time>,p=<threads>$<salt>$<hash>")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+
+	var memory, iterations uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil {
+		return nil, fmt.Errorf("invalid argon2 parameters %q", parts[3])
+	}
+	if iterations == 0 || threads == 0 {
+		return nil, fmt.Errorf("invalid argon2 parameters %q", parts[3])
+	}
+
+	salt, saltErr := base64.RawStdEncoding.DecodeString(parts[4])
+	if saltErr != nil {
+		return nil, fmt.Errorf("invalid argon2 salt: %w", saltErr)
+	}
+	key, keyErr := base64.RawStdEncoding.DecodeString(parts[5])
+	if keyErr != nil || len(key) == 0 {
+		return nil, errors.New("invalid argon2 hash value")
+	}
+
+	derive := argon2.IDKey
+	if parts[1] == "argon2i" {
+		derive = argon2.Key
+	}
+
+	return func(password string) bool {
+		derived := derive([]byte(password), salt, iterations, memory, threads, uint32(len(key)))
+		return subtle.ConstantTimeCompare(derived, key) == 1
+	}, nil
+}
+
+// plainVerifier compares the password of --auth-password, which is given in plain text
+func plainVerifier(expected string) verifier {
+	return func(password string) bool {
+		return subtle.ConstantTimeCompare([]byte(password), []byte(expected)) == 1
+	}
+}
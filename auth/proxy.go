@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// ProxyAuth trusts the user and groups headers of an authenticating reverse proxy like Authelia or oauth2-proxy,
+// the headers are only accepted from the trusted proxies
+type ProxyAuth struct {
+	UserHeader     string
+	GroupsHeader   string
+	TrustedProxies []netip.Prefix
+	Roles          GroupRoles
+}
+
+// ParsePrefixes reads CIDR ranges, single addresses are a range of their own
+func ParsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if address, err := netip.ParseAddr(value); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(address.Unmap(), address.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address or CIDR range %q", value)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// PrefixesContain tells if the address is part of one of the ranges
+func PrefixesContain(prefixes []netip.Prefix, remoteAddress string) bool {
+	address, err := netip.ParseAddr(remoteAddress)
+	if err != nil {
+		return false
+	}
+	address = address.Unmap()
+	for _, prefix := range prefixes {
+		if prefix.Contains(address) {
+			return true
+		}
+	}
+	return false
+}
+
+// Trusted tells if the request was sent by one of the trusted proxies
+func (proxy *ProxyAuth) Trusted(remoteAddress string) bool {
+	return PrefixesContain(proxy.TrustedProxies, remoteAddress)
+}
+
+// User maps the comma separated groups of the proxy to a role, Authelia and oauth2-proxy separate groups with commas
+func (proxy *ProxyAuth) User(name string, groups string) (User, bool) {
+	if name == "" {
+		return User{}, false
+	}
+	role, ok := proxy.Roles.Role(splitGroups(groups))
+	if !ok {
+		return User{}, false
+	}
+	return User{Name: name, Role: role}, true
+}
+
+func splitGroups(groups string) []string {
+	result := make([]string, 0)
+	for _, group := range strings.Split(groups, ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			result = append(result, group)
+		}
+	}
+	return result
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePrefixes(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []string
+		expected []string
+		wantErr  bool
+	}{
+		{name: "empty", values: nil, expected: []string{}},
+		{name: "address", values: []string{"127.0.0.1", "::1"}, expected: []string{"127.0.0.1/32", "::1/128"}},
+		{name: "ranges", values: []string{" 10.0.0.17/8", "", "fd00::/8"}, expected: []string{"10.0.0.0/8", "fd00::/8"}},
+		{name: "invalid", values: []string{"proxy.example.com"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefixes, err := ParsePrefixes(tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePrefixes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			result := make([]string, len(prefixes))
+			for index, prefix := range prefixes {
+				result[index] = prefix.String()
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("ParsePrefixes() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestProxyAuth_Trusted(t *testing.T) {
+	prefixes, err := ParsePrefixes([]string{"10.0.0.0/8", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := &ProxyAuth{TrustedProxies: prefixes}
+
+	tests := []struct {
+		remote   string
+		expected bool
+	}{
+		{"10.1.2.3", true},
+		{"::ffff:10.1.2.3", true},
+		{"::1", true},
+		{"192.168.1.1", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.remote, func(t *testing.T) {
+			if trusted := proxy.Trusted(tt.remote); trusted != tt.expected {
+				t.Errorf("Trusted(%q) = %v, want %v", tt.remote, trusted, tt.expected)
+			}
+		})
+	}
+}
+
+func TestProxyAuth_User(t *testing.T) {
+	tests := []struct {
+		name     string
+		proxy    ProxyAuth
+		user     string
+		groups   string
+		expected User
+		wantOK   bool
+	}{
+		{
+			name:     "admin group",
+			proxy:    ProxyAuth{Roles: GroupRoles{AdminGroups: []string{"admins"}}},
+			user:     "alice",
+			groups:   "dev, admins",
+			expected: User{Name: "alice", Role: RoleAdmin},
+			wantOK:   true,
+		},
+		{
+			name:     "viewer without viewer groups",
+			proxy:    ProxyAuth{Roles: GroupRoles{AdminGroups: []string{"admins"}}},
+			user:     "bob",
+			groups:   "dev",
+			expected: User{Name: "bob", Role: RoleViewer},
+			wantOK:   true,
+		},
+		{
+			name:     "viewer group",
+			proxy:    ProxyAuth{Roles: GroupRoles{AdminGroups: []string{"admins"}, ViewerGroups: []string{"ops"}}},
+			user:     "bob",
+			groups:   "ops",
+			expected: User{Name: "bob", Role: RoleViewer},
+			wantOK:   true,
+		},
+		{
+			name:   "not in a viewer group",
+			proxy:  ProxyAuth{Roles: GroupRoles{ViewerGroups: []string{"ops"}}},
+			user:   "mallory",
+			groups: "dev",
+		},
+		{
+			name:  "missing user",
+			proxy: ProxyAuth{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, ok := tt.proxy.User(tt.user, tt.groups)
+			if ok != tt.wantOK {
+				t.Fatalf("User() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !reflect.DeepEqual(user, tt.expected) {
+				t.Errorf("User() = %+v, want %+v", user, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package auth
+
+import "slices"
+
+// GroupRoles maps the groups of an identity provider to roles
+type GroupRoles struct {
+	AdminGroups  []string
+	ViewerGroups []string
+}
+
+// Role returns admin for members of an admin group, everybody else is a viewer
+// unless viewer groups are configured and the user is in none of them
+func (roles GroupRoles) Role(groups []string) (string, bool) {
+	switch {
+	case containsAny(groups, roles.AdminGroups):
+		return RoleAdmin, true
+	case len(roles.ViewerGroups) == 0 || containsAny(groups, roles.ViewerGroups):
+		return RoleViewer, true
+	}
+	return "", false
+}
+
+func containsAny(groups []string, wanted []string) bool {
+	for _, group := range groups {
+		if slices.Contains(wanted, group) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var errSessionExpired = errors.New("session expired")
+
+// sealer encrypts cookie values with AES-GCM, the cookie name is authenticated as well
+// so a value of one cookie can't be used as another
+type sealer struct {
+	aead cipher.AEAD
+}
+
+// envelope is the encrypted content of a cookie
+type envelope struct {
+	Expires time.Time       `json:"expires"`
+	Value   json.RawMessage `json:"value"`
+}
+
+// newSealer derives the key from the secret, without secret a random key is used and cookies are lost on restart
+func newSealer(secret string) (*sealer, error) {
+	key := make([]byte, 32)
+	if secret == "" {
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	} else {
+		sum := sha256.Sum256([]byte(secret))
+		key = sum[:]
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &sealer{aead: aead}, nil
+}
+
+func (s *sealer) seal(name string, value any, expires time.Time) (string, error) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	plain, err := json.Marshal(envelope{Expires: expires, Value: content})
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := s.aead.Seal(nonce, nonce, plain, []byte(name))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func (s *sealer) open(name string, cookie string, value any) error {
+	sealed, err := base64.RawURLEncoding.DecodeString(cookie)
+	if err != nil {
+		return err
+	}
+	if len(sealed) < s.aead.NonceSize() {
+		return errors.New("cookie too short")
+	}
+
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	plain, err := s.aead.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return err
+	}
+
+	var content envelope
+	if err = json.Unmarshal(plain, &content); err != nil {
+		return err
+	}
+	if time.Now().After(content.Expires) {
+		return errSessionExpired
+	}
+	return json.Unmarshal(content.Value, value)
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSealer(t *testing.T) {
+	s, err := newSealer("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cookie, err := s.seal(SessionCookieName, User{Name: "alice", Role: RoleAdmin}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var user User
+	if err = s.open(SessionCookieName, cookie, &user); err != nil {
+		t.Fatalf("open() error = %v", err)
+	}
+	if user.Name != "alice" || user.Role != RoleAdmin {
+		t.Errorf("open() = %+v, want alice as admin", user)
+	}
+
+	restarted, err := newSealer("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = restarted.open(SessionCookieName, cookie, &user); err != nil {
+		t.Errorf("open() with the same secret error = %v", err)
+	}
+
+	other, err := newSealer("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = other.open(SessionCookieName, cookie, &user); err == nil {
+		t.Error("open() with another secret should fail")
+	}
+
+	if err = s.open(LoginCookieName, cookie, &user); err == nil {
+		t.Error("open() of another cookie should fail")
+	}
+
+	tampered := []byte(cookie)
+	tampered[len(tampered)/2] ^= 1
+	if err = s.open(SessionCookieName, string(tampered), &user); err == nil {
+		t.Error("open() of a tampered cookie should fail")
+	}
+
+	if err = s.open(SessionCookieName, "", &user); err == nil {
+		t.Error("open() of an empty cookie should fail")
+	}
+
+	expired, err := s.seal(SessionCookieName, User{Name: "alice"}, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.open(SessionCookieName, expired, &user); !errors.Is(err, errSessionExpired) {
+		t.Errorf("open() of an expired cookie error = %v, want %v", err, errSessionExpired)
+	}
+}
@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	tokensFileName = "tokens.json"
+	// tokenPrefix makes tokens recognizable, e.g. for secret scanners
+	tokenPrefix = "f2bd_"
+)
+
+const (
+	ScopeRead   = "read"
+	ScopeBan    = "ban"
+	ScopeUnban  = "unban"
+	ScopeExport = "export"
+)
+
+// Scopes are all scopes a token can carry
+var Scopes = []string{ScopeRead, ScopeBan, ScopeUnban, ScopeExport}
+
+var ErrTokenNotFound = errors.New("token not found")
+
+// Token is an API token of an automation client, only the hash of the secret is stored.
+// Jails limits the token to these jails like the jails of a user, without jails it sees all jails
+type Token struct {
+	ID      string     `json:"id"`
+	Name    string     `json:"name"`
+	Hash    string     `json:"hash"`
+	Scopes  []string   `json:"scopes"`
+	Jails   []string   `json:"jails,omitempty"`
+	Created time.Time  `json:"created"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// HasScope tells if the token may be used for the scope
+func (token *Token) HasScope(scope string) bool {
+	return slices.Contains(token.Scopes, scope)
+}
+
+// User is the viewer the requests of the token are handled as, it has the jails of the token
+func (token *Token) User() *User {
+	return &User{Name: "token:" + token.Name, Role: RoleViewer, Jails: slices.Clone(token.Jails)}
+}
+
+// Expired tells if the token has an expiry which has passed
+func (token *Token) Expired(now time.Time) bool {
+	return token.Expires != nil && now.After(*token.Expires)
+}
+
+// TokenStore keeps the tokens in a file of the cache directory, the file is read again when it was changed
+// so tokens created or revoked with the token command are used by a running dashboard
+type TokenStore struct {
+	mutex    sync.Mutex
+	path     string
+	modified time.Time
+	tokens   []Token
+}
+
+func NewTokenStore(directory string) (*TokenStore, error) {
+	tokenStore := &TokenStore{
+		path:   filepath.Join(directory, tokensFileName),
+		tokens: make([]Token, 0),
+	}
+	if err := tokenStore.load(); err != nil {
+		return nil, err
+	}
+	return tokenStore, nil
+}
+
+func (tokenStore *TokenStore) load() error {
+	info, err := os.Stat(tokenStore.path)
+	if errors.Is(err, os.ErrNotExist) {
+		tokenStore.tokens = make([]Token, 0)
+		tokenStore.modified = time.Time{}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(tokenStore.modified) {
+		return nil
+	}
+
+	content, err := os.ReadFile(tokenStore.path)
+	if err != nil {
+		return err
+	}
+	tokens := make([]Token, 0)
+	if err = json.Unmarshal(content, &tokens); err != nil {
+		return fmt.Errorf("invalid tokens file %s: %w", tokenStore.path, err)
+	}
+	tokenStore.tokens = tokens
+	tokenStore.modified = info.ModTime()
+	return nil
+}
+
+// save replaces the file, the temporary file keeps the tokens intact when writing fails
+func (tokenStore *TokenStore) save() error {
+	content, err := json.MarshalIndent(tokenStore.tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	temporary := tokenStore.path + ".tmp"
+	if err = os.WriteFile(temporary, content, 0o600); err != nil {
+		return err
+	}
+	if err = os.Rename(temporary, tokenStore.path); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(tokenStore.path)
+	if err != nil {
+		return err
+	}
+	tokenStore.modified = info.ModTime()
+	return nil
+}
+
+// Create adds a token and returns its secret, which is only known at this point,
+// a token without jails sees all jails
+func (tokenStore *TokenStore) Create(name string, scopes []string, jails []string, expires *time.Time) (Token, string, error) {
+	if strings.TrimSpace(name) == "" {
+		return Token{}, "", errors.New("a token needs a name")
+	}
+	if len(scopes) == 0 {
+		return Token{}, "", errors.New("a token needs at least one scope")
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return Token{}, "", fmt.Errorf("unknown scope %q, scopes are %s", scope, strings.Join(Scopes, ", "))
+		}
+	}
+
+	var tokenJails []string
+	for _, jail := range jails {
+		if jail = strings.TrimSpace(jail); jail != "" {
+			tokenJails = append(tokenJails, jail)
+		}
+	}
+	if len(tokenJails) > 0 {
+		tokenJails = slices.Compact(slices.Sorted(slices.Values(tokenJails)))
+	}
+
+	tokenStore.mutex.Lock()
+	defer tokenStore.mutex.Unlock()
+	if err := tokenStore.load(); err != nil {
+		return Token{}, "", err
+	}
+
+	id := randomHex(6)
+	secret := tokenPrefix + id + "_" + randomHex(24)
+	token := Token{
+		ID:      id,
+		Name:    strings.TrimSpace(name),
+		Hash:    hashSecret(secret),
+		Scopes:  slices.Compact(slices.Sorted(slices.Values(scopes))),
+		Jails:   tokenJails,
+		Created: time.Now().UTC().Truncate(time.Second),
+		Expires: expires,
+	}
+	tokenStore.tokens = append(tokenStore.tokens, token)
+	if err := tokenStore.save(); err != nil {
+		return Token{}, "", err
+	}
+	return token, secret, nil
+}
+
+// List returns the tokens in the order they were created
+func (tokenStore *TokenStore) List() ([]Token, error) {
+	tokenStore.mutex.Lock()
+	defer tokenStore.mutex.Unlock()
+	if err := tokenStore.load(); err != nil {
+		return nil, err
+	}
+	return slices.Clone(tokenStore.tokens), nil
+}
+
+// Revoke removes the token with the id
+func (tokenStore *TokenStore) Revoke(id string) error {
+	tokenStore.mutex.Lock()
+	defer tokenStore.mutex.Unlock()
+	if err := tokenStore.load(); err != nil {
+		return err
+	}
+
+	index := slices.IndexFunc(tokenStore.tokens, func(token Token) bool { return token.ID == id })
+	if index < 0 {
+		return ErrTokenNotFound
+	}
+	tokenStore.tokens = slices.Delete(tokenStore.tokens, index, index+1)
+	return tokenStore.save()
+}
+
+// Authenticate returns the token of the secret when it exists and has not expired
+func (tokenStore *TokenStore) Authenticate(secret string) (Token, bool) {
+	id, _, found := strings.Cut(strings.TrimPrefix(secret, tokenPrefix), "_")
+	if !found || !strings.HasPrefix(secret, tokenPrefix) {
+		return Token{}, false
+	}
+
+	tokenStore.mutex.Lock()
+	defer tokenStore.mutex.Unlock()
+	// a broken file keeps the tokens which were read before
+	_ = tokenStore.load()
+
+	hash := hashSecret(secret)
+	for _, token := range tokenStore.tokens {
+		if token.ID != id {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(token.Hash), []byte(hash)) != 1 || token.Expired(time.Now()) {
+			return Token{}, false
+		}
+		return token, true
+	}
+	return Token{}, false
+}
+
+// hashSecret doesn't need a slow password hash as the secrets are long random values
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(length int) string {
+	b := make([]byte, length)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenStore(t *testing.T) {
+	directory := t.TempDir()
+	tokens, err := NewTokenStore(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, secret, err := tokens.Create("ci", []string{ScopeExport, ScopeRead, ScopeRead}, nil, nil)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if !strings.HasPrefix(secret, tokenPrefix+token.ID+"_") {
+		t.Errorf("Create() secret = %q, want prefix with id %s", secret, token.ID)
+	}
+	if !reflect.DeepEqual(token.Scopes, []string{ScopeExport, ScopeRead}) {
+		t.Errorf("Create() scopes = %v, want sorted scopes without duplicates", token.Scopes)
+	}
+
+	content, err := os.ReadFile(filepath.Join(directory, tokensFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), secret) {
+		t.Error("tokens file contains the secret")
+	}
+
+	authenticated, ok := tokens.Authenticate(secret)
+	if !ok || authenticated.ID != token.ID {
+		t.Fatalf("Authenticate() = %+v, %v, want token %s", authenticated, ok, token.ID)
+	}
+	if _, ok = tokens.Authenticate(secret + "0"); ok {
+		t.Error("Authenticate() of a wrong secret should fail")
+	}
+	if _, ok = tokens.Authenticate("secret"); ok {
+		t.Error("Authenticate() of a value without prefix should fail")
+	}
+
+	// another store like the one of the token command
+	command, err := NewTokenStore(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed, err := command.List()
+	if err != nil || len(listed) != 1 {
+		t.Fatalf("List() = %v, %v, want one token", listed, err)
+	}
+	// the modification time of the file must change for the running store to read it again
+	time.Sleep(10 * time.Millisecond)
+	if err = command.Revoke(token.ID); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	if err = command.Revoke(token.ID); err != ErrTokenNotFound {
+		t.Errorf("Revoke() of a revoked token error = %v, want %v", err, ErrTokenNotFound)
+	}
+	if _, ok = tokens.Authenticate(secret); ok {
+		t.Error("Authenticate() of a revoked token should fail")
+	}
+}
+
+func TestTokenStore_Create(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name       string
+		tokenName  string
+		scopes     []string
+		expires    *time.Time
+		wantErr    bool
+		wantAccept bool
+	}{
+		{name: "valid", tokenName: "ci", scopes: []string{ScopeBan}, wantAccept: true},
+		{name: "expired", tokenName: "ci", scopes: []string{ScopeBan}, expires: &past},
+		{name: "missing name", tokenName: " ", scopes: []string{ScopeBan}, wantErr: true},
+		{name: "missing scope", tokenName: "ci", wantErr: true},
+		{name: "unknown scope", tokenName: "ci", scopes: []string{"admin"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := NewTokenStore(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, secret, err := tokens.Create(tt.tokenName, tt.scopes, nil, tt.expires)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Create() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if _, ok := tokens.Authenticate(secret); ok != tt.wantAccept {
+				t.Errorf("Authenticate() = %v, want %v", ok, tt.wantAccept)
+			}
+		})
+	}
+}
+
+func TestToken_User(t *testing.T) {
+	tokens, err := NewTokenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		jails    []string
+		expected []string
+	}{
+		{name: "all jails", expected: nil},
+		{name: "empty jails", jails: []string{" ", ""}, expected: nil},
+		{name: "limited", jails: []string{"sshd", " postfix", "sshd"}, expected: []string{"postfix", "sshd"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, _, err := tokens.Create("ci", []string{ScopeRead}, tt.jails, nil)
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			if !reflect.DeepEqual(token.Jails, tt.expected) {
+				t.Errorf("Create() jails = %v, want %v", token.Jails, tt.expected)
+			}
+			user := token.User()
+			if user.Name != "token:ci" || user.IsAdmin() || user.AllJails() != (tt.expected == nil) {
+				t.Errorf("User() = %+v", user)
+			}
+			if tt.expected != nil && user.CanAccess("nginx") {
+				t.Error("User() may access a jail the token is not limited to")
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	RoleViewer = "viewer"
+	RoleAdmin  = "admin"
+)
+
+// User is an account of the dashboard, Password is a bcrypt or argon2 hash
+// and a user without jails may access all jails
+type User struct {
+	Name     string   `mapstructure:"name"`
+	Password string   `mapstructure:"password"`
+	Role     string   `mapstructure:"role"`
+	Jails    []string `mapstructure:"jails"`
+}
+
+// IsAdmin tells if the user may change bans, ignore lists and jails
+func (user *User) IsAdmin() bool {
+	return user.Role == RoleAdmin
+}
+
+// CanAccess tells if the user may see and, as admin, change the jail,
+// a nil user stands for a dashboard without authentication and may access every jail
+func (user *User) CanAccess(jailName string) bool {
+	if user == nil || len(user.Jails) == 0 {
+		return true
+	}
+	return slices.Contains(user.Jails, jailName)
+}
+
+// AllJails tells if the user is not limited to specific jails
+func (user *User) AllJails() bool {
+	return user == nil || len(user.Jails) == 0
+}
+
+type account struct {
+	user   User
+	verify verifier
+}
+
+// Users are the accounts allowed to log in with basic authentication
+type Users struct {
+	accounts map[string]account
+}
+
+// NewUsers validates the users, a missing role is viewer
+func NewUsers(users []User) (*Users, error) {
+	result := &Users{accounts: make(map[string]account, len(users))}
+	for _, user := range users {
+		if user.Password == "" {
+			return nil, fmt.Errorf("user %q has no password hash", user.Name)
+		}
+		verify, err := newVerifier(user.Password)
+		if err != nil {
+			return nil, fmt.Errorf("user %q: %w", user.Name, err)
+		}
+		if err = result.add(user, verify); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// AddPassword adds an admin with a plain text password, used for --auth-user and --auth-password
+func (users *Users) AddPassword(name string, password string) error {
+	return users.add(User{Name: name, Role: RoleAdmin}, plainVerifier(password))
+}
+
+func (users *Users) add(user User, verify verifier) error {
+	if user.Name == "" || strings.ContainsAny(user.Name, ":\r\n") {
+		return fmt.Errorf("invalid user name %q", user.Name)
+	}
+	if _, exists := users.accounts[user.Name]; exists {
+		return fmt.Errorf("user %q is configured twice", user.Name)
+	}
+	switch user.Role {
+	case "":
+		user.Role = RoleViewer
+	case RoleViewer, RoleAdmin:
+	default:
+		return fmt.Errorf("user %q has unknown role %q, use %s or %s", user.Name, user.Role, RoleViewer, RoleAdmin)
+	}
+	user.Password = ""
+	users.accounts[user.Name] = account{user: user, verify: verify}
+	return nil
+}
+
+// Authenticate returns the user when the password matches
+func (users *Users) Authenticate(name string, password string) (User, bool) {
+	account, exists := users.accounts[name]
+	if !exists {
+		_ = bcrypt.CompareHashAndPassword([]byte(dummyHash), []byte(password))
+		return User{}, false
+	}
+	if !account.verify(password) {
+		return User{}, false
+	}
+	return account.user, true
+}
+
+// Len is the number of users
+func (users *Users) Len() int {
+	return len(users.accounts)
+}
+
+// LoadUsersFile reads an htpasswd style file with lines like name:hash[:role[:jail,jail]],
+// empty lines and lines starting with # are skipped
+func LoadUsersFile(path string) ([]User, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	users := make([]User, 0)
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		user, parseErr := parseUserLine(line)
+		if parseErr != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNumber, parseErr)
+		}
+		users = append(users, user)
+	}
+	return users, scanner.Err()
+}
+
+func parseUserLine(line string) (User, error) {
+	fields := strings.Split(line, ":")
+	if len(fields) < 2 || len(fields) > 4 {
+		return User{}, errors.New("expected name:hash[:role[:jail,jail]]")
+	}
+	user := User{Name: fields[0], Password: fields[1]}
+	if len(fields) > 2 {
+		user.Role = fields[2]
+	}
+	if len(fields) > 3 {
+		for _, jail := range strings.Split(fields[3], ",") {
+			if jail = strings.TrimSpace(jail); jail != "" {
+				user.Jails = append(user.Jails, jail)
+			}
+		}
+	}
+	return user, nil
+}
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// hashes of the password "secret"
+const (
+	bcryptHash   = "$2a$04$D09YtrvLE3xaJ3/9finfG./cRMAUb0t5uQgAkZEMhE5nTIF.sa/C2"
+	argon2idHash = "$argon2id$v=19$m=64,t=1,p=1$wgFanWhsubh+TTFdAs0kgg$FN9RtQKT2oG2WNp/R0If7vklY9TPZR0UOko9ceoCA3E"
+	argon2iHash  = "$argon2i$v=19$m=64,t=1,p=1$wgFanWhsubh+TTFdAs0kgg$C7Q+Gabli/rj46404w8HyIozuRnd0I5Tue0dHPDP/PU"
+)
+
+func TestNewVerifier(t *testing.T) {
+	tests := []struct {
+		name    string
+		hash    string
+		wantErr bool
+	}{
+		{name: "bcrypt", hash: bcryptHash},
+		{name: "bcrypt 2y", hash: "$2y$" + bcryptHash[4:]},
+		{name: "argon2id", hash: argon2idHash},
+		{name: "argon2i", hash: argon2iHash},
+		{name: "plain text", hash: "secret", wantErr: true},
+		{name: "sha512 crypt", hash: "$6$salt$hash", wantErr: true},
+		{name: "broken bcrypt", hash: "$2a$04$short", wantErr: true},
+		{name: "argon2 missing parts", hash: "$argon2id$v=19$m=64,t=1,p=1$salt", wantErr: true},
+		{name: "argon2 wrong version", hash: "$argon2id$v=16$m=64,t=1,p=1$wgFanWhsubh+TTFdAs0kgg$FN9RtQKT2oG2WNp/R0If7vklY9TPZR0UOko9ceoCA3E", wantErr: true},
+		{name: "argon2 no threads", hash: "$argon2id$v=19$m=64,t=1,p=0$wgFanWhsubh+TTFdAs0kgg$FN9RtQKT2oG2WNp/R0If7vklY9TPZR0UOko9ceoCA3E", wantErr: true},
+		{name: "argon2 invalid salt", hash: "$argon2id$v=19$m=64,t=1,p=1$!!$FN9RtQKT2oG2WNp/R0If7vklY9TPZR0UOko9ceoCA3E", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verify, err := newVerifier(tt.hash)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newVerifier() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !verify("secret") {
+				t.Error("Expected the password to match")
+			}
+			if verify("wrong") {
+				t.Error("Expected a wrong password not to match")
+			}
+		})
+	}
+}
+
+func TestUsers_Authenticate(t *testing.T) {
+	users, err := NewUsers([]User{
+		{Name: "alice", Password: bcryptHash, Role: RoleAdmin},
+		{Name: "bob", Password: argon2idHash, Jails: []string{"sshd"}},
+	})
+	if err != nil {
+		t.Fatalf("NewUsers() error = %v", err)
+	}
+	if err = users.AddPassword("legacy", "plain"); err != nil {
+		t.Fatalf("AddPassword() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		expected User
+		wantOK   bool
+	}{
+		{name: "admin", user: "alice", password: "secret", expected: User{Name: "alice", Role: RoleAdmin}, wantOK: true},
+		{name: "viewer by default", user: "bob", password: "secret", expected: User{Name: "bob", Role: RoleViewer, Jails: []string{"sshd"}}, wantOK: true},
+		{name: "plain password", user: "legacy", password: "plain", expected: User{Name: "legacy", Role: RoleAdmin}, wantOK: true},
+		{name: "wrong password", user: "alice", password: "wrong"},
+		{name: "unknown user", user: "mallory", password: "secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, ok := users.Authenticate(tt.user, tt.password)
+			if ok != tt.wantOK {
+				t.Fatalf("Authenticate() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !reflect.DeepEqual(user, tt.expected) {
+				t.Errorf("Authenticate() user = %+v, want %+v", user, tt.expected)
+			}
+		})
+	}
+
+	if users.Len() != 3 {
+		t.Errorf("Expected 3 users, got %d", users.Len())
+	}
+}
+
+func TestNewUsers_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		users []User
+	}{
+		{name: "missing password", users: []User{{Name: "alice"}}},
+		{name: "missing name", users: []User{{Password: bcryptHash}}},
+		{name: "unknown role", users: []User{{Name: "alice", Password: bcryptHash, Role: "root"}}},
+		{name: "duplicate", users: []User{{Name: "alice", Password: bcryptHash}, {Name: "alice", Password: argon2idHash}}},
+		{name: "plain text password", users: []User{{Name: "alice", Password: "secret"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewUsers(tt.users); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestUser_CanAccess(t *testing.T) {
+	var anonymous *User
+	restricted := &User{Name: "bob", Jails: []string{"sshd", "postfix"}}
+	unrestricted := &User{Name: "alice"}
+
+	tests := []struct {
+		name     string
+		user     *User
+		jailName string
+		expected bool
+	}{
+		{name: "without authentication", user: anonymous, jailName: "sshd", expected: true},
+		{name: "all jails", user: unrestricted, jailName: "nginx", expected: true},
+		{name: "allowed jail", user: restricted, jailName: "postfix", expected: true},
+		{name: "other jail", user: restricted, jailName: "nginx", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.user.CanAccess(tt.jailName); got != tt.expected {
+				t.Errorf("CanAccess(%q) = %v, want %v", tt.jailName, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoadUsersFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users")
+	content := "# dashboard users\n\nalice:" + bcryptHash + ":admin\nbob:" + argon2idHash + "\ncarol:" + bcryptHash + ":viewer:sshd, postfix\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	users, err := LoadUsersFile(path)
+	if err != nil {
+		t.Fatalf("LoadUsersFile() error = %v", err)
+	}
+	expected := []User{
+		{Name: "alice", Password: bcryptHash, Role: RoleAdmin},
+		{Name: "bob", Password: argon2idHash},
+		{Name: "carol", Password: bcryptHash, Role: RoleViewer, Jails: []string{"sshd", "postfix"}},
+	}
+	if !reflect.DeepEqual(users, expected) {
+		t.Errorf("LoadUsersFile() = %+v, want %+v", users, expected)
+	}
+
+	invalidPath := filepath.Join(t.TempDir(), "invalid")
+	if err = os.WriteFile(invalidPath, []byte("alice\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadUsersFile(invalidPath); err == nil {
+		t.Error("Expected an error for a line without hash")
+	}
+
+	if _, err = LoadUsersFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
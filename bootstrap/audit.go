@@ -0,0 +1,18 @@
+package bootstrap
+
+import (
+	"os"
+
+	"github.com/gofiber/fiber/v3/log"
+	"github.com/webishdev/fail2ban-dashboard/store"
+)
+
+func SetupAudit(dataStore *store.DataStore, cacheDir string) {
+	trail, trailError := store.NewAuditTrail(cacheDir)
+	if trailError != nil {
+		log.Errorf("Could not open audit trail in %s: %s", cacheDir, trailError)
+		os.Exit(1)
+	}
+
+	dataStore.EnableAudit(trail)
+}
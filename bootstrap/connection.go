@@ -1,6 +1,7 @@
 package bootstrap
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/gofiber/fiber/v3/log"
@@ -9,41 +10,58 @@ import (
 
 var supportedVersions = []string{"0.11.1", "0.11.2", "1.0.1", "1.0.2", "1.1.0"}
 
-func ConnectToFail2ban(socketPath string, skipVersionCheck bool) (*client.Fail2BanClient, string) {
+// ConnectToFail2ban connects to the socket and checks the fail2ban version, the version is checked again
+// each time the client connects, as the socket may only appear later or fail2ban may have been updated.
+// Only an unsupported version at startup stops the dashboard, later it is reported as connection warning
+func ConnectToFail2ban(socketPath string, skipVersionCheck bool) *client.Fail2BanClient {
 	log.Infof("Will use socket at %s for fail2ban connection", socketPath)
 	f2bc, socketError := client.NewFail2BanClient(socketPath)
 
-	fail2banVersion := "unknown"
+	f2bc.RegisterStateHandler(func(state client.ConnectionState) {
+		if !state.Connected {
+			return
+		}
+		detectedFail2banVersion, versionError := f2bc.GetVersion()
+		if versionError != nil {
+			log.Errorf("Could not get fail2ban version after connecting: %s", versionError)
+			return
+		}
+		if !checkVersion(detectedFail2banVersion, skipVersionCheck) {
+			f2bc.SetWarning(fmt.Sprintf("fail2ban version %s is not supported, the dashboard may not work as expected", detectedFail2banVersion))
+		}
+	})
 
 	if socketError != nil {
-		log.Errorf("Could not connect to fail2ban socket at %s", socketPath)
-		return f2bc, fail2banVersion
+		log.Errorf("Could not connect to fail2ban socket at %s, will keep trying", socketPath)
+		return f2bc
 	}
 
 	detectedFail2banVersion, versionError := f2bc.GetVersion()
 	if versionError != nil {
-		log.Error("Could not get fail2ban version, using 'unknown'")
+		log.Error("Could not get fail2ban version")
+		os.Exit(1)
+	}
+
+	if !checkVersion(detectedFail2banVersion, skipVersionCheck) {
 		os.Exit(1)
 	}
 
+	return f2bc
+}
+
+// checkVersion tells if the version may be used, an unsupported version only when the check is skipped
+func checkVersion(detectedFail2banVersion string, skipVersionCheck bool) bool {
 	log.Infof("fail2ban version found: %s\n", detectedFail2banVersion)
 
-	versionIsOk := false
-	for _, supportedVersion := range supportedVersions {
-		if supportedVersion == detectedFail2banVersion {
-			versionIsOk = true
-			break
-		}
-	}
+	versionIsOk := versionSupported(detectedFail2banVersion)
 
 	if !skipVersionCheck && !versionIsOk {
 		log.Errorf("fail2ban version %s not supported\n", detectedFail2banVersion)
-		os.Exit(1)
+		return false
 	} else if skipVersionCheck && !versionIsOk {
 		log.Info("Skipping version check (dashboard may not work as expected)")
 	} else if skipVersionCheck {
 		log.Debug("Skipping version check but version is supported")
 	}
-
-	return f2bc, detectedFail2banVersion
+	return true
 }
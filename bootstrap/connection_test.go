@@ -1,6 +1,13 @@
 package bootstrap
 
-import "testing"
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/webishdev/fail2ban-dashboard/fail2bantest"
+)
 
 func TestSupportedVersions(t *testing.T) {
 	if len(supportedVersions) == 0 {
@@ -19,3 +26,50 @@ func TestSupportedVersions(t *testing.T) {
 		t.Error("Expected version 1.1.0 not found in supported versions")
 	}
 }
+
+func TestConnectToFail2ban_VersionAfterReconnect(t *testing.T) {
+	address := filepath.Join(t.TempDir(), "fail2ban.sock")
+	f2bc := ConnectToFail2ban(address, false)
+	t.Cleanup(func() { _ = f2bc.Close() })
+	if version := f2bc.Version(); version != "" {
+		t.Fatalf("Expected no version without socket, got %q", version)
+	}
+
+	server, err := fail2bantest.NewServer(address, fail2bantest.Version1_0)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+
+	deadline := time.Now().Add(5 * time.Second)
+	for f2bc.Version() != fail2bantest.Version1_0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected version %s after reconnect, got %q", fail2bantest.Version1_0, f2bc.Version())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestConnectToFail2ban_UnsupportedVersionAfterReconnect(t *testing.T) {
+	address := filepath.Join(t.TempDir(), "fail2ban.sock")
+	f2bc := ConnectToFail2ban(address, false)
+	t.Cleanup(func() { _ = f2bc.Close() })
+
+	server, err := fail2bantest.NewServer(address, "0.10.6")
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+
+	// the dashboard keeps running and reports the version instead of exiting
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(f2bc.ConnectionState().Warning, "0.10.6") {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected a warning about the unsupported version, got %+v", f2bc.ConnectionState())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !f2bc.ConnectionState().Connected {
+		t.Error("Expected the client to stay connected")
+	}
+}
@@ -0,0 +1,122 @@
+package bootstrap
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gofiber/fiber/v3/log"
+	"github.com/webishdev/fail2ban-dashboard/fail2bantest"
+)
+
+// demoInterval is how often the demo fails and bans addresses
+const demoInterval = 15 * time.Second
+
+// demoRanges are reserved for documentation, the demo never shows real addresses
+var demoRanges = []string{"192.0.2.%d", "198.51.100.%d", "203.0.113.%d", "2001:db8::%x"}
+
+// demoJails are the jails of the demo with the number of bans they start with
+var demoJails = []struct {
+	jail fail2bantest.Jail
+	bans int
+}{
+	{fail2bantest.Jail{
+		Name:      "sshd",
+		BanTime:   600,
+		FindTime:  600,
+		MaxRetry:  5,
+		LogPaths:  []string{"/var/log/auth.log"},
+		FailRegex: []string{`^Failed \S+ for .* from <HOST>`, `^Invalid user .* from <HOST>`},
+		IgnoreIPs: []string{"127.0.0.1/8", "::1"},
+		Actions:   []string{"iptables-multiport"},
+		UseDNS:    "warn",
+	}, 8},
+	{fail2bantest.Jail{
+		Name:      "postfix",
+		BanTime:   3600,
+		FindTime:  600,
+		MaxRetry:  3,
+		LogPaths:  []string{"/var/log/mail.log"},
+		FailRegex: []string{`^NOQUEUE: reject: RCPT from \S+\[<HOST>\]`},
+		IgnoreIPs: []string{"127.0.0.1/8", "::1"},
+		Actions:   []string{"iptables-multiport"},
+		UseDNS:    "warn",
+	}, 4},
+	{fail2bantest.Jail{
+		Name:      "nginx-http-auth",
+		BanTime:   1800,
+		FindTime:  600,
+		MaxRetry:  5,
+		LogPaths:  []string{"/var/log/nginx/error.log"},
+		FailRegex: []string{`^ \[error\] \d+#\d+: \*\d+ user "\S+":? (?:password mismatch|was not found in ".*"), client: <HOST>`},
+		IgnoreIPs: []string{"127.0.0.1/8", "::1"},
+		Actions:   []string{"iptables-multiport"},
+		UseDNS:    "no",
+	}, 3},
+	{fail2bantest.Jail{
+		Name:      "recidive",
+		BanTime:   604800,
+		FindTime:  86400,
+		MaxRetry:  5,
+		LogPaths:  []string{"/var/log/fail2ban.log"},
+		FailRegex: []string{`^ fail2ban\.actions\s+\[\d+\]: NOTICE\s+\[(?!recidive\])\S+\] Ban <HOST>`},
+		Actions:   []string{"iptables-allports"},
+		UseDNS:    "no",
+	}, 1},
+}
+
+// StartDemo starts a fake fail2ban of the version with sample jails on a socket in the directory,
+// addresses are failed and banned until the demo ends
+func StartDemo(directory string, version string, latency time.Duration) *fail2bantest.Server {
+	server, err := fail2bantest.NewServer(filepath.Join(directory, "fail2ban.sock"), version)
+	if err != nil {
+		log.Errorf("Could not start the demo fail2ban: %s", err)
+		os.Exit(1)
+	}
+	server.SetLatency(latency)
+
+	now := time.Now()
+	for _, demoJail := range demoJails {
+		jail := demoJail.jail
+		jail.TotalFailed = demoJail.bans * jail.MaxRetry * 3
+		jail.TotalBanned = demoJail.bans * 2
+		for range demoJail.bans {
+			// spread the bans over the ban time, so they end one after another
+			bannedAt := now.Add(-time.Duration(rand.IntN(jail.BanTime)) * time.Second)
+			jail.Bans = append(jail.Bans, fail2bantest.Ban{Address: demoAddress(), BannedAt: bannedAt, BanTime: jail.BanTime})
+		}
+		server.AddJail(jail)
+	}
+
+	go func() {
+		ticker := time.NewTicker(demoInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			demoActivity(server)
+		}
+	}()
+
+	log.Infof("Started demo fail2ban %s at %s", version, server.Address())
+	return server
+}
+
+// demoActivity fails addresses in a jail and bans one of them from time to time
+func demoActivity(server *fail2bantest.Server) {
+	jail := demoJails[rand.IntN(len(demoJails))].jail
+	if err := server.Fail(jail.Name, 1+rand.IntN(jail.MaxRetry)); err != nil {
+		// the jail was stopped in the dashboard
+		log.Debugf("Demo: %s", err)
+		return
+	}
+	if rand.IntN(2) == 0 {
+		if err := server.Ban(jail.Name, demoAddress(), time.Now(), 0); err != nil {
+			log.Debugf("Demo: %s", err)
+		}
+	}
+}
+
+func demoAddress() string {
+	return fmt.Sprintf(demoRanges[rand.IntN(len(demoRanges))], 1+rand.IntN(254))
+}
@@ -0,0 +1,60 @@
+package bootstrap
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+	"github.com/webishdev/fail2ban-dashboard/fail2bantest"
+)
+
+func TestStartDemo(t *testing.T) {
+	server := StartDemo(t.TempDir(), fail2bantest.Version1_0, 0)
+	t.Cleanup(func() { _ = server.Close() })
+
+	f2bc, err := client.NewFail2BanClient(server.Address())
+	if err != nil {
+		t.Fatalf("NewFail2BanClient() error = %v", err)
+	}
+	t.Cleanup(func() { _ = f2bc.Close() })
+
+	names, err := f2bc.GetJailNames()
+	if err != nil || !slices.Equal(names, []string{"nginx-http-auth", "postfix", "recidive", "sshd"}) {
+		t.Fatalf("GetJailNames() = %v, %v", names, err)
+	}
+
+	documentation := []netip.Prefix{
+		netip.MustParsePrefix("192.0.2.0/24"),
+		netip.MustParsePrefix("198.51.100.0/24"),
+		netip.MustParsePrefix("203.0.113.0/24"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}
+	for _, name := range names {
+		entry, bannedErr := f2bc.GetBanned(name)
+		if bannedErr != nil || len(entry.BannedEntries) == 0 {
+			t.Errorf("GetBanned(%s) = %v, %v, expected bans", name, entry, bannedErr)
+			continue
+		}
+		for _, banned := range entry.BannedEntries {
+			address := netip.MustParseAddr(banned.Address)
+			if !slices.ContainsFunc(documentation, func(prefix netip.Prefix) bool { return prefix.Contains(address) }) {
+				t.Errorf("Expected an address reserved for documentation, got %s", address)
+			}
+		}
+	}
+
+	totalFailed := func() int {
+		total := 0
+		for _, name := range names {
+			jail, _ := server.Jail(name)
+			total += jail.TotalFailed
+		}
+		return total
+	}
+	before := totalFailed()
+	demoActivity(server)
+	if after := totalFailed(); after <= before {
+		t.Errorf("Expected the demo activity to count failures, got %d before and %d after", before, after)
+	}
+}
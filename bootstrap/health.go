@@ -0,0 +1,88 @@
+package bootstrap
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3/log"
+	"github.com/webishdev/fail2ban-dashboard/auth"
+)
+
+// SetupHealthAllowed parses the ranges which may request the health endpoints
+func SetupHealthAllowed(values []string) []netip.Prefix {
+	prefixes, prefixesErr := auth.ParsePrefixes(values)
+	if prefixesErr != nil {
+		log.Errorf("Could not set up health endpoints: %s", prefixesErr)
+		os.Exit(1)
+	}
+	return prefixes
+}
+
+// HealthURL is the URL of the health endpoint of a dashboard listening on the address, unspecified addresses
+// are requested on the loopback address
+func HealthURL(address string, basePath string, useTLS bool, endpoint string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host, port = address, ""
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	if port != "" {
+		host = net.JoinHostPort(host, port)
+	}
+
+	scheme := "http"
+	if useTLS {
+		scheme = "https"
+	}
+	if !strings.HasSuffix(basePath, "/") {
+		basePath += "/"
+	}
+	if !strings.HasPrefix(basePath, "/") {
+		basePath = "/" + basePath
+	}
+	return fmt.Sprintf("%s://%s%s%s", scheme, host, basePath, endpoint)
+}
+
+// Healthcheck requests the health endpoint and returns an error with the reasons when it is not healthy,
+// the certificate isn't verified as the dashboard is usually requested on the loopback address
+func Healthcheck(url string, timeout time.Duration) error {
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var health struct {
+		Error   string   `json:"error"`
+		Reasons []string `json:"reasons"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&health)
+	switch {
+	case len(health.Reasons) > 0:
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.Join(health.Reasons, ", "))
+	case health.Error != "":
+		return fmt.Errorf("status %d: %s", resp.StatusCode, health.Error)
+	default:
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+}
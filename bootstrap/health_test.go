@@ -0,0 +1,55 @@
+package bootstrap
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		address  string
+		basePath string
+		useTLS   bool
+		endpoint string
+		expected string
+	}{
+		{"port only", ":3000", "/", false, "readyz", "http://127.0.0.1:3000/readyz"},
+		{"unspecified address", "0.0.0.0:3000", "/", false, "healthz", "http://127.0.0.1:3000/healthz"},
+		{"unspecified IPv6 address", "[::]:3000", "/", false, "readyz", "http://127.0.0.1:3000/readyz"},
+		{"specific address", "192.168.1.10:8080", "/dashboard", true, "readyz", "https://192.168.1.10:8080/dashboard/readyz"},
+		{"base path with slash", "localhost:3000", "dashboard/", false, "readyz", "http://localhost:3000/dashboard/readyz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HealthURL(tt.address, tt.basePath, tt.useTLS, tt.endpoint); got != tt.expected {
+				t.Errorf("HealthURL() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHealthcheck(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/readyz" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"status":"unavailable","reasons":["no data fetched from fail2ban yet"]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer testServer.Close()
+
+	if err := Healthcheck(testServer.URL+"/healthz", time.Second); err != nil {
+		t.Errorf("Healthcheck() error = %v", err)
+	}
+
+	err := Healthcheck(testServer.URL+"/readyz", time.Second)
+	if err == nil || !strings.Contains(err.Error(), "no data fetched from fail2ban yet") {
+		t.Errorf("Healthcheck() error = %v, want the reason", err)
+	}
+}
@@ -0,0 +1,38 @@
+package bootstrap
+
+import (
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v3/log"
+	"github.com/webishdev/fail2ban-dashboard/geoip"
+	"github.com/webishdev/fail2ban-dashboard/store"
+)
+
+func SetupHistory(dataStore *store.DataStore, geoIP *geoip.GeoIP, cacheDir string, retentionDays int, compactionHours int) {
+	if retentionDays < 0 {
+		log.Warn("Ban history retention must not be negative, keeping the history without limit")
+		retentionDays = 0
+	}
+	if compactionHours < 1 {
+		log.Warn("Ban history compaction must be at least every hour, resetting to default of 24 hours")
+		compactionHours = 24
+	}
+
+	configuration := store.HistoryConfiguration{
+		Directory:          cacheDir,
+		Retention:          time.Duration(retentionDays) * 24 * time.Hour,
+		CompactionInterval: time.Duration(compactionHours) * time.Hour,
+	}
+
+	history, historyError := store.NewHistory(configuration, func(address string) string {
+		countryCode, _ := geoIP.Lookup(address)
+		return countryCode
+	})
+	if historyError != nil {
+		log.Errorf("Could not load ban history from %s: %s", cacheDir, historyError)
+		os.Exit(1)
+	}
+
+	dataStore.EnableHistory(history)
+}
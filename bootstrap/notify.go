@@ -0,0 +1,97 @@
+package bootstrap
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3/log"
+	"github.com/webishdev/fail2ban-dashboard/geoip"
+	"github.com/webishdev/fail2ban-dashboard/notify"
+	"github.com/webishdev/fail2ban-dashboard/store"
+)
+
+// ParseWebhookFlags converts values like slack=https://hooks.slack.com/... into webhooks,
+// values without a known template prefix are sent as JSON
+func ParseWebhookFlags(values []string) []notify.Webhook {
+	webhooks := make([]notify.Webhook, 0, len(values))
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		webhook := notify.Webhook{URL: value}
+		if template, webhookURL, found := strings.Cut(value, "="); found && slices.Contains(notify.TemplateNames, template) {
+			webhook.Template = template
+			webhook.URL = webhookURL
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks
+}
+
+func SetupNotifier(dataStore *store.DataStore, geoIP *geoip.GeoIP, webhooks []notify.Webhook, debounceSeconds int, retries int) {
+	if len(webhooks) == 0 {
+		log.Info("Webhook notifications disabled")
+		return
+	}
+	if debounceSeconds < 1 {
+		log.Warn("Webhook debounce must be at least one second, resetting to default of 10 seconds")
+		debounceSeconds = 10
+	}
+	if retries < 0 {
+		log.Warn("Webhook retries must not be negative, resetting to default of 3 retries")
+		retries = 3
+	}
+
+	configuration := notify.Configuration{
+		Webhooks: webhooks,
+		Debounce: time.Duration(debounceSeconds) * time.Second,
+		Retries:  retries,
+	}
+
+	notifier, notifierError := notify.NewNotifier(configuration, func(address string) string {
+		countryCode, _ := geoIP.Lookup(address)
+		return countryCode
+	})
+	if notifierError != nil {
+		log.Errorf("Could not set up webhook notifications: %s", notifierError)
+		os.Exit(1)
+	}
+
+	dataStore.Subscribe(notifier.Handle)
+	log.Infof("Webhook notifications enabled for %d webhooks", len(webhooks))
+}
+
+func SetupEmailNotifier(dataStore *store.DataStore, geoIP *geoip.GeoIP, configuration notify.SMTPConfiguration) {
+	if configuration.Host == "" {
+		log.Info("Email notifications disabled")
+		return
+	}
+
+	// the digest is built from the ban history, without history only alerts can be sent
+	var historySource notify.HistorySource
+	if history := dataStore.History(); history != nil {
+		historySource = func(since time.Time) []store.HistoryEntry {
+			return history.Entries(store.HistoryFilter{Since: since})
+		}
+	}
+
+	notifier, notifierError := notify.NewEmailNotifier(configuration, func(address string) string {
+		countryCode, _ := geoIP.Lookup(address)
+		return countryCode
+	}, historySource)
+	if notifierError != nil {
+		log.Errorf("Could not set up email notifications: %s", notifierError)
+		os.Exit(1)
+	}
+
+	dataStore.Subscribe(notifier.Handle)
+	notifier.Start()
+	if len(configuration.AlertJails) > 0 {
+		log.Infof("Email alerts enabled for jails %s", strings.Join(configuration.AlertJails, ", "))
+	}
+	if configuration.Digest != "" {
+		log.Infof("Email %s digest enabled at %d:00", configuration.Digest, configuration.DigestHour)
+	}
+}
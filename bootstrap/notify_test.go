@@ -0,0 +1,34 @@
+package bootstrap
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/webishdev/fail2ban-dashboard/notify"
+)
+
+func TestParseWebhookFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []string
+		expected []notify.Webhook
+	}{
+		{"empty", nil, []notify.Webhook{}},
+		{"plain url", []string{"https://example.com/hook"}, []notify.Webhook{{URL: "https://example.com/hook"}}},
+		{"with template", []string{"slack=https://hooks.slack.com/services/T/B/X"}, []notify.Webhook{{URL: "https://hooks.slack.com/services/T/B/X", Template: "slack"}}},
+		{"equal sign in query", []string{"https://example.com/hook?token=secret"}, []notify.Webhook{{URL: "https://example.com/hook?token=secret"}}},
+		{"several", []string{"ntfy=https://ntfy.sh/alerts", "", "gotify=https://gotify.example.com/message?token=x"}, []notify.Webhook{
+			{URL: "https://ntfy.sh/alerts", Template: "ntfy"},
+			{URL: "https://gotify.example.com/message?token=x", Template: "gotify"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ParseWebhookFlags(tt.input)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("ParseWebhookFlags(%v) = %v, expected %v", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
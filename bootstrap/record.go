@@ -0,0 +1,55 @@
+package bootstrap
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/gofiber/fiber/v3/log"
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+)
+
+// RecordSession writes the commands the dashboard reads fail2ban with and their responses to the fixture file,
+// nothing is changed in fail2ban and the recorded version is returned
+func RecordSession(socketPath string, fixturePath string) (string, error) {
+	f2bc, err := client.NewRecordingClient(socketPath, fixturePath)
+	if f2bc != nil {
+		defer func() { _ = f2bc.Close() }()
+	}
+	if err != nil {
+		return "", err
+	}
+	return readSession(f2bc)
+}
+
+// readSession sends the commands of a refresh and of the jail pages, every response must be understood
+func readSession(f2bc *client.Fail2BanClient) (string, error) {
+	version, err := f2bc.GetVersion()
+	if err != nil {
+		return "", fmt.Errorf("version: %w", err)
+	}
+	names, err := f2bc.GetJailNames()
+	if err != nil {
+		return version, fmt.Errorf("status: %w", err)
+	}
+	for _, name := range names {
+		if _, err = f2bc.GetJailInfo(name); err != nil {
+			return version, fmt.Errorf("status of %s: %w", name, err)
+		}
+		if _, err = f2bc.GetBanned(name); err != nil {
+			return version, fmt.Errorf("bans of %s: %w", name, err)
+		}
+		if _, err = f2bc.GetJailConfig(name); err != nil {
+			return version, fmt.Errorf("configuration of %s: %w", name, err)
+		}
+		if _, err = f2bc.GetIgnoreIPs(name); err != nil {
+			return version, fmt.Errorf("ignore list of %s: %w", name, err)
+		}
+	}
+	log.Debugf("Read %d jails of fail2ban %s", len(names), version)
+	return version, nil
+}
+
+// versionSupported tells if the version is one of the versions the dashboard was tested with
+func versionSupported(version string) bool {
+	return slices.Contains(supportedVersions, version)
+}
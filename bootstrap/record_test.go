@@ -0,0 +1,71 @@
+package bootstrap
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+	"github.com/webishdev/fail2ban-dashboard/fail2bantest"
+)
+
+func TestRecordSession(t *testing.T) {
+	fake := fail2bantest.Start(t, fail2bantest.Version0_11)
+	fake.AddJail(fail2bantest.Jail{
+		Name:    "sshd",
+		BanTime: 600,
+		Bans:    []fail2bantest.Ban{{Address: "192.0.2.10", BannedAt: time.Now(), BanTime: 600}},
+	})
+	fixture := filepath.Join(t.TempDir(), "fail2ban-0.11.2.jsonl")
+
+	version, err := RecordSession(fake.Address(), fixture)
+	if err != nil || version != fail2bantest.Version0_11 {
+		t.Fatalf("RecordSession() = %q, %v", version, err)
+	}
+	for _, command := range fake.Commands() {
+		if name, _, _ := strings.Cut(command, " "); name == "set" || name == "start" || name == "stop" {
+			t.Errorf("Recording must not change fail2ban, got %q", command)
+		}
+	}
+
+	replayed := replaySession(t, fixture)
+	if replayed != version {
+		t.Errorf("Replayed version %q, recorded %q", replayed, version)
+	}
+}
+
+// TestRecordedSessions replays the sessions recorded with real fail2ban versions by ./e2e/e2e.sh record,
+// a version whose session replays without errors can be added to the supported versions
+func TestRecordedSessions(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "sessions", "*.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Skip("no recorded sessions in testdata/sessions")
+	}
+
+	for _, fixture := range fixtures {
+		t.Run(filepath.Base(fixture), func(t *testing.T) {
+			version := replaySession(t, fixture)
+			if !versionSupported(version) {
+				t.Errorf("fail2ban %s replays without errors but is not a supported version", version)
+			}
+		})
+	}
+}
+
+func replaySession(t *testing.T, fixture string) string {
+	t.Helper()
+	f2bc, err := client.NewReplayClient(fixture)
+	if err != nil {
+		t.Fatalf("NewReplayClient() error = %v", err)
+	}
+	defer func() { _ = f2bc.Close() }()
+	version, err := readSession(f2bc)
+	if err != nil {
+		t.Fatalf("Replaying %s failed: %v", fixture, err)
+	}
+	return version
+}
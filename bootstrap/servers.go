@@ -1,6 +1,7 @@
 package bootstrap
 
 import (
+	"crypto/tls"
 	"os"
 
 	"github.com/gofiber/fiber/v3"
@@ -12,9 +13,10 @@ import (
 var osExit = os.Exit
 
 func StartDashboardServer(app *fiber.App, config *server.Configuration) {
-	log.Infof("Dashboard available at address %s", config.Address)
+	log.Infof("Dashboard available at address %s%s", config.Address, tlsInfo(config.TLSConfig))
 	serveError := app.Listen(config.Address, fiber.ListenConfig{
 		DisableStartupMessage: true,
+		TLSConfig:             config.TLSConfig,
 	})
 	if serveError != nil {
 		log.Errorf("Could not start server: %s\n", serveError)
@@ -23,12 +25,24 @@ func StartDashboardServer(app *fiber.App, config *server.Configuration) {
 }
 
 func StartMetricsServer(metricsApp *fiber.App, config *metrics.Configuration) {
-	log.Infof("Metrics available at address %s", config.Address)
+	log.Infof("Metrics available at address %s%s", config.Address, tlsInfo(config.TLSConfig))
 	serveError := metricsApp.Listen(config.Address, fiber.ListenConfig{
 		DisableStartupMessage: true,
+		TLSConfig:             config.TLSConfig,
 	})
 	if serveError != nil {
 		log.Errorf("Could not start server: %s\n", serveError)
 		osExit(1)
 	}
 }
+
+func tlsInfo(tlsConfig *tls.Config) string {
+	switch {
+	case tlsConfig == nil:
+		return ""
+	case tlsConfig.ClientAuth == tls.RequireAndVerifyClientCert:
+		return " with TLS and client certificates"
+	default:
+		return " with TLS"
+	}
+}
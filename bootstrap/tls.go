@@ -0,0 +1,82 @@
+package bootstrap
+
+import (
+	"crypto/tls"
+	"net"
+	"os"
+	"slices"
+
+	"github.com/gofiber/fiber/v3/log"
+	"github.com/webishdev/fail2ban-dashboard/certs"
+)
+
+// SetupTLS loads the certificate files or creates a self-signed certificate in the cache directory,
+// nil means the servers use plain HTTP
+func SetupTLS(certFile string, keyFile string, selfSigned bool, cacheDir string, addresses ...string) *certs.Reloader {
+	switch {
+	case certFile != "" || keyFile != "":
+		if certFile == "" || keyFile == "" {
+			log.Error("TLS requires both --tls-cert and --tls-key")
+			os.Exit(1)
+		}
+		if selfSigned {
+			log.Warn("Certificate files are configured, no self-signed certificate is used")
+		}
+	case selfSigned:
+		var selfSignedErr error
+		certFile, keyFile, selfSignedErr = certs.SelfSigned(cacheDir, selfSignedHosts(addresses))
+		if selfSignedErr != nil {
+			log.Errorf("Could not create self-signed certificate in %s: %s", cacheDir, selfSignedErr)
+			os.Exit(1)
+		}
+		log.Warn("Using a self-signed certificate, browsers will warn about it")
+	default:
+		return nil
+	}
+
+	reloader, reloaderErr := certs.NewReloader(certFile, keyFile)
+	if reloaderErr != nil {
+		log.Errorf("Could not set up TLS: %s", reloaderErr)
+		os.Exit(1)
+	}
+	return reloader
+}
+
+// SetupServerTLS returns the TLS configuration of a listener, client certificates need TLS
+func SetupServerTLS(reloader *certs.Reloader, clientCAFile string) *tls.Config {
+	if reloader == nil {
+		if clientCAFile != "" {
+			log.Error("Client certificates require TLS with --tls-cert and --tls-key or --tls-self-signed")
+			os.Exit(1)
+		}
+		return nil
+	}
+
+	tlsConfig, tlsErr := certs.ServerConfig(reloader, clientCAFile)
+	if tlsErr != nil {
+		log.Errorf("Could not set up TLS: %s", tlsErr)
+		os.Exit(1)
+	}
+	return tlsConfig
+}
+
+// selfSignedHosts are the names the self-signed certificate is valid for, the local names and the listen addresses
+func selfSignedHosts(addresses []string) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		hosts = append(hosts, hostname)
+	}
+	for _, address := range addresses {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil || host == "" {
+			continue
+		}
+		if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+			continue
+		}
+		if !slices.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
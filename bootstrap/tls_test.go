@@ -0,0 +1,159 @@
+package bootstrap
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/webishdev/fail2ban-dashboard/metrics"
+	"github.com/webishdev/fail2ban-dashboard/server"
+)
+
+func TestSelfSignedHosts(t *testing.T) {
+	hosts := selfSignedHosts([]string{"0.0.0.0:3000", "192.168.1.10:9100", "127.0.0.1:3000", ":3000", "invalid"})
+
+	for _, expected := range []string{"localhost", "127.0.0.1", "::1", "192.168.1.10"} {
+		if !slices.Contains(hosts, expected) {
+			t.Errorf("selfSignedHosts() = %v, missing %s", hosts, expected)
+		}
+	}
+	if slices.Contains(hosts, "0.0.0.0") {
+		t.Errorf("selfSignedHosts() = %v, contains the unspecified address", hosts)
+	}
+	if len(hosts) != len(slices.Compact(slices.Sorted(slices.Values(hosts)))) {
+		t.Errorf("selfSignedHosts() = %v, contains duplicates", hosts)
+	}
+}
+
+func TestStartDashboardServer_TLS(t *testing.T) {
+	address := findAvailablePort(t)
+
+	app := fiber.New(fiber.Config{})
+	app.Get("/", func(c fiber.Ctx) error {
+		return c.SendString(c.Protocol())
+	})
+
+	certificate := SetupTLS("", "", true, t.TempDir(), address)
+	config := &server.Configuration{
+		Address:   address,
+		TLSConfig: SetupServerTLS(certificate, ""),
+	}
+
+	go StartDashboardServer(app, config)
+	defer func() { _ = app.Shutdown() }()
+	time.Sleep(100 * time.Millisecond)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := client.Get("https://" + address + "/")
+	if err != nil {
+		t.Fatalf("Failed to make request: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.TLS == nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected TLS response, got %d", resp.StatusCode)
+	}
+}
+
+func TestStartMetricsServer_ClientCertificates(t *testing.T) {
+	directory := t.TempDir()
+	caFile, clientCertificate := createClientCertificate(t, directory)
+	address := findAvailablePort(t)
+
+	metricsApp := fiber.New(fiber.Config{})
+	metricsApp.Get("/metrics", func(c fiber.Ctx) error {
+		return c.SendString("metrics")
+	})
+
+	certificate := SetupTLS("", "", true, directory, address)
+	config := &metrics.Configuration{
+		Address:   address,
+		TLSConfig: SetupServerTLS(certificate, caFile),
+	}
+
+	go StartMetricsServer(metricsApp, config)
+	defer func() { _ = metricsApp.Shutdown() }()
+	time.Sleep(100 * time.Millisecond)
+
+	withoutCertificate := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	if resp, err := withoutCertificate.Get("https://" + address + "/metrics"); err == nil {
+		_ = resp.Body.Close()
+		t.Error("Expected request without client certificate to fail")
+	}
+
+	withCertificate := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		InsecureSkipVerify: true,
+		Certificates:       []tls.Certificate{clientCertificate},
+	}}}
+	resp, err := withCertificate.Get("https://" + address + "/metrics")
+	if err != nil {
+		t.Fatalf("Failed to make request with client certificate: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+}
+
+// createClientCertificate writes a CA to the directory and returns it with a client certificate signed by it
+func createClientCertificate(t *testing.T, directory string) (string, tls.Certificate) {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "metrics CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCertificate, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "prometheus"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, clientTemplate, caCertificate, &clientKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caFile := filepath.Join(directory, "ca.crt")
+	if err = os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return caFile, tls.Certificate{Certificate: [][]byte{clientDER}, PrivateKey: clientKey}
+}
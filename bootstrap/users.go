@@ -0,0 +1,102 @@
+package bootstrap
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v3/log"
+	"github.com/webishdev/fail2ban-dashboard/auth"
+)
+
+// SetupUsers combines the users of the config file with the users file, nil means no users are configured
+func SetupUsers(configured []auth.User, usersFile string) *auth.Users {
+	users := configured
+	if usersFile != "" {
+		fileUsers, fileErr := auth.LoadUsersFile(usersFile)
+		if fileErr != nil {
+			log.Errorf("Could not read users file: %s", fileErr)
+			os.Exit(1)
+		}
+		log.Infof("Loaded %d users from %s", len(fileUsers), usersFile)
+		users = append(users, fileUsers...)
+	}
+
+	if len(users) == 0 {
+		return nil
+	}
+
+	result, usersErr := auth.NewUsers(users)
+	if usersErr != nil {
+		log.Errorf("Could not set up users: %s", usersErr)
+		os.Exit(1)
+	}
+	return result
+}
+
+// SetupProxyAuth enables header authentication when a user header is configured,
+// it builds on trusting proxy headers and needs the addresses of the trusted proxies
+func SetupProxyAuth(trustProxyHeaders bool, userHeader string, groupsHeader string, trustedProxies []string, adminGroups []string, viewerGroups []string) *auth.ProxyAuth {
+	if userHeader == "" {
+		return nil
+	}
+	if !trustProxyHeaders {
+		log.Errorf("Header authentication with %s requires --trust-proxy-headers", userHeader)
+		os.Exit(1)
+	}
+
+	prefixes, proxiesErr := auth.ParsePrefixes(trustedProxies)
+	if proxiesErr != nil {
+		log.Errorf("Could not set up header authentication: %s", proxiesErr)
+		os.Exit(1)
+	}
+	if len(prefixes) == 0 {
+		log.Errorf("Header authentication with %s requires --trusted-proxies", userHeader)
+		os.Exit(1)
+	}
+	if groupsHeader == "" && len(adminGroups) > 0 {
+		log.Warn("Admin groups are configured without groups header, every user is a viewer")
+	}
+
+	return &auth.ProxyAuth{
+		UserHeader:     userHeader,
+		GroupsHeader:   groupsHeader,
+		TrustedProxies: prefixes,
+		Roles:          auth.GroupRoles{AdminGroups: adminGroups, ViewerGroups: viewerGroups},
+	}
+}
+
+// SetupOIDC enables the OpenID Connect login when an issuer is configured, header authentication takes precedence
+func SetupOIDC(configuration auth.OIDCConfiguration, proxyAuthEnabled bool) *auth.OIDC {
+	if configuration.Issuer == "" {
+		return nil
+	}
+	if proxyAuthEnabled {
+		log.Warn("Header authentication is enabled, the OpenID Connect login is ignored")
+		return nil
+	}
+	if configuration.SessionSecret == "" {
+		log.Warn("No OpenID Connect session secret configured, users have to log in again after a restart")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	provider, oidcErr := auth.NewOIDC(ctx, configuration)
+	if oidcErr != nil {
+		log.Errorf("Could not set up OpenID Connect login: %s", oidcErr)
+		os.Exit(1)
+	}
+	log.Infof("OpenID Connect login with %s", configuration.Issuer)
+	return provider
+}
+
+// SetupTokens opens the API tokens in the cache directory, they are managed with the token command
+func SetupTokens(cacheDir string) *auth.TokenStore {
+	tokens, tokensErr := auth.NewTokenStore(cacheDir)
+	if tokensErr != nil {
+		log.Errorf("Could not read API tokens in %s: %s", cacheDir, tokensErr)
+		os.Exit(1)
+	}
+	return tokens
+}
@@ -0,0 +1,208 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3/log"
+)
+
+const (
+	selfSignedCertName = "selfsigned.crt"
+	selfSignedKeyName  = "selfsigned.key"
+	// selfSignedValidity is how long a generated certificate is valid, it is renewed a month before
+	selfSignedValidity = 365 * 24 * time.Hour
+	selfSignedRenewal  = 30 * 24 * time.Hour
+	// checkInterval limits how often the files are checked for changes
+	checkInterval = 10 * time.Second
+)
+
+// Reloader serves the certificate of a certificate and key file, the files are loaded again when they were changed,
+// e.g. by certbot, so no restart is needed
+type Reloader struct {
+	mutex         sync.Mutex
+	certFile      string
+	keyFile       string
+	certificate   *tls.Certificate
+	modified      time.Time
+	checked       time.Time
+	checkInterval time.Duration
+}
+
+func NewReloader(certFile string, keyFile string) (*Reloader, error) {
+	reloader := &Reloader{
+		certFile:      certFile,
+		keyFile:       keyFile,
+		checkInterval: checkInterval,
+	}
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// lastModified is the newer modification time of both files
+func (reloader *Reloader) lastModified() (time.Time, error) {
+	certInfo, err := os.Stat(reloader.certFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	keyInfo, err := os.Stat(reloader.keyFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if keyInfo.ModTime().After(certInfo.ModTime()) {
+		return keyInfo.ModTime(), nil
+	}
+	return certInfo.ModTime(), nil
+}
+
+func (reloader *Reloader) load() error {
+	modified, err := reloader.lastModified()
+	if err != nil {
+		return err
+	}
+	certificate, err := tls.LoadX509KeyPair(reloader.certFile, reloader.keyFile)
+	if err != nil {
+		return fmt.Errorf("could not load certificate %s with key %s: %w", reloader.certFile, reloader.keyFile, err)
+	}
+	reloader.certificate = &certificate
+	reloader.modified = modified
+	return nil
+}
+
+// reload loads the files again when they were changed, a broken pair keeps the certificate loaded before
+// as certificate and key are often not replaced at the same time
+func (reloader *Reloader) reload(now time.Time) {
+	if now.Sub(reloader.checked) < reloader.checkInterval {
+		return
+	}
+	reloader.checked = now
+
+	modified, err := reloader.lastModified()
+	if err != nil {
+		log.Warnf("Could not check certificate %s: %s", reloader.certFile, err)
+		return
+	}
+	if modified.Equal(reloader.modified) {
+		return
+	}
+	if err = reloader.load(); err != nil {
+		log.Warnf("Keeping the current certificate: %s", err)
+		return
+	}
+	log.Infof("Reloaded certificate %s", reloader.certFile)
+}
+
+// GetCertificate is used as tls.Config.GetCertificate
+func (reloader *Reloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	reloader.mutex.Lock()
+	defer reloader.mutex.Unlock()
+	reloader.reload(time.Now())
+	return reloader.certificate, nil
+}
+
+// ServerConfig serves the certificate of the reloader, clients need a certificate signed by the client CA when given
+func ServerConfig(reloader *Reloader, clientCAFile string) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if clientCAFile == "" {
+		return config, nil
+	}
+
+	clientCA, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(clientCA) {
+		return nil, fmt.Errorf("no certificate found in %s", clientCAFile)
+	}
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	config.ClientCAs = pool
+	return config, nil
+}
+
+// SelfSigned returns the files of a self-signed certificate in the directory, the certificate is created when it is
+// missing and renewed before it expires
+func SelfSigned(directory string, hosts []string) (string, string, error) {
+	certFile := filepath.Join(directory, selfSignedCertName)
+	keyFile := filepath.Join(directory, selfSignedKeyName)
+
+	if certificate, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil {
+		if time.Until(certificate.Leaf.NotAfter) > selfSignedRenewal {
+			return certFile, keyFile, nil
+		}
+		log.Info("Renewing self-signed certificate")
+	} else if !errors.Is(err, os.ErrNotExist) {
+		log.Warnf("Replacing self-signed certificate: %s", err)
+	}
+
+	certPEM, keyPEM, err := generateSelfSigned(hosts, time.Now())
+	if err != nil {
+		return "", "", err
+	}
+	if err = os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		return "", "", err
+	}
+	if err = os.WriteFile(certFile, certPEM, 0o644); err != nil {
+		return "", "", err
+	}
+	log.Infof("Created self-signed certificate %s", certFile)
+	return certFile, keyFile, nil
+}
+
+func generateSelfSigned(hosts []string, now time.Time) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "fail2ban-dashboard", Organization: []string{"fail2ban-dashboard"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if host != "" {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
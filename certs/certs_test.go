@@ -0,0 +1,165 @@
+package certs
+
+import (
+	"crypto/tls"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSelfSigned(t *testing.T) {
+	directory := t.TempDir()
+
+	certFile, keyFile, err := SelfSigned(directory, []string{"localhost", "127.0.0.1", "dashboard.lan"})
+	if err != nil {
+		t.Fatalf("SelfSigned() error = %v", err)
+	}
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("LoadX509KeyPair() error = %v", err)
+	}
+	if !reflect.DeepEqual(certificate.Leaf.DNSNames, []string{"localhost", "dashboard.lan"}) {
+		t.Errorf("DNSNames = %v", certificate.Leaf.DNSNames)
+	}
+	if len(certificate.Leaf.IPAddresses) != 1 || !certificate.Leaf.IPAddresses[0].Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("IPAddresses = %v", certificate.Leaf.IPAddresses)
+	}
+
+	info, err := os.Stat(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("key file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	// an existing certificate is kept
+	if _, _, err = SelfSigned(directory, []string{"localhost"}); err != nil {
+		t.Fatalf("SelfSigned() error = %v", err)
+	}
+	again, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Leaf.SerialNumber.Cmp(certificate.Leaf.SerialNumber) != 0 {
+		t.Error("SelfSigned() replaced a valid certificate")
+	}
+
+	// an expiring certificate is renewed
+	certPEM, keyPEM, err := generateSelfSigned([]string{"localhost"}, time.Now().Add(-selfSignedValidity+time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, certFile, certPEM, keyFile, keyPEM)
+	if _, _, err = SelfSigned(directory, []string{"localhost"}); err != nil {
+		t.Fatalf("SelfSigned() error = %v", err)
+	}
+	renewed, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Until(renewed.Leaf.NotAfter) < selfSignedRenewal {
+		t.Errorf("SelfSigned() did not renew the certificate expiring at %s", renewed.Leaf.NotAfter)
+	}
+}
+
+func TestReloader(t *testing.T) {
+	directory := t.TempDir()
+	certFile := filepath.Join(directory, "tls.crt")
+	keyFile := filepath.Join(directory, "tls.key")
+
+	certPEM, keyPEM, err := generateSelfSigned([]string{"first.example.com"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, certFile, certPEM, keyFile, keyPEM)
+
+	reloader, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewReloader() error = %v", err)
+	}
+	reloader.checkInterval = 0
+	assertServed(t, reloader, "first.example.com")
+
+	// a key which doesn't match the certificate keeps the current certificate
+	otherCertPEM, otherKeyPEM, err := generateSelfSigned([]string{"second.example.com"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err = os.WriteFile(certFile, otherCertPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chtimes(certFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	assertServed(t, reloader, "first.example.com")
+
+	if err = os.WriteFile(keyFile, otherKeyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chtimes(keyFile, later.Add(time.Second), later.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	assertServed(t, reloader, "second.example.com")
+
+	if _, err = NewReloader(certFile, filepath.Join(directory, "missing.key")); err == nil {
+		t.Error("NewReloader() with a missing key should fail")
+	}
+}
+
+func TestServerConfig(t *testing.T) {
+	directory := t.TempDir()
+	certFile, keyFile, err := SelfSigned(directory, []string{"localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloader, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := ServerConfig(reloader, "")
+	if err != nil {
+		t.Fatalf("ServerConfig() error = %v", err)
+	}
+	if config.ClientAuth != tls.NoClientCert {
+		t.Errorf("ClientAuth = %v, want no client certificate", config.ClientAuth)
+	}
+
+	config, err = ServerConfig(reloader, certFile)
+	if err != nil {
+		t.Fatalf("ServerConfig() error = %v", err)
+	}
+	if config.ClientAuth != tls.RequireAndVerifyClientCert || config.ClientCAs == nil {
+		t.Errorf("ServerConfig() does not verify client certificates")
+	}
+
+	if _, err = ServerConfig(reloader, keyFile); err == nil {
+		t.Error("ServerConfig() with a client CA file without certificate should fail")
+	}
+}
+
+func writeFiles(t *testing.T, certFile string, certPEM []byte, keyFile string, keyPEM []byte) {
+	t.Helper()
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertServed(t *testing.T, reloader *Reloader, dnsName string) {
+	t.Helper()
+	certificate, err := reloader.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("GetCertificate() error = %v", err)
+	}
+	if names := certificate.Leaf.DNSNames; len(names) != 1 || names[0] != dnsName {
+		t.Errorf("GetCertificate() = %v, want %s", names, dnsName)
+	}
+}
@@ -3,16 +3,23 @@ package main
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/gofiber/fiber/v3/log"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/webishdev/fail2ban-dashboard/auth"
 	"github.com/webishdev/fail2ban-dashboard/bootstrap"
+	"github.com/webishdev/fail2ban-dashboard/export"
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+	"github.com/webishdev/fail2ban-dashboard/fail2bantest"
 	"github.com/webishdev/fail2ban-dashboard/geoip"
 	"github.com/webishdev/fail2ban-dashboard/metrics"
+	"github.com/webishdev/fail2ban-dashboard/notify"
 	"github.com/webishdev/fail2ban-dashboard/server"
 	"github.com/webishdev/fail2ban-dashboard/store"
 )
@@ -43,6 +50,62 @@ var versionCmd = &cobra.Command{
 	},
 }
 
+var healthcheckCmd = &cobra.Command{
+	Use:   "healthcheck",
+	Short: "Check the readiness of a running dashboard, e.g. for a Docker HEALTHCHECK",
+	Long:  "Request /readyz of the dashboard at the configured address and exit with status 1 when it is not ready",
+	Args:  cobra.NoArgs,
+	Run:   healthcheck,
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Print the current bans in a firewall or blocklist format",
+	Long:  fmt.Sprintf("Print the current bans of fail2ban in one of the formats %s", export.FormatNames()),
+	Args:  cobra.NoArgs,
+	// the global flags are bound to the serve command, they are bound again to read the values of this command
+	PreRun: func(cmd *cobra.Command, args []string) {
+		bindFlagsErr := viper.BindPFlags(cmd.Flags())
+		if bindFlagsErr != nil {
+			fmt.Printf("Could not bind flags: %s\n", bindFlagsErr)
+			os.Exit(1)
+		}
+	},
+	Run: exportBans,
+}
+
+var demoCmd = &cobra.Command{
+	Use:   "demo",
+	Short: "Start the dashboard against a simulated fail2ban with sample jails",
+	Long:  "Start the dashboard against a fail2ban simulated in the process, its sample jails ban addresses reserved for documentation, no fail2ban installation is needed",
+	Args:  cobra.NoArgs,
+	// the global and serve flags are bound to the serve command, they are bound again to read the values of this command
+	PreRun: func(cmd *cobra.Command, args []string) {
+		bindFlagsErr := viper.BindPFlags(cmd.Flags())
+		if bindFlagsErr != nil {
+			fmt.Printf("Could not bind flags: %s\n", bindFlagsErr)
+			os.Exit(1)
+		}
+	},
+	Run: demo,
+}
+
+var recordCmd = &cobra.Command{
+	Use:   "record <fixture-file>",
+	Short: "Record a session with fail2ban for the protocol regression tests",
+	Long:  "Send the commands the dashboard reads fail2ban with and write them with the responses to the fixture file, nothing is changed in fail2ban",
+	Args:  cobra.ExactArgs(1),
+	// the global flags are bound to the serve command, they are bound again to read the values of this command
+	PreRun: func(cmd *cobra.Command, args []string) {
+		bindFlagsErr := viper.BindPFlags(cmd.Flags())
+		if bindFlagsErr != nil {
+			fmt.Printf("Could not bind flags: %s\n", bindFlagsErr)
+			os.Exit(1)
+		}
+	},
+	Run: recordSession,
+}
+
 func setupRootCommand() {
 	// Add search paths to find the file
 	viper.SetConfigName("config")
@@ -60,15 +123,36 @@ func setupRootCommand() {
 
 	viper.AutomaticEnv()
 	viper.SetEnvPrefix("F2BD")
-	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_", ".", "_"))
+
+	// the SMTP notifier is only configured in the config file or the environment, e.g. F2BD_NOTIFY_SMTP_HOST
+	viper.SetDefault("notify.smtp.port", 587)
+	viper.SetDefault("notify.smtp.security", notify.SecurityStartTLS)
+	viper.SetDefault("notify.smtp.digest-hour", 8)
+
+	// the OpenID Connect login is only configured in the config file or the environment, e.g. F2BD_OIDC_ISSUER
+	viper.SetDefault("oidc.session-hours", 12)
 
+	addGlobalFlags(exportCmd)
+	addGlobalFlags(recordCmd)
+	addGlobalFlags(demoCmd)
+	addServeFlags(demoCmd)
+	addDemoFlags(demoCmd)
 	addGlobalFlags(rootCmd)
 	addGlobalFlags(serveCmd)
 	addServeFlags(rootCmd)
 	addServeFlags(serveCmd)
+	addExportFlags(exportCmd)
+	addTokenCommands(tokenCmd)
+	addHealthcheckFlags(healthcheckCmd)
 
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(tokenCmd)
+	rootCmd.AddCommand(healthcheckCmd)
+	rootCmd.AddCommand(demoCmd)
+	rootCmd.AddCommand(recordCmd)
 }
 
 func addGlobalFlags(cmd *cobra.Command) {
@@ -142,6 +226,13 @@ func addServeFlags(cmd *cobra.Command) {
 		os.Exit(1)
 	}
 
+	flags.String("users-file", "", "htpasswd style file with lines of name:hash[:role[:jail,jail]], also F2BD_USERS_FILE")
+	usersFileErr := viper.BindPFlag("users-file", flags.Lookup("users-file"))
+	if usersFileErr != nil {
+		fmt.Printf("Could not bind users-file flag: %s\n", usersFileErr)
+		os.Exit(1)
+	}
+
 	flags.Bool("trust-proxy-headers", false, "trust proxy headers like X-Forwarded-For, also F2BD_TRUST_PROXY_HEADERS")
 	trustProxyHeadersErr := viper.BindPFlag("trust-proxy-headers", flags.Lookup("trust-proxy-headers"))
 	if trustProxyHeadersErr != nil {
@@ -149,6 +240,69 @@ func addServeFlags(cmd *cobra.Command) {
 		os.Exit(1)
 	}
 
+	flags.StringSlice("trusted-proxies", nil, "addresses or CIDR ranges of proxies allowed to send the auth headers, also F2BD_TRUSTED_PROXIES")
+	trustedProxiesErr := viper.BindPFlag("trusted-proxies", flags.Lookup("trusted-proxies"))
+	if trustedProxiesErr != nil {
+		fmt.Printf("Could not bind trusted-proxies flag: %s\n", trustedProxiesErr)
+		os.Exit(1)
+	}
+
+	flags.String("auth-user-header", "", "header with the user authenticated by the proxy, e.g. Remote-User, also F2BD_AUTH_USER_HEADER")
+	authUserHeaderErr := viper.BindPFlag("auth-user-header", flags.Lookup("auth-user-header"))
+	if authUserHeaderErr != nil {
+		fmt.Printf("Could not bind auth-user-header flag: %s\n", authUserHeaderErr)
+		os.Exit(1)
+	}
+
+	flags.String("auth-groups-header", "", "header with the comma separated groups of the user, e.g. Remote-Groups, also F2BD_AUTH_GROUPS_HEADER")
+	authGroupsHeaderErr := viper.BindPFlag("auth-groups-header", flags.Lookup("auth-groups-header"))
+	if authGroupsHeaderErr != nil {
+		fmt.Printf("Could not bind auth-groups-header flag: %s\n", authGroupsHeaderErr)
+		os.Exit(1)
+	}
+
+	flags.StringSlice("auth-admin-groups", nil, "proxy groups with the admin role, also F2BD_AUTH_ADMIN_GROUPS")
+	authAdminGroupsErr := viper.BindPFlag("auth-admin-groups", flags.Lookup("auth-admin-groups"))
+	if authAdminGroupsErr != nil {
+		fmt.Printf("Could not bind auth-admin-groups flag: %s\n", authAdminGroupsErr)
+		os.Exit(1)
+	}
+
+	flags.StringSlice("auth-viewer-groups", nil, "proxy groups with the viewer role, all other users when empty, also F2BD_AUTH_VIEWER_GROUPS")
+	authViewerGroupsErr := viper.BindPFlag("auth-viewer-groups", flags.Lookup("auth-viewer-groups"))
+	if authViewerGroupsErr != nil {
+		fmt.Printf("Could not bind auth-viewer-groups flag: %s\n", authViewerGroupsErr)
+		os.Exit(1)
+	}
+
+	flags.String("tls-cert", "", "certificate file to serve the dashboard and the metrics with TLS, reloaded on change, also F2BD_TLS_CERT")
+	tlsCertErr := viper.BindPFlag("tls-cert", flags.Lookup("tls-cert"))
+	if tlsCertErr != nil {
+		fmt.Printf("Could not bind tls-cert flag: %s\n", tlsCertErr)
+		os.Exit(1)
+	}
+
+	flags.String("tls-key", "", "key file of the TLS certificate, also F2BD_TLS_KEY")
+	tlsKeyErr := viper.BindPFlag("tls-key", flags.Lookup("tls-key"))
+	if tlsKeyErr != nil {
+		fmt.Printf("Could not bind tls-key flag: %s\n", tlsKeyErr)
+		os.Exit(1)
+	}
+
+	flags.Bool("tls-self-signed", false, "serve with TLS using a self-signed certificate created in the cache directory, also F2BD_TLS_SELF_SIGNED")
+	tlsSelfSignedErr := viper.BindPFlag("tls-self-signed", flags.Lookup("tls-self-signed"))
+	if tlsSelfSignedErr != nil {
+		fmt.Printf("Could not bind tls-self-signed flag: %s\n", tlsSelfSignedErr)
+		os.Exit(1)
+	}
+
+	flags.StringSlice("health-allowed", []string{"127.0.0.0/8", "::1"}, "addresses or CIDR ranges allowed to request /healthz and /readyz without authentication, also F2BD_HEALTH_ALLOWED")
+	healthAllowedErr := viper.BindPFlag("health-allowed", flags.Lookup("health-allowed"))
+	if healthAllowedErr != nil {
+		fmt.Printf("Could not bind health-allowed flag: %s\n", healthAllowedErr)
+		os.Exit(1)
+	}
+
 	flags.String("base-path", "/", "base path of the application, also F2BD_BASE_PATH")
 	basePathError := viper.BindPFlag("base-path", flags.Lookup("base-path"))
 	if basePathError != nil {
@@ -156,6 +310,20 @@ func addServeFlags(cmd *cobra.Command) {
 		os.Exit(1)
 	}
 
+	flags.String("fail2ban-client", "fail2ban-client", "fail2ban-client command used to reload jails, e.g. \"sudo fail2ban-client\", also F2BD_FAIL2BAN_CLIENT")
+	fail2banClientErr := viper.BindPFlag("fail2ban-client", flags.Lookup("fail2ban-client"))
+	if fail2banClientErr != nil {
+		fmt.Printf("Could not bind fail2ban-client flag: %s\n", fail2banClientErr)
+		os.Exit(1)
+	}
+
+	flags.Int("page-size", 100, "default number of banned addresses per page (value from 1 to 1000), also F2BD_PAGE_SIZE")
+	pageSizeErr := viper.BindPFlag("page-size", flags.Lookup("page-size"))
+	if pageSizeErr != nil {
+		fmt.Printf("Could not bind page-size flag: %s\n", pageSizeErr)
+		os.Exit(1)
+	}
+
 	flags.BoolP("metrics", "m", false, "will provide metrics endpoint, also F2BD_METRICS")
 	metricsErr := viper.BindPFlag("metrics", flags.Lookup("metrics"))
 	if metricsErr != nil {
@@ -169,6 +337,213 @@ func addServeFlags(cmd *cobra.Command) {
 		fmt.Printf("Could not bind metrics-address flag: %s\n", metricsAddressErr)
 		os.Exit(1)
 	}
+
+	flags.String("metrics-client-ca", "", "CA file to require client certificates for the metrics, needs TLS, also F2BD_METRICS_CLIENT_CA")
+	metricsClientCAErr := viper.BindPFlag("metrics-client-ca", flags.Lookup("metrics-client-ca"))
+	if metricsClientCAErr != nil {
+		fmt.Printf("Could not bind metrics-client-ca flag: %s\n", metricsClientCAErr)
+		os.Exit(1)
+	}
+
+	flags.Bool("history", true, "record the ban history in the cache directory, also F2BD_HISTORY")
+	historyErr := viper.BindPFlag("history", flags.Lookup("history"))
+	if historyErr != nil {
+		fmt.Printf("Could not bind history flag: %s\n", historyErr)
+		os.Exit(1)
+	}
+
+	flags.Int("history-retention-days", 90, "days to keep ended bans in the history (0 keeps everything), also F2BD_HISTORY_RETENTION_DAYS")
+	historyRetentionDaysErr := viper.BindPFlag("history-retention-days", flags.Lookup("history-retention-days"))
+	if historyRetentionDaysErr != nil {
+		fmt.Printf("Could not bind history-retention-days flag: %s\n", historyRetentionDaysErr)
+		os.Exit(1)
+	}
+
+	flags.Int("history-compaction-hours", 24, "hours between compactions of the history file, also F2BD_HISTORY_COMPACTION_HOURS")
+	historyCompactionHoursErr := viper.BindPFlag("history-compaction-hours", flags.Lookup("history-compaction-hours"))
+	if historyCompactionHoursErr != nil {
+		fmt.Printf("Could not bind history-compaction-hours flag: %s\n", historyCompactionHoursErr)
+		os.Exit(1)
+	}
+
+	flags.StringSlice("webhook", nil, "webhook to notify about added and removed bans as [template=]url, templates are json, slack, discord, teams, ntfy and gotify, also F2BD_WEBHOOK")
+	webhookErr := viper.BindPFlag("webhook", flags.Lookup("webhook"))
+	if webhookErr != nil {
+		fmt.Printf("Could not bind webhook flag: %s\n", webhookErr)
+		os.Exit(1)
+	}
+
+	flags.Int("webhook-debounce-seconds", 10, "seconds to collect bans into one webhook notification, also F2BD_WEBHOOK_DEBOUNCE_SECONDS")
+	webhookDebounceSecondsErr := viper.BindPFlag("webhook-debounce-seconds", flags.Lookup("webhook-debounce-seconds"))
+	if webhookDebounceSecondsErr != nil {
+		fmt.Printf("Could not bind webhook-debounce-seconds flag: %s\n", webhookDebounceSecondsErr)
+		os.Exit(1)
+	}
+
+	flags.Int("webhook-retries", 3, "retries of a failed webhook notification, also F2BD_WEBHOOK_RETRIES")
+	webhookRetriesErr := viper.BindPFlag("webhook-retries", flags.Lookup("webhook-retries"))
+	if webhookRetriesErr != nil {
+		fmt.Printf("Could not bind webhook-retries flag: %s\n", webhookRetriesErr)
+		os.Exit(1)
+	}
+}
+
+func addExportFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+
+	flags.StringP("format", "f", string(export.FormatText), fmt.Sprintf("export format (%s)", export.FormatNames()))
+	flags.String("jail", "", "only export bans of this jail")
+	flags.String("country", "", "only export bans from this country code, e.g. DE")
+	flags.String("name", export.DefaultName, "name of the iptables chain or ipset set")
+	flags.StringP("output", "o", "", "file to write the export to (default standard output)")
+}
+
+func addHealthcheckFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+
+	flags.Bool("live", false, "request /healthz, which only checks the fail2ban connection, instead of /readyz")
+	flags.String("url", "", "URL of the health endpoint (default derived from the address, base path and TLS configuration)")
+	flags.Duration("timeout", 5*time.Second, "timeout of the request")
+}
+
+func addDemoFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+
+	flags.String("fail2ban-version", fail2bantest.Version1_1, fmt.Sprintf("fail2ban version to simulate (%s, %s or %s)", fail2bantest.Version0_11, fail2bantest.Version1_0, fail2bantest.Version1_1))
+	flags.Duration("latency", 0, "delay of each answer of the simulated fail2ban")
+}
+
+// demo serves the dashboard like the serve command, the socket is replaced by the simulated fail2ban
+func demo(cmd *cobra.Command, args []string) {
+	flags := cmd.Flags()
+	version, _ := flags.GetString("fail2ban-version")
+	latency, _ := flags.GetDuration("latency")
+
+	directory, tempErr := os.MkdirTemp("", "fail2ban-dashboard-demo-")
+	if tempErr != nil {
+		fmt.Printf("Could not create demo directory: %s\n", tempErr)
+		os.Exit(1)
+	}
+	defer func() { _ = os.RemoveAll(directory) }()
+
+	bootstrap.ConfigureLogging(viper.GetString("log-level"))
+	fail2ban := bootstrap.StartDemo(directory, version, latency)
+	defer func() { _ = fail2ban.Close() }()
+
+	viper.Set("socket", fail2ban.Address())
+	// reloading must never reach a real fail2ban, the simulated one has nothing to reload
+	viper.Set("fail2ban-client", "true")
+	// history, audit and tokens of the demo are thrown away unless a cache directory is given
+	if viper.GetString("cache-dir") == "" {
+		viper.Set("cache-dir", directory)
+	}
+
+	serve(cmd, args)
+}
+
+// healthcheck reads the address, base path and TLS configuration like the serve command
+func healthcheck(cmd *cobra.Command, _ []string) {
+	flags := cmd.Flags()
+	live, _ := flags.GetBool("live")
+	url, _ := flags.GetString("url")
+	timeout, _ := flags.GetDuration("timeout")
+
+	if url == "" {
+		endpoint := "readyz"
+		if live {
+			endpoint = "healthz"
+		}
+		useTLS := viper.GetString("tls-cert") != "" || viper.GetBool("tls-self-signed")
+		url = bootstrap.HealthURL(viper.GetString("address"), viper.GetString("base-path"), useTLS, endpoint)
+	}
+
+	if healthErr := bootstrap.Healthcheck(url, timeout); healthErr != nil {
+		fmt.Fprintf(os.Stderr, "Not healthy: %s\n", healthErr)
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
+
+func exportBans(cmd *cobra.Command, _ []string) {
+	socketPath := viper.GetString("socket")
+	cacheDir := viper.GetString("cache-dir")
+	logLevel := viper.GetString("log-level")
+	skipVersionCheck := viper.GetBool("skip-version-check")
+
+	flags := cmd.Flags()
+	formatName, _ := flags.GetString("format")
+	jailName, _ := flags.GetString("jail")
+	countryCode, _ := flags.GetString("country")
+	name, _ := flags.GetString("name")
+	output, _ := flags.GetString("output")
+
+	// Configure logging, the log is written to standard error and does not mix with the export
+	bootstrap.ConfigureLogging(logLevel)
+
+	format, formatErr := export.ParseFormat(formatName)
+	if formatErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", formatErr)
+		os.Exit(1)
+	}
+
+	options := export.Options{
+		JailName:    jailName,
+		CountryCode: countryCode,
+		Name:        name,
+	}
+	if optionsErr := options.Validate(); optionsErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", optionsErr)
+		os.Exit(1)
+	}
+
+	// Fetch the current bans once
+	f2bc := bootstrap.ConnectToFail2ban(socketPath, skipVersionCheck)
+	dataStore := store.NewDataStore(f2bc, 30)
+	if refreshErr := dataStore.Refresh(); refreshErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not fetch bans from fail2ban: %s\n", refreshErr)
+		os.Exit(1)
+	}
+
+	// GeoIP data is only needed when the country is filtered or exported
+	var lookup export.CountryLookup
+	if countryCode != "" || format.NeedsCountry() {
+		lookup = geoip.NewGeoIP(bootstrap.SetupCacheDirectory(cacheDir), false).Lookup
+	}
+	banned := export.Collect(dataStore.GetJails(), lookup, format, options)
+
+	if output == "" {
+		writeExport(os.Stdout, format, banned, options)
+		return
+	}
+
+	file, createErr := os.Create(output)
+	if createErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", createErr)
+		os.Exit(1)
+	}
+	writeExport(file, format, banned, options)
+	if closeErr := file.Close(); closeErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", closeErr)
+		os.Exit(1)
+	}
+}
+
+func recordSession(_ *cobra.Command, args []string) {
+	bootstrap.ConfigureLogging(viper.GetString("log-level"))
+
+	version, recordErr := bootstrap.RecordSession(viper.GetString("socket"), args[0])
+	if recordErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not record the session with fail2ban %s: %s\n", version, recordErr)
+		os.Exit(1)
+	}
+	fmt.Printf("Recorded the session with fail2ban %s to %s\n", version, args[0])
+}
+
+func writeExport(writer io.Writer, format export.Format, banned []client.BanEntry, options export.Options) {
+	if writeErr := export.Write(writer, format, banned, options); writeErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", writeErr)
+		os.Exit(1)
+	}
 }
 
 func main() {
@@ -187,15 +562,78 @@ func serve(_ *cobra.Command, _ []string) {
 	address := viper.GetString("address")
 	user := viper.GetString("auth-user")
 	password := viper.GetString("auth-password")
+	usersFile := viper.GetString("users-file")
+	authUserHeader := viper.GetString("auth-user-header")
+	authGroupsHeader := viper.GetString("auth-groups-header")
+	trustedProxies := viper.GetStringSlice("trusted-proxies")
+	authAdminGroups := viper.GetStringSlice("auth-admin-groups")
+	authViewerGroups := viper.GetStringSlice("auth-viewer-groups")
 	cacheDir := viper.GetString("cache-dir")
 	logLevel := viper.GetString("log-level")
 	skipVersionCheck := viper.GetBool("skip-version-check")
 	trustProxyHeaders := viper.GetBool("trust-proxy-headers")
 	refreshSeconds := viper.GetInt("refresh-seconds")
 	basePath := viper.GetString("base-path")
+	pageSize := viper.GetInt("page-size")
+	fail2banClient := viper.GetString("fail2ban-client")
 	enableSchedule := viper.GetBool("scheduled-geoip-download")
 	metricsEnabled := viper.GetBool("metrics")
 	metricsAddress := viper.GetString("metrics-address")
+	metricsClientCA := viper.GetString("metrics-client-ca")
+	tlsCert := viper.GetString("tls-cert")
+	tlsKey := viper.GetString("tls-key")
+	tlsSelfSigned := viper.GetBool("tls-self-signed")
+	healthAllowed := viper.GetStringSlice("health-allowed")
+	historyEnabled := viper.GetBool("history")
+	historyRetentionDays := viper.GetInt("history-retention-days")
+	historyCompactionHours := viper.GetInt("history-compaction-hours")
+	webhookDebounceSeconds := viper.GetInt("webhook-debounce-seconds")
+	webhookRetries := viper.GetInt("webhook-retries")
+
+	// Webhooks from flags and the environment are combined with the webhooks of the config file
+	webhooks := bootstrap.ParseWebhookFlags(viper.GetStringSlice("webhook"))
+	var configuredWebhooks []notify.Webhook
+	if webhooksErr := viper.UnmarshalKey("webhooks", &configuredWebhooks); webhooksErr != nil {
+		fmt.Printf("Could not parse webhooks from config file: %s\n", webhooksErr)
+		os.Exit(1)
+	}
+	webhooks = append(webhooks, configuredWebhooks...)
+
+	// Users with roles are only configured in the config file or the users file
+	var configuredUsers []auth.User
+	if usersErr := viper.UnmarshalKey("users", &configuredUsers); usersErr != nil {
+		fmt.Printf("Could not parse users from config file: %s\n", usersErr)
+		os.Exit(1)
+	}
+
+	smtpConfiguration := notify.SMTPConfiguration{
+		Host:       viper.GetString("notify.smtp.host"),
+		Port:       viper.GetInt("notify.smtp.port"),
+		Username:   viper.GetString("notify.smtp.username"),
+		Password:   viper.GetString("notify.smtp.password"),
+		From:       viper.GetString("notify.smtp.from"),
+		To:         viper.GetStringSlice("notify.smtp.to"),
+		Security:   viper.GetString("notify.smtp.security"),
+		AlertJails: viper.GetStringSlice("notify.smtp.alert-jails"),
+		Digest:     viper.GetString("notify.smtp.digest"),
+		DigestHour: viper.GetInt("notify.smtp.digest-hour"),
+	}
+
+	oidcConfiguration := auth.OIDCConfiguration{
+		Issuer:        viper.GetString("oidc.issuer"),
+		ClientID:      viper.GetString("oidc.client-id"),
+		ClientSecret:  viper.GetString("oidc.client-secret"),
+		RedirectURL:   viper.GetString("oidc.redirect-url"),
+		Scopes:        viper.GetStringSlice("oidc.scopes"),
+		UsernameClaim: viper.GetString("oidc.username-claim"),
+		GroupsClaim:   viper.GetString("oidc.groups-claim"),
+		Roles: auth.GroupRoles{
+			AdminGroups:  viper.GetStringSlice("oidc.admin-groups"),
+			ViewerGroups: viper.GetStringSlice("oidc.viewer-groups"),
+		},
+		SessionSecret: viper.GetString("oidc.session-secret"),
+		SessionHours:  viper.GetInt("oidc.session-hours"),
+	}
 
 	// Configure logging
 	bootstrap.ConfigureLogging(logLevel)
@@ -211,7 +649,10 @@ func serve(_ *cobra.Command, _ []string) {
 	log.Infof("Data refresh from fail2ban set to %d seconds", refreshSeconds)
 
 	// Connect to fail2ban and verify version
-	f2bc, fail2banVersion := bootstrap.ConnectToFail2ban(socketPath, skipVersionCheck)
+	f2bc := bootstrap.ConnectToFail2ban(socketPath, skipVersionCheck)
+	if executableErr := f2bc.SetExecutable(fail2banClient); executableErr != nil {
+		log.Warnf("Could not find %s: %s", fail2banClient, executableErr)
+	}
 
 	// Initialize data store
 	dataStore := store.NewDataStore(f2bc, refreshSeconds)
@@ -222,6 +663,33 @@ func serve(_ *cobra.Command, _ []string) {
 	// Initialize GeoIP
 	geoIP := geoip.NewGeoIP(absoluteCacheDir, enableSchedule)
 
+	// Record ban history
+	if historyEnabled {
+		bootstrap.SetupHistory(dataStore, geoIP, absoluteCacheDir, historyRetentionDays, historyCompactionHours)
+	} else {
+		log.Info("Ban history disabled")
+	}
+
+	// Record changes made through the dashboard
+	bootstrap.SetupAudit(dataStore, absoluteCacheDir)
+
+	// Notify webhooks about added and removed bans
+	bootstrap.SetupNotifier(dataStore, geoIP, webhooks, webhookDebounceSeconds, webhookRetries)
+
+	// Send email alerts and digest reports
+	bootstrap.SetupEmailNotifier(dataStore, geoIP, smtpConfiguration)
+
+	// Users and roles for basic authentication
+	users := bootstrap.SetupUsers(configuredUsers, usersFile)
+	proxyAuth := bootstrap.SetupProxyAuth(trustProxyHeaders, authUserHeader, authGroupsHeader, trustedProxies, authAdminGroups, authViewerGroups)
+	openIDConnect := bootstrap.SetupOIDC(oidcConfiguration, proxyAuth != nil)
+
+	// API tokens for automation clients
+	tokens := bootstrap.SetupTokens(absoluteCacheDir)
+
+	// TLS for the dashboard and the metrics
+	certificate := bootstrap.SetupTLS(tlsCert, tlsKey, tlsSelfSigned, absoluteCacheDir, address, metricsAddress)
+
 	// Create dashboard application
 	dashboardApp := fiber.New(fiber.Config{})
 
@@ -229,17 +697,23 @@ func serve(_ *cobra.Command, _ []string) {
 		Address:           address,
 		AuthUser:          user,
 		AuthPassword:      password,
+		Users:             users,
+		ProxyAuth:         proxyAuth,
+		OIDC:              openIDConnect,
+		Tokens:            tokens,
 		BasePath:          basePath,
 		TrustProxyHeaders: trustProxyHeaders,
-		Fail2BanVersion:   fail2banVersion,
 		Version:           Version,
+		PageSize:          pageSize,
+		TLSConfig:         bootstrap.SetupServerTLS(certificate, ""),
+		HealthAllowed:     bootstrap.SetupHealthAllowed(healthAllowed),
 	}
 
 	if metricsEnabled {
 		metricConfiguration := &metrics.Configuration{
-			Address:         metricsAddress,
-			Fail2BanVersion: fail2banVersion,
-			Version:         Version,
+			Address:   metricsAddress,
+			Version:   Version,
+			TLSConfig: bootstrap.SetupServerTLS(certificate, metricsClientCA),
 		}
 		if address != metricsAddress {
 			metricsApp := fiber.New(fiber.Config{})
@@ -249,6 +723,9 @@ func serve(_ *cobra.Command, _ []string) {
 			go bootstrap.StartMetricsServer(metricsApp, metricConfiguration)
 		} else {
 			log.Warn("Metrics address is identical to dashboard address, your metrics will be exposed the same way as the dashboard")
+			if metricsClientCA != "" {
+				log.Warn("Client certificates are only required on a separate metrics address")
+			}
 			metrics.RegisterMetricsEndpoints(dashboardApp, dataStore, metricConfiguration)
 		}
 
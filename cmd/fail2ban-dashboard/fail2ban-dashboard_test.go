@@ -37,6 +37,55 @@ func TestSetupRootCommand(t *testing.T) {
 	assertFlagExists(t, serveCmd, "cache-dir", "serveCmd")
 	assertFlagExists(t, serveCmd, "socket", "serveCmd")
 	assertFlagExists(t, serveCmd, "address", "serveCmd")
+	assertFlagExists(t, serveCmd, "webhook", "serveCmd")
+
+	// Verify flags on exportCmd
+	if !hasSubCommand(rootCmd, exportCmd) {
+		t.Errorf("export command missing from root")
+	}
+	assertFlagExists(t, exportCmd, "socket", "exportCmd")
+	assertFlagExists(t, exportCmd, "format", "exportCmd")
+	assertFlagExists(t, exportCmd, "output", "exportCmd")
+	assertFlagDoesNotExist(t, exportCmd, "address", "exportCmd")
+	assertFlagDoesNotExist(t, exportCmd, "webhook", "exportCmd")
+	assertFlagDoesNotExist(t, rootCmd, "format", "rootCmd")
+
+	// Verify the token commands
+	if !hasSubCommand(rootCmd, tokenCmd) {
+		t.Errorf("token command missing from root")
+	}
+	for _, sub := range []*cobra.Command{tokenCreateCmd, tokenListCmd, tokenRevokeCmd} {
+		if !hasSubCommand(tokenCmd, sub) {
+			t.Errorf("%s command missing from token", sub.Name())
+		}
+	}
+	assertFlagExists(t, tokenCreateCmd, "scope", "tokenCreateCmd")
+	assertFlagExists(t, tokenCreateCmd, "jail", "tokenCreateCmd")
+	assertFlagExists(t, tokenCreateCmd, "expires-days", "tokenCreateCmd")
+	assertFlagDoesNotExist(t, tokenCmd, "address", "tokenCmd")
+
+	// Verify the healthcheck command
+	if !hasSubCommand(rootCmd, healthcheckCmd) {
+		t.Errorf("healthcheck command missing from root")
+	}
+	assertFlagExists(t, healthcheckCmd, "live", "healthcheckCmd")
+	assertFlagExists(t, serveCmd, "health-allowed", "serveCmd")
+
+	// Verify the demo command
+	if !hasSubCommand(rootCmd, demoCmd) {
+		t.Errorf("demo command missing from root")
+	}
+	assertFlagExists(t, demoCmd, "address", "demoCmd")
+	assertFlagExists(t, demoCmd, "fail2ban-version", "demoCmd")
+	assertFlagExists(t, demoCmd, "latency", "demoCmd")
+	assertFlagDoesNotExist(t, serveCmd, "fail2ban-version", "serveCmd")
+
+	// Verify the record command
+	if !hasSubCommand(rootCmd, recordCmd) {
+		t.Errorf("record command missing from root")
+	}
+	assertFlagExists(t, recordCmd, "socket", "recordCmd")
+	assertFlagDoesNotExist(t, recordCmd, "address", "recordCmd")
 
 	// Verify flags NOT on versionCmd
 	assertFlagDoesNotExist(t, versionCmd, "cache-dir", "versionCmd")
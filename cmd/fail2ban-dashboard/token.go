@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/webishdev/fail2ban-dashboard/auth"
+	"github.com/webishdev/fail2ban-dashboard/bootstrap"
+)
+
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Manage API tokens for automation clients",
+	Long:  fmt.Sprintf("Manage bearer tokens for the JSON API and the exports, tokens carry the scopes %s", strings.Join(auth.Scopes, ", ")),
+	// the cache directory is bound again to read the value of the token commands
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		bindFlagErr := viper.BindPFlag("cache-dir", cmd.Flags().Lookup("cache-dir"))
+		if bindFlagErr != nil {
+			fmt.Printf("Could not bind cache-dir flag: %s\n", bindFlagErr)
+			os.Exit(1)
+		}
+	},
+}
+
+var tokenCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a token and print its secret",
+	Args:  cobra.NoArgs,
+	Run:   createToken,
+}
+
+var tokenListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the tokens",
+	Args:  cobra.NoArgs,
+	Run:   listTokens,
+}
+
+var tokenRevokeCmd = &cobra.Command{
+	Use:   "revoke <id>",
+	Short: "Revoke a token",
+	Args:  cobra.ExactArgs(1),
+	Run:   revokeToken,
+}
+
+func addTokenCommands(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringP("cache-dir", "c", "", "directory of the tokens file, also F2BD_CACHE_DIR (default current working directory)")
+
+	createFlags := tokenCreateCmd.Flags()
+	createFlags.StringP("name", "n", "", "name of the token, e.g. the script using it")
+	createFlags.StringSlice("scope", []string{auth.ScopeRead}, fmt.Sprintf("scopes of the token (%s)", strings.Join(auth.Scopes, ", ")))
+	createFlags.StringSlice("jail", nil, "jails the token is limited to (default all jails)")
+	createFlags.Int("expires-days", 0, "days until the token expires (0 never expires)")
+
+	cmd.AddCommand(tokenCreateCmd)
+	cmd.AddCommand(tokenListCmd)
+	cmd.AddCommand(tokenRevokeCmd)
+}
+
+func openTokenStore() *auth.TokenStore {
+	return bootstrap.SetupTokens(bootstrap.SetupCacheDirectory(viper.GetString("cache-dir")))
+}
+
+func createToken(cmd *cobra.Command, _ []string) {
+	flags := cmd.Flags()
+	name, _ := flags.GetString("name")
+	scopes, _ := flags.GetStringSlice("scope")
+	jails, _ := flags.GetStringSlice("jail")
+	expiresDays, _ := flags.GetInt("expires-days")
+
+	if expiresDays < 0 {
+		fmt.Fprintln(os.Stderr, "Error: expires-days must not be negative")
+		os.Exit(1)
+	}
+	var expires *time.Time
+	if expiresDays > 0 {
+		expiry := time.Now().UTC().Truncate(time.Second).AddDate(0, 0, expiresDays)
+		expires = &expiry
+	}
+
+	token, secret, createErr := openTokenStore().Create(name, scopes, jails, expires)
+	if createErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", createErr)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "Created token %s (%s) with scopes %s for %s, the secret is only shown once:\n", token.ID, token.Name, strings.Join(token.Scopes, ", "), tokenJails(token))
+	fmt.Println(secret)
+}
+
+func listTokens(_ *cobra.Command, _ []string) {
+	tokens, listErr := openTokenStore().List()
+	if listErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", listErr)
+		os.Exit(1)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "ID\tNAME\tSCOPES\tJAILS\tCREATED\tEXPIRES")
+	now := time.Now()
+	for _, token := range tokens {
+		expires := "never"
+		if token.Expires != nil {
+			expires = token.Expires.Format(time.RFC3339)
+			if token.Expired(now) {
+				expires += " (expired)"
+			}
+		}
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n", token.ID, token.Name, strings.Join(token.Scopes, ","), tokenJails(token), token.Created.Format(time.RFC3339), expires)
+	}
+	_ = writer.Flush()
+}
+
+// tokenJails lists the jails of the token for the output of the commands
+func tokenJails(token auth.Token) string {
+	if len(token.Jails) == 0 {
+		return "all jails"
+	}
+	return strings.Join(token.Jails, ",")
+}
+
+func revokeToken(_ *cobra.Command, args []string) {
+	revokeErr := openTokenStore().Revoke(args[0])
+	if errors.Is(revokeErr, auth.ErrTokenNotFound) {
+		fmt.Fprintf(os.Stderr, "Error: no token with id %s\n", args[0])
+		os.Exit(1)
+	}
+	if revokeErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", revokeErr)
+		os.Exit(1)
+	}
+	fmt.Printf("Revoked token %s\n", args[0])
+}
@@ -3,11 +3,11 @@
 set -euo pipefail
 
 usage() {
-  echo "Usage: $0 {start|stop|dashboard|debug} [fail2ban_version]"
+  echo "Usage: $0 {start|stop|dashboard|debug|record} [fail2ban_version]"
 }
 
 ACTION="${1:-}"
-if [[ "$ACTION" != "start" && "$ACTION" != "stop" && "$ACTION" != "dashboard" && "$ACTION" != "debug" ]]; then
+if [[ "$ACTION" != "start" && "$ACTION" != "stop" && "$ACTION" != "dashboard" && "$ACTION" != "debug" && "$ACTION" != "record" ]]; then
   usage
   exit 1
 fi
@@ -53,12 +53,16 @@ prepare_debug() {
     "${COMPOSE_CMD[@]}" exec dashboard go install github.com/go-delve/delve/cmd/dlv@latest
 }
 
-if [[ "$ACTION" == "start" ]]; then
+require_executable() {
   if [[ ! -x "$ROOT_DIR/bin/linux/amd64/fail2ban-dashboard" ]]; then
     echo "Missing executable: $ROOT_DIR/bin/linux/amd64/fail2ban-dashboard"
     echo "Build the dashboard before starting."
     exit 1
   fi
+}
+
+if [[ "$ACTION" == "start" ]]; then
+  require_executable
   if has_running_containers; then
     "${COMPOSE_CMD[@]}" down
   fi
@@ -78,6 +82,22 @@ elif [[ "$ACTION" == "debug" ]]; then
   prepare_debug
   "${COMPOSE_CMD[@]}" exec dashboard /script/debug.sh
 
+elif [[ "$ACTION" == "record" ]]; then
+  require_executable
+  if has_running_containers; then
+    "${COMPOSE_CMD[@]}" down
+  fi
+  "${COMPOSE_CMD[@]}" up -d
+
+  prepare_after_start
+  FIXTURE="fail2ban-$FAIL2BAN_VERSION.jsonl"
+  "${COMPOSE_CMD[@]}" exec fail2ban /app/fail2ban-dashboard record "/app/$FIXTURE"
+  mkdir -p "$ROOT_DIR/bootstrap/testdata/sessions"
+  cp "$ROOT_DIR/bin/linux/amd64/$FIXTURE" "$ROOT_DIR/bootstrap/testdata/sessions/$FIXTURE"
+  rm -f "$ROOT_DIR/bin/linux/amd64/$FIXTURE"
+  echo "Recorded session: bootstrap/testdata/sessions/$FIXTURE"
+  "${COMPOSE_CMD[@]}" down
+
 elif [[ "$ACTION" == "stop" ]]; then
   if [[ -f "$DEBUG_COMPOSE_FILE" ]]; then
     set_compose_cmd debug
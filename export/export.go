@@ -0,0 +1,352 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+	"github.com/webishdev/fail2ban-dashboard/store"
+)
+
+type Format string
+
+const (
+	FormatText     Format = "text"
+	FormatCSV      Format = "csv"
+	FormatJSON     Format = "json"
+	FormatNginx    Format = "nginx"
+	FormatIPTables Format = "iptables"
+	FormatIPSet    Format = "ipset"
+	FormatCIDR     Format = "cidr"
+)
+
+// Formats are all supported formats in the order they are documented
+var Formats = []Format{FormatText, FormatCSV, FormatJSON, FormatNginx, FormatIPTables, FormatIPSet, FormatCIDR}
+
+// DefaultName is used for the iptables chain and the ipset set when no name is given
+const DefaultName = "fail2ban-dashboard"
+
+// maxNameLength keeps chain names within the iptables limit, which also leaves room for the ipset suffix
+const maxNameLength = 28
+
+// Options select and name the exported bans, empty values match everything
+type Options struct {
+	JailName    string
+	CountryCode string
+	Name        string
+}
+
+// Validate checks the name as it ends up in files which are executed by iptables-restore and ipset
+func (options Options) Validate() error {
+	if len(options.Name) > maxNameLength {
+		return fmt.Errorf("name must not be longer than %d characters", maxNameLength)
+	}
+	for _, r := range options.Name {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '-' && r != '_' {
+			return errors.New("name may only contain letters, digits, - and _")
+		}
+	}
+	return nil
+}
+
+func ParseFormat(value string) (Format, error) {
+	for _, format := range Formats {
+		if string(format) == value {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("unknown export format %s, supported formats are %s", value, FormatNames())
+}
+
+// FormatNames is a comma separated list of the supported formats
+func FormatNames() string {
+	names := make([]string, len(Formats))
+	for index, format := range Formats {
+		names[index] = string(format)
+	}
+	return strings.Join(names, ", ")
+}
+
+func (format Format) ContentType() string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatJSON:
+		return "application/json; charset=utf-8"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
+
+// NeedsCountry is true when the country codes of the bans are part of the output
+func (format Format) NeedsCountry() bool {
+	return format == FormatCSV || format == FormatJSON
+}
+
+// CountryLookup returns the country code of an address
+type CountryLookup func(address string) (string, bool)
+
+// Collect gathers the bans of all jails matching the options, the country codes are only looked up
+// when they are filtered or part of the format
+func Collect(jails []store.Jail, lookup CountryLookup, format Format, options Options) []client.BanEntry {
+	banned := make([]client.BanEntry, 0)
+	for _, jail := range jails {
+		banned = append(banned, jail.BannedEntries...)
+	}
+	if lookup != nil && (options.CountryCode != "" || format.NeedsCountry()) {
+		for index := range banned {
+			countryCode, exists := lookup(banned[index].Address)
+			if !exists {
+				countryCode = "unknown"
+			}
+			banned[index].CountryCode = countryCode
+		}
+	}
+	return Filter(banned, options)
+}
+
+// Filter keeps the bans matching the jail and country code of the options
+func Filter(banned []client.BanEntry, options Options) []client.BanEntry {
+	result := make([]client.BanEntry, 0, len(banned))
+	for _, ban := range banned {
+		if options.JailName != "" && ban.JailName != options.JailName {
+			continue
+		}
+		if options.CountryCode != "" && !strings.EqualFold(ban.CountryCode, options.CountryCode) {
+			continue
+		}
+		result = append(result, ban)
+	}
+	return result
+}
+
+// Write renders the bans in the format, the bans must already be filtered,
+// firewall formats contain every address only once even when it is banned in several jails
+func Write(writer io.Writer, format Format, banned []client.BanEntry, options Options) error {
+	if err := options.Validate(); err != nil {
+		return err
+	}
+	name := options.Name
+	if name == "" {
+		name = DefaultName
+	}
+
+	switch format {
+	case FormatText:
+		return writeLines(writer, "", uniqueAddresses(banned), "")
+	case FormatCSV:
+		return writeCSV(writer, banned)
+	case FormatJSON:
+		return writeJSON(writer, banned)
+	case FormatNginx:
+		return writeLines(writer, "deny ", uniqueAddresses(banned), ";")
+	case FormatIPTables:
+		return writeIPTables(writer, name, uniqueAddresses(banned))
+	case FormatIPSet:
+		return writeIPSet(writer, name, uniqueAddresses(banned))
+	case FormatCIDR:
+		return writeLines(writer, "", Aggregate(uniqueAddresses(banned)), "")
+	default:
+		return fmt.Errorf("unknown export format %s", format)
+	}
+}
+
+func writeLines(writer io.Writer, prefix string, lines []string, suffix string) error {
+	for _, line := range lines {
+		if _, err := fmt.Fprintf(writer, "%s%s%s\n", prefix, line, suffix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeCSV(writer io.Writer, banned []client.BanEntry) error {
+	csvWriter := csv.NewWriter(writer)
+	err := csvWriter.Write([]string{"address", "jail", "country", "bannedAt", "banEndsAt", "penalty"})
+	if err != nil {
+		return err
+	}
+	for _, ban := range sortedBans(banned) {
+		err = csvWriter.Write([]string{
+			ban.Address,
+			ban.JailName,
+			ban.CountryCode,
+			ban.BannedAt.Format(time.RFC3339),
+			ban.BanEndsAt.Format(time.RFC3339),
+			ban.CurrenPenalty,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+func writeJSON(writer io.Writer, banned []client.BanEntry) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sortedBans(banned))
+}
+
+// writeIPTables renders a chain for iptables-restore --noflush, declaring the chain flushes it,
+// IPv6 addresses are skipped as they need ip6tables
+func writeIPTables(writer io.Writer, name string, addresses []string) error {
+	lines := []string{"*filter", fmt.Sprintf(":%s - [0:0]", name)}
+	for _, address := range addresses {
+		if isIPv6(address) {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("-A %s -s %s -j DROP", name, address))
+	}
+	lines = append(lines, "COMMIT")
+	return writeLines(writer, "", lines, "")
+}
+
+// writeIPSet renders sets for ipset restore, IPv6 addresses go into a second set with the suffix 6
+func writeIPSet(writer io.Writer, name string, addresses []string) error {
+	lines := []string{
+		fmt.Sprintf("create %s hash:net family inet -exist", name),
+		fmt.Sprintf("create %s6 hash:net family inet6 -exist", name),
+		fmt.Sprintf("flush %s", name),
+		fmt.Sprintf("flush %s6", name),
+	}
+	for _, address := range addresses {
+		setName := name
+		if isIPv6(address) {
+			setName += "6"
+		}
+		lines = append(lines, fmt.Sprintf("add %s %s -exist", setName, address))
+	}
+	return writeLines(writer, "", lines, "")
+}
+
+// Aggregate merges addresses and ranges into the smallest list of CIDR ranges covering them,
+// values which are neither addresses nor ranges are dropped
+func Aggregate(addresses []string) []string {
+	prefixes := make([]netip.Prefix, 0, len(addresses))
+	for _, address := range addresses {
+		if prefix, ok := toPrefix(address); ok {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+
+	for merged := true; merged; {
+		sortPrefixes(prefixes)
+		prefixes, merged = mergePrefixes(prefixes)
+	}
+
+	result := make([]string, len(prefixes))
+	for index, prefix := range prefixes {
+		result[index] = prefix.String()
+	}
+	return result
+}
+
+// mergePrefixes drops ranges inside the previous range and joins neighbours which form a larger range,
+// the prefixes must be sorted
+func mergePrefixes(prefixes []netip.Prefix) ([]netip.Prefix, bool) {
+	merged := false
+	result := make([]netip.Prefix, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		if len(result) == 0 {
+			result = append(result, prefix)
+			continue
+		}
+		last := result[len(result)-1]
+		if last.Overlaps(prefix) && last.Bits() <= prefix.Bits() {
+			merged = true
+			continue
+		}
+		if last.Bits() == prefix.Bits() && last.Bits() > 0 {
+			parent, _ := last.Addr().Prefix(last.Bits() - 1)
+			if parent.Contains(prefix.Addr()) {
+				result[len(result)-1] = parent
+				merged = true
+				continue
+			}
+		}
+		result = append(result, prefix)
+	}
+	return result, merged
+}
+
+func sortPrefixes(prefixes []netip.Prefix) {
+	sort.Slice(prefixes, func(i, j int) bool {
+		if compared := prefixes[i].Addr().Compare(prefixes[j].Addr()); compared != 0 {
+			return compared < 0
+		}
+		return prefixes[i].Bits() < prefixes[j].Bits()
+	})
+}
+
+func toPrefix(address string) (netip.Prefix, bool) {
+	if addr, err := netip.ParseAddr(address); err == nil {
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), true
+	}
+	if prefix, err := netip.ParsePrefix(address); err == nil {
+		return prefix.Masked(), true
+	}
+	return netip.Prefix{}, false
+}
+
+func isIPv6(address string) bool {
+	prefix, ok := toPrefix(address)
+	return ok && prefix.Addr().Is6()
+}
+
+// uniqueAddresses returns every valid address once, IPv4 before IPv6 and in numeric order,
+// other values are dropped as they would break the firewall formats
+func uniqueAddresses(banned []client.BanEntry) []string {
+	seen := make(map[string]bool)
+	addresses := make([]string, 0, len(banned))
+	for _, ban := range banned {
+		if _, ok := toPrefix(ban.Address); ok && !seen[ban.Address] {
+			seen[ban.Address] = true
+			addresses = append(addresses, ban.Address)
+		}
+	}
+	sort.SliceStable(addresses, func(i, j int) bool {
+		return compareAddresses(addresses[i], addresses[j]) < 0
+	})
+	return addresses
+}
+
+func sortedBans(banned []client.BanEntry) []client.BanEntry {
+	result := make([]client.BanEntry, len(banned))
+	copy(result, banned)
+	sort.SliceStable(result, func(i, j int) bool {
+		if compared := compareAddresses(result[i].Address, result[j].Address); compared != 0 {
+			return compared < 0
+		}
+		return result[i].JailName < result[j].JailName
+	})
+	return result
+}
+
+// compareAddresses orders addresses numerically, values which cannot be parsed are ordered as text after them
+func compareAddresses(first string, second string) int {
+	firstPrefix, firstOk := toPrefix(first)
+	secondPrefix, secondOk := toPrefix(second)
+	switch {
+	case firstOk && secondOk:
+		if compared := firstPrefix.Addr().Compare(secondPrefix.Addr()); compared != 0 {
+			return compared
+		}
+		return firstPrefix.Bits() - secondPrefix.Bits()
+	case firstOk:
+		return -1
+	case secondOk:
+		return 1
+	default:
+		return strings.Compare(first, second)
+	}
+}
@@ -0,0 +1,232 @@
+package export
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+	"github.com/webishdev/fail2ban-dashboard/store"
+)
+
+func createTestBans() []client.BanEntry {
+	bannedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	banEndsAt := bannedAt.Add(10 * time.Minute)
+	return []client.BanEntry{
+		{Address: "192.168.1.2", JailName: "sshd", CountryCode: "DE", CurrenPenalty: "600", BannedAt: bannedAt, BanEndsAt: banEndsAt},
+		{Address: "2001:db8::1", JailName: "sshd", CountryCode: "US", CurrenPenalty: "600", BannedAt: bannedAt, BanEndsAt: banEndsAt},
+		{Address: "10.0.0.1", JailName: "postfix", CountryCode: "unknown", CurrenPenalty: "-1", BannedAt: bannedAt, BanEndsAt: banEndsAt},
+		{Address: "192.168.1.2", JailName: "postfix", CountryCode: "DE", CurrenPenalty: "600", BannedAt: bannedAt, BanEndsAt: banEndsAt},
+		{Address: "1.2.3.4 -j ACCEPT", JailName: "postfix", CountryCode: "unknown", CurrenPenalty: "600", BannedAt: bannedAt, BanEndsAt: banEndsAt},
+	}
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   Format
+		options  Options
+		expected string
+	}{
+		{
+			name:     "text",
+			format:   FormatText,
+			expected: "10.0.0.1\n192.168.1.2\n2001:db8::1\n",
+		},
+		{
+			name:   "csv",
+			format: FormatCSV,
+			expected: "address,jail,country,bannedAt,banEndsAt,penalty\n" +
+				"10.0.0.1,postfix,unknown,2025-01-02T03:04:05Z,2025-01-02T03:14:05Z,-1\n" +
+				"192.168.1.2,postfix,DE,2025-01-02T03:04:05Z,2025-01-02T03:14:05Z,600\n" +
+				"192.168.1.2,sshd,DE,2025-01-02T03:04:05Z,2025-01-02T03:14:05Z,600\n" +
+				"2001:db8::1,sshd,US,2025-01-02T03:04:05Z,2025-01-02T03:14:05Z,600\n" +
+				"1.2.3.4 -j ACCEPT,postfix,unknown,2025-01-02T03:04:05Z,2025-01-02T03:14:05Z,600\n",
+		},
+		{
+			name:     "nginx",
+			format:   FormatNginx,
+			expected: "deny 10.0.0.1;\ndeny 192.168.1.2;\ndeny 2001:db8::1;\n",
+		},
+		{
+			name:   "iptables",
+			format: FormatIPTables,
+			expected: "*filter\n:fail2ban-dashboard - [0:0]\n" +
+				"-A fail2ban-dashboard -s 10.0.0.1 -j DROP\n" +
+				"-A fail2ban-dashboard -s 192.168.1.2 -j DROP\n" +
+				"COMMIT\n",
+		},
+		{
+			name:    "ipset with name",
+			format:  FormatIPSet,
+			options: Options{Name: "blocklist"},
+			expected: "create blocklist hash:net family inet -exist\n" +
+				"create blocklist6 hash:net family inet6 -exist\n" +
+				"flush blocklist\nflush blocklist6\n" +
+				"add blocklist 10.0.0.1 -exist\n" +
+				"add blocklist 192.168.1.2 -exist\n" +
+				"add blocklist6 2001:db8::1 -exist\n",
+		},
+		{
+			name:     "cidr",
+			format:   FormatCIDR,
+			expected: "10.0.0.1/32\n192.168.1.2/32\n2001:db8::1/128\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			err := Write(&sb, tt.format, createTestBans(), tt.options)
+			if err != nil {
+				t.Fatalf("Failed to write %s: %v", tt.format, err)
+			}
+			if sb.String() != tt.expected {
+				t.Errorf("Expected\n%s\ngot\n%s", tt.expected, sb.String())
+			}
+		})
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var sb strings.Builder
+	err := Write(&sb, FormatJSON, createTestBans()[:1], Options{})
+	if err != nil {
+		t.Fatalf("Failed to write json: %v", err)
+	}
+	expected := `"address": "192.168.1.2"`
+	if !strings.HasPrefix(sb.String(), "[") || !strings.Contains(sb.String(), expected) {
+		t.Errorf("Expected JSON array containing %s, got %s", expected, sb.String())
+	}
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  Options
+		expected int
+	}{
+		{"everything", Options{}, 5},
+		{"by jail", Options{JailName: "postfix"}, 3},
+		{"by country", Options{CountryCode: "de"}, 2},
+		{"by jail and country", Options{JailName: "sshd", CountryCode: "US"}, 1},
+		{"no match", Options{JailName: "nginx"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := Filter(createTestBans(), tt.options); len(result) != tt.expected {
+				t.Errorf("Expected %d bans, got %d", tt.expected, len(result))
+			}
+		})
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	tests := []struct {
+		name      string
+		addresses []string
+		expected  []string
+	}{
+		{"empty", nil, []string{}},
+		{"single address", []string{"192.168.1.1"}, []string{"192.168.1.1/32"}},
+		{"neighbours", []string{"192.168.1.0", "192.168.1.1"}, []string{"192.168.1.0/31"}},
+		{"not aligned neighbours", []string{"192.168.1.1", "192.168.1.2"}, []string{"192.168.1.1/32", "192.168.1.2/32"}},
+		{"repeated merges", []string{"10.0.0.3", "10.0.0.0", "10.0.0.2", "10.0.0.1"}, []string{"10.0.0.0/30"}},
+		{"contained in range", []string{"10.0.0.0/8", "10.1.2.3", "10.200.0.0/16"}, []string{"10.0.0.0/8"}},
+		{"neighbouring ranges", []string{"10.0.0.0/25", "10.0.0.128/25"}, []string{"10.0.0.0/24"}},
+		{"ipv6", []string{"2001:db8::", "2001:db8::1", "10.0.0.1"}, []string{"10.0.0.1/32", "2001:db8::/127"}},
+		{"duplicates and invalid values", []string{"10.0.0.1", "10.0.0.1", "invalid"}, []string{"10.0.0.1/32"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Aggregate(tt.addresses)
+			if strings.Join(result, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, format := range Formats {
+		parsed, err := ParseFormat(string(format))
+		if err != nil || parsed != format {
+			t.Errorf("Expected %s to be parsed, got %s and %v", format, parsed, err)
+		}
+	}
+
+	_, err := ParseFormat("pf")
+	if err == nil || !strings.Contains(err.Error(), "iptables") {
+		t.Errorf("Expected error listing the supported formats, got %v", err)
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		valid   bool
+	}{
+		{"default name", Options{}, true},
+		{"custom name", Options{Name: "f2b_blocklist-1"}, true},
+		{"too long", Options{Name: strings.Repeat("a", 29)}, false},
+		{"line break", Options{Name: "chain\n-A INPUT -j ACCEPT"}, false},
+		{"space", Options{Name: "my chain"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.options.Validate()
+			if (err == nil) != tt.valid {
+				t.Errorf("Expected valid %t, got %v", tt.valid, err)
+			}
+			if !tt.valid && Write(&strings.Builder{}, FormatIPTables, nil, tt.options) == nil {
+				t.Error("Expected Write to reject the options")
+			}
+		})
+	}
+}
+
+func TestCollect(t *testing.T) {
+	jails := []store.Jail{
+		{Name: "sshd", BannedEntries: createTestBans()[:2]},
+		{Name: "postfix", BannedEntries: createTestBans()[2:]},
+	}
+	lookups := 0
+	lookup := func(address string) (string, bool) {
+		lookups++
+		if address == "10.0.0.1" {
+			return "", false
+		}
+		return "NL", true
+	}
+
+	tests := []struct {
+		name            string
+		format          Format
+		options         Options
+		expected        int
+		expectedLookups int
+	}{
+		{"without country", FormatText, Options{}, 5, 0},
+		{"by jail", FormatNginx, Options{JailName: "sshd"}, 2, 0},
+		{"country in format", FormatCSV, Options{}, 5, 5},
+		{"by country", FormatText, Options{CountryCode: "nl"}, 4, 5},
+		{"unknown country", FormatText, Options{CountryCode: "unknown"}, 1, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookups = 0
+			result := Collect(jails, lookup, tt.format, tt.options)
+			if len(result) != tt.expected {
+				t.Errorf("Expected %d bans, got %d", tt.expected, len(result))
+			}
+			if lookups != tt.expectedLookups {
+				t.Errorf("Expected %d lookups, got %d", tt.expectedLookups, lookups)
+			}
+		})
+	}
+}
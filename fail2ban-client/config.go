@@ -0,0 +1,197 @@
+package fail2ban_client
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v3/log"
+	"github.com/nlpodyssey/gopickle/types"
+)
+
+// JailConfig is the configuration fail2ban uses for a jail, times are in seconds
+type JailConfig struct {
+	BanTime          int              `json:"banTime"`
+	FindTime         int              `json:"findTime"`
+	MaxRetry         int              `json:"maxRetry"`
+	LogPaths         []string         `json:"logPaths"`
+	FailRegex        []string         `json:"failRegex"`
+	IgnoreRegex      []string         `json:"ignoreRegex"`
+	IgnoreIPs        []string         `json:"ignoreIPs"`
+	Actions          []string         `json:"actions"`
+	UseDNS           string           `json:"useDNS"`
+	BanTimeIncrement BanTimeIncrement `json:"banTimeIncrement"`
+}
+
+// BanTimeIncrement are the bantime.* settings which increase the ban time of repeated offenders,
+// the other settings are only read when the increment is enabled
+type BanTimeIncrement struct {
+	Enabled      bool   `json:"enabled"`
+	Factor       string `json:"factor,omitempty"`
+	Formula      string `json:"formula,omitempty"`
+	Multipliers  string `json:"multipliers,omitempty"`
+	MaxTime      string `json:"maxTime,omitempty"`
+	RndTime      string `json:"rndTime,omitempty"`
+	OverallJails bool   `json:"overallJails"`
+}
+
+// GetJailConfig reads the configuration of the jail, settings refused by fail2ban are left empty
+// as older versions don't know all of them, only a refused bantime means the jail does not exist
+func (f2bc *Fail2BanClient) GetJailConfig(jailName string) (*JailConfig, error) {
+	log.Tracef("GetJailConfig: Fetching configuration for jail '%s'", jailName)
+	config := &JailConfig{}
+
+	banTime, err := f2bc.getSetting(jailName, "bantime")
+	if err != nil {
+		log.Errorf("GetJailConfig: Failed to get bantime for jail '%s': %v", jailName, err)
+		return nil, err
+	}
+	config.BanTime = intSetting(banTime)
+
+	settings := []jailSetting{
+		{"findtime", func(value interface{}) { config.FindTime = intSetting(value) }},
+		{"maxretry", func(value interface{}) { config.MaxRetry = intSetting(value) }},
+		{"logpath", func(value interface{}) { config.LogPaths = listSetting(value) }},
+		{"failregex", func(value interface{}) { config.FailRegex = listSetting(value) }},
+		{"ignoreregex", func(value interface{}) { config.IgnoreRegex = listSetting(value) }},
+		{"ignoreip", func(value interface{}) { config.IgnoreIPs = listSetting(value) }},
+		{"actions", func(value interface{}) { config.Actions = listSetting(value) }},
+		{"usedns", func(value interface{}) { config.UseDNS = stringSetting(value) }},
+		{"bantime.increment", func(value interface{}) { config.BanTimeIncrement.Enabled = boolSetting(value) }},
+	}
+	if err = f2bc.applySettings(jailName, settings); err != nil {
+		return nil, err
+	}
+
+	if config.BanTimeIncrement.Enabled {
+		increment := &config.BanTimeIncrement
+		err = f2bc.applySettings(jailName, []jailSetting{
+			{"bantime.factor", func(value interface{}) { increment.Factor = stringSetting(value) }},
+			{"bantime.formula", func(value interface{}) { increment.Formula = stringSetting(value) }},
+			{"bantime.multipliers", func(value interface{}) { increment.Multipliers = stringSetting(value) }},
+			{"bantime.maxtime", func(value interface{}) { increment.MaxTime = stringSetting(value) }},
+			{"bantime.rndtime", func(value interface{}) { increment.RndTime = stringSetting(value) }},
+			{"bantime.overalljails", func(value interface{}) { increment.OverallJails = boolSetting(value) }},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	log.Debugf("GetJailConfig: Successfully retrieved configuration for jail '%s'", jailName)
+	return config, nil
+}
+
+// jailSetting stores the value of a setting in the configuration
+type jailSetting struct {
+	name  string
+	apply func(value interface{})
+}
+
+func (f2bc *Fail2BanClient) applySettings(jailName string, settings []jailSetting) error {
+	for _, setting := range settings {
+		value, err := f2bc.getSetting(jailName, setting.name)
+		if err == nil {
+			setting.apply(value)
+			continue
+		}
+		if _, refused := errors.AsType[*settingRefusedError](err); !refused {
+			log.Errorf("GetJailConfig: Failed to get %s for jail '%s': %v", setting.name, jailName, err)
+			return err
+		}
+		log.Debugf("GetJailConfig: fail2ban refused %s for jail '%s': %v", setting.name, jailName, err)
+	}
+	return nil
+}
+
+// settingRefusedError is returned when fail2ban answered but refused to return the setting
+type settingRefusedError struct {
+	cause error
+}
+
+func (e *settingRefusedError) Error() string {
+	return e.cause.Error()
+}
+
+func (f2bc *Fail2BanClient) getSetting(jailName string, setting string) (interface{}, error) {
+	result, err := f2bc.sendCommand([]string{getCommand, jailName, setting})
+	if err != nil {
+		return nil, err
+	}
+	value, responseErr := responseValue(result)
+	if responseErr != nil {
+		return nil, &settingRefusedError{cause: responseErr}
+	}
+	return value, nil
+}
+
+func intSetting(value interface{}) int {
+	switch typed := value.(type) {
+	case int:
+		return typed
+	case int64:
+		return int(typed)
+	case float64:
+		return int(typed)
+	case string:
+		parsed, _ := strconv.Atoi(typed)
+		return parsed
+	}
+	return 0
+}
+
+func boolSetting(value interface{}) bool {
+	switch typed := value.(type) {
+	case bool:
+		return typed
+	case int:
+		return typed != 0
+	case string:
+		parsed, _ := strconv.ParseBool(typed)
+		return parsed
+	}
+	return false
+}
+
+func stringSetting(value interface{}) string {
+	switch typed := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return typed
+	case float64:
+		return strconv.FormatFloat(typed, 'g', -1, 64)
+	case *types.List, *types.Tuple:
+		return strings.Join(listSetting(typed), ", ")
+	}
+	return fmt.Sprint(value)
+}
+
+// listSetting converts a list of values, nested lists are flattened
+func listSetting(value interface{}) []string {
+	result := make([]string, 0)
+	var length int
+	var get func(index int) interface{}
+	switch typed := value.(type) {
+	case *types.List:
+		length, get = typed.Len(), typed.Get
+	case *types.Tuple:
+		length, get = typed.Len(), typed.Get
+	case nil:
+		return result
+	default:
+		return append(result, stringSetting(value))
+	}
+	for index := 0; index < length; index++ {
+		switch item := get(index).(type) {
+		case *types.List, *types.Tuple:
+			result = append(result, listSetting(item)...)
+		default:
+			if text := stringSetting(item); text != "" {
+				result = append(result, text)
+			}
+		}
+	}
+	return result
+}
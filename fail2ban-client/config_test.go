@@ -0,0 +1,144 @@
+package fail2ban_client
+
+import (
+	"bytes"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	ogórek "github.com/kisielk/og-rek"
+)
+
+// serveResponses answers each command with the response for the command joined by spaces,
+// unknown commands are refused like fail2ban does for unsupported settings
+func serveResponses(t *testing.T, listener net.Listener, responses map[string]interface{}) {
+	t.Helper()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer func() { _ = conn.Close() }()
+				buf := make([]byte, socketReadBufferSize)
+				var data []byte
+				for {
+					n, readErr := conn.Read(buf)
+					if readErr != nil {
+						return
+					}
+					data = append(data, buf[:n]...)
+					end := bytes.Index(data, []byte(commandTerminator))
+					if end < 0 {
+						continue
+					}
+					decoded, decodeErr := ogórek.NewDecoder(bytes.NewReader(data[:end])).Decode()
+					data = nil
+					if decodeErr != nil {
+						return
+					}
+					parts := make([]string, 0)
+					for _, part := range decoded.([]interface{}) {
+						parts = append(parts, part.(string))
+					}
+					response, exists := responses[strings.Join(parts, " ")]
+					if !exists {
+						response = ogórek.Tuple{1, &ogórek.Call{
+							Callable: ogórek.Class{Module: "builtins", Name: "KeyError"},
+							Args:     ogórek.Tuple{"unknown setting"},
+						}}
+					}
+					_, _ = conn.Write(createPickleData(response))
+				}
+			}(conn)
+		}
+	}()
+}
+
+func TestFail2BanClient_GetJailConfig(t *testing.T) {
+	baseResponses := map[string]interface{}{
+		"get sshd bantime":     ogórek.Tuple{0, 600},
+		"get sshd findtime":    ogórek.Tuple{0, 300},
+		"get sshd maxretry":    ogórek.Tuple{0, 5},
+		"get sshd logpath":     ogórek.Tuple{0, []interface{}{"/var/log/auth.log"}},
+		"get sshd failregex":   ogórek.Tuple{0, []interface{}{"^Failed password for .* from <HOST>", "^Invalid user .* from <HOST>"}},
+		"get sshd ignoreregex": ogórek.Tuple{0, []interface{}{}},
+		"get sshd ignoreip":    ogórek.Tuple{0, []interface{}{"127.0.0.1/8", "::1"}},
+		"get sshd actions":     ogórek.Tuple{0, []interface{}{"iptables-multiport"}},
+		"get sshd usedns":      ogórek.Tuple{0, "warn"},
+	}
+
+	tests := []struct {
+		name      string
+		responses map[string]interface{}
+		expected  *JailConfig
+		wantErr   bool
+	}{
+		{
+			name:      "without bantime increment support",
+			responses: baseResponses,
+			expected: &JailConfig{
+				BanTime:     600,
+				FindTime:    300,
+				MaxRetry:    5,
+				LogPaths:    []string{"/var/log/auth.log"},
+				FailRegex:   []string{"^Failed password for .* from <HOST>", "^Invalid user .* from <HOST>"},
+				IgnoreRegex: []string{},
+				IgnoreIPs:   []string{"127.0.0.1/8", "::1"},
+				Actions:     []string{"iptables-multiport"},
+				UseDNS:      "warn",
+			},
+		},
+		{
+			name: "with bantime increment",
+			responses: map[string]interface{}{
+				"get sshd bantime":              ogórek.Tuple{0, -1},
+				"get sshd bantime.increment":    ogórek.Tuple{0, true},
+				"get sshd bantime.factor":       ogórek.Tuple{0, "1"},
+				"get sshd bantime.multipliers":  ogórek.Tuple{0, "1 5 30 60 300 720 1440 2880"},
+				"get sshd bantime.maxtime":      ogórek.Tuple{0, 604800},
+				"get sshd bantime.rndtime":      ogórek.Tuple{0, nil},
+				"get sshd bantime.overalljails": ogórek.Tuple{0, false},
+			},
+			expected: &JailConfig{
+				BanTime: -1,
+				BanTimeIncrement: BanTimeIncrement{
+					Enabled:     true,
+					Factor:      "1",
+					Multipliers: "1 5 30 60 300 720 1440 2880",
+					MaxTime:     "604800",
+				},
+			},
+		},
+		{
+			name:      "unknown jail",
+			responses: map[string]interface{}{},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address := filepath.Join(t.TempDir(), "fail2ban.sock")
+			listener := listenUnix(t, address)
+			serveResponses(t, listener, tt.responses)
+
+			client, err := NewFail2BanClient(address)
+			if err != nil {
+				t.Fatalf("NewFail2BanClient() error = %v", err)
+			}
+			defer func() { _ = client.Close() }()
+
+			config, err := client.GetJailConfig("sshd")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetJailConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(config, tt.expected) {
+				t.Errorf("GetJailConfig() = %+v, want %+v", config, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package fail2ban_client
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/gofiber/fiber/v3/log"
+	ogórek "github.com/kisielk/og-rek"
+)
+
+const (
+	commandTimeout    = 10 * time.Second
+	minReconnectDelay = 1 * time.Second
+	maxReconnectDelay = 1 * time.Minute
+	// poolSize is how many additional connections are opened for commands sent while the main connection is busy
+	poolSize = 3
+)
+
+// ErrNotConnected is returned for commands while the client waits for the socket to come back
+var ErrNotConnected = errors.New("not connected to fail2ban socket")
+
+// ConnectionState describes the connection to the fail2ban socket,
+// Since is the time the connection was established or lost and Warning a problem of the connected fail2ban
+type ConnectionState struct {
+	Connected bool      `json:"connected"`
+	Since     time.Time `json:"since"`
+	LastError string    `json:"lastError,omitempty"`
+	Warning   string    `json:"warning,omitempty"`
+}
+
+type StateHandler func(state ConnectionState)
+
+// ConnectionState returns the current connection state without waiting for a running command
+func (f2bc *Fail2BanClient) ConnectionState() ConnectionState {
+	f2bc.stateMutex.RLock()
+	defer f2bc.stateMutex.RUnlock()
+	return f2bc.state
+}
+
+// SetWarning reports a problem of the connected fail2ban, like an unsupported version,
+// the warning is cleared when the connection changes
+func (f2bc *Fail2BanClient) SetWarning(warning string) {
+	f2bc.stateMutex.Lock()
+	defer f2bc.stateMutex.Unlock()
+	if f2bc.state.Connected {
+		f2bc.state.Warning = warning
+	}
+}
+
+// RegisterStateHandler adds a handler called each time the client connects or loses the connection
+func (f2bc *Fail2BanClient) RegisterStateHandler(handler StateHandler) {
+	f2bc.stateMutex.Lock()
+	defer f2bc.stateMutex.Unlock()
+	f2bc.stateHandlers = append(f2bc.stateHandlers, handler)
+}
+
+// Close stops reconnecting and closes the socket
+func (f2bc *Fail2BanClient) Close() error {
+	f2bc.stateMutex.Lock()
+	if f2bc.done != nil {
+		close(f2bc.done)
+		f2bc.done = nil
+	}
+	f2bc.stateMutex.Unlock()
+
+	for _, pooled := range f2bc.pooled {
+		_ = pooled.Close()
+	}
+	if f2bc.fixture != nil {
+		_ = f2bc.fixture.Close()
+		f2bc.fixture = nil
+	}
+
+	f2bc.mutex.Lock()
+	defer f2bc.mutex.Unlock()
+	if f2bc.socket == nil {
+		return nil
+	}
+	err := f2bc.socket.Close()
+	f2bc.socket = nil
+	f2bc.encoder = nil
+	return err
+}
+
+// connect dials the socket, the caller must hold the command mutex
+func (f2bc *Fail2BanClient) connect() error {
+	if f2bc.dial == nil {
+		return ErrNotConnected
+	}
+	socket, err := f2bc.dial()
+	if err != nil {
+		return err
+	}
+	f2bc.socket = socket
+	f2bc.encoder = ogórek.NewEncoder(socket)
+	f2bc.setState(ConnectionState{Connected: true, Since: time.Now()})
+	return nil
+}
+
+// disconnect closes a broken socket, the caller must hold the command mutex
+func (f2bc *Fail2BanClient) disconnect(cause error) {
+	if f2bc.socket != nil {
+		_ = f2bc.socket.Close()
+	}
+	f2bc.socket = nil
+	f2bc.encoder = nil
+
+	f2bc.stateMutex.RLock()
+	wasConnected := f2bc.state.Connected
+	f2bc.stateMutex.RUnlock()
+	if wasConnected {
+		log.Errorf("Lost connection to fail2ban socket: %v", cause)
+		f2bc.setState(ConnectionState{Connected: false, Since: time.Now(), LastError: cause.Error()})
+	}
+}
+
+// reconnect dials the socket in the background with exponential backoff until it succeeds or the client is closed
+func (f2bc *Fail2BanClient) reconnect() {
+	f2bc.stateMutex.Lock()
+	if f2bc.reconnecting || f2bc.dial == nil || f2bc.done == nil {
+		f2bc.stateMutex.Unlock()
+		return
+	}
+	f2bc.reconnecting = true
+	done := f2bc.done
+	f2bc.stateMutex.Unlock()
+
+	go func() {
+		delay := minReconnectDelay
+		for {
+			select {
+			case <-done:
+				f2bc.setReconnecting(false)
+				return
+			case <-time.After(delay):
+			}
+
+			f2bc.mutex.Lock()
+			var err error
+			if f2bc.socket == nil {
+				err = f2bc.connect()
+			}
+			if err == nil {
+				f2bc.setReconnecting(false)
+				f2bc.mutex.Unlock()
+				log.Info("Reconnected to fail2ban socket")
+				return
+			}
+			f2bc.mutex.Unlock()
+
+			delay = min(delay*2, maxReconnectDelay)
+			log.Debugf("Reconnect to fail2ban socket failed, next attempt in %s: %v", delay, err)
+			f2bc.stateMutex.Lock()
+			f2bc.state.LastError = err.Error()
+			f2bc.stateMutex.Unlock()
+		}
+	}()
+}
+
+func (f2bc *Fail2BanClient) setReconnecting(reconnecting bool) {
+	f2bc.stateMutex.Lock()
+	defer f2bc.stateMutex.Unlock()
+	f2bc.reconnecting = reconnecting
+}
+
+func (f2bc *Fail2BanClient) setState(state ConnectionState) {
+	f2bc.stateMutex.Lock()
+	f2bc.state = state
+	handlers := f2bc.stateHandlers
+	f2bc.stateMutex.Unlock()
+
+	for _, handler := range handlers {
+		go handler(state)
+	}
+}
+
+// sendPooled sends the command over an idle connection of the pool, used is false when no connection
+// could be taken or opened and the main connection has to send the command, a failed command is not
+// sent again as fail2ban may have executed it already
+func (f2bc *Fail2BanClient) sendPooled(command []string) (result interface{}, used bool, err error) {
+	if f2bc.pool == nil {
+		return nil, false, nil
+	}
+	f2bc.stateMutex.RLock()
+	usable := f2bc.state.Connected && f2bc.done != nil
+	f2bc.stateMutex.RUnlock()
+	if !usable {
+		return nil, false, nil
+	}
+
+	var pooled *Fail2BanClient
+	select {
+	case pooled = <-f2bc.pool:
+	default:
+		return nil, false, nil
+	}
+	defer func() { f2bc.pool <- pooled }()
+
+	pooled.mutex.Lock()
+	defer pooled.mutex.Unlock()
+	pooled.timeout = f2bc.timeout
+	if pooled.socket == nil {
+		if connectErr := pooled.connect(); connectErr != nil {
+			log.Debugf("Could not open additional connection to fail2ban socket: %v", connectErr)
+			return nil, false, nil
+		}
+	}
+	result, _, err = pooled.exchange(command)
+	if err != nil {
+		_ = pooled.socket.Close()
+		pooled.socket = nil
+		pooled.encoder = nil
+		return nil, true, err
+	}
+	return result, true, nil
+}
+
+// setDeadline limits the time a single command may take, a hanging fail2ban must not block the dashboard
+func (f2bc *Fail2BanClient) setDeadline() error {
+	timeout := f2bc.timeout
+	if timeout <= 0 {
+		timeout = commandTimeout
+	}
+	return f2bc.socket.SetDeadline(time.Now().Add(timeout))
+}
+
+func dialer(address string) func() (net.Conn, error) {
+	return func() (net.Conn, error) {
+		return net.DialTimeout("unix", address, commandTimeout)
+	}
+}
@@ -0,0 +1,288 @@
+package fail2ban_client
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ogórek "github.com/kisielk/og-rek"
+	"github.com/webishdev/fail2ban-dashboard/fail2bantest"
+)
+
+// serveVersion answers each command on the socket with a version response after the delay,
+// the first dropFirst connections are closed right away like a restarting fail2ban would do
+func serveVersion(t *testing.T, listener net.Listener, dropFirst int32, silent bool, delay time.Duration) *atomic.Int32 {
+	t.Helper()
+	var accepted atomic.Int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			if accepted.Add(1) <= dropFirst {
+				_ = conn.Close()
+				continue
+			}
+			go func(conn net.Conn) {
+				defer func() { _ = conn.Close() }()
+				buf := make([]byte, socketReadBufferSize)
+				var data []byte
+				for {
+					n, readErr := conn.Read(buf)
+					if readErr != nil {
+						return
+					}
+					data = append(data, buf[:n]...)
+					if !bytes.Contains(data, []byte(commandTerminator)) {
+						continue
+					}
+					data = nil
+					if silent {
+						continue
+					}
+					time.Sleep(delay)
+					_, _ = conn.Write(createPickleData(ogórek.Tuple{0, "1.1.0"}))
+				}
+			}(conn)
+		}
+	}()
+	return &accepted
+}
+
+func listenUnix(t *testing.T, address string) net.Listener {
+	t.Helper()
+	listener, err := net.Listen("unix", address)
+	if err != nil {
+		t.Fatalf("Failed to listen on %s: %v", address, err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	return listener
+}
+
+func TestFail2BanClient_RedialBrokenSocket(t *testing.T) {
+	address := filepath.Join(t.TempDir(), "fail2ban.sock")
+	listener := listenUnix(t, address)
+	accepted := serveVersion(t, listener, 1, false, 0)
+
+	client, err := NewFail2BanClient(address)
+	if err != nil {
+		t.Fatalf("NewFail2BanClient() error = %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	version, err := client.GetVersion()
+	if err != nil {
+		t.Fatalf("GetVersion() should redial transparently, error = %v", err)
+	}
+	if version != "1.1.0" {
+		t.Errorf("GetVersion() = %s, want 1.1.0", version)
+	}
+	if accepted.Load() != 2 {
+		t.Errorf("Expected 2 connections, got %d", accepted.Load())
+	}
+	if !client.ConnectionState().Connected {
+		t.Error("ConnectionState() should be connected")
+	}
+}
+
+func TestFail2BanClient_NoResendAfterFailedRead(t *testing.T) {
+	address := filepath.Join(t.TempDir(), "fail2ban.sock")
+	listener := listenUnix(t, address)
+	// fail2ban receives each command and closes the connection before answering
+	var received atomic.Int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer func() { _ = conn.Close() }()
+				buf := make([]byte, socketReadBufferSize)
+				var data []byte
+				for !bytes.Contains(data, []byte(commandTerminator)) {
+					n, readErr := conn.Read(buf)
+					if readErr != nil {
+						return
+					}
+					data = append(data, buf[:n]...)
+				}
+				received.Add(1)
+			}(conn)
+		}
+	}()
+
+	client, err := NewFail2BanClient(address)
+	if err != nil {
+		t.Fatalf("NewFail2BanClient() error = %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if err = client.Ban("sshd", "203.0.113.5"); err == nil {
+		t.Error("Ban() should fail when the response is missing")
+	}
+	if received.Load() != 1 {
+		t.Errorf("Expected the ban to be sent once, fail2ban received it %d times", received.Load())
+	}
+
+	received.Store(0)
+	if _, err = client.GetVersion(); err == nil {
+		t.Error("GetVersion() should fail when the response is missing")
+	}
+	if received.Load() != 2 {
+		t.Errorf("Expected the version command to be sent again, fail2ban received it %d times", received.Load())
+	}
+}
+
+func TestFail2BanClient_ReconnectWhenSocketAppears(t *testing.T) {
+	address := filepath.Join(t.TempDir(), "fail2ban.sock")
+
+	client, err := NewFail2BanClient(address)
+	if err == nil {
+		t.Fatal("NewFail2BanClient() should fail without socket")
+	}
+	defer func() { _ = client.Close() }()
+
+	connected := make(chan ConnectionState, 1)
+	client.RegisterStateHandler(func(state ConnectionState) {
+		connected <- state
+	})
+
+	state := client.ConnectionState()
+	if state.Connected || state.Since.IsZero() || state.LastError == "" {
+		t.Errorf("Expected reconnecting state with error, got %+v", state)
+	}
+
+	listener := listenUnix(t, address)
+	serveVersion(t, listener, 0, false, 0)
+
+	select {
+	case state = <-connected:
+		if !state.Connected {
+			t.Errorf("Expected connected state, got %+v", state)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Client did not reconnect")
+	}
+
+	if _, err = client.GetVersion(); err != nil {
+		t.Errorf("GetVersion() error = %v", err)
+	}
+}
+
+func TestFail2BanClient_CommandDeadline(t *testing.T) {
+	address := filepath.Join(t.TempDir(), "fail2ban.sock")
+	listener := listenUnix(t, address)
+	serveVersion(t, listener, 0, true, 0)
+
+	client, err := NewFail2BanClient(address)
+	if err != nil {
+		t.Fatalf("NewFail2BanClient() error = %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	client.timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	_, err = client.GetVersion()
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("GetVersion() error = %v, want timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("GetVersion() took %s, deadline was not applied", elapsed)
+	}
+	state := client.ConnectionState()
+	if state.Connected || state.LastError == "" {
+		t.Errorf("Expected reconnecting state after timeout, got %+v", state)
+	}
+}
+
+func TestFail2BanClient_ConcurrentCommands(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	address := filepath.Join(t.TempDir(), "fail2ban.sock")
+	listener := listenUnix(t, address)
+	accepted := serveVersion(t, listener, 0, false, delay)
+
+	client, err := NewFail2BanClient(address)
+	if err != nil {
+		t.Fatalf("NewFail2BanClient() error = %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	const commands = 1 + poolSize
+	start := time.Now()
+	var wg sync.WaitGroup
+	errs := make(chan error, commands)
+	for range commands {
+		wg.Go(func() {
+			if _, versionErr := client.GetVersion(); versionErr != nil {
+				errs <- versionErr
+			}
+		})
+	}
+	wg.Wait()
+	close(errs)
+	for versionErr := range errs {
+		t.Errorf("GetVersion() error = %v", versionErr)
+	}
+
+	if elapsed := time.Since(start); elapsed >= commands*delay {
+		t.Errorf("%d commands took %s, they were not sent concurrently", commands, elapsed)
+	}
+	if connections := accepted.Load(); connections > commands {
+		t.Errorf("Expected at most %d connections, got %d", commands, connections)
+	}
+
+	// a single command uses the main connection
+	before := accepted.Load()
+	if _, err = client.GetVersion(); err != nil {
+		t.Fatalf("GetVersion() error = %v", err)
+	}
+	if accepted.Load() != before {
+		t.Error("GetVersion() opened another connection while the main connection was idle")
+	}
+}
+
+func TestFail2BanClient_NoFallbackAfterPooledCommand(t *testing.T) {
+	fake := fail2bantest.Start(t, fail2bantest.Version1_1)
+	fake.AddJail(fail2bantest.Jail{Name: "sshd", BanTime: 600})
+	fake.SetLatency(200 * time.Millisecond)
+
+	client, err := NewFail2BanClient(fake.Address())
+	if err != nil {
+		t.Fatalf("NewFail2BanClient() error = %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	// the main connection is busy, the ban is sent over a connection of the pool
+	busy := make(chan error, 1)
+	go func() {
+		_, versionErr := client.GetVersion()
+		busy <- versionErr
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	fake.DropCommand("set sshd banip", true)
+	if err = client.Ban("sshd", "203.0.113.5"); err == nil {
+		t.Error("Ban() should fail when the pooled connection is dropped")
+	}
+	if versionErr := <-busy; versionErr != nil {
+		t.Errorf("GetVersion() error = %v", versionErr)
+	}
+
+	sent := 0
+	for _, command := range fake.Commands() {
+		if command == "set sshd banip 203.0.113.5" {
+			sent++
+		}
+	}
+	if sent != 1 {
+		t.Errorf("Expected the ban to be sent once, fail2ban received it %d times", sent)
+	}
+}
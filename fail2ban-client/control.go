@@ -0,0 +1,143 @@
+package fail2ban_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3/log"
+)
+
+const (
+	defaultExecutable = "fail2ban-client"
+	reloadTimeout     = 2 * time.Minute
+)
+
+// jailNamePattern matches the names fail2ban accepts for jails, names are also passed
+// as arguments to fail2ban-client and must not look like an option
+var jailNamePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._@-]*$`)
+
+// ValidJailName checks the name before it is sent to fail2ban
+func ValidJailName(jailName string) bool {
+	return len(jailName) <= 100 && jailNamePattern.MatchString(jailName)
+}
+
+// ErrReloadUnavailable is returned by reloads when the fail2ban-client command was not found
+var ErrReloadUnavailable = errors.New("reloading jails is not available")
+
+// SetExecutable changes the fail2ban-client command used for reloads, arguments like in
+// "sudo fail2ban-client" are separated by spaces. The error tells that the command was not found,
+// reloads are not available then while starting and stopping jails still works over the socket
+func (f2bc *Fail2BanClient) SetExecutable(executable string) error {
+	if strings.TrimSpace(executable) == "" {
+		executable = defaultExecutable
+	}
+	f2bc.executable = executable
+	f2bc.executableErr = lookupExecutable(executable)
+	return f2bc.executableErr
+}
+
+// ReloadAvailable tells if the fail2ban-client command was found
+func (f2bc *Fail2BanClient) ReloadAvailable() bool {
+	return f2bc.executableErr == nil
+}
+
+// lookupExecutable checks that the command of the executable exists
+func lookupExecutable(executable string) error {
+	command := strings.Fields(executable)
+	if len(command) == 0 {
+		command = []string{defaultExecutable}
+	}
+	if _, err := exec.LookPath(command[0]); err != nil {
+		return fmt.Errorf("%w: %w", ErrReloadUnavailable, err)
+	}
+	return nil
+}
+
+// StartJail starts a jail which is known to fail2ban but not running
+func (f2bc *Fail2BanClient) StartJail(jailName string) (string, error) {
+	return f2bc.jailCommand(startCommand, jailName)
+}
+
+// StopJail stops the jail, fail2ban removes stopped jails until they are reloaded
+func (f2bc *Fail2BanClient) StopJail(jailName string) (string, error) {
+	return f2bc.jailCommand(stopCommand, jailName)
+}
+
+// ReloadJail reloads the configuration of the jail and starts it when it was stopped
+func (f2bc *Fail2BanClient) ReloadJail(jailName string) (string, error) {
+	if !ValidJailName(jailName) {
+		return "", fmt.Errorf("invalid jail name %q", jailName)
+	}
+	return f2bc.reload(jailName)
+}
+
+// Reload reloads the configuration of fail2ban and all jails
+func (f2bc *Fail2BanClient) Reload() (string, error) {
+	return f2bc.reload()
+}
+
+func (f2bc *Fail2BanClient) jailCommand(command string, jailName string) (string, error) {
+	if !ValidJailName(jailName) {
+		return "", fmt.Errorf("invalid jail name %q", jailName)
+	}
+	log.Tracef("%s: Sending %s for jail '%s'", command, command, jailName)
+	result, err := f2bc.sendCommand([]string{command, jailName})
+	if err != nil {
+		log.Errorf("%s: Failed to %s jail '%s': %v", command, command, jailName, err)
+		return "", err
+	}
+
+	value, responseErr := responseValue(result)
+	if responseErr != nil {
+		log.Errorf("%s: fail2ban refused to %s jail '%s': %v", command, command, jailName, responseErr)
+		return "", responseErr
+	}
+
+	log.Debugf("%s: Successfully sent %s for jail '%s'", command, command, jailName)
+	if value == nil {
+		return "OK", nil
+	}
+	return stringSetting(value), nil
+}
+
+// reload runs fail2ban-client as the configuration is read and sent to the server by the client,
+// a plain reload over the socket would stop every jail missing from the configuration
+func (f2bc *Fail2BanClient) reload(jailNames ...string) (string, error) {
+	if f2bc.executableErr != nil {
+		return "", f2bc.executableErr
+	}
+	command := strings.Fields(f2bc.executable)
+	if len(command) == 0 {
+		command = []string{defaultExecutable}
+	}
+	arguments := append(command[1:], "-s", f2bc.address, reloadCommand)
+	arguments = append(arguments, jailNames...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reloadTimeout)
+	defer cancel()
+
+	log.Tracef("reload: Running %s %s", command[0], strings.Join(arguments, " "))
+	output, err := exec.CommandContext(ctx, command[0], arguments...).CombinedOutput()
+	response := strings.TrimSpace(string(output))
+	if err != nil {
+		log.Errorf("reload: Failed to reload %v: %v %s", jailNames, err, response)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return response, fmt.Errorf("reload did not finish within %s", reloadTimeout)
+		}
+		if response != "" {
+			return response, fmt.Errorf("%s failed: %w", command[0], err)
+		}
+		return "", err
+	}
+
+	log.Debugf("reload: Successfully reloaded %v", jailNames)
+	if response == "" {
+		response = "OK"
+	}
+	return response, nil
+}
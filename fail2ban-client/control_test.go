@@ -0,0 +1,225 @@
+package fail2ban_client
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ogórek "github.com/kisielk/og-rek"
+	"github.com/webishdev/fail2ban-dashboard/fail2bantest"
+)
+
+func TestValidJailName(t *testing.T) {
+	tests := []struct {
+		name     string
+		jailName string
+		expected bool
+	}{
+		{name: "simple", jailName: "sshd", expected: true},
+		{name: "with dash and dot", jailName: "nginx-http-auth.local", expected: true},
+		{name: "empty", jailName: "", expected: false},
+		{name: "option", jailName: "--all", expected: false},
+		{name: "space", jailName: "ssh d", expected: false},
+		{name: "path", jailName: "../sshd", expected: false},
+		{name: "too long", jailName: strings.Repeat("a", 101), expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidJailName(tt.jailName); got != tt.expected {
+				t.Errorf("ValidJailName(%q) = %v, want %v", tt.jailName, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFail2BanClient_StopJail(t *testing.T) {
+	tests := []struct {
+		name     string
+		jailName string
+		readData []byte
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "stopped",
+			jailName: "sshd",
+			readData: createPickleData(ogórek.Tuple{0, nil}),
+			expected: "OK",
+		},
+		{
+			name:     "unknown jail",
+			jailName: "sshd",
+			readData: createPickleData(ogórek.Tuple{1, &ogórek.Call{
+				Callable: ogórek.Class{Module: "fail2ban.server.jails", Name: "UnknownJailException"},
+				Args:     ogórek.Tuple{"sshd"},
+			}}),
+			wantErr: true,
+		},
+		{
+			name:     "invalid jail name",
+			jailName: "--all",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := createMockClient(tt.readData, nil, nil)
+			mockSocket := client.socket.(*mockConn)
+			response, err := client.StopJail(tt.jailName)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StopJail() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if response != tt.expected {
+				t.Errorf("StopJail() = %q, want %q", response, tt.expected)
+			}
+			if tt.readData == nil && len(mockSocket.writeData) > 0 {
+				t.Errorf("StopJail() sent a command for an invalid jail name")
+			}
+		})
+	}
+}
+
+func TestFail2BanClient_StartJail(t *testing.T) {
+	client := createMockClient(createPickleData(ogórek.Tuple{0, "Jail started"}), nil, nil)
+	mockSocket := client.socket.(*mockConn)
+
+	response, err := client.StartJail("sshd")
+	if err != nil {
+		t.Fatalf("StartJail() error = %v", err)
+	}
+	if response != "Jail started" {
+		t.Errorf("StartJail() = %q, want %q", response, "Jail started")
+	}
+	if !strings.Contains(string(mockSocket.writeData), "start") {
+		t.Errorf("StartJail() did not send the start command")
+	}
+}
+
+// fakeExecutable writes a shell script standing in for fail2ban-client
+func fakeExecutable(t *testing.T, script string) string {
+	t.Helper()
+	executable := filepath.Join(t.TempDir(), "fail2ban-client")
+	if err := os.WriteFile(executable, []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatalf("could not write fake executable: %v", err)
+	}
+	return executable
+}
+
+func TestFail2BanClient_Reload(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		jailName string
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "all jails",
+			script:   `echo "$@"`,
+			expected: "-s /run/fail2ban/fail2ban.sock reload",
+		},
+		{
+			name:     "single jail",
+			script:   `echo "$@"`,
+			jailName: "sshd",
+			expected: "-s /run/fail2ban/fail2ban.sock reload sshd",
+		},
+		{
+			name:     "empty output",
+			script:   `exit 0`,
+			jailName: "sshd",
+			expected: "OK",
+		},
+		{
+			name:     "failed reload",
+			script:   `echo "ERROR  Failed during configuration: Have not found any log file for sshd jail" >&2; exit 255`,
+			jailName: "sshd",
+			expected: "ERROR  Failed during configuration: Have not found any log file for sshd jail",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &Fail2BanClient{address: "/run/fail2ban/fail2ban.sock"}
+			client.SetExecutable(fakeExecutable(t, tt.script))
+
+			var response string
+			var err error
+			if tt.jailName == "" {
+				response, err = client.Reload()
+			} else {
+				response, err = client.ReloadJail(tt.jailName)
+			}
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Reload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if response != tt.expected {
+				t.Errorf("Reload() = %q, want %q", response, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFail2BanClient_ReloadInvalidJailName(t *testing.T) {
+	client := &Fail2BanClient{address: "/run/fail2ban/fail2ban.sock"}
+	client.SetExecutable(fakeExecutable(t, `touch "$0.called"`))
+
+	if _, err := client.ReloadJail("-all"); err == nil {
+		t.Fatal("ReloadJail() expected an error for an invalid jail name")
+	}
+	if _, err := os.Stat(client.executable + ".called"); err == nil {
+		t.Error("ReloadJail() ran fail2ban-client for an invalid jail name")
+	}
+}
+
+func TestFail2BanClient_ReloadWithoutExecutable(t *testing.T) {
+	fake := fail2bantest.Start(t, fail2bantest.Version1_1)
+	fake.AddJail(fail2bantest.Jail{Name: "sshd", BanTime: 600})
+	fake.AddJail(fail2bantest.Jail{Name: "postfix", BanTime: 600})
+	client, err := NewFail2BanClient(fake.Address())
+	if err != nil {
+		t.Fatalf("NewFail2BanClient() error = %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	if err = client.SetExecutable(filepath.Join(t.TempDir(), "fail2ban-client")); !errors.Is(err, ErrReloadUnavailable) {
+		t.Fatalf("SetExecutable() error = %v, want %v", err, ErrReloadUnavailable)
+	}
+	if client.ReloadAvailable() {
+		t.Error("ReloadAvailable() = true without fail2ban-client")
+	}
+	if _, err = client.ReloadJail("sshd"); !errors.Is(err, ErrReloadUnavailable) {
+		t.Errorf("ReloadJail() error = %v, want %v", err, ErrReloadUnavailable)
+	}
+	if _, err = client.Reload(); !errors.Is(err, ErrReloadUnavailable) {
+		t.Errorf("Reload() error = %v, want %v", err, ErrReloadUnavailable)
+	}
+
+	// stopping still works over the socket
+	if _, err = client.StopJail("postfix"); err != nil {
+		t.Fatalf("StopJail() error = %v", err)
+	}
+	if _, exists := fake.Jail("postfix"); exists {
+		t.Error("Expected postfix to be stopped")
+	}
+	for _, command := range fake.Commands() {
+		if strings.HasPrefix(command, "reload") {
+			t.Errorf("Expected no reload to be sent, got %q", command)
+		}
+	}
+
+	if err = client.SetExecutable(fakeExecutable(t, `echo "$@"`)); err != nil {
+		t.Fatalf("SetExecutable() error = %v", err)
+	}
+	response, err := client.ReloadJail("sshd")
+	if err != nil || response != "-s "+fake.Address()+" reload sshd" {
+		t.Errorf("ReloadJail() = %q, %v", response, err)
+	}
+}
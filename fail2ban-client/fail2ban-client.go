@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"strings"
 	"sync"
@@ -22,7 +23,13 @@ const (
 	statusCommand        = "status"
 	versionCommand       = "version"
 	getCommand           = "get"
+	setCommand           = "set"
 	bannedCommand        = "banned"
+	banIPCommand         = "banip"
+	unbanIPCommand       = "unbanip"
+	startCommand         = "start"
+	stopCommand          = "stop"
+	reloadCommand        = "reload"
 	socketReadBufferSize = 1024
 )
 
@@ -32,12 +39,12 @@ const (
 )
 
 type BanEntry struct {
-	Address       string
-	BannedAt      time.Time
-	CurrenPenalty string
-	BanEndsAt     time.Time
-	JailName      string
-	CountryCode   string
+	Address       string    `json:"address"`
+	BannedAt      time.Time `json:"bannedAt"`
+	CurrenPenalty string    `json:"currentPenalty"`
+	BanEndsAt     time.Time `json:"banEndsAt"`
+	JailName      string    `json:"jail"`
+	CountryCode   string    `json:"countryCode"`
 }
 
 type JailEntry struct {
@@ -46,33 +53,68 @@ type JailEntry struct {
 }
 
 type JailInfo struct {
-	CurrentlyFailed int
-	TotalFailed     int
-	CurrentlyBanned int
-	TotalBanned     int
+	CurrentlyFailed int `json:"currentlyFailed"`
+	TotalFailed     int `json:"totalFailed"`
+	CurrentlyBanned int `json:"currentlyBanned"`
+	TotalBanned     int `json:"totalBanned"`
 }
 
 type Fail2BanClient struct {
-	mutex   sync.RWMutex
-	socket  net.Conn
-	encoder *ogórek.Encoder
+	mutex         sync.Mutex
+	socket        net.Conn
+	encoder       *ogórek.Encoder
+	dial          func() (net.Conn, error)
+	address       string
+	executable    string
+	executableErr error
+	timeout       time.Duration
+	stateMutex    sync.RWMutex
+	state         ConnectionState
+	stateHandlers []StateHandler
+	reconnecting  bool
+	done          chan struct{}
+	pool          chan *Fail2BanClient
+	pooled        []*Fail2BanClient
+	fixture       io.Closer
+	version       string
 }
 
+// NewFail2BanClient connects to the socket at the address, when the socket is not available
+// the error is returned together with a client which keeps trying to connect in the background
 func NewFail2BanClient(address string) (*Fail2BanClient, error) {
+	return newFail2BanClient(address, dialer(address))
+}
+
+// newFail2BanClient connects with the dial function, which is shared by the connections of the pool
+func newFail2BanClient(address string, dial func() (net.Conn, error)) (*Fail2BanClient, error) {
+	f2bc := &Fail2BanClient{
+		dial:          dial,
+		address:       address,
+		executable:    defaultExecutable,
+		executableErr: lookupExecutable(defaultExecutable),
+		timeout:       commandTimeout,
+		done:          make(chan struct{}),
+		pool:          make(chan *Fail2BanClient, poolSize),
+	}
+	for range poolSize {
+		pooled := &Fail2BanClient{dial: f2bc.dial, address: address, executable: defaultExecutable}
+		f2bc.pooled = append(f2bc.pooled, pooled)
+		f2bc.pool <- pooled
+	}
+
 	log.Tracef("Attempting to connect to fail2ban socket at %s", address)
-	socket, err := net.Dial("unix", address)
+	f2bc.mutex.Lock()
+	err := f2bc.connect()
+	f2bc.mutex.Unlock()
 	if err != nil {
 		log.Errorf("Failed to connect to fail2ban socket at %s: %v", address, err)
-		return nil, err
+		f2bc.setState(ConnectionState{Connected: false, Since: time.Now(), LastError: err.Error()})
+		f2bc.reconnect()
+		return f2bc, err
 	}
 
 	log.Debugf("Successfully connected to fail2ban socket at %s", address)
-	encoder := ogórek.NewEncoder(socket)
-
-	return &Fail2BanClient{
-		socket:  socket,
-		encoder: encoder,
-	}, nil
+	return f2bc, nil
 }
 
 func (f2bc *Fail2BanClient) GetVersion() (string, error) {
@@ -87,6 +129,9 @@ func (f2bc *Fail2BanClient) GetVersion() (string, error) {
 	if versionTuple, tupleOk := result.(*types.Tuple); tupleOk {
 		if versionStr, versionOk := versionTuple.Get(1).(string); versionOk {
 			log.Debugf("GetVersion: Successfully retrieved version: %s", versionStr)
+			f2bc.stateMutex.Lock()
+			f2bc.version = versionStr
+			f2bc.stateMutex.Unlock()
 			return versionStr, nil
 		}
 	}
@@ -95,6 +140,13 @@ func (f2bc *Fail2BanClient) GetVersion() (string, error) {
 	return "", errors.New("fetching version failed")
 }
 
+// Version is the fail2ban version read by the last successful GetVersion, it is empty before
+func (f2bc *Fail2BanClient) Version() string {
+	f2bc.stateMutex.RLock()
+	defer f2bc.stateMutex.RUnlock()
+	return f2bc.version
+}
+
 func (f2bc *Fail2BanClient) GetJailNames() ([]string, error) {
 	log.Trace("GetJailNames: Fetching jail names")
 	result, err := f2bc.sendCommand([]string{statusCommand})
@@ -235,7 +287,7 @@ func (f2bc *Fail2BanClient) GetJailInfo(jailName string) (*JailInfo, error) {
 func (f2bc *Fail2BanClient) GetBanned(jailName string) (*JailEntry, error) {
 	log.Tracef("GetBanned: Fetching banned IPs for jail '%s'", jailName)
 	var bannedEntries []*BanEntry
-	result, err := f2bc.sendCommand([]string{getCommand, jailName, "banip", "--with-time"})
+	result, err := f2bc.sendCommand([]string{getCommand, jailName, banIPCommand, "--with-time"})
 	if err != nil {
 		log.Errorf("GetBanned: Failed to get banned IPs for jail '%s': %v", jailName, err)
 		return nil, err
@@ -262,6 +314,42 @@ func (f2bc *Fail2BanClient) GetBanned(jailName string) (*JailEntry, error) {
 	return &JailEntry{Name: jailName, BannedEntries: bannedEntries}, nil
 }
 
+func (f2bc *Fail2BanClient) Ban(jailName string, address string) error {
+	log.Tracef("Ban: Adding %s to jail '%s'", address, jailName)
+	result, err := f2bc.sendCommand([]string{setCommand, jailName, banIPCommand, address})
+	if err != nil {
+		log.Errorf("Ban: Failed to ban %s in jail '%s': %v", address, jailName, err)
+		return err
+	}
+
+	_, responseErr := responseValue(result)
+	if responseErr != nil {
+		log.Errorf("Ban: fail2ban refused to ban %s in jail '%s': %v", address, jailName, responseErr)
+		return responseErr
+	}
+
+	log.Debugf("Ban: Successfully banned %s in jail '%s'", address, jailName)
+	return nil
+}
+
+func (f2bc *Fail2BanClient) Unban(jailName string, address string) error {
+	log.Tracef("Unban: Removing %s from jail '%s'", address, jailName)
+	result, err := f2bc.sendCommand([]string{setCommand, jailName, unbanIPCommand, address})
+	if err != nil {
+		log.Errorf("Unban: Failed to unban %s from jail '%s': %v", address, jailName, err)
+		return err
+	}
+
+	_, responseErr := responseValue(result)
+	if responseErr != nil {
+		log.Errorf("Unban: fail2ban refused to unban %s from jail '%s': %v", address, jailName, responseErr)
+		return responseErr
+	}
+
+	log.Debugf("Unban: Successfully unbanned %s from jail '%s'", address, jailName)
+	return nil
+}
+
 func (f2bc *Fail2BanClient) parseEntry(jailName string, listEntry string) (*BanEntry, error) {
 	log.Tracef("GetBanned: Parsing ban entry: %s", listEntry)
 
@@ -282,28 +370,105 @@ func (f2bc *Fail2BanClient) parseEntry(jailName string, listEntry string) (*BanE
 	return banEntry, nil
 }
 
+// responseValue unwraps the (code, value) tuple fail2ban sends for each command,
+// a code other than 0 means the command failed and the value holds the exception
+func responseValue(result interface{}) (interface{}, error) {
+	if responseTuple, tupleOk := result.(*types.Tuple); tupleOk && responseTuple.Len() == 2 {
+		if code, codeOk := responseTuple.Get(0).(int); codeOk {
+			if code == 0 {
+				return responseTuple.Get(1), nil
+			}
+			if exception, exceptionOk := responseTuple.Get(1).(error); exceptionOk {
+				return nil, exception
+			}
+			return nil, fmt.Errorf("fail2ban returned code %d: %v", code, responseTuple.Get(1))
+		}
+	}
+	return nil, errors.New("unexpected response from fail2ban")
+}
+
 func (f2bc *Fail2BanClient) sendCommand(command []string) (interface{}, error) {
+	// write and read must not interleave with other commands, otherwise responses get mixed up,
+	// while another command is running an additional connection of the pool is used
+	if !f2bc.mutex.TryLock() {
+		if result, used, err := f2bc.sendPooled(command); used {
+			return result, err
+		}
+		f2bc.mutex.Lock()
+	}
+	defer f2bc.mutex.Unlock()
+
+	if f2bc.socket == nil {
+		log.Debugf("Not sending command %v, waiting for fail2ban socket", command)
+		f2bc.reconnect()
+		return nil, ErrNotConnected
+	}
+
+	result, sent, err := f2bc.exchange(command)
+	if err == nil {
+		return result, nil
+	}
+
+	// the socket is broken after fail2ban restarted, a fresh connection usually works right away,
+	// a command which reached fail2ban may have been applied already and is only sent again when it just reads
+	f2bc.disconnect(err)
+	if f2bc.connect() != nil {
+		f2bc.reconnect()
+		return nil, err
+	}
+	if sent && !readOnly(command) {
+		return nil, err
+	}
+
+	log.Debugf("Reconnected to fail2ban socket, retrying command %v", command)
+	result, _, err = f2bc.exchange(command)
+	if err != nil {
+		f2bc.disconnect(err)
+		f2bc.reconnect()
+		return nil, err
+	}
+	return result, nil
+}
+
+// readOnly tells if the command does not change fail2ban, it is safe to send such a command twice
+func readOnly(command []string) bool {
+	if len(command) == 0 {
+		return false
+	}
+	switch command[0] {
+	case pingCommand, statusCommand, versionCommand, getCommand, bannedCommand:
+		return true
+	}
+	return false
+}
+
+// exchange sends the command and reads the response, sent tells if the command was written completely,
+// fail2ban may have executed it then even when reading the response failed
+func (f2bc *Fail2BanClient) exchange(command []string) (result interface{}, sent bool, err error) {
 	log.Tracef("Sending command to fail2ban: %v", command)
-	err := f2bc.write(command)
+	err = f2bc.setDeadline()
+	if err != nil {
+		log.Errorf("Failed to set deadline for command %v: %v", command, err)
+		return nil, false, err
+	}
+
+	err = f2bc.write(command)
 	if err != nil {
 		log.Errorf("Failed to write command %v: %v", command, err)
-		return nil, err
+		return nil, false, err
 	}
 
-	result, err := f2bc.read()
+	result, err = f2bc.read()
 	if err != nil {
 		log.Errorf("Failed to read response for command %v: %v", command, err)
-		return nil, err
+		return nil, true, err
 	}
 
 	log.Tracef("Command %v completed successfully", command)
-	return result, nil
+	return result, true, nil
 }
 
 func (f2bc *Fail2BanClient) write(command []string) error {
-	f2bc.mutex.Lock()
-	defer f2bc.mutex.Unlock()
-
 	log.Tracef("Writing command to socket: %v", command)
 	err := f2bc.encoder.Encode(command)
 	if err != nil {
@@ -323,9 +488,6 @@ func (f2bc *Fail2BanClient) write(command []string) error {
 }
 
 func (f2bc *Fail2BanClient) read() (interface{}, error) {
-	f2bc.mutex.RLock()
-	defer f2bc.mutex.RUnlock()
-
 	log.Trace("Starting to read response from socket")
 	reader := bufio.NewReader(f2bc.socket)
 
@@ -350,7 +512,11 @@ func (f2bc *Fail2BanClient) read() (interface{}, error) {
 	}
 
 	log.Tracef("Raw response data (first 200 bytes): %q", string(data[:min(200, len(data))]))
+	return unpickle(data)
+}
 
+// unpickle decodes a response, exceptions are decoded as Py_exception
+func unpickle(data []byte) (interface{}, error) {
 	bufReader := bytes.NewReader(data)
 	unpickler := pickle.NewUnpickler(bufReader)
 
@@ -359,6 +525,9 @@ func (f2bc *Fail2BanClient) read() (interface{}, error) {
 		if (module == "builtins" || module == "__builtin__") && name == "str" {
 			return &Py_builtins_str{}, nil
 		}
+		if strings.HasSuffix(name, "Error") || strings.HasSuffix(name, "Exception") {
+			return &Py_exception_class{name: name}, nil
+		}
 		return nil, fmt.Errorf("class not found: [%s] %s", module, name)
 	}
 
@@ -296,8 +296,15 @@ func TestNewFail2BanClient(t *testing.T) {
 				t.Errorf("NewFail2BanClient() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if client != nil {
-				_ = client.socket.Close()
+			if client == nil {
+				t.Fatal("NewFail2BanClient() should return a client which keeps reconnecting")
+			}
+			defer func() { _ = client.Close() }()
+			if client.ConnectionState().Connected {
+				t.Error("ConnectionState() should not be connected")
+			}
+			if _, commandErr := client.GetVersion(); !errors.Is(commandErr, ErrNotConnected) {
+				t.Errorf("GetVersion() error = %v, want %v", commandErr, ErrNotConnected)
 			}
 		})
 	}
@@ -809,6 +816,99 @@ func TestFail2BanClient_sendCommand(t *testing.T) {
 	}
 }
 
+func TestFail2BanClient_Ban(t *testing.T) {
+	tests := []struct {
+		name     string
+		readData []byte
+		readErr  error
+		wantErr  bool
+	}{
+		{
+			name:     "successful ban",
+			readData: createPickleData(ogórek.Tuple{0, 1}),
+			wantErr:  false,
+		},
+		{
+			name: "unknown jail",
+			readData: createPickleData(ogórek.Tuple{1, &ogórek.Call{
+				Callable: ogórek.Class{Module: "fail2ban.server.jails", Name: "UnknownJailException"},
+				Args:     ogórek.Tuple{"unknown"},
+			}}),
+			wantErr: true,
+		},
+		{
+			name:     "unexpected response",
+			readData: createPickleData("invalid"),
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := createMockClient(tt.readData, tt.readErr, nil)
+			mockSocket := client.socket.(*mockConn)
+			err := client.Ban("sshd", "10.0.0.0/24")
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Ban() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !strings.Contains(string(mockSocket.writeData), "10.0.0.0/24") {
+				t.Errorf("Ban() did not send the address")
+			}
+		})
+	}
+}
+
+func TestFail2BanClient_Unban(t *testing.T) {
+	tests := []struct {
+		name        string
+		readData    []byte
+		readErr     error
+		wantErr     bool
+		wantMessage string
+	}{
+		{
+			name:     "successful unban",
+			readData: createPickleData(ogórek.Tuple{0, 1}),
+			wantErr:  false,
+		},
+		{
+			name: "fail2ban exception",
+			readData: createPickleData(ogórek.Tuple{1, &ogórek.Call{
+				Callable: ogórek.Class{Module: "builtins", Name: "ValueError"},
+				Args:     ogórek.Tuple{"IP 10.0.0.1 is not banned"},
+			}}),
+			wantErr:     true,
+			wantMessage: "ValueError: IP 10.0.0.1 is not banned",
+		},
+		{
+			name:    "read error",
+			readErr: errors.New("read failed"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := createMockClient(tt.readData, tt.readErr, nil)
+			mockSocket := client.socket.(*mockConn)
+			err := client.Unban("sshd", "10.0.0.1")
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unban() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantMessage != "" && err.Error() != tt.wantMessage {
+				t.Errorf("Unban() error = %q, want %q", err.Error(), tt.wantMessage)
+			}
+
+			if !strings.Contains(string(mockSocket.writeData), "unbanip") {
+				t.Errorf("Unban() did not send unbanip command")
+			}
+		})
+	}
+}
+
 func TestFail2BanClient_parseEntry(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -0,0 +1,49 @@
+package fail2ban_client
+
+import (
+	"github.com/gofiber/fiber/v3/log"
+)
+
+const (
+	ignoreIPCommand    = "ignoreip"
+	addIgnoreIPCommand = "addignoreip"
+	delIgnoreIPCommand = "delignoreip"
+)
+
+// GetIgnoreIPs returns the addresses, ranges and hostnames the jail never bans
+func (f2bc *Fail2BanClient) GetIgnoreIPs(jailName string) ([]string, error) {
+	log.Tracef("GetIgnoreIPs: Fetching ignore list of jail '%s'", jailName)
+	return f2bc.ignoreListCommand("GetIgnoreIPs", []string{getCommand, jailName, ignoreIPCommand})
+}
+
+// AddIgnoreIP adds the address to the ignore list of the jail and returns the new list,
+// the change is not written to the configuration and is lost when the jail is reloaded
+func (f2bc *Fail2BanClient) AddIgnoreIP(jailName string, address string) ([]string, error) {
+	log.Tracef("AddIgnoreIP: Adding %s to the ignore list of jail '%s'", address, jailName)
+	return f2bc.ignoreListCommand("AddIgnoreIP", []string{setCommand, jailName, addIgnoreIPCommand, address})
+}
+
+// DelIgnoreIP removes the address from the ignore list of the jail and returns the new list
+func (f2bc *Fail2BanClient) DelIgnoreIP(jailName string, address string) ([]string, error) {
+	log.Tracef("DelIgnoreIP: Removing %s from the ignore list of jail '%s'", address, jailName)
+	return f2bc.ignoreListCommand("DelIgnoreIP", []string{setCommand, jailName, delIgnoreIPCommand, address})
+}
+
+// ignoreListCommand sends the command and reads the ignore list fail2ban answers with
+func (f2bc *Fail2BanClient) ignoreListCommand(name string, command []string) ([]string, error) {
+	result, err := f2bc.sendCommand(command)
+	if err != nil {
+		log.Errorf("%s: Failed to send %v: %v", name, command, err)
+		return nil, err
+	}
+
+	value, responseErr := responseValue(result)
+	if responseErr != nil {
+		log.Errorf("%s: fail2ban refused %v: %v", name, command, responseErr)
+		return nil, responseErr
+	}
+
+	ignoreIPs := listSetting(value)
+	log.Debugf("%s: Ignore list of jail '%s' has %d entries", name, command[1], len(ignoreIPs))
+	return ignoreIPs, nil
+}
@@ -0,0 +1,71 @@
+package fail2ban_client
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	ogórek "github.com/kisielk/og-rek"
+)
+
+func TestFail2BanClient_IgnoreIPs(t *testing.T) {
+	address := filepath.Join(t.TempDir(), "fail2ban.sock")
+	listener := listenUnix(t, address)
+	serveResponses(t, listener, map[string]interface{}{
+		"get sshd ignoreip":                   ogórek.Tuple{0, []interface{}{"127.0.0.1/8", "::1"}},
+		"set sshd addignoreip 192.168.1.0/24": ogórek.Tuple{0, []interface{}{"127.0.0.1/8", "::1", "192.168.1.0/24"}},
+		"set sshd delignoreip ::1":            ogórek.Tuple{0, []interface{}{"127.0.0.1/8"}},
+		"get empty ignoreip":                  ogórek.Tuple{0, []interface{}{}},
+	})
+
+	client, err := NewFail2BanClient(address)
+	if err != nil {
+		t.Fatalf("NewFail2BanClient() error = %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	tests := []struct {
+		name     string
+		command  func() ([]string, error)
+		expected []string
+		wantErr  bool
+	}{
+		{
+			name:     "get",
+			command:  func() ([]string, error) { return client.GetIgnoreIPs("sshd") },
+			expected: []string{"127.0.0.1/8", "::1"},
+		},
+		{
+			name:     "get empty",
+			command:  func() ([]string, error) { return client.GetIgnoreIPs("empty") },
+			expected: []string{},
+		},
+		{
+			name:     "add",
+			command:  func() ([]string, error) { return client.AddIgnoreIP("sshd", "192.168.1.0/24") },
+			expected: []string{"127.0.0.1/8", "::1", "192.168.1.0/24"},
+		},
+		{
+			name:     "delete",
+			command:  func() ([]string, error) { return client.DelIgnoreIP("sshd", "::1") },
+			expected: []string{"127.0.0.1/8"},
+		},
+		{
+			name:    "unknown jail",
+			command: func() ([]string, error) { return client.GetIgnoreIPs("unknown") },
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ignoreIPs, err := tt.command()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(ignoreIPs, tt.expected) {
+				t.Errorf("ignore list = %v, want %v", ignoreIPs, tt.expected)
+			}
+		})
+	}
+}
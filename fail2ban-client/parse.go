@@ -3,6 +3,7 @@ package fail2ban_client
 import (
 	"errors"
 	"net"
+	"net/netip"
 	"regexp"
 	"time"
 
@@ -39,8 +40,11 @@ func parse(entry string) (*parsedEntry, error) {
 
 	ipAddress := matches[1]
 
+	// manually banned subnets are listed in CIDR notation
 	if net.ParseIP(ipAddress) == nil {
-		return nil, errors.New("invalid IP address in banned IPs entry")
+		if _, prefixErr := netip.ParsePrefix(ipAddress); prefixErr != nil {
+			return nil, errors.New("invalid IP address in banned IPs entry")
+		}
 	}
 
 	result := &parsedEntry{
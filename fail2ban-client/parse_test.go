@@ -40,6 +40,17 @@ func Test_parse(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name:  "valid IPv4 subnet",
+			entry: "10.10.0.0/16 2023-10-27 10:00:00 + 3600 = 2023-10-27 11:00:00",
+			want: &parsedEntry{
+				ipAddress:      "10.10.0.0/16",
+				currentPenalty: "3600",
+				bannedAt:       t1,
+				banEndsAt:      t2,
+			},
+			wantErr: false,
+		},
 		{
 			name:  "valid IPv6 localhost",
 			entry: "::1 2023-10-27 10:00:00 + 3600 = 2023-10-27 11:00:00",
@@ -1,7 +1,35 @@
 package fail2ban_client
 
+import "fmt"
+
 type Py_builtins_str struct{}
 
 func (c Py_builtins_str) Call(args ...interface{}) (interface{}, error) {
 	return args[0], nil
 }
+
+// Py_exception_class is used for exceptions fail2ban sends back when a command failed
+type Py_exception_class struct {
+	name string
+}
+
+func (c Py_exception_class) Call(args ...interface{}) (interface{}, error) {
+	return &Py_exception{Name: c.name, Args: args}, nil
+}
+
+type Py_exception struct {
+	Name string
+	Args []interface{}
+}
+
+func (e *Py_exception) Error() string {
+	if len(e.Args) == 0 {
+		return e.Name
+	}
+	return fmt.Sprintf("%s: %v", e.Name, e.Args[0])
+}
+
+// PySetState ignores additional exception attributes, only name and arguments are of interest
+func (e *Py_exception) PySetState(_ interface{}) error {
+	return nil
+}
@@ -0,0 +1,265 @@
+package fail2ban_client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3/log"
+	ogórek "github.com/kisielk/og-rek"
+	"github.com/nlpodyssey/gopickle/types"
+)
+
+// Exchange is a command sent to fail2ban and its response, fixture files contain one exchange per line,
+// the raw pickles include the command terminator and the decoded response is kept for reading only
+type Exchange struct {
+	Command  []string    `json:"command"`
+	Request  []byte      `json:"request"`
+	Response []byte      `json:"response"`
+	Decoded  interface{} `json:"decoded"`
+}
+
+// NewRecordingClient connects like NewFail2BanClient and writes every exchange with fail2ban
+// to the fixture file, an existing file is replaced
+func NewRecordingClient(address string, fixturePath string) (*Fail2BanClient, error) {
+	file, err := os.Create(fixturePath)
+	if err != nil {
+		return nil, err
+	}
+	recorder := &recorder{writer: file}
+	baseDial := dialer(address)
+	f2bc, err := newFail2BanClient(address, func() (net.Conn, error) {
+		conn, dialErr := baseDial()
+		if dialErr != nil {
+			return nil, dialErr
+		}
+		return &recordingConn{Conn: conn, recorder: recorder}, nil
+	})
+	f2bc.fixture = file
+	return f2bc, err
+}
+
+// NewReplayClient answers commands with the responses of the fixture file instead of a socket,
+// responses of a command are replayed in the recorded order and the last one is repeated
+func NewReplayClient(fixturePath string) (*Fail2BanClient, error) {
+	exchanges, err := ReadFixture(fixturePath)
+	if err != nil {
+		return nil, err
+	}
+	replay := &replay{address: fixturePath, responses: make(map[string][][]byte)}
+	for _, exchange := range exchanges {
+		key := strings.Join(exchange.Command, " ")
+		replay.responses[key] = append(replay.responses[key], exchange.Response)
+	}
+	return newFail2BanClient(fixturePath, func() (net.Conn, error) {
+		return &replayConn{replay: replay}, nil
+	})
+}
+
+// ReadFixture reads the exchanges of a fixture file
+func ReadFixture(fixturePath string) ([]Exchange, error) {
+	file, err := os.Open(fixturePath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	var exchanges []Exchange
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var exchange Exchange
+		if unmarshalErr := json.Unmarshal(scanner.Bytes(), &exchange); unmarshalErr != nil {
+			return nil, fmt.Errorf("%s line %d: %w", fixturePath, line, unmarshalErr)
+		}
+		exchanges = append(exchanges, exchange)
+	}
+	return exchanges, scanner.Err()
+}
+
+// recorder writes the exchanges of all connections of a client
+type recorder struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+func (r *recorder) record(request []byte, response []byte) {
+	exchange := Exchange{Request: request, Response: response}
+
+	decoded, err := ogórek.NewDecoder(bytes.NewReader(request)).Decode()
+	if err == nil {
+		if parts, ok := decoded.([]interface{}); ok {
+			for _, part := range parts {
+				exchange.Command = append(exchange.Command, fmt.Sprint(part))
+			}
+		}
+	}
+	if result, unpickleErr := unpickle(bytes.TrimSuffix(response, []byte(commandTerminator))); unpickleErr == nil {
+		exchange.Decoded = plainValue(result)
+	} else {
+		exchange.Decoded = unpickleErr.Error()
+	}
+
+	line, err := json.Marshal(exchange)
+	if err != nil {
+		// the raw response is what is replayed, the decoded form may be anything gopickle returns
+		exchange.Decoded = fmt.Sprint(exchange.Decoded)
+		line, err = json.Marshal(exchange)
+	}
+	if err != nil {
+		log.Errorf("Could not record response to %v: %v", exchange.Command, err)
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, err = r.writer.Write(append(line, '\n')); err != nil {
+		log.Errorf("Could not record response to %v: %v", exchange.Command, err)
+	}
+}
+
+// plainValue converts tuples, lists and exceptions of a response to values JSON can encode
+func plainValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case *types.Tuple:
+		return plainValues(typed.Len(), typed.Get)
+	case *types.List:
+		return plainValues(typed.Len(), typed.Get)
+	case *Py_exception:
+		return map[string]interface{}{"exception": typed.Name, "args": plainValues(len(typed.Args), func(index int) interface{} { return typed.Args[index] })}
+	}
+	return value
+}
+
+func plainValues(length int, get func(index int) interface{}) []interface{} {
+	values := make([]interface{}, 0, length)
+	for index := 0; index < length; index++ {
+		values = append(values, plainValue(get(index)))
+	}
+	return values
+}
+
+// recordingConn passes the data through and hands each command and its response to the recorder
+type recordingConn struct {
+	net.Conn
+	recorder *recorder
+	request  []byte
+	response []byte
+}
+
+func (c *recordingConn) Write(p []byte) (int, error) {
+	// a command without a response, e.g. after a timeout, is not recorded
+	if bytes.HasSuffix(c.request, []byte(commandTerminator)) {
+		c.request, c.response = nil, nil
+	}
+	c.request = append(c.request, p...)
+	return c.Conn.Write(p)
+}
+
+func (c *recordingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.response = append(c.response, p[:n]...)
+	if bytes.Contains(c.response, []byte(commandTerminator)) {
+		c.recorder.record(c.request, c.response)
+		c.request, c.response = nil, nil
+	}
+	return n, err
+}
+
+// replay holds the recorded responses shared by all connections of a replay client
+type replay struct {
+	mutex     sync.Mutex
+	address   string
+	responses map[string][][]byte
+}
+
+func (r *replay) next(command []string) ([]byte, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	key := strings.Join(command, " ")
+	responses := r.responses[key]
+	if len(responses) == 0 {
+		return nil, false
+	}
+	if len(responses) > 1 {
+		r.responses[key] = responses[1:]
+	}
+	return responses[0], true
+}
+
+// replayConn answers each written command with the next recorded response
+type replayConn struct {
+	replay  *replay
+	request []byte
+	pending bytes.Buffer
+	err     error
+}
+
+func (c *replayConn) Write(p []byte) (int, error) {
+	c.request = append(c.request, p...)
+	for {
+		end := bytes.Index(c.request, []byte(commandTerminator))
+		if end < 0 {
+			return len(p), nil
+		}
+		var command []string
+		decoded, err := ogórek.NewDecoder(bytes.NewReader(c.request[:end])).Decode()
+		if parts, ok := decoded.([]interface{}); err == nil && ok {
+			for _, part := range parts {
+				command = append(command, fmt.Sprint(part))
+			}
+		}
+		c.request = c.request[end+len(commandTerminator):]
+
+		response, found := c.replay.next(command)
+		if !found {
+			c.err = fmt.Errorf("no recorded response for %v", command)
+			continue
+		}
+		c.pending.Write(response)
+	}
+}
+
+func (c *replayConn) Read(p []byte) (int, error) {
+	if c.pending.Len() > 0 {
+		return c.pending.Read(p)
+	}
+	if c.err != nil {
+		return 0, c.err
+	}
+	return 0, io.EOF
+}
+
+func (c *replayConn) Close() error {
+	return nil
+}
+
+func (c *replayConn) LocalAddr() net.Addr {
+	return &net.UnixAddr{Name: c.replay.address, Net: "unix"}
+}
+
+func (c *replayConn) RemoteAddr() net.Addr {
+	return &net.UnixAddr{Name: c.replay.address, Net: "unix"}
+}
+
+func (c *replayConn) SetDeadline(time.Time) error {
+	return nil
+}
+
+func (c *replayConn) SetReadDeadline(time.Time) error {
+	return nil
+}
+
+func (c *replayConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
@@ -0,0 +1,94 @@
+package fail2ban_client
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/webishdev/fail2ban-dashboard/fail2bantest"
+)
+
+// session sends the commands of a refresh and a jail page, the results are compared between recording and replay
+func session(t *testing.T, f2bc *Fail2BanClient) []interface{} {
+	t.Helper()
+	version, err := f2bc.GetVersion()
+	if err != nil {
+		t.Fatalf("GetVersion() error = %v", err)
+	}
+	names, err := f2bc.GetJailNames()
+	if err != nil {
+		t.Fatalf("GetJailNames() error = %v", err)
+	}
+	info, err := f2bc.GetJailInfo("sshd")
+	if err != nil {
+		t.Fatalf("GetJailInfo() error = %v", err)
+	}
+	banned, err := f2bc.GetBanned("sshd")
+	if err != nil {
+		t.Fatalf("GetBanned() error = %v", err)
+	}
+	config, err := f2bc.GetJailConfig("sshd")
+	if err != nil {
+		t.Fatalf("GetJailConfig() error = %v", err)
+	}
+	unbanErr := f2bc.Unban("sshd", "203.0.113.5")
+	if unbanErr == nil {
+		t.Fatal("Unban() of an address which is not banned should fail")
+	}
+	return []interface{}{version, names, info, banned, config, unbanErr.Error()}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	fake := fail2bantest.Start(t, fail2bantest.Version1_0)
+	fake.AddJail(fail2bantest.Jail{
+		Name:      "sshd",
+		BanTime:   600,
+		MaxRetry:  5,
+		LogPaths:  []string{"/var/log/auth.log"},
+		IgnoreIPs: []string{"127.0.0.1/8"},
+		Bans:      []fail2bantest.Ban{{Address: "192.0.2.10", BannedAt: time.Now().Truncate(time.Second), BanTime: 600}},
+	})
+	fixture := filepath.Join(t.TempDir(), "session.jsonl")
+
+	recording, err := NewRecordingClient(fake.Address(), fixture)
+	if err != nil {
+		t.Fatalf("NewRecordingClient() error = %v", err)
+	}
+	recorded := session(t, recording)
+	_ = recording.Close()
+	_ = fake.Close()
+
+	exchanges, err := ReadFixture(fixture)
+	if err != nil {
+		t.Fatalf("ReadFixture() error = %v", err)
+	}
+	if len(exchanges) != len(fake.Commands()) {
+		t.Errorf("Expected %d exchanges, got %d", len(fake.Commands()), len(exchanges))
+	}
+	first := exchanges[0]
+	if strings.Join(first.Command, " ") != "version" || !reflect.DeepEqual(first.Decoded, []interface{}{float64(0), "1.0.2"}) {
+		t.Errorf("Unexpected first exchange %+v", first)
+	}
+	if !strings.HasSuffix(string(first.Request), commandTerminator) || !strings.HasSuffix(string(first.Response), commandTerminator) {
+		t.Errorf("Expected the raw pickles with the terminator, got %q and %q", first.Request, first.Response)
+	}
+	last := exchanges[len(exchanges)-1]
+	if exception, ok := last.Decoded.([]interface{})[1].(map[string]interface{}); !ok || exception["exception"] != "ValueError" {
+		t.Errorf("Expected the decoded exception, got %v", last.Decoded)
+	}
+
+	replaying, err := NewReplayClient(fixture)
+	if err != nil {
+		t.Fatalf("NewReplayClient() error = %v", err)
+	}
+	defer func() { _ = replaying.Close() }()
+	if replayed := session(t, replaying); !reflect.DeepEqual(replayed, recorded) {
+		t.Errorf("Replayed session differs\n got: %v\nwant: %v", replayed, recorded)
+	}
+
+	if err = replaying.Ban("sshd", "203.0.113.5"); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("Ban() error = %v, want the missing response", err)
+	}
+}
@@ -0,0 +1,263 @@
+// Package fail2bantest provides a fake fail2ban server speaking the socket protocol of fail2ban,
+// commands are pickled lists ending with <F2B_END_COMMAND> and answered with a pickled (code, value) tuple
+package fail2bantest
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ogórek "github.com/kisielk/og-rek"
+)
+
+const (
+	commandTerminator = "<F2B_END_COMMAND>"
+	readBufferSize    = 4096
+)
+
+// The versions the responses can be shaped like
+const (
+	Version0_11 = "0.11.2"
+	Version1_0  = "1.0.2"
+	Version1_1  = "1.1.0"
+)
+
+// Server is a fake fail2ban listening on a unix socket, the jails are changed with its methods
+// or by the commands of clients, connections are served concurrently like fail2ban does
+type Server struct {
+	mutex    sync.Mutex
+	listener net.Listener
+	address  string
+	version  string
+	jails    map[string]*Jail
+	stopped  map[string]*Jail
+	latency  time.Duration
+	failures map[string]string
+	drops    map[string]bool
+	commands []string
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// NewServer listens on the socket address and answers like the fail2ban version,
+// see Version0_11, Version1_0 and Version1_1
+func NewServer(address string, version string) (*Server, error) {
+	listener, err := net.Listen("unix", address)
+	if err != nil {
+		return nil, err
+	}
+	server := &Server{
+		listener: listener,
+		address:  address,
+		version:  version,
+		jails:    make(map[string]*Jail),
+		stopped:  make(map[string]*Jail),
+		failures: make(map[string]string),
+		drops:    make(map[string]bool),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	server.wg.Go(server.accept)
+	return server, nil
+}
+
+// Address is the path of the socket
+func (server *Server) Address() string {
+	return server.address
+}
+
+// Close stops listening and closes the connections of the clients
+func (server *Server) Close() error {
+	err := server.listener.Close()
+	server.mutex.Lock()
+	for conn := range server.conns {
+		_ = conn.Close()
+	}
+	server.mutex.Unlock()
+	server.wg.Wait()
+	return err
+}
+
+// SetLatency delays every answer, e.g. to simulate a busy fail2ban
+func (server *Server) SetLatency(latency time.Duration) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.latency = latency
+}
+
+// FailCommand answers commands starting with the prefix, like "status sshd", with an exception
+// carrying the message, an empty message removes the failure
+func (server *Server) FailCommand(prefix string, message string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if message == "" {
+		delete(server.failures, prefix)
+		return
+	}
+	server.failures[prefix] = message
+}
+
+// DropCommand closes the connection instead of answering commands starting with the prefix,
+// like a restarting fail2ban does, drop false answers them again
+func (server *Server) DropCommand(prefix string, drop bool) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if !drop {
+		delete(server.drops, prefix)
+		return
+	}
+	server.drops[prefix] = true
+}
+
+// Commands are the commands received so far joined by spaces
+func (server *Server) Commands() []string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return append([]string(nil), server.commands...)
+}
+
+func (server *Server) accept() {
+	for {
+		conn, err := server.listener.Accept()
+		if err != nil {
+			return
+		}
+		server.mutex.Lock()
+		server.conns[conn] = struct{}{}
+		server.mutex.Unlock()
+		server.wg.Go(func() {
+			server.serve(conn)
+			server.mutex.Lock()
+			delete(server.conns, conn)
+			server.mutex.Unlock()
+		})
+	}
+}
+
+func (server *Server) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	buf := make([]byte, readBufferSize)
+	var data []byte
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		data = append(data, buf[:n]...)
+		for {
+			end := bytes.Index(data, []byte(commandTerminator))
+			if end < 0 {
+				break
+			}
+			command, decodeErr := decodeCommand(data[:end])
+			data = data[end+len(commandTerminator):]
+			if decodeErr != nil {
+				return
+			}
+
+			response, drop, latency := server.handle(command)
+			time.Sleep(latency)
+			if drop {
+				return
+			}
+			if _, err = conn.Write(response); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func decodeCommand(data []byte) ([]string, error) {
+	decoded, err := ogórek.NewDecoder(bytes.NewReader(data)).Decode()
+	if err != nil {
+		return nil, err
+	}
+	parts, ok := decoded.([]interface{})
+	if !ok {
+		return nil, errors.New("command is not a list")
+	}
+	command := make([]string, 0, len(parts))
+	for _, part := range parts {
+		text, isText := part.(string)
+		if !isText {
+			return nil, errors.New("command contains a value which is not a string")
+		}
+		command = append(command, text)
+	}
+	return command, nil
+}
+
+// handle answers the command, the response is pickled and terminated already
+func (server *Server) handle(command []string) ([]byte, bool, time.Duration) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	joined := strings.Join(command, " ")
+	server.commands = append(server.commands, joined)
+	for prefix := range server.drops {
+		if strings.HasPrefix(joined, prefix) {
+			return nil, true, server.latency
+		}
+	}
+
+	var response interface{}
+	if message, failed := server.failure(joined); failed {
+		response = exception("builtins", "Exception", message)
+	} else {
+		value, err := server.execute(command, time.Now())
+		if err != nil {
+			response = err.pickle()
+		} else {
+			response = ogórek.Tuple{0, value}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := ogórek.NewEncoder(&buf).Encode(response); err != nil {
+		return nil, true, server.latency
+	}
+	buf.WriteString(commandTerminator)
+	return buf.Bytes(), false, server.latency
+}
+
+func (server *Server) failure(joined string) (string, bool) {
+	for prefix, message := range server.failures {
+		if strings.HasPrefix(joined, prefix) {
+			return message, true
+		}
+	}
+	return "", false
+}
+
+// commandError is an exception raised by fail2ban, it is sent with code 1
+type commandError struct {
+	module  string
+	name    string
+	message string
+}
+
+func (err *commandError) pickle() ogórek.Tuple {
+	return exception(err.module, err.name, err.message)
+}
+
+func exception(module string, name string, message string) ogórek.Tuple {
+	return ogórek.Tuple{1, &ogórek.Call{
+		Callable: ogórek.Class{Module: module, Name: name},
+		Args:     ogórek.Tuple{message},
+	}}
+}
+
+// Start runs a server on a socket in a temporary directory of the test and closes it when the test is done
+func Start(tb testing.TB, version string) *Server {
+	tb.Helper()
+	server, err := NewServer(filepath.Join(tb.TempDir(), "fail2ban.sock"), version)
+	if err != nil {
+		tb.Fatalf("Failed to start fake fail2ban: %v", err)
+	}
+	tb.Cleanup(func() { _ = server.Close() })
+	return server
+}
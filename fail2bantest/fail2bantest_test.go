@@ -0,0 +1,197 @@
+package fail2bantest
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+)
+
+func newClient(t *testing.T, server *Server) *client.Fail2BanClient {
+	t.Helper()
+	f2bc, err := client.NewFail2BanClient(server.Address())
+	if err != nil {
+		t.Fatalf("NewFail2BanClient() error = %v", err)
+	}
+	t.Cleanup(func() { _ = f2bc.Close() })
+	return f2bc
+}
+
+func sshdJail(bannedAt time.Time) Jail {
+	return Jail{
+		Name:            "sshd",
+		BanTime:         600,
+		FindTime:        300,
+		MaxRetry:        5,
+		LogPaths:        []string{"/var/log/auth.log"},
+		FailRegex:       []string{"^Failed password for .* from <HOST>"},
+		IgnoreIPs:       []string{"127.0.0.1/8"},
+		Actions:         []string{"iptables-multiport"},
+		UseDNS:          "warn",
+		CurrentlyFailed: 2,
+		TotalFailed:     12,
+		TotalBanned:     3,
+		Bans: []Ban{
+			{Address: "192.0.2.10", BannedAt: bannedAt, BanTime: 600},
+			{Address: "2001:db8::1", BannedAt: bannedAt, BanTime: -1},
+			{Address: "198.51.100.7", BannedAt: bannedAt.Add(-time.Hour), BanTime: 600},
+		},
+	}
+}
+
+func TestServer_Versions(t *testing.T) {
+	tests := []struct {
+		name      string
+		version   string
+		separator string
+	}{
+		{"0.11", Version0_11, " \t2"},
+		{"1.0", Version1_0, " \t \t2"},
+		{"1.1", Version1_1, " \t2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := Start(t, tt.version)
+			bannedAt := time.Now().Truncate(time.Second)
+			server.AddJail(sshdJail(bannedAt))
+			server.AddJail(Jail{Name: "postfix", BanTime: 3600})
+			f2bc := newClient(t, server)
+
+			version, err := f2bc.GetVersion()
+			if err != nil || version != tt.version {
+				t.Errorf("GetVersion() = %q, %v, want %q", version, err, tt.version)
+			}
+
+			names, err := f2bc.GetJailNames()
+			if err != nil || !slices.Equal(names, []string{"postfix", "sshd"}) {
+				t.Errorf("GetJailNames() = %v, %v", names, err)
+			}
+
+			info, err := f2bc.GetJailInfo("sshd")
+			if err != nil {
+				t.Fatalf("GetJailInfo() error = %v", err)
+			}
+			// the ban of an hour ago is over already
+			if info.CurrentlyFailed != 2 || info.TotalFailed != 12 || info.CurrentlyBanned != 2 || info.TotalBanned != 3 {
+				t.Errorf("GetJailInfo() = %+v", info)
+			}
+
+			entry, err := f2bc.GetBanned("sshd")
+			if err != nil {
+				t.Fatalf("GetBanned() error = %v", err)
+			}
+			if len(entry.BannedEntries) != 2 {
+				t.Fatalf("Expected 2 bans, got %d", len(entry.BannedEntries))
+			}
+			banned, permanent := entry.BannedEntries[0], entry.BannedEntries[1]
+			if banned.Address != "192.0.2.10" || banned.CurrenPenalty != "600" || banned.BanEndsAt.Sub(banned.BannedAt) != 10*time.Minute {
+				t.Errorf("Unexpected ban %+v", banned)
+			}
+			if permanent.Address != "2001:db8::1" || permanent.CurrenPenalty != "-1" || permanent.BanEndsAt.Year() != 9999 {
+				t.Errorf("Unexpected permanent ban %+v", permanent)
+			}
+
+			server.mutex.Lock()
+			jail, _ := server.execute([]string{"get", "sshd", "banip", "--with-time"}, time.Now())
+			server.mutex.Unlock()
+			if first := jail.([]interface{})[0].(string); !strings.HasPrefix(first, "192.0.2.10"+tt.separator) {
+				t.Errorf("Expected the separator %q, got %q", tt.separator, first)
+			}
+		})
+	}
+}
+
+func TestServer_Commands(t *testing.T) {
+	server := Start(t, Version1_1)
+	server.AddJail(sshdJail(time.Now()))
+	f2bc := newClient(t, server)
+
+	config, err := f2bc.GetJailConfig("sshd")
+	if err != nil {
+		t.Fatalf("GetJailConfig() error = %v", err)
+	}
+	if config.BanTime != 600 || config.FindTime != 300 || config.MaxRetry != 5 || config.UseDNS != "warn" ||
+		!slices.Equal(config.LogPaths, []string{"/var/log/auth.log"}) || !slices.Equal(config.Actions, []string{"iptables-multiport"}) {
+		t.Errorf("GetJailConfig() = %+v", config)
+	}
+
+	if err = f2bc.Ban("sshd", "203.0.113.5"); err != nil {
+		t.Errorf("Ban() error = %v", err)
+	}
+	if err = f2bc.Unban("sshd", "192.0.2.10"); err != nil {
+		t.Errorf("Unban() error = %v", err)
+	}
+	if err = f2bc.Unban("sshd", "192.0.2.10"); err == nil || !strings.Contains(err.Error(), "ValueError") {
+		t.Errorf("Unban() of an address which is not banned error = %v", err)
+	}
+	jail, _ := server.Jail("sshd")
+	addresses := make([]string, 0, len(jail.Bans))
+	for _, ban := range jail.Bans {
+		addresses = append(addresses, ban.Address)
+	}
+	if !slices.Equal(addresses, []string{"2001:db8::1", "203.0.113.5"}) || jail.TotalBanned != 4 {
+		t.Errorf("Unexpected bans %v, total %d", addresses, jail.TotalBanned)
+	}
+
+	ignoreIPs, err := f2bc.AddIgnoreIP("sshd", "10.0.0.0/8")
+	if err != nil || !slices.Equal(ignoreIPs, []string{"127.0.0.1/8", "10.0.0.0/8"}) {
+		t.Errorf("AddIgnoreIP() = %v, %v", ignoreIPs, err)
+	}
+	ignoreIPs, err = f2bc.DelIgnoreIP("sshd", "127.0.0.1/8")
+	if err != nil || !slices.Equal(ignoreIPs, []string{"10.0.0.0/8"}) {
+		t.Errorf("DelIgnoreIP() = %v, %v", ignoreIPs, err)
+	}
+
+	if _, err = f2bc.StopJail("sshd"); err != nil {
+		t.Errorf("StopJail() error = %v", err)
+	}
+	if _, err = f2bc.GetJailConfig("sshd"); err == nil || !strings.Contains(err.Error(), "UnknownJailException") {
+		t.Errorf("GetJailConfig() of the stopped jail error = %v", err)
+	}
+	if _, err = f2bc.StartJail("sshd"); err != nil {
+		t.Errorf("StartJail() error = %v", err)
+	}
+	if _, err = f2bc.StartJail("nginx"); err == nil || !strings.Contains(err.Error(), "UnknownJailException") {
+		t.Errorf("StartJail() of an unknown jail error = %v", err)
+	}
+
+	if commands := server.Commands(); !slices.Contains(commands, "set sshd banip 203.0.113.5") {
+		t.Errorf("Expected the ban command to be recorded, got %v", commands)
+	}
+}
+
+func TestServer_InjectedFailures(t *testing.T) {
+	server := Start(t, Version1_1)
+	server.AddJail(sshdJail(time.Now()))
+	f2bc := newClient(t, server)
+
+	server.FailCommand("get sshd ignoreip", "database is locked")
+	if _, err := f2bc.GetIgnoreIPs("sshd"); err == nil || !strings.Contains(err.Error(), "database is locked") {
+		t.Errorf("GetIgnoreIPs() error = %v, want the injected failure", err)
+	}
+	if err := f2bc.Ban("sshd", "203.0.113.5"); err != nil {
+		t.Errorf("Ban() error = %v, only the ignore list should fail", err)
+	}
+	server.FailCommand("get sshd ignoreip", "")
+	if _, err := f2bc.GetIgnoreIPs("sshd"); err != nil {
+		t.Errorf("GetIgnoreIPs() error = %v after removing the failure", err)
+	}
+
+	server.SetLatency(50 * time.Millisecond)
+	start := time.Now()
+	if _, err := f2bc.GetVersion(); err != nil {
+		t.Errorf("GetVersion() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected the answer to be delayed, took %s", elapsed)
+	}
+	server.SetLatency(0)
+
+	server.DropCommand("status", true)
+	if _, err := f2bc.GetJailNames(); err == nil {
+		t.Error("Expected GetJailNames() to fail when the connection is dropped")
+	}
+}
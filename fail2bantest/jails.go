@@ -0,0 +1,335 @@
+package fail2bantest
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	ogórek "github.com/kisielk/og-rek"
+)
+
+const timeLayout = "2006-01-02 15:04:05"
+
+// permanentEnd is the end fail2ban lists for bans without a ban time
+var permanentEnd = time.Date(9999, 12, 31, 23, 59, 59, 0, time.Local)
+
+// Jail is the state of a fake jail, times are in seconds like fail2ban uses them
+type Jail struct {
+	Name            string
+	BanTime         int
+	FindTime        int
+	MaxRetry        int
+	LogPaths        []string
+	FailRegex       []string
+	IgnoreRegex     []string
+	IgnoreIPs       []string
+	Actions         []string
+	UseDNS          string
+	CurrentlyFailed int
+	TotalFailed     int
+	TotalBanned     int
+	Bans            []Ban
+}
+
+// Ban is a banned address of a jail, a ban time of -1 bans the address permanently
+type Ban struct {
+	Address  string
+	BannedAt time.Time
+	BanTime  int
+}
+
+func (ban Ban) end() time.Time {
+	if ban.BanTime < 0 {
+		return permanentEnd
+	}
+	return ban.BannedAt.Add(time.Duration(ban.BanTime) * time.Second)
+}
+
+func (ban Ban) expired(now time.Time) bool {
+	return ban.BanTime >= 0 && !ban.end().After(now)
+}
+
+// profile is the shape of the responses which differs between the fail2ban releases
+type profile struct {
+	banSeparator string
+}
+
+func profileFor(version string) profile {
+	if strings.HasPrefix(version, "1.0.") {
+		return profile{banSeparator: " \t \t"}
+	}
+	return profile{banSeparator: " \t"}
+}
+
+// AddJail adds the jail or replaces the jail with the same name
+func (server *Server) AddJail(jail Jail) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	jail.Bans = slices.Clone(jail.Bans)
+	delete(server.stopped, jail.Name)
+	server.jails[jail.Name] = &jail
+}
+
+// RemoveJail removes the jail, whether it is running or stopped
+func (server *Server) RemoveJail(name string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	delete(server.jails, name)
+	delete(server.stopped, name)
+}
+
+// Jail returns a copy of the running jail
+func (server *Server) Jail(name string) (Jail, bool) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	jail, ok := server.jails[name]
+	if !ok {
+		return Jail{}, false
+	}
+	jail.prune(time.Now())
+	result := *jail
+	result.Bans = slices.Clone(jail.Bans)
+	return result, true
+}
+
+// Ban bans the address in the jail like fail2ban does after too many failures,
+// a ban time of 0 uses the ban time of the jail
+func (server *Server) Ban(jailName string, address string, bannedAt time.Time, banTime int) error {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	jail, ok := server.jails[jailName]
+	if !ok {
+		return fmt.Errorf("unknown jail %q", jailName)
+	}
+	if banTime == 0 {
+		banTime = jail.BanTime
+	}
+	jail.ban(address, bannedAt, banTime)
+	return nil
+}
+
+// Unban removes the ban of the address and tells if it was banned
+func (server *Server) Unban(jailName string, address string) bool {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	jail, ok := server.jails[jailName]
+	return ok && jail.unban(address)
+}
+
+// Fail counts failures of the jail, they are added to the total failures as well
+func (server *Server) Fail(jailName string, failures int) error {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	jail, ok := server.jails[jailName]
+	if !ok {
+		return fmt.Errorf("unknown jail %q", jailName)
+	}
+	jail.CurrentlyFailed += failures
+	jail.TotalFailed += failures
+	return nil
+}
+
+func (jail *Jail) ban(address string, bannedAt time.Time, banTime int) {
+	jail.unban(address)
+	jail.Bans = append(jail.Bans, Ban{Address: address, BannedAt: bannedAt, BanTime: banTime})
+	jail.TotalBanned++
+	jail.CurrentlyFailed = max(0, jail.CurrentlyFailed-jail.MaxRetry)
+}
+
+func (jail *Jail) unban(address string) bool {
+	index := slices.IndexFunc(jail.Bans, func(ban Ban) bool { return ban.Address == address })
+	if index < 0 {
+		return false
+	}
+	jail.Bans = slices.Delete(jail.Bans, index, index+1)
+	return true
+}
+
+// prune removes the expired bans like fail2ban does when the ban time is over
+func (jail *Jail) prune(now time.Time) {
+	jail.Bans = slices.DeleteFunc(jail.Bans, func(ban Ban) bool { return ban.expired(now) })
+}
+
+// execute runs the command against the jails and returns the value of the response,
+// the mutex of the server is held already
+func (server *Server) execute(command []string, now time.Time) (interface{}, *commandError) {
+	if len(command) == 0 {
+		return nil, invalidCommand(command)
+	}
+	switch {
+	case len(command) == 1 && command[0] == "ping":
+		return "pong", nil
+	case len(command) == 1 && command[0] == "version":
+		return server.version, nil
+	case len(command) == 1 && command[0] == "status":
+		return server.status(), nil
+	case len(command) < 2:
+		return nil, invalidCommand(command)
+	case command[0] == "start":
+		return server.start(command[1])
+	case command[0] == "stop":
+		return server.stop(command[1])
+	}
+
+	jail, ok := server.jails[command[1]]
+	if !ok {
+		return nil, unknownJail(command[1])
+	}
+	jail.prune(now)
+
+	switch {
+	case len(command) == 2 && command[0] == "status":
+		return jail.status(), nil
+	case len(command) >= 3 && command[0] == "get":
+		return server.get(jail, command[2:])
+	case len(command) == 4 && command[0] == "set":
+		return server.set(jail, command[2], command[3], now)
+	}
+	return nil, invalidCommand(command)
+}
+
+func (server *Server) jailNames() []string {
+	names := make([]string, 0, len(server.jails))
+	for name := range server.jails {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (server *Server) status() []interface{} {
+	names := server.jailNames()
+	return []interface{}{
+		ogórek.Tuple{"Number of jail", len(names)},
+		ogórek.Tuple{"Jail list", strings.Join(names, ", ")},
+	}
+}
+
+func (jail *Jail) status() []interface{} {
+	addresses := make([]interface{}, 0, len(jail.Bans))
+	for _, ban := range jail.Bans {
+		addresses = append(addresses, ban.Address)
+	}
+	return []interface{}{
+		ogórek.Tuple{"Filter", []interface{}{
+			ogórek.Tuple{"Currently failed", jail.CurrentlyFailed},
+			ogórek.Tuple{"Total failed", jail.TotalFailed},
+			ogórek.Tuple{"File list", toList(jail.LogPaths)},
+		}},
+		ogórek.Tuple{"Actions", []interface{}{
+			ogórek.Tuple{"Currently banned", len(jail.Bans)},
+			ogórek.Tuple{"Total banned", jail.TotalBanned},
+			ogórek.Tuple{"Banned IP list", addresses},
+		}},
+	}
+}
+
+func (server *Server) start(name string) (interface{}, *commandError) {
+	if _, running := server.jails[name]; running {
+		return nil, &commandError{module: "builtins", name: "ValueError", message: fmt.Sprintf("jail %s is already running", name)}
+	}
+	jail, stopped := server.stopped[name]
+	if !stopped {
+		return nil, unknownJail(name)
+	}
+	delete(server.stopped, name)
+	server.jails[name] = jail
+	return nil, nil
+}
+
+// stop keeps the jail to allow starting it again, fail2ban reads it from the configuration instead
+func (server *Server) stop(name string) (interface{}, *commandError) {
+	jail, running := server.jails[name]
+	if !running {
+		return nil, unknownJail(name)
+	}
+	jail.Bans = nil
+	jail.CurrentlyFailed = 0
+	delete(server.jails, name)
+	server.stopped[name] = jail
+	return nil, nil
+}
+
+func (server *Server) get(jail *Jail, arguments []string) (interface{}, *commandError) {
+	if len(arguments) == 2 && arguments[0] == "banip" && arguments[1] == "--with-time" {
+		separator := profileFor(server.version).banSeparator
+		entries := make([]interface{}, 0, len(jail.Bans))
+		for _, ban := range jail.Bans {
+			entries = append(entries, fmt.Sprintf("%s%s%s + %d = %s",
+				ban.Address, separator, ban.BannedAt.Format(timeLayout), ban.BanTime, ban.end().Format(timeLayout)))
+		}
+		return entries, nil
+	}
+	if len(arguments) != 1 {
+		return nil, invalidCommand(append([]string{"get", jail.Name}, arguments...))
+	}
+
+	switch arguments[0] {
+	case "banip":
+		addresses := make([]interface{}, 0, len(jail.Bans))
+		for _, ban := range jail.Bans {
+			addresses = append(addresses, ban.Address)
+		}
+		return addresses, nil
+	case "bantime":
+		return jail.BanTime, nil
+	case "findtime":
+		return jail.FindTime, nil
+	case "maxretry":
+		return jail.MaxRetry, nil
+	case "logpath":
+		return toList(jail.LogPaths), nil
+	case "failregex":
+		return toList(jail.FailRegex), nil
+	case "ignoreregex":
+		return toList(jail.IgnoreRegex), nil
+	case "ignoreip":
+		return toList(jail.IgnoreIPs), nil
+	case "actions":
+		return toList(jail.Actions), nil
+	case "usedns":
+		return jail.UseDNS, nil
+	case "bantime.increment":
+		return false, nil
+	}
+	return nil, &commandError{module: "builtins", name: "KeyError", message: "Invalid command: " + arguments[0]}
+}
+
+func (server *Server) set(jail *Jail, setting string, address string, now time.Time) (interface{}, *commandError) {
+	switch setting {
+	case "banip":
+		jail.ban(address, now, jail.BanTime)
+		return 1, nil
+	case "unbanip":
+		if !jail.unban(address) {
+			return nil, &commandError{module: "builtins", name: "ValueError", message: fmt.Sprintf("%s is not banned", address)}
+		}
+		return 1, nil
+	case "addignoreip":
+		if !slices.Contains(jail.IgnoreIPs, address) {
+			jail.IgnoreIPs = append(jail.IgnoreIPs, address)
+		}
+		return toList(jail.IgnoreIPs), nil
+	case "delignoreip":
+		jail.IgnoreIPs = slices.DeleteFunc(jail.IgnoreIPs, func(ignored string) bool { return ignored == address })
+		return toList(jail.IgnoreIPs), nil
+	}
+	return nil, invalidCommand([]string{"set", jail.Name, setting, address})
+}
+
+func toList(values []string) []interface{} {
+	list := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		list = append(list, value)
+	}
+	return list
+}
+
+func unknownJail(name string) *commandError {
+	return &commandError{module: "fail2ban.server.jails", name: "UnknownJailException", message: name}
+}
+
+func invalidCommand(command []string) *commandError {
+	return &commandError{module: "builtins", name: "Exception", message: "Invalid command: " + strings.Join(command, " ")}
+}
@@ -64,6 +64,24 @@ func (geoIP *GeoIP) Lookup(value string) (string, bool) {
 	return geoIP.findCountry(value)
 }
 
+// Updated is when the IPv4 data was downloaded, zero when there is no data yet
+func (geoIP *GeoIP) Updated() time.Time {
+	if geoIP.dir == "" {
+		return time.Time{}
+	}
+	stat, err := os.Stat(filepath.Join(geoIP.dir, cacheName4))
+	if err != nil {
+		return time.Time{}
+	}
+	return stat.ModTime()
+}
+
+// Stale tells if there is no data or it is older than expected, e.g. because the download failed
+func (geoIP *GeoIP) Stale() bool {
+	updated := geoIP.Updated()
+	return updated.IsZero() || time.Since(updated) > 2*cacheTTL
+}
+
 func (geoIP *GeoIP) scheduledDownload() {
 	duration := cacheTTL + 10*time.Minute
 	ticker := time.NewTicker(duration)
@@ -1,18 +1,21 @@
 package metrics
 
 import (
+	"crypto/tls"
+
 	"github.com/gofiber/fiber/v3"
 	"github.com/gofiber/fiber/v3/log"
 	"github.com/gofiber/fiber/v3/middleware/adaptor"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
 	"github.com/webishdev/fail2ban-dashboard/store"
 )
 
 type Configuration struct {
-	Address         string
-	Fail2BanVersion string
-	Version         string
+	Address   string
+	Version   string
+	TLSConfig *tls.Config
 }
 
 type metrics struct {
@@ -24,14 +27,19 @@ type metrics struct {
 	jailFailedCurrentMetrics *prometheus.GaugeVec
 	jailBannedTotalMetrics   *prometheus.GaugeVec
 	jailFailedTotalMetrics   *prometheus.GaugeVec
+	connectedMetrics         prometheus.Gauge
+	connectionSinceMetrics   prometheus.Gauge
+	lastRefreshMetrics       prometheus.Gauge
+	refreshFailedMetrics     prometheus.Gauge
+	jailRefreshFailedMetrics *prometheus.GaugeVec
 }
 
 func RegisterMetricsEndpoints(app *fiber.App, dataStore *store.DataStore, configuration *Configuration) {
 
 	currentMetrics := setupRegistry()
-	currentMetrics.versionInfoMetrics.WithLabelValues(configuration.Version, configuration.Fail2BanVersion).Set(1)
+	currentMetrics.versionInfoMetrics.WithLabelValues(configuration.Version, dataStore.Fail2BanVersion()).Set(1)
 
-	updateMetrics(currentMetrics, dataStore)
+	updateMetrics(currentMetrics, dataStore, configuration.Version)
 
 	app.Get("/metrics", adaptor.HTTPHandler(promhttp.HandlerFor(currentMetrics.reg, promhttp.HandlerOpts{})))
 }
@@ -86,9 +94,40 @@ func setupRegistry() *metrics {
 			},
 			[]string{"jail"},
 		),
+		connectedMetrics: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "fail2ban_dashboard_connected",
+				Help: "Whether the fail2ban socket is connected (1) or reconnecting (0)",
+			},
+		),
+		connectionSinceMetrics: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "fail2ban_dashboard_connection_changed_timestamp_seconds",
+				Help: "Unix time the fail2ban socket was connected or lost",
+			},
+		),
+		lastRefreshMetrics: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "fail2ban_dashboard_last_refresh_success_timestamp_seconds",
+				Help: "Unix time all fail2ban data was fetched successfully",
+			},
+		),
+		refreshFailedMetrics: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "fail2ban_dashboard_refresh_failed",
+				Help: "Whether the last refresh of the fail2ban data failed (1) or not (0)",
+			},
+		),
+		jailRefreshFailedMetrics: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "fail2ban_dashboard_jail_refresh_failed",
+				Help: "Whether the jail could not be fetched by the last refresh (1) and shows outdated data",
+			},
+			[]string{"jail"},
+		),
 	}
 
-	result.reg.MustRegister(result.versionInfoMetrics, result.jailCountMetrics, result.bannedSumMetrics, result.jailBannedCurrentMetrics, result.jailFailedCurrentMetrics, result.jailBannedTotalMetrics, result.jailFailedTotalMetrics)
+	result.reg.MustRegister(result.versionInfoMetrics, result.jailCountMetrics, result.bannedSumMetrics, result.jailBannedCurrentMetrics, result.jailFailedCurrentMetrics, result.jailBannedTotalMetrics, result.jailFailedTotalMetrics, result.connectedMetrics, result.connectionSinceMetrics, result.lastRefreshMetrics, result.refreshFailedMetrics, result.jailRefreshFailedMetrics)
 
 	return result
 }
@@ -97,11 +136,17 @@ func setupRegistry() *metrics {
 type dataStoreInterface interface {
 	RegisterUpdateHandler(handler store.UpdateHandler)
 	GetJails() []store.Jail
+	ConnectionState() client.ConnectionState
+	RefreshStatus() store.RefreshStatus
+	Fail2BanVersion() string
 }
 
-func updateMetrics(currentMetrics *metrics, dataStore dataStoreInterface) {
+func updateMetrics(currentMetrics *metrics, dataStore dataStoreInterface, version string) {
 	dataStore.RegisterUpdateHandler(func() {
 		log.Debug("Updating metrics")
+		// the version of fail2ban is read again after each reconnect
+		currentMetrics.versionInfoMetrics.Reset()
+		currentMetrics.versionInfoMetrics.WithLabelValues(version, dataStore.Fail2BanVersion()).Set(1)
 		jails := dataStore.GetJails()
 		jailCount := float64(len(jails))
 		currentMetrics.jailCountMetrics.Set(jailCount)
@@ -118,5 +163,33 @@ func updateMetrics(currentMetrics *metrics, dataStore dataStoreInterface) {
 			currentMetrics.jailFailedTotalMetrics.WithLabelValues(jail.Name).Set(totalFailed)
 		}
 		currentMetrics.bannedSumMetrics.Set(float64(sum))
+
+		connectionState := dataStore.ConnectionState()
+		if connectionState.Connected {
+			currentMetrics.connectedMetrics.Set(1)
+		} else {
+			currentMetrics.connectedMetrics.Set(0)
+		}
+		if !connectionState.Since.IsZero() {
+			currentMetrics.connectionSinceMetrics.Set(float64(connectionState.Since.Unix()))
+		}
+
+		refreshStatus := dataStore.RefreshStatus()
+		if !refreshStatus.LastSuccess.IsZero() {
+			currentMetrics.lastRefreshMetrics.Set(float64(refreshStatus.LastSuccess.Unix()))
+		}
+		if refreshStatus.Failed() {
+			currentMetrics.refreshFailedMetrics.Set(1)
+		} else {
+			currentMetrics.refreshFailedMetrics.Set(0)
+		}
+		currentMetrics.jailRefreshFailedMetrics.Reset()
+		for _, jail := range jails {
+			if _, failed := refreshStatus.FailedJails[jail.Name]; failed {
+				currentMetrics.jailRefreshFailedMetrics.WithLabelValues(jail.Name).Set(1)
+			} else {
+				currentMetrics.jailRefreshFailedMetrics.WithLabelValues(jail.Name).Set(0)
+			}
+		}
 	})
 }
@@ -2,9 +2,13 @@ package metrics
 
 import (
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	dto "github.com/prometheus/client_model/go"
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+	"github.com/webishdev/fail2ban-dashboard/fail2bantest"
 	"github.com/webishdev/fail2ban-dashboard/store"
 )
 
@@ -123,8 +127,11 @@ func TestSetupRegistry(t *testing.T) {
 
 // mockDataStore is a simple mock for DataStore that allows us to test updateMetrics
 type mockDataStore struct {
-	jails   []store.Jail
-	handler store.UpdateHandler
+	jails      []store.Jail
+	connection client.ConnectionState
+	refresh    store.RefreshStatus
+	version    string
+	handler    store.UpdateHandler
 }
 
 func newMockDataStore(jails []store.Jail) *mockDataStore {
@@ -137,6 +144,18 @@ func (m *mockDataStore) GetJails() []store.Jail {
 	return m.jails
 }
 
+func (m *mockDataStore) ConnectionState() client.ConnectionState {
+	return m.connection
+}
+
+func (m *mockDataStore) RefreshStatus() store.RefreshStatus {
+	return m.refresh
+}
+
+func (m *mockDataStore) Fail2BanVersion() string {
+	return m.version
+}
+
 func (m *mockDataStore) RegisterUpdateHandler(handler store.UpdateHandler) {
 	m.handler = handler
 }
@@ -152,7 +171,7 @@ func TestUpdateMetrics(t *testing.T) {
 		m := setupRegistry()
 		mock := newMockDataStore(nil)
 
-		updateMetrics(m, mock)
+		updateMetrics(m, mock, "1.0.0")
 
 		if mock.handler == nil {
 			t.Error("expected handler to be non-nil")
@@ -178,7 +197,7 @@ func TestUpdateMetrics(t *testing.T) {
 			},
 		})
 
-		updateMetrics(m, mock)
+		updateMetrics(m, mock, "1.0.0")
 		mock.TriggerUpdate()
 
 		// Verify jail count
@@ -257,7 +276,7 @@ func TestUpdateMetrics(t *testing.T) {
 		m := setupRegistry()
 		mock := newMockDataStore([]store.Jail{})
 
-		updateMetrics(m, mock)
+		updateMetrics(m, mock, "1.0.0")
 		mock.TriggerUpdate()
 
 		// Verify jail count is 0
@@ -278,7 +297,7 @@ func TestUpdateMetrics(t *testing.T) {
 			},
 		})
 
-		updateMetrics(m, mock)
+		updateMetrics(m, mock, "1.0.0")
 		mock.TriggerUpdate()
 
 		// First check
@@ -324,4 +343,111 @@ func TestUpdateMetrics(t *testing.T) {
 			t.Errorf("expected nginx banned current 2.0, got %f", actual)
 		}
 	})
+
+	t.Run("updates connection state", func(t *testing.T) {
+		m := setupRegistry()
+		mock := newMockDataStore([]store.Jail{})
+		since := time.Unix(1700000000, 0)
+		mock.connection = client.ConnectionState{Connected: true, Since: since}
+
+		updateMetrics(m, mock, "1.0.0")
+		mock.TriggerUpdate()
+
+		if actual := testutil.ToFloat64(m.connectedMetrics); actual != 1.0 {
+			t.Errorf("expected connected 1.0, got %f", actual)
+		}
+		if actual := testutil.ToFloat64(m.connectionSinceMetrics); actual != 1700000000.0 {
+			t.Errorf("expected connection timestamp 1700000000, got %f", actual)
+		}
+
+		mock.connection = client.ConnectionState{Connected: false, Since: since.Add(time.Minute)}
+		mock.TriggerUpdate()
+
+		if actual := testutil.ToFloat64(m.connectedMetrics); actual != 0.0 {
+			t.Errorf("expected connected 0.0, got %f", actual)
+		}
+		if actual := testutil.ToFloat64(m.connectionSinceMetrics); actual != 1700000060.0 {
+			t.Errorf("expected connection timestamp 1700000060, got %f", actual)
+		}
+	})
+	t.Run("updates refresh status", func(t *testing.T) {
+		m := setupRegistry()
+		mock := newMockDataStore([]store.Jail{{Name: "sshd"}, {Name: "nginx"}})
+		lastSuccess := time.Unix(1700000000, 0)
+		mock.refresh = store.RefreshStatus{LastSuccess: lastSuccess, FailedJails: map[string]string{"nginx": "broken pipe"}}
+
+		updateMetrics(m, mock, "1.0.0")
+		mock.TriggerUpdate()
+
+		if actual := testutil.ToFloat64(m.lastRefreshMetrics); actual != 1700000000.0 {
+			t.Errorf("expected last refresh timestamp 1700000000, got %f", actual)
+		}
+		if actual := testutil.ToFloat64(m.refreshFailedMetrics); actual != 1.0 {
+			t.Errorf("expected refresh failed 1.0, got %f", actual)
+		}
+		nginxFailed, _ := m.jailRefreshFailedMetrics.GetMetricWithLabelValues("nginx")
+		if actual := testutil.ToFloat64(nginxFailed); actual != 1.0 {
+			t.Errorf("expected nginx refresh failed 1.0, got %f", actual)
+		}
+		sshdFailed, _ := m.jailRefreshFailedMetrics.GetMetricWithLabelValues("sshd")
+		if actual := testutil.ToFloat64(sshdFailed); actual != 0.0 {
+			t.Errorf("expected sshd refresh failed 0.0, got %f", actual)
+		}
+
+		mock.refresh = store.RefreshStatus{LastSuccess: lastSuccess.Add(time.Minute)}
+		mock.TriggerUpdate()
+
+		if actual := testutil.ToFloat64(m.refreshFailedMetrics); actual != 0.0 {
+			t.Errorf("expected refresh failed 0.0, got %f", actual)
+		}
+	})
+}
+
+func TestUpdateMetricsWithFail2Ban(t *testing.T) {
+	fail2ban := fail2bantest.Start(t, fail2bantest.Version0_11)
+	fail2ban.AddJail(fail2bantest.Jail{
+		Name:            "sshd",
+		BanTime:         600,
+		CurrentlyFailed: 4,
+		TotalFailed:     40,
+		TotalBanned:     7,
+		Bans:            []fail2bantest.Ban{{Address: "192.0.2.10", BannedAt: time.Now(), BanTime: 600}},
+	})
+	f2bc, err := client.NewFail2BanClient(fail2ban.Address())
+	if err != nil {
+		t.Fatalf("NewFail2BanClient() error = %v", err)
+	}
+	t.Cleanup(func() { _ = f2bc.Close() })
+	dataStore := store.NewDataStore(f2bc, 30)
+
+	m := setupRegistry()
+	updateMetrics(m, dataStore, "1.0.0")
+	if _, err = f2bc.GetVersion(); err != nil {
+		t.Fatalf("GetVersion() error = %v", err)
+	}
+	if err = dataStore.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	// the update handlers run in the background
+	for deadline := time.Now().Add(time.Second); testutil.ToFloat64(m.bannedSumMetrics) == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	expected := map[*prometheus.GaugeVec]float64{
+		m.jailBannedCurrentMetrics: 1,
+		m.jailBannedTotalMetrics:   7,
+		m.jailFailedCurrentMetrics: 4,
+		m.jailFailedTotalMetrics:   40,
+	}
+	for gauge, value := range expected {
+		if actual := testutil.ToFloat64(gauge.WithLabelValues("sshd")); actual != value {
+			t.Errorf("expected %f, got %f", value, actual)
+		}
+	}
+	if actual := testutil.ToFloat64(m.jailCountMetrics); actual != 1.0 {
+		t.Errorf("expected jail count 1.0, got %f", actual)
+	}
+	if actual := testutil.ToFloat64(m.versionInfoMetrics.WithLabelValues("1.0.0", fail2bantest.Version0_11)); actual != 1.0 {
+		t.Errorf("expected the detected fail2ban version in the info metric, got %f", actual)
+	}
 }
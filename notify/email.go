@@ -0,0 +1,370 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	_ "embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	textTemplate "text/template"
+	"time"
+
+	"github.com/gofiber/fiber/v3/log"
+	"github.com/webishdev/fail2ban-dashboard/store"
+)
+
+//go:embed resources/alert.html
+var alertHtml []byte
+
+//go:embed resources/alert.txt
+var alertTxt []byte
+
+//go:embed resources/digest.html
+var digestHtml []byte
+
+//go:embed resources/digest.txt
+var digestTxt []byte
+
+const (
+	SecurityStartTLS = "starttls"
+	SecurityTLS      = "tls"
+	SecurityNone     = "none"
+)
+
+// smtpTimeout limits a whole SMTP session, from connecting to the server until quitting
+const smtpTimeout = 30 * time.Second
+
+// SMTPConfiguration configures the email notifier, alerts are only sent for the alert jails
+// and the digest is disabled when it is empty
+type SMTPConfiguration struct {
+	Host       string
+	Port       int
+	Username   string
+	Password   string
+	From       string
+	To         []string
+	Security   string
+	AlertJails []string
+	Digest     string
+	DigestHour int
+	Debounce   time.Duration
+}
+
+// HistorySource returns the history entries of bans started since the given time
+type HistorySource func(since time.Time) []store.HistoryEntry
+
+type alertData struct {
+	Title string
+	Digest
+}
+
+// EmailNotifier sends alerts for new bans of selected jails and a daily or weekly digest report
+type EmailNotifier struct {
+	configuration SMTPConfiguration
+	from          *mail.Address
+	to            []*mail.Address
+	lookup        CountryLookup
+	history       HistorySource
+	alerts        *batch
+	templates     emailTemplates
+	done          chan struct{}
+}
+
+type emailTemplates struct {
+	alertHtml  *template.Template
+	alertText  *textTemplate.Template
+	digestHtml *template.Template
+	digestText *textTemplate.Template
+}
+
+func NewEmailNotifier(configuration SMTPConfiguration, lookup CountryLookup, history HistorySource) (*EmailNotifier, error) {
+	if configuration.Host == "" {
+		return nil, errors.New("SMTP host is missing")
+	}
+	if configuration.Port < 1 || configuration.Port > 65535 {
+		return nil, fmt.Errorf("SMTP port %d is not valid", configuration.Port)
+	}
+	if configuration.Security == "" {
+		configuration.Security = SecurityStartTLS
+	}
+	if configuration.Security != SecurityStartTLS && configuration.Security != SecurityTLS && configuration.Security != SecurityNone {
+		return nil, fmt.Errorf("unknown SMTP security %s, supported are %s, %s and %s", configuration.Security, SecurityStartTLS, SecurityTLS, SecurityNone)
+	}
+	if configuration.Digest != "" && configuration.Digest != DigestDaily && configuration.Digest != DigestWeekly {
+		return nil, fmt.Errorf("unknown digest %s, supported are %s and %s", configuration.Digest, DigestDaily, DigestWeekly)
+	}
+	if configuration.Digest != "" && history == nil {
+		return nil, errors.New("the digest report needs the ban history")
+	}
+	if configuration.DigestHour < 0 || configuration.DigestHour > 23 {
+		return nil, fmt.Errorf("digest hour %d must be between 0 and 23", configuration.DigestHour)
+	}
+	if configuration.Debounce <= 0 {
+		configuration.Debounce = defaultDebounce
+	}
+
+	from, fromErr := mail.ParseAddress(configuration.From)
+	if fromErr != nil {
+		return nil, fmt.Errorf("sender %q is not a valid email address", configuration.From)
+	}
+	if len(configuration.To) == 0 {
+		return nil, errors.New("no recipient for emails configured")
+	}
+	to := make([]*mail.Address, len(configuration.To))
+	for index, recipient := range configuration.To {
+		address, addressErr := mail.ParseAddress(recipient)
+		if addressErr != nil {
+			return nil, fmt.Errorf("recipient %q is not a valid email address", recipient)
+		}
+		to[index] = address
+	}
+
+	templates, templatesErr := parseEmailTemplates()
+	if templatesErr != nil {
+		return nil, templatesErr
+	}
+
+	notifier := &EmailNotifier{
+		configuration: configuration,
+		from:          from,
+		to:            to,
+		lookup:        lookup,
+		history:       history,
+		templates:     templates,
+		done:          make(chan struct{}),
+	}
+	notifier.alerts = &batch{debounce: configuration.Debounce, flush: notifier.sendAlert}
+	return notifier, nil
+}
+
+func parseEmailTemplates() (emailTemplates, error) {
+	functions := map[string]any{
+		"time": func(t time.Time) string {
+			return t.Format("2006-01-02 15:04 MST")
+		},
+		"formatPenalty": func(p string) string {
+			if p == "-1" {
+				return "permanent"
+			}
+			return p
+		},
+		"line": notificationLine,
+	}
+
+	alertHtmlTemplate, alertHtmlErr := template.New("alertHtml").Funcs(functions).Parse(string(alertHtml))
+	if alertHtmlErr != nil {
+		return emailTemplates{}, alertHtmlErr
+	}
+
+	alertTextTemplate, alertTextErr := textTemplate.New("alertText").Funcs(functions).Parse(string(alertTxt))
+	if alertTextErr != nil {
+		return emailTemplates{}, alertTextErr
+	}
+
+	digestHtmlTemplate, digestHtmlErr := template.New("digestHtml").Funcs(functions).Parse(string(digestHtml))
+	if digestHtmlErr != nil {
+		return emailTemplates{}, digestHtmlErr
+	}
+
+	digestTextTemplate, digestTextErr := textTemplate.New("digestText").Funcs(functions).Parse(string(digestTxt))
+	if digestTextErr != nil {
+		return emailTemplates{}, digestTextErr
+	}
+
+	return emailTemplates{
+		alertHtml:  alertHtmlTemplate,
+		alertText:  alertTextTemplate,
+		digestHtml: digestHtmlTemplate,
+		digestText: digestTextTemplate,
+	}, nil
+}
+
+// Handle is subscribed to the data store and collects new bans of the alert jails
+func (notifier *EmailNotifier) Handle(update store.Update) {
+	if update.Initial || len(notifier.configuration.AlertJails) == 0 {
+		return
+	}
+	matching := make([]Notification, 0)
+	for _, notification := range toNotifications(update, notifier.lookup) {
+		if notification.Type == NotificationAdded && containsFold(notifier.configuration.AlertJails, notification.JailName) {
+			matching = append(matching, notification)
+		}
+	}
+	notifier.alerts.add(matching)
+}
+
+func (notifier *EmailNotifier) sendAlert(digest Digest) {
+	data := alertData{Title: digestTitle(digest), Digest: digest}
+	err := notifier.render(data.Title, notifier.templates.alertText, notifier.templates.alertHtml, data)
+	if err != nil {
+		log.Errorf("Could not send email alert about %d bans: %s", len(digest.Notifications), err)
+		return
+	}
+	log.Debugf("Sent email alert about %d bans", len(digest.Notifications))
+}
+
+// SendDigest reports the bans of the digest period ending at the given time
+func (notifier *EmailNotifier) SendDigest(to time.Time) error {
+	since := to.Add(-2 * digestPeriod(notifier.configuration.Digest))
+	report := NewReport(notifier.history(since), notifier.configuration.Digest, to)
+	return notifier.render(report.Title(), notifier.templates.digestText, notifier.templates.digestHtml, report)
+}
+
+// Start sends the digest on schedule until the notifier is closed
+func (notifier *EmailNotifier) Start() {
+	if notifier.configuration.Digest == "" {
+		return
+	}
+	go func() {
+		for {
+			next := nextDigest(time.Now(), notifier.configuration.Digest, notifier.configuration.DigestHour)
+			log.Debugf("Next %s digest will be sent at %s", notifier.configuration.Digest, next.Format(time.RFC3339))
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-notifier.done:
+				timer.Stop()
+				return
+			case <-timer.C:
+				if err := notifier.SendDigest(next); err != nil {
+					log.Errorf("Could not send %s digest: %s", notifier.configuration.Digest, err)
+				}
+			}
+		}
+	}()
+}
+
+func (notifier *EmailNotifier) Close() {
+	close(notifier.done)
+}
+
+func (notifier *EmailNotifier) render(subject string, text *textTemplate.Template, html *template.Template, data any) error {
+	var textBody, htmlBody bytes.Buffer
+	if err := text.Execute(&textBody, data); err != nil {
+		return err
+	}
+	if err := html.Execute(&htmlBody, data); err != nil {
+		return err
+	}
+	message, err := notifier.message(subject, textBody.Bytes(), htmlBody.Bytes())
+	if err != nil {
+		return err
+	}
+	return notifier.deliver(message)
+}
+
+// message builds a multipart email with the text and the HTML version of the body
+func (notifier *EmailNotifier) message(subject string, text []byte, html []byte) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType)
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		partWriter, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		encoder := quotedprintable.NewWriter(partWriter)
+		if _, err = encoder.Write(part.content); err != nil {
+			return nil, err
+		}
+		if err = encoder.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	recipients := make([]string, len(notifier.to))
+	for index, address := range notifier.to {
+		recipients[index] = address.String()
+	}
+
+	var message bytes.Buffer
+	message.WriteString("From: " + notifier.from.String() + "\r\n")
+	message.WriteString("To: " + strings.Join(recipients, ", ") + "\r\n")
+	message.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	message.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: multipart/alternative; boundary=" + writer.Boundary() + "\r\n")
+	message.WriteString("\r\n")
+	message.Write(body.Bytes())
+	return message.Bytes(), nil
+}
+
+// deliver sends the message to the SMTP server, credentials are only sent over TLS or to localhost
+func (notifier *EmailNotifier) deliver(message []byte) error {
+	configuration := notifier.configuration
+	address := net.JoinHostPort(configuration.Host, strconv.Itoa(configuration.Port))
+	tlsConfig := &tls.Config{ServerName: configuration.Host}
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+
+	var connection net.Conn
+	var err error
+	if configuration.Security == SecurityTLS {
+		connection, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+	} else {
+		connection, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return err
+	}
+	_ = connection.SetDeadline(time.Now().Add(smtpTimeout))
+
+	client, err := smtp.NewClient(connection, configuration.Host)
+	if err != nil {
+		_ = connection.Close()
+		return err
+	}
+	defer func() { _ = client.Close() }()
+
+	if configuration.Security == SecurityStartTLS {
+		if supported, _ := client.Extension("STARTTLS"); !supported {
+			return errors.New("SMTP server does not support STARTTLS")
+		}
+		if err = client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if configuration.Username != "" {
+		if err = client.Auth(smtp.PlainAuth("", configuration.Username, configuration.Password, configuration.Host)); err != nil {
+			return err
+		}
+	}
+	if err = client.Mail(notifier.from.Address); err != nil {
+		return err
+	}
+	for _, recipient := range notifier.to {
+		if err = client.Rcpt(recipient.Address); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = writer.Write(message); err != nil {
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
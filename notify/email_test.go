@@ -0,0 +1,341 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/webishdev/fail2ban-dashboard/store"
+)
+
+// receivedMail is a message accepted by the SMTP stand-in
+type receivedMail struct {
+	auth       string
+	from       string
+	recipients []string
+	data       string
+}
+
+// smtpServer is a minimal SMTP stand-in on localhost which accepts every message
+func smtpServer(t *testing.T, extensions ...string) (int, chan receivedMail) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	mails := make(chan receivedMail, 4)
+	go func() {
+		for {
+			connection, acceptErr := listener.Accept()
+			if acceptErr != nil {
+				return
+			}
+			go serveSMTP(connection, extensions, mails)
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port, mails
+}
+
+func serveSMTP(connection net.Conn, extensions []string, mails chan receivedMail) {
+	defer func() { _ = connection.Close() }()
+	reader := bufio.NewReader(connection)
+	reply := func(line string) {
+		_, _ = io.WriteString(connection, line+"\r\n")
+	}
+
+	reply("220 localhost ESMTP stand-in")
+	var current receivedMail
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch command {
+		case "EHLO":
+			for _, extension := range extensions {
+				reply("250-" + extension)
+			}
+			reply("250 localhost")
+		case "AUTH":
+			current.auth = line
+			reply("235 2.7.0 Authentication successful")
+		case "MAIL":
+			current.from = line
+			reply("250 OK")
+		case "RCPT":
+			current.recipients = append(current.recipients, line)
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, dataErr := reader.ReadString('\n')
+				if dataErr != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(dataLine, "."))
+			}
+			current.data = data.String()
+			mails <- current
+			current = receivedMail{}
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func receiveMail(t *testing.T, mails chan receivedMail) receivedMail {
+	t.Helper()
+	select {
+	case received := <-mails:
+		return received
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected an email")
+		return receivedMail{}
+	}
+}
+
+// mailParts returns the subject and the decoded text and HTML parts of a message
+func mailParts(t *testing.T, data string) (string, string, string) {
+	t.Helper()
+	message, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+	_, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("Failed to parse content type: %v", err)
+	}
+
+	parts := make(map[string]string)
+	reader := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, partErr := reader.NextPart()
+		if partErr != nil {
+			break
+		}
+		content, _ := io.ReadAll(part)
+		mediaType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[mediaType] = strings.ReplaceAll(string(content), "\r\n", "\n")
+	}
+	return subject, parts["text/plain"], parts["text/html"]
+}
+
+func newTestEmailNotifier(t *testing.T, port int, configure func(configuration *SMTPConfiguration), history HistorySource) *EmailNotifier {
+	t.Helper()
+	configuration := SMTPConfiguration{
+		Host:     "127.0.0.1",
+		Port:     port,
+		From:     "fail2ban <fail2ban@example.com>",
+		To:       []string{"oncall@example.com", "Admin <admin@example.com>"},
+		Security: SecurityNone,
+		Debounce: 20 * time.Millisecond,
+	}
+	if configure != nil {
+		configure(&configuration)
+	}
+	notifier, err := NewEmailNotifier(configuration, lookupCountry, history)
+	if err != nil {
+		t.Fatalf("Failed to create email notifier: %v", err)
+	}
+	return notifier
+}
+
+func TestEmailAlert(t *testing.T) {
+	port, mails := smtpServer(t, "AUTH PLAIN")
+	notifier := newTestEmailNotifier(t, port, func(configuration *SMTPConfiguration) {
+		configuration.Username = "user"
+		configuration.Password = "secret"
+		configuration.AlertJails = []string{"recidive"}
+	}, nil)
+
+	notifier.Handle(createUpdate(store.BanAdded, "sshd", "192.168.1.1"))
+	notifier.Handle(createUpdate(store.BanAdded, "recidive", "192.168.1.2", "10.0.0.1"))
+	notifier.Handle(createUpdate(store.BanRemoved, "recidive", "192.168.1.3"))
+
+	received := receiveMail(t, mails)
+	if expected := "AUTH PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00user\x00secret")); received.auth != expected {
+		t.Errorf("Expected %s, got %s", expected, received.auth)
+	}
+	if received.from != "MAIL FROM:<fail2ban@example.com>" {
+		t.Errorf("Unexpected sender %s", received.from)
+	}
+	if strings.Join(received.recipients, ",") != "RCPT TO:<oncall@example.com>,RCPT TO:<admin@example.com>" {
+		t.Errorf("Unexpected recipients %v", received.recipients)
+	}
+
+	subject, text, html := mailParts(t, received.data)
+	if subject != "fail2ban: 2 bans added" {
+		t.Errorf("Unexpected subject %s", subject)
+	}
+	for _, expected := range []string{"192.168.1.2 (US) banned in recidive for 600 seconds", "10.0.0.1 (DE) banned in recidive"} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected %q in text %s", expected, text)
+		}
+	}
+	if strings.Contains(text, "192.168.1.1") || strings.Contains(text, "192.168.1.3") {
+		t.Errorf("Expected only new bans of the alert jails in %s", text)
+	}
+	if !strings.Contains(html, "<td style=\"padding: 4px 12px 4px 0; font-family: monospace;\">10.0.0.1</td>") {
+		t.Errorf("Expected address row in html %s", html)
+	}
+
+	select {
+	case unexpected := <-mails:
+		t.Errorf("Expected one email, got another %s", unexpected.data)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestEmailDigest(t *testing.T) {
+	port, mails := smtpServer(t)
+	to := time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC)
+	entries := []store.HistoryEntry{
+		{Address: "192.168.1.1", JailName: "sshd", CountryCode: "US", BannedAt: to.Add(-time.Hour)},
+		{Address: "192.168.1.1", JailName: "recidive", CountryCode: "US", BannedAt: to.Add(-2 * time.Hour)},
+		{Address: "10.0.0.1", JailName: "sshd", CountryCode: "DE", BannedAt: to.Add(-3 * time.Hour)},
+		{Address: "10.0.0.2", JailName: "sshd", CountryCode: "DE", BannedAt: to.Add(-25 * time.Hour)},
+		{Address: "10.0.0.3", JailName: "postfix", BannedAt: to.Add(-26 * time.Hour)},
+	}
+	var requestedSince time.Time
+	notifier := newTestEmailNotifier(t, port, func(configuration *SMTPConfiguration) {
+		configuration.Digest = DigestDaily
+	}, func(since time.Time) []store.HistoryEntry {
+		requestedSince = since
+		return entries
+	})
+
+	if err := notifier.SendDigest(to); err != nil {
+		t.Fatalf("Failed to send digest: %v", err)
+	}
+	if !requestedSince.Equal(to.Add(-48 * time.Hour)) {
+		t.Errorf("Expected history since two periods, got %s", requestedSince)
+	}
+
+	subject, text, html := mailParts(t, receiveMail(t, mails).data)
+	if subject != "fail2ban daily report: 3 bans" {
+		t.Errorf("Unexpected subject %s", subject)
+	}
+	for _, expected := range []string{
+		"Bans: 3 (+1 compared to the previous period)",
+		"  sshd: 2 (+1)\n  recidive: 1 (+1)\n  postfix: 0 (-1)\n",
+		"  192.168.1.1: 2 (+2)\n  10.0.0.1: 1 (+1)\n",
+		"  US: 2 (+2)\n  DE: 1 (±0)\n",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected %q in text %s", expected, text)
+		}
+	}
+	if !strings.Contains(html, "<h3 style=\"margin-bottom: 4px;\">Top offending countries</h3>") {
+		t.Errorf("Expected countries section in html %s", html)
+	}
+}
+
+func TestEmailStartTLSRequired(t *testing.T) {
+	port, _ := smtpServer(t)
+	notifier := newTestEmailNotifier(t, port, func(configuration *SMTPConfiguration) {
+		configuration.Security = SecurityStartTLS
+		configuration.Digest = DigestWeekly
+	}, func(since time.Time) []store.HistoryEntry { return nil })
+
+	err := notifier.SendDigest(time.Now())
+	if err == nil || err.Error() != "SMTP server does not support STARTTLS" {
+		t.Errorf("Expected STARTTLS error, got %v", err)
+	}
+}
+
+func TestNewEmailNotifierErrors(t *testing.T) {
+	history := func(since time.Time) []store.HistoryEntry { return nil }
+	tests := []struct {
+		name      string
+		configure func(configuration *SMTPConfiguration)
+		history   HistorySource
+		expected  string
+	}{
+		{"missing host", func(c *SMTPConfiguration) { c.Host = "" }, nil, "SMTP host is missing"},
+		{"invalid port", func(c *SMTPConfiguration) { c.Port = 0 }, nil, "SMTP port 0 is not valid"},
+		{"unknown security", func(c *SMTPConfiguration) { c.Security = "ssl" }, nil, "unknown SMTP security ssl, supported are starttls, tls and none"},
+		{"unknown digest", func(c *SMTPConfiguration) { c.Digest = "monthly" }, history, "unknown digest monthly, supported are daily and weekly"},
+		{"digest without history", func(c *SMTPConfiguration) { c.Digest = DigestDaily }, nil, "the digest report needs the ban history"},
+		{"invalid digest hour", func(c *SMTPConfiguration) { c.DigestHour = 24 }, nil, "digest hour 24 must be between 0 and 23"},
+		{"invalid sender", func(c *SMTPConfiguration) { c.From = "fail2ban" }, nil, `sender "fail2ban" is not a valid email address`},
+		{"missing recipient", func(c *SMTPConfiguration) { c.To = nil }, nil, "no recipient for emails configured"},
+		{"invalid recipient", func(c *SMTPConfiguration) { c.To = []string{"oncall"} }, nil, `recipient "oncall" is not a valid email address`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configuration := SMTPConfiguration{Host: "127.0.0.1", Port: 25, From: "fail2ban@example.com", To: []string{"oncall@example.com"}}
+			tt.configure(&configuration)
+			_, err := NewEmailNotifier(configuration, nil, tt.history)
+			if err == nil || err.Error() != tt.expected {
+				t.Errorf("Expected error %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestNextDigest(t *testing.T) {
+	// 2025-03-12 is a Wednesday
+	now := time.Date(2025, 3, 12, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		now      time.Time
+		digest   string
+		hour     int
+		expected time.Time
+	}{
+		{"daily later today", now, DigestDaily, 18, time.Date(2025, 3, 12, 18, 0, 0, 0, time.UTC)},
+		{"daily tomorrow", now, DigestDaily, 8, time.Date(2025, 3, 13, 8, 0, 0, 0, time.UTC)},
+		{"daily exactly now", time.Date(2025, 3, 12, 8, 0, 0, 0, time.UTC), DigestDaily, 8, time.Date(2025, 3, 13, 8, 0, 0, 0, time.UTC)},
+		{"weekly next monday", now, DigestWeekly, 8, time.Date(2025, 3, 17, 8, 0, 0, 0, time.UTC)},
+		{"weekly later on monday", time.Date(2025, 3, 17, 7, 0, 0, 0, time.UTC), DigestWeekly, 8, time.Date(2025, 3, 17, 8, 0, 0, 0, time.UTC)},
+		{"weekly after monday hour", time.Date(2025, 3, 17, 9, 0, 0, 0, time.UTC), DigestWeekly, 8, time.Date(2025, 3, 24, 8, 0, 0, 0, time.UTC)},
+		{"weekly on sunday", time.Date(2025, 3, 16, 9, 0, 0, 0, time.UTC), DigestWeekly, 0, time.Date(2025, 3, 17, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := nextDigest(tt.now, tt.digest, tt.hour); !result.Equal(tt.expected) {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestReportLimitsTopOffenders(t *testing.T) {
+	to := time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC)
+	entries := make([]store.HistoryEntry, 0)
+	for index := range 15 {
+		entries = append(entries, store.HistoryEntry{Address: "10.0.0." + strconv.Itoa(index), JailName: "sshd", BannedAt: to.Add(-time.Minute)})
+	}
+	entries = append(entries, store.HistoryEntry{Address: "10.0.1.1", JailName: "sshd", BannedAt: to.Add(-25 * time.Hour)})
+
+	report := NewReport(entries, DigestDaily, to)
+	if len(report.Addresses) != maxReportRows {
+		t.Errorf("Expected %d addresses, got %d", maxReportRows, len(report.Addresses))
+	}
+	if len(report.Countries) != 1 || report.Countries[0].Name != "unknown" || report.Countries[0].Change() != "+14" {
+		t.Errorf("Unexpected countries %+v", report.Countries)
+	}
+}
@@ -0,0 +1,298 @@
+package notify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3/log"
+	"github.com/webishdev/fail2ban-dashboard/store"
+)
+
+const (
+	NotificationAdded   = "added"
+	NotificationRemoved = "removed"
+)
+
+const (
+	defaultDebounce     = 10 * time.Second
+	defaultRetries      = 3
+	defaultRetryBackoff = 2 * time.Second
+	requestTimeout      = 10 * time.Second
+)
+
+// Webhook is a single target for notifications, empty filters match everything
+type Webhook struct {
+	URL       string   `mapstructure:"url"`
+	Template  string   `mapstructure:"template"`
+	Jails     []string `mapstructure:"jails"`
+	Countries []string `mapstructure:"countries"`
+	Events    []string `mapstructure:"events"`
+}
+
+type Configuration struct {
+	Webhooks     []Webhook
+	Debounce     time.Duration
+	Retries      int
+	RetryBackoff time.Duration
+}
+
+type CountryLookup func(address string) string
+
+// Notification is a single added or removed ban
+type Notification struct {
+	Type        string    `json:"type"`
+	Address     string    `json:"address"`
+	JailName    string    `json:"jail"`
+	CountryCode string    `json:"countryCode,omitempty"`
+	BannedAt    time.Time `json:"bannedAt"`
+	BanEndsAt   time.Time `json:"banEndsAt"`
+	Penalty     string    `json:"penalty"`
+}
+
+// Digest collects the notifications of one debounce window into a single message
+type Digest struct {
+	Time          time.Time      `json:"time"`
+	Notifications []Notification `json:"notifications"`
+}
+
+// webhookState buffers the notifications of a webhook until its debounce window ends
+type webhookState struct {
+	webhook  Webhook
+	template payloadTemplate
+	batch    *batch
+}
+
+// batch collects notifications until its debounce window ends and hands them over as one digest
+type batch struct {
+	mutex    sync.Mutex
+	debounce time.Duration
+	pending  []Notification
+	timer    *time.Timer
+	flush    func(digest Digest)
+}
+
+func (b *batch) add(notifications []Notification) {
+	if len(notifications) == 0 {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.pending = append(b.pending, notifications...)
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.debounce, b.send)
+	}
+}
+
+func (b *batch) send() {
+	b.mutex.Lock()
+	digest := Digest{Time: time.Now(), Notifications: b.pending}
+	b.pending = nil
+	b.timer = nil
+	b.mutex.Unlock()
+	b.flush(digest)
+}
+
+// Notifier sends the added and removed bans of the data store to webhooks,
+// the notifications within the debounce window are sent as one digest
+type Notifier struct {
+	webhooks     []*webhookState
+	lookup       CountryLookup
+	debounce     time.Duration
+	retries      int
+	retryBackoff time.Duration
+	httpClient   *http.Client
+}
+
+func NewNotifier(configuration Configuration, lookup CountryLookup) (*Notifier, error) {
+	notifier := &Notifier{
+		lookup:       lookup,
+		debounce:     configuration.Debounce,
+		retries:      configuration.Retries,
+		retryBackoff: configuration.RetryBackoff,
+		httpClient:   &http.Client{Timeout: requestTimeout},
+	}
+	if notifier.debounce <= 0 {
+		notifier.debounce = defaultDebounce
+	}
+	if notifier.retries < 0 {
+		notifier.retries = defaultRetries
+	}
+	if notifier.retryBackoff <= 0 {
+		notifier.retryBackoff = defaultRetryBackoff
+	}
+
+	for index, webhook := range configuration.Webhooks {
+		parsed, err := url.Parse(webhook.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("webhook %d has no valid http or https URL", index+1)
+		}
+		if webhook.Template == "" {
+			webhook.Template = TemplateJSON
+		}
+		template, exists := templates[webhook.Template]
+		if !exists {
+			return nil, fmt.Errorf("webhook %d has the unknown template %s, supported templates are %s", index+1, webhook.Template, strings.Join(TemplateNames, ", "))
+		}
+		for _, event := range webhook.Events {
+			if event != NotificationAdded && event != NotificationRemoved {
+				return nil, fmt.Errorf("webhook %d has the unknown event %s, supported events are %s and %s", index+1, event, NotificationAdded, NotificationRemoved)
+			}
+		}
+		state := &webhookState{webhook: webhook, template: template}
+		state.batch = &batch{debounce: notifier.debounce, flush: func(digest Digest) {
+			notifier.flush(state, digest)
+		}}
+		notifier.webhooks = append(notifier.webhooks, state)
+	}
+
+	return notifier, nil
+}
+
+// Handle is subscribed to the data store, the initial update is skipped
+// as it reports every existing ban as added
+func (notifier *Notifier) Handle(update store.Update) {
+	if update.Initial {
+		return
+	}
+	notifications := toNotifications(update, notifier.lookup)
+	if len(notifications) == 0 {
+		return
+	}
+
+	for _, state := range notifier.webhooks {
+		matching := make([]Notification, 0, len(notifications))
+		for _, notification := range notifications {
+			if state.webhook.matches(notification) {
+				matching = append(matching, notification)
+			}
+		}
+		state.batch.add(matching)
+	}
+}
+
+// toNotifications converts the added and removed bans of an update, other events are skipped
+func toNotifications(update store.Update, lookup CountryLookup) []Notification {
+	notifications := make([]Notification, 0, len(update.Events))
+	for _, event := range update.Events {
+		var notificationType string
+		switch event.Type {
+		case store.BanAdded:
+			notificationType = NotificationAdded
+		case store.BanRemoved:
+			notificationType = NotificationRemoved
+		default:
+			continue
+		}
+		notification := Notification{
+			Type:      notificationType,
+			Address:   event.Ban.Address,
+			JailName:  event.JailName,
+			BannedAt:  event.Ban.BannedAt,
+			BanEndsAt: event.Ban.BanEndsAt,
+			Penalty:   event.Ban.CurrenPenalty,
+		}
+		if lookup != nil {
+			notification.CountryCode = lookup(event.Ban.Address)
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications
+}
+
+func (webhook Webhook) matches(notification Notification) bool {
+	if len(webhook.Events) > 0 && !containsFold(webhook.Events, notification.Type) {
+		return false
+	}
+	if len(webhook.Jails) > 0 && !containsFold(webhook.Jails, notification.JailName) {
+		return false
+	}
+	if len(webhook.Countries) > 0 && !containsFold(webhook.Countries, notification.CountryCode) {
+		return false
+	}
+	return true
+}
+
+func containsFold(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (notifier *Notifier) flush(state *webhookState, digest Digest) {
+	err := notifier.send(state, digest)
+	if err != nil {
+		log.Errorf("Could not notify webhook %s about %d bans: %s", redactURL(state.webhook.URL), len(digest.Notifications), err)
+		return
+	}
+	log.Debugf("Notified webhook %s about %d bans", redactURL(state.webhook.URL), len(digest.Notifications))
+}
+
+// send posts the digest and retries with an increasing delay, client errors except rate limits are not retried
+func (notifier *Notifier) send(state *webhookState, digest Digest) error {
+	body, contentType, headers, err := state.template(digest)
+	if err != nil {
+		return err
+	}
+
+	backoff := notifier.retryBackoff
+	for attempt := 0; ; attempt++ {
+		retry, sendErr := notifier.post(state.webhook.URL, body, contentType, headers)
+		if sendErr == nil {
+			return nil
+		}
+		if !retry || attempt >= notifier.retries {
+			return sendErr
+		}
+		log.Debugf("Retrying webhook %s in %s: %s", redactURL(state.webhook.URL), backoff, sendErr)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (notifier *Notifier) post(webhookURL string, body []byte, contentType string, headers map[string]string) (bool, error) {
+	request, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Type", contentType)
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+
+	response, err := notifier.httpClient.Do(request)
+	if err != nil {
+		return true, errors.New(redactURL(err.Error()))
+	}
+	defer func() { _ = response.Body.Close() }()
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return false, nil
+	}
+	retry := response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500
+	return retry, fmt.Errorf("unexpected status %s", response.Status)
+}
+
+// redactURL removes the path and query from URLs in the value, webhook URLs usually contain secrets
+func redactURL(value string) string {
+	fields := strings.Fields(value)
+	for index, field := range fields {
+		trimmed := strings.Trim(field, `"`)
+		parsed, err := url.Parse(trimmed)
+		if err != nil || parsed.Host == "" {
+			continue
+		}
+		fields[index] = parsed.Scheme + "://" + parsed.Host + "/..."
+	}
+	return strings.Join(fields, " ")
+}
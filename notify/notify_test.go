@@ -0,0 +1,283 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+	"github.com/webishdev/fail2ban-dashboard/store"
+)
+
+type receivedRequest struct {
+	body    string
+	headers http.Header
+}
+
+// webhookServer records the requests and answers with the given status codes, the last one is repeated
+func webhookServer(t *testing.T, statusCodes ...int) (*httptest.Server, chan receivedRequest) {
+	t.Helper()
+	requests := make(chan receivedRequest, 16)
+	var mutex sync.Mutex
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		status := http.StatusOK
+		if len(statusCodes) > 0 {
+			status = statusCodes[min(count, len(statusCodes)-1)]
+		}
+		count++
+		mutex.Unlock()
+		requests <- receivedRequest{body: string(body), headers: r.Header}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func receiveRequest(t *testing.T, requests chan receivedRequest) receivedRequest {
+	t.Helper()
+	select {
+	case request := <-requests:
+		return request
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a webhook request")
+		return receivedRequest{}
+	}
+}
+
+func expectNoRequest(t *testing.T, requests chan receivedRequest) {
+	t.Helper()
+	select {
+	case request := <-requests:
+		t.Fatalf("Expected no webhook request, got %s", request.body)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func createUpdate(eventType store.EventType, jailName string, addresses ...string) store.Update {
+	update := store.Update{Time: time.Now()}
+	for _, address := range addresses {
+		update.Events = append(update.Events, store.Event{
+			Type:     eventType,
+			JailName: jailName,
+			Ban:      &client.BanEntry{Address: address, JailName: jailName, CurrenPenalty: "600"},
+		})
+	}
+	return update
+}
+
+func lookupCountry(address string) string {
+	if strings.HasPrefix(address, "10.") {
+		return "DE"
+	}
+	return "US"
+}
+
+func newTestNotifier(t *testing.T, webhooks ...Webhook) *Notifier {
+	t.Helper()
+	notifier, err := NewNotifier(Configuration{
+		Webhooks:     webhooks,
+		Debounce:     20 * time.Millisecond,
+		Retries:      2,
+		RetryBackoff: time.Millisecond,
+	}, lookupCountry)
+	if err != nil {
+		t.Fatalf("Failed to create notifier: %v", err)
+	}
+	return notifier
+}
+
+func TestNotifierDigest(t *testing.T) {
+	server, requests := webhookServer(t)
+	notifier := newTestNotifier(t, Webhook{URL: server.URL})
+
+	addresses := make([]string, 500)
+	for index := range addresses {
+		addresses[index] = fmt.Sprintf("192.168.%d.%d", index/256, index%256)
+	}
+	notifier.Handle(createUpdate(store.BanAdded, "sshd", addresses[:250]...))
+	notifier.Handle(createUpdate(store.BanAdded, "sshd", addresses[250:]...))
+	notifier.Handle(createUpdate(store.BanRemoved, "sshd", "10.0.0.1"))
+
+	request := receiveRequest(t, requests)
+	var digest Digest
+	if err := json.Unmarshal([]byte(request.body), &digest); err != nil {
+		t.Fatalf("Failed to parse digest: %v", err)
+	}
+	if len(digest.Notifications) != 501 {
+		t.Fatalf("Expected 501 notifications in one digest, got %d", len(digest.Notifications))
+	}
+	last := digest.Notifications[500]
+	if last.Type != NotificationRemoved || last.Address != "10.0.0.1" || last.CountryCode != "DE" {
+		t.Errorf("Unexpected removed notification %+v", last)
+	}
+	expectNoRequest(t, requests)
+}
+
+func TestNotifierSkipsInitialAndOtherEvents(t *testing.T) {
+	server, requests := webhookServer(t)
+	notifier := newTestNotifier(t, Webhook{URL: server.URL})
+
+	initial := createUpdate(store.BanAdded, "sshd", "192.168.1.1")
+	initial.Initial = true
+	notifier.Handle(initial)
+	notifier.Handle(store.Update{Events: []store.Event{{Type: store.JailAppeared, JailName: "sshd"}}})
+	notifier.Handle(createUpdate(store.BanExtended, "sshd", "192.168.1.1"))
+
+	expectNoRequest(t, requests)
+}
+
+func TestNotifierFilters(t *testing.T) {
+	recidive, recidiveRequests := webhookServer(t)
+	germany, germanyRequests := webhookServer(t)
+	added, addedRequests := webhookServer(t)
+	notifier := newTestNotifier(t,
+		Webhook{URL: recidive.URL, Jails: []string{"recidive"}},
+		Webhook{URL: germany.URL, Countries: []string{"de"}},
+		Webhook{URL: added.URL, Events: []string{NotificationAdded}},
+	)
+
+	notifier.Handle(createUpdate(store.BanAdded, "sshd", "192.168.1.1", "10.0.0.1"))
+	notifier.Handle(createUpdate(store.BanRemoved, "recidive", "192.168.1.2"))
+
+	expectAddresses := func(requests chan receivedRequest, expected string) {
+		t.Helper()
+		var digest Digest
+		if err := json.Unmarshal([]byte(receiveRequest(t, requests).body), &digest); err != nil {
+			t.Fatalf("Failed to parse digest: %v", err)
+		}
+		addresses := make([]string, len(digest.Notifications))
+		for index, notification := range digest.Notifications {
+			addresses[index] = notification.Address
+		}
+		if strings.Join(addresses, ",") != expected {
+			t.Errorf("Expected %s, got %v", expected, addresses)
+		}
+	}
+
+	expectAddresses(recidiveRequests, "192.168.1.2")
+	expectAddresses(germanyRequests, "10.0.0.1")
+	expectAddresses(addedRequests, "192.168.1.1,10.0.0.1")
+}
+
+func TestNotifierRetries(t *testing.T) {
+	tests := []struct {
+		name        string
+		statusCodes []int
+		expected    int
+	}{
+		{"success", []int{http.StatusNoContent}, 1},
+		{"server error then success", []int{http.StatusBadGateway, http.StatusOK}, 2},
+		{"rate limited until giving up", []int{http.StatusTooManyRequests}, 3},
+		{"client error", []int{http.StatusNotFound}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := webhookServer(t, tt.statusCodes...)
+			notifier := newTestNotifier(t, Webhook{URL: server.URL})
+
+			notifier.Handle(createUpdate(store.BanAdded, "sshd", "192.168.1.1"))
+
+			for range tt.expected {
+				receiveRequest(t, requests)
+			}
+			expectNoRequest(t, requests)
+		})
+	}
+}
+
+func TestNewNotifierErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		webhook  Webhook
+		expected string
+	}{
+		{"missing url", Webhook{}, "webhook 1 has no valid http or https URL"},
+		{"unsupported scheme", Webhook{URL: "ftp://example.com"}, "webhook 1 has no valid http or https URL"},
+		{"unknown template", Webhook{URL: "https://example.com", Template: "irc"}, "webhook 1 has the unknown template irc, supported templates are json, slack, discord, teams, ntfy, gotify"},
+		{"unknown event", Webhook{URL: "https://example.com", Events: []string{"extended"}}, "webhook 1 has the unknown event extended, supported events are added and removed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewNotifier(Configuration{Webhooks: []Webhook{tt.webhook}}, nil)
+			if err == nil || err.Error() != tt.expected {
+				t.Errorf("Expected error %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	digest := Digest{Notifications: []Notification{
+		{Type: NotificationAdded, Address: "192.168.1.1", JailName: "recidive", CountryCode: "US", Penalty: "-1"},
+		{Type: NotificationAdded, Address: "192.168.1.2", JailName: "sshd", Penalty: "600"},
+		{Type: NotificationRemoved, Address: "10.0.0.1", JailName: "sshd", CountryCode: "DE"},
+	}}
+
+	tests := []struct {
+		template     string
+		expectedType string
+		expectedBody []string
+	}{
+		{TemplateJSON, "application/json", []string{`"notifications":[`}},
+		{TemplateSlack, "application/json", []string{`"text":"fail2ban: 2 bans added, 1 ban removed\n192.168.1.1 (US) banned in recidive permanently\n`}},
+		{TemplateDiscord, "application/json", []string{`"content":"**fail2ban: 2 bans added, 1 ban removed**\n`, "192.168.1.2 banned in sshd for 600 seconds"}},
+		{TemplateTeams, "application/json", []string{`"type":"AdaptiveCard"`, "10.0.0.1 (DE) unbanned from sshd"}},
+		{TemplateNtfy, "text/plain; charset=utf-8", []string{"192.168.1.1 (US) banned in recidive permanently\n"}},
+		{TemplateGotify, "application/json", []string{`"title":"fail2ban: 2 bans added, 1 ban removed"`, `"priority":5`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			body, contentType, headers, err := templates[tt.template](digest)
+			if err != nil {
+				t.Fatalf("Failed to render template: %v", err)
+			}
+			if contentType != tt.expectedType {
+				t.Errorf("Expected content type %s, got %s", tt.expectedType, contentType)
+			}
+			for _, expected := range tt.expectedBody {
+				if !strings.Contains(string(body), expected) {
+					t.Errorf("Expected %q in %s", expected, string(body))
+				}
+			}
+			if tt.template == TemplateNtfy && headers["Title"] != "fail2ban: 2 bans added, 1 ban removed" {
+				t.Errorf("Expected ntfy title header, got %v", headers)
+			}
+		})
+	}
+
+	if len(templates) != len(TemplateNames) {
+		t.Errorf("Expected a template for each of %v", TemplateNames)
+	}
+}
+
+func TestDigestText(t *testing.T) {
+	digest := Digest{Notifications: make([]Notification, 25)}
+	for index := range digest.Notifications {
+		digest.Notifications[index] = Notification{Type: NotificationAdded, Address: fmt.Sprintf("192.168.1.%d", index), JailName: "sshd", Penalty: "600"}
+	}
+
+	lines := strings.Split(digestText(digest), "\n")
+	if len(lines) != maxDigestLines+1 || lines[maxDigestLines] != "and 5 more" {
+		t.Errorf("Expected %d lines ending with the remaining count, got %v", maxDigestLines+1, lines)
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	value := `Post "https://hooks.slack.com/services/T000/B000/secret": dial tcp: timeout`
+	expected := `Post https://hooks.slack.com/... dial tcp: timeout`
+	if result := redactURL(value); result != expected {
+		t.Errorf("Expected %s, got %s", expected, result)
+	}
+}
@@ -0,0 +1,168 @@
+package notify
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/webishdev/fail2ban-dashboard/store"
+)
+
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// maxReportRows limits the top offending addresses and countries of a report
+const maxReportRows = 10
+
+// ReportCount is the number of bans of an address, country or jail in the reported and the previous period
+type ReportCount struct {
+	Name     string
+	Count    int
+	Previous int
+}
+
+// Change is the difference to the previous period with its sign
+func (count ReportCount) Change() string {
+	return formatChange(count.Count - count.Previous)
+}
+
+// Report summarizes the bans of a period compared to the period before
+type Report struct {
+	Period        string
+	From          time.Time
+	To            time.Time
+	Total         int
+	PreviousTotal int
+	Addresses     []ReportCount
+	Countries     []ReportCount
+	Jails         []ReportCount
+}
+
+// ReportSection is a table of counts in the rendered report
+type ReportSection struct {
+	Title  string
+	Label  string
+	Counts []ReportCount
+}
+
+func (report Report) Sections() []ReportSection {
+	return []ReportSection{
+		{Title: "Bans per jail", Label: "Jail", Counts: report.Jails},
+		{Title: "Top offending addresses", Label: "Address", Counts: report.Addresses},
+		{Title: "Top offending countries", Label: "Country", Counts: report.Countries},
+	}
+}
+
+func (report Report) Title() string {
+	return fmt.Sprintf("fail2ban %s report: %s", report.Period, pluralize(report.Total, "ban"))
+}
+
+func (report Report) TotalChange() string {
+	return formatChange(report.Total - report.PreviousTotal)
+}
+
+// digestPeriod is the duration covered by a digest
+func digestPeriod(digest string) time.Duration {
+	if digest == DigestWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// NewReport counts the bans started within the period ending at to and within the period before,
+// jails are listed completely while addresses and countries are limited to the top offenders
+func NewReport(entries []store.HistoryEntry, digest string, to time.Time) Report {
+	period := digestPeriod(digest)
+	from := to.Add(-period)
+	previousFrom := from.Add(-period)
+
+	report := Report{Period: digest, From: from, To: to}
+	addresses := make(map[string]*ReportCount)
+	countries := make(map[string]*ReportCount)
+	jails := make(map[string]*ReportCount)
+
+	for _, entry := range entries {
+		if entry.BannedAt.Before(previousFrom) || !entry.BannedAt.Before(to) {
+			continue
+		}
+		current := !entry.BannedAt.Before(from)
+		countryCode := entry.CountryCode
+		if countryCode == "" {
+			countryCode = "unknown"
+		}
+		if current {
+			report.Total++
+		} else {
+			report.PreviousTotal++
+		}
+		countBan(addresses, entry.Address, current)
+		countBan(countries, countryCode, current)
+		countBan(jails, entry.JailName, current)
+	}
+
+	report.Addresses = topCounts(addresses, maxReportRows)
+	report.Countries = topCounts(countries, maxReportRows)
+	report.Jails = topCounts(jails, 0)
+	return report
+}
+
+func countBan(counts map[string]*ReportCount, name string, current bool) {
+	count, exists := counts[name]
+	if !exists {
+		count = &ReportCount{Name: name}
+		counts[name] = count
+	}
+	if current {
+		count.Count++
+	} else {
+		count.Previous++
+	}
+}
+
+// topCounts orders by the bans of the reported period, a limit of 0 keeps every count
+// and counts only seen in the previous period are dropped when limited
+func topCounts(counts map[string]*ReportCount, limit int) []ReportCount {
+	result := make([]ReportCount, 0, len(counts))
+	for _, count := range counts {
+		if limit > 0 && count.Count == 0 {
+			continue
+		}
+		result = append(result, *count)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Name < result[j].Name
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+func formatChange(change int) string {
+	if change > 0 {
+		return fmt.Sprintf("+%d", change)
+	}
+	if change == 0 {
+		return "±0"
+	}
+	return fmt.Sprintf("%d", change)
+}
+
+// nextDigest is the next time a digest is sent at the hour, weekly digests are sent on Mondays
+func nextDigest(now time.Time, digest string, hour int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	days := 1
+	if digest == DigestWeekly {
+		days = 7
+		next = next.AddDate(0, 0, (int(time.Monday)-int(next.Weekday())+7)%7)
+	}
+	if !next.After(now) {
+		next = next.AddDate(0, 0, days)
+	}
+	return next
+}
@@ -0,0 +1,121 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	TemplateJSON    = "json"
+	TemplateSlack   = "slack"
+	TemplateDiscord = "discord"
+	TemplateTeams   = "teams"
+	TemplateNtfy    = "ntfy"
+	TemplateGotify  = "gotify"
+)
+
+// TemplateNames are all supported templates in the order they are documented
+var TemplateNames = []string{TemplateJSON, TemplateSlack, TemplateDiscord, TemplateTeams, TemplateNtfy, TemplateGotify}
+
+// maxDigestLines keeps chat messages readable and below the message size limits of the services
+const maxDigestLines = 20
+
+// payloadTemplate renders the digest as request body with its content type and additional headers
+type payloadTemplate func(digest Digest) ([]byte, string, map[string]string, error)
+
+var templates = map[string]payloadTemplate{
+	TemplateJSON: func(digest Digest) ([]byte, string, map[string]string, error) {
+		return jsonPayload(digest)
+	},
+	TemplateSlack: func(digest Digest) ([]byte, string, map[string]string, error) {
+		return jsonPayload(map[string]string{"text": digestTitle(digest) + "\n" + digestText(digest)})
+	},
+	TemplateDiscord: func(digest Digest) ([]byte, string, map[string]string, error) {
+		return jsonPayload(map[string]string{"content": "**" + digestTitle(digest) + "**\n" + digestText(digest)})
+	},
+	TemplateTeams: func(digest Digest) ([]byte, string, map[string]string, error) {
+		card := map[string]any{
+			"type": "message",
+			"attachments": []map[string]any{{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content": map[string]any{
+					"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+					"type":    "AdaptiveCard",
+					"version": "1.4",
+					"body": []map[string]any{
+						{"type": "TextBlock", "text": digestTitle(digest), "weight": "Bolder", "wrap": true},
+						{"type": "TextBlock", "text": strings.ReplaceAll(digestText(digest), "\n", "\n\n"), "wrap": true},
+					},
+				},
+			}},
+		}
+		return jsonPayload(card)
+	},
+	TemplateNtfy: func(digest Digest) ([]byte, string, map[string]string, error) {
+		headers := map[string]string{"Title": digestTitle(digest), "Tags": "no_entry"}
+		return []byte(digestText(digest)), "text/plain; charset=utf-8", headers, nil
+	},
+	TemplateGotify: func(digest Digest) ([]byte, string, map[string]string, error) {
+		return jsonPayload(map[string]any{"title": digestTitle(digest), "message": digestText(digest), "priority": 5})
+	},
+}
+
+func jsonPayload(value any) ([]byte, string, map[string]string, error) {
+	body, err := json.Marshal(value)
+	return body, "application/json", nil, err
+}
+
+func digestTitle(digest Digest) string {
+	added, removed := 0, 0
+	for _, notification := range digest.Notifications {
+		if notification.Type == NotificationAdded {
+			added++
+		} else {
+			removed++
+		}
+	}
+	parts := make([]string, 0, 2)
+	if added > 0 {
+		parts = append(parts, pluralize(added, "ban")+" added")
+	}
+	if removed > 0 {
+		parts = append(parts, pluralize(removed, "ban")+" removed")
+	}
+	return "fail2ban: " + strings.Join(parts, ", ")
+}
+
+// digestText lists the first notifications, one per line
+func digestText(digest Digest) string {
+	lines := make([]string, 0, maxDigestLines+1)
+	for index, notification := range digest.Notifications {
+		if index == maxDigestLines {
+			lines = append(lines, fmt.Sprintf("and %d more", len(digest.Notifications)-maxDigestLines))
+			break
+		}
+		lines = append(lines, notificationLine(notification))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func notificationLine(notification Notification) string {
+	country := ""
+	if notification.CountryCode != "" {
+		country = " (" + notification.CountryCode + ")"
+	}
+	if notification.Type == NotificationRemoved {
+		return fmt.Sprintf("%s%s unbanned from %s", notification.Address, country, notification.JailName)
+	}
+	penalty := "permanently"
+	if notification.Penalty != "-1" {
+		penalty = "for " + notification.Penalty + " seconds"
+	}
+	return fmt.Sprintf("%s%s banned in %s %s", notification.Address, country, notification.JailName, penalty)
+}
+
+func pluralize(count int, word string) string {
+	if count == 1 {
+		return fmt.Sprintf("1 %s", word)
+	}
+	return fmt.Sprintf("%d %ss", count, word)
+}
@@ -0,0 +1,233 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/log"
+	"github.com/webishdev/fail2ban-dashboard/auth"
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+	"github.com/webishdev/fail2ban-dashboard/store"
+)
+
+// userKey stores the authenticated user in the locals of the request
+type userKey struct{}
+
+// tokenKey stores the API token of the request, the user of a token is a viewer named after the token
+// which is limited to the jails of the token
+type tokenKey struct{}
+
+// authorizer checks the credentials of basic authentication and remembers the user for the handlers
+func authorizer(users *auth.Users) func(name string, password string, c fiber.Ctx) bool {
+	return func(name string, password string, c fiber.Ctx) bool {
+		user, ok := users.Authenticate(name, password)
+		if ok {
+			c.Locals(userKey{}, &user)
+		}
+		return ok
+	}
+}
+
+// proxyAuthenticator takes the user from the headers of a trusted reverse proxy, the headers are checked against
+// the address of the connection as X-Forwarded-For could be sent by anybody
+func proxyAuthenticator(proxy *auth.ProxyAuth) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if tokenAuthenticated(c) {
+			return c.Next()
+		}
+
+		remoteAddress := c.RequestCtx().RemoteIP().String()
+		if !proxy.Trusted(remoteAddress) {
+			log.Warnf("Rejected request from %s, which is not a trusted proxy", remoteAddress)
+			return c.Status(fiber.StatusForbidden).SendString("Requests must be sent through a trusted proxy")
+		}
+
+		name := c.Get(proxy.UserHeader)
+		if name == "" {
+			return c.Status(fiber.StatusUnauthorized).SendString("The proxy did not send a user")
+		}
+
+		user, ok := proxy.User(name, c.Get(proxy.GroupsHeader))
+		if !ok {
+			log.Warnf("Rejected user %s from %s, which is in none of the configured groups", name, remoteAddress)
+			return c.Status(fiber.StatusForbidden).SendString("The user is in none of the configured groups")
+		}
+		c.Locals(userKey{}, &user)
+		return c.Next()
+	}
+}
+
+// tokenAuthenticator accepts bearer tokens for the JSON API and the exports, requests without token are left
+// to the other authentication. Reading the API needs the read scope and exports the export scope, changes check
+// their scope in the endpoint
+func tokenAuthenticator(tokens *auth.TokenStore, basePath string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		authorization := c.Get(fiber.HeaderAuthorization)
+		if len(authorization) < 7 || !strings.EqualFold(authorization[:7], "Bearer ") {
+			return c.Next()
+		}
+
+		token, ok := tokens.Authenticate(strings.TrimSpace(authorization[7:]))
+		if !ok {
+			log.Warnf("Rejected invalid or expired API token from %s", c.IP())
+			return c.Status(fiber.StatusUnauthorized).JSON(apiError{Error: "invalid or expired token"})
+		}
+
+		requestPath := c.Path()
+		switch {
+		case strings.HasPrefix(requestPath, basePath+"export/"):
+			if !token.HasScope(auth.ScopeExport) {
+				return c.Status(fiber.StatusForbidden).JSON(apiError{Error: "token lacks the export scope"})
+			}
+		case strings.HasPrefix(requestPath, basePath+"api/"):
+			if c.Method() == fiber.MethodGet && !token.HasScope(auth.ScopeRead) {
+				return c.Status(fiber.StatusForbidden).JSON(apiError{Error: "token lacks the read scope"})
+			}
+		default:
+			return c.Status(fiber.StatusUnauthorized).JSON(apiError{Error: "tokens are only accepted by the JSON API and the exports"})
+		}
+
+		c.Locals(userKey{}, token.User())
+		c.Locals(tokenKey{}, &token)
+		return c.Next()
+	}
+}
+
+// tokenAuthenticated tells if the request was authenticated with an API token
+func tokenAuthenticated(c fiber.Ctx) bool {
+	token, _ := c.Locals(tokenKey{}).(*auth.Token)
+	return token != nil
+}
+
+// apiScope protects changes of the JSON API which tokens can be allowed to make, other users need the admin role
+func apiScope(scope string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		token, _ := c.Locals(tokenKey{}).(*auth.Token)
+		if token == nil {
+			return apiAdminOnly(c)
+		}
+		if !token.HasScope(scope) {
+			return c.Status(fiber.StatusForbidden).JSON(apiError{Error: "token lacks the " + scope + " scope"})
+		}
+		return c.Next()
+	}
+}
+
+// currentUser is the user of the basic or header authentication, nil when authentication is disabled
+func currentUser(c fiber.Ctx) *auth.User {
+	user, _ := c.Locals(userKey{}).(*auth.User)
+	return user
+}
+
+// mayChange tells if bans and ignore lists may be changed, which always needs an authenticated admin
+func mayChange(c fiber.Ctx) bool {
+	user := currentUser(c)
+	return user != nil && user.IsAdmin()
+}
+
+// mayControl tells if jails may be started, stopped and reloaded, which always needs an authenticated admin
+func mayControl(c fiber.Ctx) bool {
+	user := currentUser(c)
+	return user != nil && user.IsAdmin()
+}
+
+// adminOnly protects the actions of the web application
+func adminOnly(c fiber.Ctx) error {
+	if !mayChange(c) {
+		return c.Status(fiber.StatusForbidden).SendString("Changes require the admin role")
+	}
+	return c.Next()
+}
+
+// apiAdminOnly protects the changes of the JSON API
+func apiAdminOnly(c fiber.Ctx) error {
+	if !mayChange(c) {
+		return c.Status(fiber.StatusForbidden).JSON(apiError{Error: "changes require the admin role"})
+	}
+	return c.Next()
+}
+
+// reservedJailNames are the first path segments of the other pages, the page of a jail with one
+// of these names would be shadowed by them, so the jail is not shown at all
+var reservedJailNames = map[string]bool{
+	"api":     true,
+	"control": true,
+	"css":     true,
+	"events":  true,
+	"export":  true,
+	"healthz": true,
+	"images":  true,
+	"ip":      true,
+	"js":      true,
+	"login":   true,
+	"logout":  true,
+	"readyz":  true,
+}
+
+// jailAccessible tells if the user may access the jail and its name does not collide with a page
+func jailAccessible(user *auth.User, jailName string) bool {
+	return !reservedJailNames[jailName] && user.CanAccess(jailName)
+}
+
+// warnReservedJails logs the jails which are not shown because their name collides with a page
+func warnReservedJails(update store.Update) {
+	for _, event := range update.Events {
+		if event.Type == store.JailAppeared && reservedJailNames[event.JailName] {
+			log.Warnf("Jail %s is not shown, its name is used by a page of the dashboard", event.JailName)
+		}
+	}
+}
+
+// lookupJail returns the jail when it exists and the user may access it,
+// other jails are not found so users can't tell which jails exist
+func lookupJail(c fiber.Ctx, dataStore *store.DataStore, jailName string) (store.Jail, bool) {
+	if !jailAccessible(currentUser(c), jailName) {
+		return store.Jail{}, false
+	}
+	return dataStore.GetJailByName(jailName)
+}
+
+// visibleJails keeps the jails the user may access
+func visibleJails(user *auth.User, jails []store.Jail) []store.Jail {
+	return filterByJail(user, jails, func(jail store.Jail) string { return jail.Name })
+}
+
+// visibleBans keeps the bans of jails the user may access
+func visibleBans(user *auth.User, banned []client.BanEntry) []client.BanEntry {
+	return filterByJail(user, banned, func(ban client.BanEntry) string { return ban.JailName })
+}
+
+// visibleHistory keeps the history entries of jails the user may access
+func visibleHistory(user *auth.User, entries []store.HistoryEntry) []store.HistoryEntry {
+	return filterByJail(user, entries, func(entry store.HistoryEntry) string { return entry.JailName })
+}
+
+// visibleAudit keeps the audit entries of jails the user may access
+func visibleAudit(user *auth.User, entries []store.AuditEntry) []store.AuditEntry {
+	return filterByJail(user, entries, func(entry store.AuditEntry) string { return entry.JailName })
+}
+
+// visibleRefresh keeps the failed jails the user may access
+func visibleRefresh(user *auth.User, status store.RefreshStatus) store.RefreshStatus {
+	if len(status.FailedJails) == 0 {
+		return status
+	}
+	failedJails := make(map[string]string)
+	for jailName, jailErr := range status.FailedJails {
+		if jailAccessible(user, jailName) {
+			failedJails[jailName] = jailErr
+		}
+	}
+	status.FailedJails = failedJails
+	return status
+}
+
+func filterByJail[T any](user *auth.User, values []T, jailName func(T) string) []T {
+	result := make([]T, 0, len(values))
+	for _, value := range values {
+		if jailAccessible(user, jailName(value)) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
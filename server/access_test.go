@@ -0,0 +1,365 @@
+package server
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/webishdev/fail2ban-dashboard/auth"
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+	"github.com/webishdev/fail2ban-dashboard/geoip"
+	"github.com/webishdev/fail2ban-dashboard/store"
+)
+
+// testPasswordHash is a bcrypt hash of the password "secret"
+const testPasswordHash = "$2a$04$D09YtrvLE3xaJ3/9finfG./cRMAUb0t5uQgAkZEMhE5nTIF.sa/C2"
+
+func TestRoleBasedAccess(t *testing.T) {
+	trail, err := store.NewAuditTrail(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create audit trail: %v", err)
+	}
+	defer func() { _ = trail.Close() }()
+	changedAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	trail.Record(store.AuditEntry{Time: changedAt, User: "admin", Action: store.AuditAddIgnoreIP, JailName: "sshd", Address: "192.168.1.1"})
+	trail.Record(store.AuditEntry{Time: changedAt, User: "admin", Action: store.AuditAddIgnoreIP, JailName: "postfix", Address: "192.168.1.2"})
+
+	dataStore := newDisconnectedDataStore(t)
+	dataStore.EnableAudit(trail)
+
+	users, err := auth.NewUsers([]auth.User{
+		{Name: "alice", Password: testPasswordHash, Role: auth.RoleAdmin},
+		{Name: "bob", Password: testPasswordHash, Role: auth.RoleViewer, Jails: []string{"sshd"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create users: %v", err)
+	}
+
+	app := fiber.New(fiber.Config{})
+	err = RegisterDashboardEndpoints(app, dataStore, &geoip.GeoIP{}, &Configuration{BasePath: "/", AuthUser: "legacy", AuthPassword: "plain", Users: users})
+	if err != nil {
+		t.Fatalf("Failed to register endpoints: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		user         string
+		password     string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "wrong password",
+			method:       "GET",
+			path:         "/api/v1/audit",
+			user:         "alice",
+			password:     "wrong",
+			expectedCode: fiber.StatusUnauthorized,
+		},
+		{
+			name:         "admin sees all jails",
+			method:       "GET",
+			path:         "/api/v1/audit",
+			user:         "alice",
+			password:     "secret",
+			expectedCode: fiber.StatusOK,
+			expectedBody: `"jail":"postfix"`,
+		},
+		{
+			name:         "viewer only sees own jails",
+			method:       "GET",
+			path:         "/api/v1/audit",
+			user:         "bob",
+			password:     "secret",
+			expectedCode: fiber.StatusOK,
+			expectedBody: `[{"time":"2025-01-01T12:00:00Z","user":"admin","action":"addignoreip","jail":"sshd","address":"192.168.1.1"}]`,
+		},
+		{
+			name:         "viewer can't change ignore lists",
+			method:       "POST",
+			path:         "/api/v1/jails/sshd/ignoreip",
+			user:         "bob",
+			password:     "secret",
+			body:         `{"address":"192.168.1.1"}`,
+			expectedCode: fiber.StatusForbidden,
+			expectedBody: `{"error":"changes require the admin role"}`,
+		},
+		{
+			name:         "viewer can't remove from ignore lists",
+			method:       "DELETE",
+			path:         "/api/v1/jails/sshd/ignoreip/192.168.1.1",
+			user:         "bob",
+			password:     "secret",
+			expectedCode: fiber.StatusForbidden,
+		},
+		{
+			name:         "admin passes the role check",
+			method:       "POST",
+			path:         "/api/v1/jails/sshd/ignoreip",
+			user:         "alice",
+			password:     "secret",
+			body:         `{"address":"192.168.1.1"}`,
+			expectedCode: fiber.StatusNotFound,
+		},
+		{
+			name:         "auth user is an admin",
+			method:       "DELETE",
+			path:         "/api/v1/jails/sshd/ignoreip/192.168.1.1",
+			user:         "legacy",
+			password:     "plain",
+			expectedCode: fiber.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			req.SetBasicAuth(tt.user, tt.password)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to make request: %v", err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+
+			if resp.StatusCode != tt.expectedCode {
+				t.Errorf("Expected status code %d, got %d", tt.expectedCode, resp.StatusCode)
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("Failed to read response body: %v", err)
+			}
+			if !strings.Contains(string(body), tt.expectedBody) {
+				t.Errorf("Expected %s in body %s", tt.expectedBody, string(body))
+			}
+		})
+	}
+}
+
+func TestVisibleBans(t *testing.T) {
+	now := time.Now()
+	banned := []client.BanEntry{
+		createTestBanEntry("192.168.1.1", "sshd", "600", now, now.Add(time.Hour)),
+		createTestBanEntry("192.168.1.2", "postfix", "600", now, now.Add(time.Hour)),
+	}
+
+	tests := []struct {
+		name     string
+		user     *auth.User
+		expected int
+	}{
+		{"without authentication", nil, 2},
+		{"all jails", &auth.User{Name: "alice", Role: auth.RoleViewer}, 2},
+		{"restricted", &auth.User{Name: "bob", Role: auth.RoleViewer, Jails: []string{"postfix"}}, 1},
+		{"other jails", &auth.User{Name: "carol", Role: auth.RoleAdmin, Jails: []string{"nginx"}}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := visibleBans(tt.user, banned); len(result) != tt.expected {
+				t.Errorf("Expected %d bans, got %d", tt.expected, len(result))
+			}
+		})
+	}
+}
+
+func TestVisibleRefresh(t *testing.T) {
+	status := store.RefreshStatus{FailedJails: map[string]string{"sshd": "timeout", "nginx": "broken pipe"}}
+
+	if result := visibleRefresh(nil, status); len(result.FailedJails) != 2 {
+		t.Errorf("Expected all failed jails without user, got %v", result.FailedJails)
+	}
+	viewer := &auth.User{Name: "bob", Role: auth.RoleViewer, Jails: []string{"sshd", "postfix"}}
+	if result := visibleRefresh(viewer, status); len(result.FailedJails) != 1 || result.FailedJails["sshd"] != "timeout" {
+		t.Errorf("Expected only sshd, got %v", result.FailedJails)
+	}
+	if len(status.FailedJails) != 2 {
+		t.Error("visibleRefresh() changed the status of the data store")
+	}
+}
+
+func TestReservedJailNames(t *testing.T) {
+	jails := []store.Jail{{Name: "sshd"}, {Name: "events"}, {Name: "login"}}
+	status := store.RefreshStatus{FailedJails: map[string]string{"control": "timeout"}}
+
+	tests := []struct {
+		name string
+		user *auth.User
+	}{
+		{"without authentication", nil},
+		{"all jails", &auth.User{Name: "alice", Role: auth.RoleAdmin}},
+		{"restricted", &auth.User{Name: "bob", Role: auth.RoleAdmin, Jails: []string{"sshd", "events", "control"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := visibleJails(tt.user, jails); len(result) != 1 || result[0].Name != "sshd" {
+				t.Errorf("Expected only sshd, got %+v", result)
+			}
+			if result := visibleRefresh(tt.user, status); len(result.FailedJails) != 0 {
+				t.Errorf("Expected no failed jails, got %v", result.FailedJails)
+			}
+		})
+	}
+}
+
+func TestProxyAuthentication(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		headers        map[string]string
+		method         string
+		path           string
+		expectedCode   int
+	}{
+		{
+			name:           "untrusted proxy",
+			trustedProxies: []string{"10.0.0.0/8"},
+			headers:        map[string]string{"Remote-User": "alice", "Remote-Groups": "admins"},
+			method:         "GET",
+			path:           "/api/v1/jails",
+			expectedCode:   fiber.StatusForbidden,
+		},
+		{
+			name:           "missing user",
+			trustedProxies: []string{"0.0.0.0"},
+			method:         "GET",
+			path:           "/api/v1/jails",
+			expectedCode:   fiber.StatusUnauthorized,
+		},
+		{
+			name:           "viewer reads",
+			trustedProxies: []string{"0.0.0.0"},
+			headers:        map[string]string{"Remote-User": "bob", "Remote-Groups": "dev"},
+			method:         "GET",
+			path:           "/api/v1/jails",
+			expectedCode:   fiber.StatusOK,
+		},
+		{
+			name:           "viewer can't change",
+			trustedProxies: []string{"0.0.0.0"},
+			headers:        map[string]string{"Remote-User": "bob", "Remote-Groups": "dev"},
+			method:         "DELETE",
+			path:           "/api/v1/jails/sshd/ignoreip/192.168.1.1",
+			expectedCode:   fiber.StatusForbidden,
+		},
+		{
+			name:           "admin group",
+			trustedProxies: []string{"0.0.0.0"},
+			headers:        map[string]string{"Remote-User": "alice", "Remote-Groups": "dev,admins"},
+			method:         "DELETE",
+			path:           "/api/v1/jails/sshd/ignoreip/192.168.1.1",
+			expectedCode:   fiber.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefixes, err := auth.ParsePrefixes(tt.trustedProxies)
+			if err != nil {
+				t.Fatal(err)
+			}
+			proxyAuth := &auth.ProxyAuth{
+				UserHeader:     "Remote-User",
+				GroupsHeader:   "Remote-Groups",
+				TrustedProxies: prefixes,
+				Roles:          auth.GroupRoles{AdminGroups: []string{"admins"}},
+			}
+
+			app := fiber.New(fiber.Config{})
+			err = RegisterDashboardEndpoints(app, newDisconnectedDataStore(t), &geoip.GeoIP{}, &Configuration{BasePath: "/", ProxyAuth: proxyAuth})
+			if err != nil {
+				t.Fatalf("Failed to register endpoints: %v", err)
+			}
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to make request: %v", err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+
+			if resp.StatusCode != tt.expectedCode {
+				t.Errorf("Expected status code %d, got %d", tt.expectedCode, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestTokenAuthentication(t *testing.T) {
+	tokens, err := auth.NewTokenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, reader, err := tokens.Create("reader", []string{auth.ScopeRead}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, banner, err := tokens.Create("banner", []string{auth.ScopeBan, auth.ScopeExport}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users, err := auth.NewUsers([]auth.User{{Name: "alice", Password: testPasswordHash, Role: auth.RoleAdmin}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New(fiber.Config{})
+	err = RegisterDashboardEndpoints(app, newDisconnectedDataStore(t), &geoip.GeoIP{}, &Configuration{BasePath: "/dashboard", Users: users, Tokens: tokens})
+	if err != nil {
+		t.Fatalf("Failed to register endpoints: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		method        string
+		path          string
+		body          string
+		expectedCode  int
+	}{
+		{name: "without token", method: "GET", path: "/dashboard/api/v1/jails", expectedCode: fiber.StatusUnauthorized},
+		{name: "invalid token", authorization: "Bearer f2bd_0_0", method: "GET", path: "/dashboard/api/v1/jails", expectedCode: fiber.StatusUnauthorized},
+		{name: "read", authorization: "Bearer " + reader, method: "GET", path: "/dashboard/api/v1/jails", expectedCode: fiber.StatusOK},
+		{name: "lowercase scheme", authorization: "bearer " + reader, method: "GET", path: "/dashboard/api/v1/bans", expectedCode: fiber.StatusOK},
+		{name: "read without scope", authorization: "Bearer " + banner, method: "GET", path: "/dashboard/api/v1/jails", expectedCode: fiber.StatusForbidden},
+		{name: "export without scope", authorization: "Bearer " + reader, method: "GET", path: "/dashboard/export/text", expectedCode: fiber.StatusForbidden},
+		{name: "export", authorization: "Bearer " + banner, method: "GET", path: "/dashboard/export/text", expectedCode: fiber.StatusOK},
+		{name: "ban without scope", authorization: "Bearer " + reader, method: "POST", path: "/dashboard/api/v1/jails/sshd/bans", body: `{"address":"192.168.1.1"}`, expectedCode: fiber.StatusForbidden},
+		{name: "ban", authorization: "Bearer " + banner, method: "POST", path: "/dashboard/api/v1/jails/sshd/bans", body: `{"address":"192.168.1.1"}`, expectedCode: fiber.StatusNotFound},
+		{name: "unban without scope", authorization: "Bearer " + banner, method: "DELETE", path: "/dashboard/api/v1/jails/sshd/bans/192.168.1.1", expectedCode: fiber.StatusForbidden},
+		{name: "ignore list", authorization: "Bearer " + banner, method: "DELETE", path: "/dashboard/api/v1/jails/sshd/ignoreip/192.168.1.1", expectedCode: fiber.StatusForbidden},
+		{name: "page", authorization: "Bearer " + reader, method: "GET", path: "/dashboard/", expectedCode: fiber.StatusUnauthorized},
+		{name: "basic authentication", authorization: "Basic YWxpY2U6c2VjcmV0", method: "DELETE", path: "/dashboard/api/v1/jails/sshd/bans/192.168.1.1", expectedCode: fiber.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			if tt.authorization != "" {
+				req.Header.Set(fiber.HeaderAuthorization, tt.authorization)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to make request: %v", err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+
+			if resp.StatusCode != tt.expectedCode {
+				t.Errorf("Expected status code %d, got %d", tt.expectedCode, resp.StatusCode)
+			}
+		})
+	}
+}
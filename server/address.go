@@ -0,0 +1,78 @@
+package server
+
+import (
+	"github.com/webishdev/fail2ban-dashboard/auth"
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+	"github.com/webishdev/fail2ban-dashboard/geoip"
+	"github.com/webishdev/fail2ban-dashboard/store"
+)
+
+// addressDetail is everything known about a single address across all jails
+type addressDetail struct {
+	Address     string               `json:"address"`
+	CountryCode string               `json:"countryCode"`
+	Bans        []client.BanEntry    `json:"bans"`
+	History     []store.HistoryEntry `json:"history"`
+	Escalation  []penaltyEscalation  `json:"escalation"`
+}
+
+// penaltyEscalation shows how the penalty of an address grew in a jail which currently bans it,
+// the penalties are ordered from the first observed ban to the current one
+type penaltyEscalation struct {
+	JailName  string   `json:"jail"`
+	BanCount  int      `json:"banCount"`
+	Penalties []string `json:"penalties"`
+}
+
+type addressData struct {
+	baseData
+	Detail addressDetail
+}
+
+// newAddressDetail only contains the jails the user may access
+func newAddressDetail(dataStore *store.DataStore, geoIP *geoip.GeoIP, address string, user *auth.User) addressDetail {
+	detail := addressDetail{
+		Address:     address,
+		CountryCode: "unknown",
+		Bans:        visibleBans(user, dataStore.GetBansByAddress(address)),
+		History:     make([]store.HistoryEntry, 0),
+		Escalation:  make([]penaltyEscalation, 0),
+	}
+
+	if countryCode, exists := geoIP.Lookup(address); exists {
+		detail.CountryCode = countryCode
+	}
+
+	for index := range detail.Bans {
+		detail.Bans[index].CountryCode = detail.CountryCode
+	}
+
+	if history := dataStore.History(); history != nil {
+		detail.History = visibleHistory(user, history.Entries(store.HistoryFilter{Address: address}))
+	}
+
+	for _, ban := range detail.Bans {
+		detail.Escalation = append(detail.Escalation, newPenaltyEscalation(ban, detail.History))
+	}
+
+	return detail
+}
+
+// newPenaltyEscalation collects the penalties of earlier bans from the history, which is ordered latest first,
+// the current ban is always the last step even when the history is disabled
+func newPenaltyEscalation(ban client.BanEntry, history []store.HistoryEntry) penaltyEscalation {
+	escalation := penaltyEscalation{
+		JailName:  ban.JailName,
+		Penalties: make([]string, 0),
+	}
+	for index := len(history) - 1; index >= 0; index-- {
+		entry := history[index]
+		if entry.JailName != ban.JailName || entry.BannedAt.Unix() == ban.BannedAt.Unix() {
+			continue
+		}
+		escalation.Penalties = append(escalation.Penalties, entry.Penalty)
+	}
+	escalation.Penalties = append(escalation.Penalties, ban.CurrenPenalty)
+	escalation.BanCount = len(escalation.Penalties)
+	return escalation
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/webishdev/fail2ban-dashboard/geoip"
+	"github.com/webishdev/fail2ban-dashboard/store"
+)
+
+func TestNewPenaltyEscalation(t *testing.T) {
+	now := time.Now()
+	ban := createTestBanEntry("192.168.1.1", "sshd", "2400", now, now.Add(40*time.Minute))
+
+	tests := []struct {
+		name     string
+		history  []store.HistoryEntry
+		expected []string
+		banCount int
+	}{
+		{"without history", nil, []string{"2400"}, 1},
+		{
+			name: "earlier bans oldest first",
+			history: []store.HistoryEntry{
+				{Address: "192.168.1.1", JailName: "sshd", BannedAt: now, Penalty: "2400"},
+				{Address: "192.168.1.1", JailName: "postfix", BannedAt: now.Add(-time.Hour), Penalty: "600"},
+				{Address: "192.168.1.1", JailName: "sshd", BannedAt: now.Add(-2 * time.Hour), Penalty: "1200"},
+				{Address: "192.168.1.1", JailName: "sshd", BannedAt: now.Add(-3 * time.Hour), Penalty: "600"},
+			},
+			expected: []string{"600", "1200", "2400"},
+			banCount: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			escalation := newPenaltyEscalation(ban, tt.history)
+			if escalation.JailName != "sshd" || escalation.BanCount != tt.banCount {
+				t.Errorf("Expected %d bans in sshd, got %d in %s", tt.banCount, escalation.BanCount, escalation.JailName)
+			}
+			if strings.Join(escalation.Penalties, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected penalties %v, got %v", tt.expected, escalation.Penalties)
+			}
+		})
+	}
+}
+
+func TestAddressRouteHandler(t *testing.T) {
+	app := fiber.New(fiber.Config{})
+	err := RegisterDashboardEndpoints(app, newDisconnectedDataStore(t), &geoip.GeoIP{}, &Configuration{BasePath: "/"})
+	if err != nil {
+		t.Fatalf("Failed to register endpoints: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		expectedCode int
+		expectedBody string
+	}{
+		{"address", "/ip/192.168.1.1", fiber.StatusOK, "192.168.1.1 is currently not banned"},
+		{"address range", "/ip/10.0.0.0%2F24", fiber.StatusOK, "Address: 10.0.0.0/24"},
+		{"invalid address", "/ip/example.com", fiber.StatusBadRequest, "Invalid address"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", tt.path, nil))
+			if err != nil {
+				t.Fatalf("Failed to make request: %v", err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+
+			if resp.StatusCode != tt.expectedCode {
+				t.Errorf("Expected status code %d, got %d", tt.expectedCode, resp.StatusCode)
+			}
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("Failed to read response body: %v", err)
+			}
+			if !strings.Contains(string(body), tt.expectedBody) {
+				t.Errorf("Expected body to contain %q, got %s", tt.expectedBody, string(body))
+			}
+			if strings.Contains(string(body), "js/live.js") {
+				t.Error("Expected address page without live updates")
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package server
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/log"
+	"github.com/webishdev/fail2ban-dashboard/auth"
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+	"github.com/webishdev/fail2ban-dashboard/geoip"
+	"github.com/webishdev/fail2ban-dashboard/store"
+)
+
+//go:embed resources/openapi.yaml
+var openAPIYaml []byte
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// apiStatus is the state of the fail2ban connection and of the data refreshes
+type apiStatus struct {
+	Connected         bool                `json:"connected"`
+	ConnectionChanged time.Time           `json:"connectionChanged,omitzero"`
+	ConnectionError   string              `json:"connectionError,omitempty"`
+	Stale             bool                `json:"stale"`
+	Message           string              `json:"message,omitempty"`
+	Refresh           store.RefreshStatus `json:"refresh"`
+}
+
+// ignoreIPRequest is the body to add an address to the ignore list of a jail
+type ignoreIPRequest struct {
+	Address string `json:"address"`
+}
+
+// banRequest is the body to ban an address in a jail
+type banRequest struct {
+	Address string `json:"address"`
+}
+
+func registerAPIEndpoints(router fiber.Router, dataStore *store.DataStore, geoIP *geoip.GeoIP, configuration *Configuration) {
+	api := router.Group("/api/v1")
+
+	api.Get("/openapi.yaml", func(c fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, "application/yaml")
+		return c.Send(openAPIYaml)
+	})
+
+	api.Get("/status", func(c fiber.Ctx) error {
+		accessLog(configuration.TrustProxyHeaders, "api status", c)
+		state := dataStore.ConnectionState()
+		refresh := visibleRefresh(currentUser(c), dataStore.RefreshStatus())
+		warning := newRefreshData(state, refresh)
+		return c.JSON(apiStatus{
+			Connected:         state.Connected,
+			ConnectionChanged: state.Since,
+			ConnectionError:   state.LastError,
+			Stale:             warning.Stale,
+			Message:           warning.Message,
+			Refresh:           refresh,
+		})
+	})
+
+	api.Get("/jails", func(c fiber.Ctx) error {
+		accessLog(configuration.TrustProxyHeaders, "api jails", c)
+		jails := visibleJails(currentUser(c), dataStore.GetJails())
+
+		// the list only contains the counters, bans are available per jail or with /bans
+		for index := range jails {
+			jails[index].BannedEntries = nil
+		}
+
+		return c.JSON(jails)
+	})
+
+	api.Get("/jails/:name", func(c fiber.Ctx) error {
+		jailName := c.Params("name")
+		accessLog(configuration.TrustProxyHeaders, "api jail "+jailName, c)
+
+		jail, exists := lookupJail(c, dataStore, jailName)
+		if !exists {
+			return c.Status(fiber.StatusNotFound).JSON(apiError{Error: "jail not found"})
+		}
+
+		banned := make([]client.BanEntry, 0)
+		banned = append(banned, jail.BannedEntries...)
+		lookupCountryCodes(geoIP, banned)
+		jail.BannedEntries = banned
+
+		return c.JSON(jail)
+	})
+
+	api.Get("/jails/:name/ignoreip", func(c fiber.Ctx) error {
+		jailName := c.Params("name")
+		accessLog(configuration.TrustProxyHeaders, "api ignoreip "+jailName, c)
+
+		if _, exists := lookupJail(c, dataStore, jailName); !exists {
+			return c.Status(fiber.StatusNotFound).JSON(apiError{Error: "jail not found"})
+		}
+
+		ignoreIPs, err := dataStore.GetIgnoreIPs(jailName)
+		if err != nil {
+			return c.Status(fiber.StatusBadGateway).JSON(apiError{Error: err.Error()})
+		}
+		return c.JSON(ignoreIPs)
+	})
+
+	// changes only accept JSON, browsers don't send JSON to other sites without a CORS preflight
+	api.Post("/jails/:name/ignoreip", apiAdminOnly, func(c fiber.Ctx) error {
+		jailName := c.Params("name")
+		accessLog(configuration.TrustProxyHeaders, "api add ignoreip "+jailName, c)
+
+		if !strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON) {
+			return c.Status(fiber.StatusUnsupportedMediaType).JSON(apiError{Error: "content type must be application/json"})
+		}
+		if _, exists := lookupJail(c, dataStore, jailName); !exists {
+			return c.Status(fiber.StatusNotFound).JSON(apiError{Error: "jail not found"})
+		}
+
+		var request ignoreIPRequest
+		if err := json.Unmarshal(c.Body(), &request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(apiError{Error: "body must be a JSON object with an address"})
+		}
+		address, valid := normalizeAddress(request.Address)
+		if !valid {
+			return c.Status(fiber.StatusBadRequest).JSON(apiError{Error: "not a valid IP address or CIDR range"})
+		}
+
+		ignoreIPs, err := dataStore.AddIgnoreIP(actorFromContext(c), jailName, address)
+		if err != nil {
+			return c.Status(fiber.StatusBadGateway).JSON(apiError{Error: err.Error()})
+		}
+		return c.JSON(ignoreIPs)
+	})
+
+	api.Delete("/jails/:name/ignoreip/:address", apiAdminOnly, func(c fiber.Ctx) error {
+		jailName := c.Params("name")
+		value, unescapeErr := url.PathUnescape(c.Params("address"))
+		accessLog(configuration.TrustProxyHeaders, "api delete ignoreip "+jailName, c)
+
+		if _, exists := lookupJail(c, dataStore, jailName); !exists {
+			return c.Status(fiber.StatusNotFound).JSON(apiError{Error: "jail not found"})
+		}
+		address, valid := ignoreEntry(value)
+		if unescapeErr != nil || !valid {
+			return c.Status(fiber.StatusBadRequest).JSON(apiError{Error: "not a valid ignore list entry"})
+		}
+
+		ignoreIPs, err := dataStore.DelIgnoreIP(actorFromContext(c), jailName, address)
+		if err != nil {
+			return c.Status(fiber.StatusBadGateway).JSON(apiError{Error: err.Error()})
+		}
+		return c.JSON(ignoreIPs)
+	})
+
+	api.Post("/jails/:name/bans", apiScope(auth.ScopeBan), func(c fiber.Ctx) error {
+		jailName := c.Params("name")
+		accessLog(configuration.TrustProxyHeaders, "api ban "+jailName, c)
+
+		if !strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON) {
+			return c.Status(fiber.StatusUnsupportedMediaType).JSON(apiError{Error: "content type must be application/json"})
+		}
+		if _, exists := lookupJail(c, dataStore, jailName); !exists {
+			return c.Status(fiber.StatusNotFound).JSON(apiError{Error: "jail not found"})
+		}
+
+		var request banRequest
+		if err := json.Unmarshal(c.Body(), &request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(apiError{Error: "body must be a JSON object with an address"})
+		}
+		address, valid := normalizeAddress(request.Address)
+		if !valid {
+			return c.Status(fiber.StatusBadRequest).JSON(apiError{Error: "not a valid IP address or CIDR range"})
+		}
+
+		if err := dataStore.Ban(jailName, address)[0]; err != nil {
+			log.Errorf("Could not ban %s in %s: %s", address, jailName, err)
+			return c.Status(fiber.StatusBadGateway).JSON(apiError{Error: err.Error()})
+		}
+		log.Infof("Banned %s in %s", address, jailName)
+		return c.JSON(jailBans(c, dataStore, geoIP, jailName))
+	})
+
+	api.Delete("/jails/:name/bans/:address", apiScope(auth.ScopeUnban), func(c fiber.Ctx) error {
+		jailName := c.Params("name")
+		value, unescapeErr := url.PathUnescape(c.Params("address"))
+		accessLog(configuration.TrustProxyHeaders, "api unban "+jailName, c)
+
+		if _, exists := lookupJail(c, dataStore, jailName); !exists {
+			return c.Status(fiber.StatusNotFound).JSON(apiError{Error: "jail not found"})
+		}
+		address, valid := normalizeAddress(value)
+		if unescapeErr != nil || !valid {
+			return c.Status(fiber.StatusBadRequest).JSON(apiError{Error: "not a valid IP address or CIDR range"})
+		}
+
+		if err := dataStore.Unban(jailName, address); err != nil {
+			log.Errorf("Could not unban %s from %s: %s", address, jailName, err)
+			return c.Status(fiber.StatusBadGateway).JSON(apiError{Error: err.Error()})
+		}
+		log.Infof("Unbanned %s from %s", address, jailName)
+		return c.JSON(jailBans(c, dataStore, geoIP, jailName))
+	})
+
+	api.Get("/bans", func(c fiber.Ctx) error {
+		accessLog(configuration.TrustProxyHeaders, "api bans", c)
+		jails := visibleJails(currentUser(c), dataStore.GetJails())
+
+		banned := make([]client.BanEntry, 0)
+		for _, jail := range jails {
+			banned = append(banned, jail.BannedEntries...)
+		}
+
+		lookupCountryCodes(geoIP, banned)
+		banned = filterBans(banned, c.Query("jail"), c.Query("country"))
+
+		sorting := c.Query("sort", "ends")
+		order := c.Query("order", "asc")
+		sort.Slice(banned, sortSlice(sorting, order, banned))
+
+		return c.JSON(banned)
+	})
+
+	api.Get("/addresses/:address", func(c fiber.Ctx) error {
+		value, unescapeErr := url.PathUnescape(c.Params("address"))
+		accessLog(configuration.TrustProxyHeaders, "api address "+value, c)
+
+		address, valid := normalizeAddress(value)
+		if unescapeErr != nil || !valid {
+			return c.Status(fiber.StatusBadRequest).JSON(apiError{Error: "not a valid IP address or CIDR range"})
+		}
+
+		return c.JSON(newAddressDetail(dataStore, geoIP, address, currentUser(c)))
+	})
+
+	api.Get("/history", func(c fiber.Ctx) error {
+		accessLog(configuration.TrustProxyHeaders, "api history", c)
+		history := dataStore.History()
+		if history == nil {
+			return c.Status(fiber.StatusNotFound).JSON(apiError{Error: "history is disabled"})
+		}
+
+		filter := store.HistoryFilter{
+			Address:  c.Query("address"),
+			JailName: c.Query("jail"),
+		}
+		if since := c.Query("since"); since != "" {
+			parsed, err := time.Parse(time.RFC3339, since)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(apiError{Error: "since must be a RFC 3339 date-time"})
+			}
+			filter.Since = parsed
+		}
+
+		return c.JSON(visibleHistory(currentUser(c), history.Entries(filter)))
+	})
+
+	api.Get("/audit", func(c fiber.Ctx) error {
+		accessLog(configuration.TrustProxyHeaders, "api audit", c)
+		trail := dataStore.Audit()
+		if trail == nil {
+			return c.Status(fiber.StatusNotFound).JSON(apiError{Error: "audit trail is disabled"})
+		}
+		return c.JSON(visibleAudit(currentUser(c), trail.Entries(c.Query("jail"))))
+	})
+}
+
+// jailBans are the bans of the jail after a change, the store was refreshed by the change
+func jailBans(c fiber.Ctx, dataStore *store.DataStore, geoIP *geoip.GeoIP, jailName string) []client.BanEntry {
+	banned := make([]client.BanEntry, 0)
+	if jail, exists := lookupJail(c, dataStore, jailName); exists {
+		banned = append(banned, jail.BannedEntries...)
+	}
+	lookupCountryCodes(geoIP, banned)
+	return banned
+}
+
+// filterBans keeps the bans matching the jail and country code, empty values match everything
+func filterBans(banned []client.BanEntry, jailName string, countryCode string) []client.BanEntry {
+	result := make([]client.BanEntry, 0, len(banned))
+	for _, ban := range banned {
+		if jailName != "" && ban.JailName != jailName {
+			continue
+		}
+		if countryCode != "" && !strings.EqualFold(ban.CountryCode, countryCode) {
+			continue
+		}
+		result = append(result, ban)
+	}
+	return result
+}
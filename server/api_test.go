@@ -0,0 +1,366 @@
+package server
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+	"github.com/webishdev/fail2ban-dashboard/geoip"
+	"github.com/webishdev/fail2ban-dashboard/store"
+)
+
+func TestAPIEndpoints(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       Configuration
+		path         string
+		expectedCode int
+		expectedType string
+		expectedBody string
+		expectedPart string
+	}{
+		{
+			name:         "jails",
+			config:       Configuration{BasePath: "/"},
+			path:         "/api/v1/jails",
+			expectedCode: fiber.StatusOK,
+			expectedType: fiber.MIMEApplicationJSONCharsetUTF8,
+			expectedBody: "[]",
+		},
+		{
+			name:         "unknown jail",
+			config:       Configuration{BasePath: "/"},
+			path:         "/api/v1/jails/sshd",
+			expectedCode: fiber.StatusNotFound,
+			expectedType: fiber.MIMEApplicationJSONCharsetUTF8,
+			expectedBody: `{"error":"jail not found"}`,
+		},
+		{
+			name:         "bans",
+			config:       Configuration{BasePath: "/"},
+			path:         "/api/v1/bans?jail=sshd&country=DE&sort=address&order=desc",
+			expectedCode: fiber.StatusOK,
+			expectedType: fiber.MIMEApplicationJSONCharsetUTF8,
+			expectedBody: "[]",
+		},
+		{
+			name:         "address",
+			config:       Configuration{BasePath: "/"},
+			path:         "/api/v1/addresses/192.168.1.1",
+			expectedCode: fiber.StatusOK,
+			expectedType: fiber.MIMEApplicationJSONCharsetUTF8,
+			expectedBody: `{"address":"192.168.1.1","countryCode":"unknown","bans":[],"history":[],"escalation":[]}`,
+		},
+		{
+			name:         "address range",
+			config:       Configuration{BasePath: "/"},
+			path:         "/api/v1/addresses/10.0.0.1%2F24",
+			expectedCode: fiber.StatusOK,
+			expectedType: fiber.MIMEApplicationJSONCharsetUTF8,
+			expectedBody: `{"address":"10.0.0.0/24","countryCode":"unknown","bans":[],"history":[],"escalation":[]}`,
+		},
+		{
+			name:         "invalid address",
+			config:       Configuration{BasePath: "/"},
+			path:         "/api/v1/addresses/example.com",
+			expectedCode: fiber.StatusBadRequest,
+			expectedType: fiber.MIMEApplicationJSONCharsetUTF8,
+			expectedBody: `{"error":"not a valid IP address or CIDR range"}`,
+		},
+		{
+			name:         "history disabled",
+			config:       Configuration{BasePath: "/"},
+			path:         "/api/v1/history",
+			expectedCode: fiber.StatusNotFound,
+			expectedType: fiber.MIMEApplicationJSONCharsetUTF8,
+			expectedBody: `{"error":"history is disabled"}`,
+		},
+		{
+			name:         "ignore list of unknown jail",
+			config:       Configuration{BasePath: "/"},
+			path:         "/api/v1/jails/sshd/ignoreip",
+			expectedCode: fiber.StatusNotFound,
+			expectedType: fiber.MIMEApplicationJSONCharsetUTF8,
+			expectedBody: `{"error":"jail not found"}`,
+		},
+		{
+			name:         "audit disabled",
+			config:       Configuration{BasePath: "/"},
+			path:         "/api/v1/audit",
+			expectedCode: fiber.StatusNotFound,
+			expectedType: fiber.MIMEApplicationJSONCharsetUTF8,
+			expectedBody: `{"error":"audit trail is disabled"}`,
+		},
+		{
+			name:         "openapi document",
+			config:       Configuration{BasePath: "/"},
+			path:         "/api/v1/openapi.yaml",
+			expectedCode: fiber.StatusOK,
+			expectedType: "application/yaml",
+		},
+		{
+			name:         "status",
+			config:       Configuration{BasePath: "/"},
+			path:         "/api/v1/status",
+			expectedCode: fiber.StatusOK,
+			expectedType: fiber.MIMEApplicationJSONCharsetUTF8,
+			expectedPart: `"stale":true,"message":"Not connected to fail2ban, no data could be fetched yet","refresh":{}}`,
+		},
+		{
+			name:         "with base path",
+			config:       Configuration{BasePath: "/dashboard"},
+			path:         "/dashboard/api/v1/jails",
+			expectedCode: fiber.StatusOK,
+			expectedType: fiber.MIMEApplicationJSONCharsetUTF8,
+			expectedBody: "[]",
+		},
+		{
+			name:         "with authentication",
+			config:       Configuration{BasePath: "/", AuthUser: "admin", AuthPassword: "secret"},
+			path:         "/api/v1/jails",
+			expectedCode: fiber.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New(fiber.Config{})
+			config := tt.config
+			err := RegisterDashboardEndpoints(app, newDisconnectedDataStore(t), &geoip.GeoIP{}, &config)
+			if err != nil {
+				t.Fatalf("Failed to register endpoints: %v", err)
+			}
+
+			req := httptest.NewRequest("GET", tt.path, nil)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to make request: %v", err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+
+			if resp.StatusCode != tt.expectedCode {
+				t.Errorf("Expected status code %d, got %d", tt.expectedCode, resp.StatusCode)
+			}
+
+			if tt.expectedType != "" && resp.Header.Get("Content-Type") != tt.expectedType {
+				t.Errorf("Expected content type %s, got %s", tt.expectedType, resp.Header.Get("Content-Type"))
+			}
+
+			if tt.expectedBody != "" {
+				body, err := io.ReadAll(resp.Body)
+				if err != nil {
+					t.Fatalf("Failed to read response body: %v", err)
+				}
+				if string(body) != tt.expectedBody {
+					t.Errorf("Expected body %s, got %s", tt.expectedBody, string(body))
+				}
+			}
+			if tt.expectedPart != "" {
+				body, err := io.ReadAll(resp.Body)
+				if err != nil {
+					t.Fatalf("Failed to read response body: %v", err)
+				}
+				if !strings.Contains(string(body), tt.expectedPart) {
+					t.Errorf("Expected body containing %s, got %s", tt.expectedPart, string(body))
+				}
+			}
+		})
+	}
+}
+
+func TestHistoryEndpoint(t *testing.T) {
+	history, err := store.NewHistory(store.HistoryConfiguration{Directory: t.TempDir()}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create history: %v", err)
+	}
+	defer func() { _ = history.Close() }()
+
+	dataStore := newDisconnectedDataStore(t)
+	dataStore.EnableHistory(history)
+
+	app := fiber.New(fiber.Config{})
+	err = RegisterDashboardEndpoints(app, dataStore, &geoip.GeoIP{}, &Configuration{BasePath: "/"})
+	if err != nil {
+		t.Fatalf("Failed to register endpoints: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		expectedCode int
+		expectedBody string
+	}{
+		{"all entries", "/api/v1/history", fiber.StatusOK, "[]"},
+		{"filtered", "/api/v1/history?address=192.168.1.1&jail=sshd&since=2025-01-01T00:00:00Z", fiber.StatusOK, "[]"},
+		{"invalid since", "/api/v1/history?since=yesterday", fiber.StatusBadRequest, `{"error":"since must be a RFC 3339 date-time"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", tt.path, nil))
+			if err != nil {
+				t.Fatalf("Failed to make request: %v", err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+
+			if resp.StatusCode != tt.expectedCode {
+				t.Errorf("Expected status code %d, got %d", tt.expectedCode, resp.StatusCode)
+			}
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("Failed to read response body: %v", err)
+			}
+			if string(body) != tt.expectedBody {
+				t.Errorf("Expected body %s, got %s", tt.expectedBody, string(body))
+			}
+		})
+	}
+}
+
+func TestIgnoreIPEndpoints(t *testing.T) {
+	app := fiber.New(fiber.Config{})
+	err := RegisterDashboardEndpoints(app, newDisconnectedDataStore(t), &geoip.GeoIP{}, &Configuration{BasePath: "/", AuthUser: "admin", AuthPassword: "secret"})
+	if err != nil {
+		t.Fatalf("Failed to register endpoints: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		contentType  string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "add without JSON",
+			method:       "POST",
+			path:         "/api/v1/jails/sshd/ignoreip",
+			contentType:  fiber.MIMEApplicationForm,
+			body:         "address=192.168.1.1",
+			expectedCode: fiber.StatusUnsupportedMediaType,
+			expectedBody: `{"error":"content type must be application/json"}`,
+		},
+		{
+			name:         "add to unknown jail",
+			method:       "POST",
+			path:         "/api/v1/jails/sshd/ignoreip",
+			contentType:  fiber.MIMEApplicationJSON,
+			body:         `{"address":"192.168.1.1"}`,
+			expectedCode: fiber.StatusNotFound,
+			expectedBody: `{"error":"jail not found"}`,
+		},
+		{
+			name:         "delete from unknown jail",
+			method:       "DELETE",
+			path:         "/api/v1/jails/sshd/ignoreip/192.168.1.0%2F24",
+			expectedCode: fiber.StatusNotFound,
+			expectedBody: `{"error":"jail not found"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set(fiber.HeaderContentType, tt.contentType)
+			}
+			req.SetBasicAuth("admin", "secret")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to make request: %v", err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+
+			if resp.StatusCode != tt.expectedCode {
+				t.Errorf("Expected status code %d, got %d", tt.expectedCode, resp.StatusCode)
+			}
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("Failed to read response body: %v", err)
+			}
+			if string(body) != tt.expectedBody {
+				t.Errorf("Expected body %s, got %s", tt.expectedBody, string(body))
+			}
+		})
+	}
+}
+
+func TestAuditEndpoint(t *testing.T) {
+	trail, err := store.NewAuditTrail(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create audit trail: %v", err)
+	}
+	defer func() { _ = trail.Close() }()
+	changedAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	trail.Record(store.AuditEntry{Time: changedAt, User: "admin", Action: store.AuditAddIgnoreIP, JailName: "sshd", Address: "192.168.1.1"})
+	trail.Record(store.AuditEntry{Time: changedAt, User: "admin", Action: store.AuditAddIgnoreIP, JailName: "postfix", Address: "192.168.1.2"})
+
+	dataStore := newDisconnectedDataStore(t)
+	dataStore.EnableAudit(trail)
+
+	app := fiber.New(fiber.Config{})
+	err = RegisterDashboardEndpoints(app, dataStore, &geoip.GeoIP{}, &Configuration{BasePath: "/"})
+	if err != nil {
+		t.Fatalf("Failed to register endpoints: %v", err)
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/audit?jail=sshd", nil))
+	if err != nil {
+		t.Fatalf("Failed to make request: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response body: %v", err)
+	}
+	expected := `[{"time":"2025-01-01T12:00:00Z","user":"admin","action":"addignoreip","jail":"sshd","address":"192.168.1.1"}]`
+	if string(body) != expected {
+		t.Errorf("Expected body %s, got %s", expected, string(body))
+	}
+}
+
+func TestFilterBans(t *testing.T) {
+	now := time.Now()
+	banned := []client.BanEntry{
+		createTestBanEntry("192.168.1.1", "sshd", "600", now, now.Add(time.Hour)),
+		createTestBanEntry("192.168.1.2", "postfix", "600", now, now.Add(time.Hour)),
+		createTestBanEntry("192.168.1.3", "sshd", "600", now, now.Add(time.Hour)),
+	}
+	banned[0].CountryCode = "DE"
+	banned[1].CountryCode = "DE"
+	banned[2].CountryCode = "US"
+
+	tests := []struct {
+		name     string
+		jail     string
+		country  string
+		expected []string
+	}{
+		{"no filter", "", "", []string{"192.168.1.1", "192.168.1.2", "192.168.1.3"}},
+		{"by jail", "sshd", "", []string{"192.168.1.1", "192.168.1.3"}},
+		{"by country", "", "de", []string{"192.168.1.1", "192.168.1.2"}},
+		{"by jail and country", "sshd", "US", []string{"192.168.1.3"}},
+		{"no match", "nginx", "", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := filterBans(banned, tt.jail, tt.country)
+			if len(result) != len(tt.expected) {
+				t.Fatalf("Expected %d bans, got %d", len(tt.expected), len(result))
+			}
+			for index, address := range tt.expected {
+				if result[index].Address != address {
+					t.Errorf("Expected %s at position %d, got %s", address, index, result[index].Address)
+				}
+			}
+		})
+	}
+}
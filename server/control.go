@@ -0,0 +1,155 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/log"
+	"github.com/gofiber/fiber/v3/middleware/csrf"
+	"github.com/webishdev/fail2ban-dashboard/auth"
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+	"github.com/webishdev/fail2ban-dashboard/store"
+)
+
+const (
+	controlStart  = "start"
+	controlStop   = "stop"
+	controlReload = "reload"
+)
+
+// jailController are the data store methods used to control jails
+type jailController interface {
+	StartJail(jailName string) (string, error)
+	StopJail(jailName string) (string, error)
+	ReloadJail(jailName string) (string, error)
+	Reload() (string, error)
+}
+
+// controlRequest is a jail control action, reload without a jail reloads all jails
+type controlRequest struct {
+	Action string
+	Jail   string
+}
+
+// controlData is the confirmation page of a control action and after confirming the response of fail2ban
+type controlData struct {
+	baseData
+	Request   controlRequest
+	Confirmed bool
+	Response  string
+	Error     string
+	Return    string
+}
+
+func parseControlRequest(action string, jailName string) (controlRequest, error) {
+	request := controlRequest{Action: action, Jail: strings.TrimSpace(jailName)}
+	switch request.Action {
+	case controlStart, controlStop, controlReload:
+	default:
+		return request, fmt.Errorf("unknown action %q", action)
+	}
+	if request.Jail == "" {
+		if request.Action != controlReload {
+			return request, errors.New("the jail is missing")
+		}
+		return request, nil
+	}
+	if !client.ValidJailName(request.Jail) {
+		return request, fmt.Errorf("invalid jail name %q", request.Jail)
+	}
+	return request, nil
+}
+
+// Description is shown as title on the confirmation and the result page
+func (request controlRequest) Description() string {
+	if request.Jail == "" {
+		return "Reload all jails"
+	}
+	return fmt.Sprintf("%s%s jail %s", strings.ToUpper(request.Action[:1]), request.Action[1:], request.Jail)
+}
+
+// allowed tells if the user may control the jail, reloading all jails needs access to all jails
+func (request controlRequest) allowed(user *auth.User) bool {
+	if request.Jail == "" {
+		return user.AllJails()
+	}
+	return user.CanAccess(request.Jail)
+}
+
+func (request controlRequest) run(controller jailController) (string, error) {
+	switch {
+	case request.Action == controlStart:
+		return controller.StartJail(request.Jail)
+	case request.Action == controlStop:
+		return controller.StopJail(request.Jail)
+	case request.Jail != "":
+		return controller.ReloadJail(request.Jail)
+	}
+	return controller.Reload()
+}
+
+// controlHandler asks for confirmation and runs the action once it was confirmed,
+// controlling jails is only allowed for authenticated admins
+func controlHandler(dataStore *store.DataStore, controlTemplate *template.Template, configuration *Configuration, basePath string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		request, requestErr := parseControlRequest(c.FormValue("action"), c.FormValue("jail"))
+		name := fmt.Sprintf("%s control %s", request.Jail, request.Action)
+		accessLog(configuration.TrustProxyHeaders, name, c)
+
+		if !mayControl(c) {
+			return c.Status(fiber.StatusForbidden).SendString("Controlling jails requires an authenticated admin")
+		}
+		if requestErr != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(requestErr.Error())
+		}
+		if !request.allowed(currentUser(c)) {
+			return c.Status(fiber.StatusForbidden).SendString(fmt.Sprintf("Not allowed to %s", strings.ToLower(request.Description())))
+		}
+		if request.Action == controlReload && !dataStore.ReloadAvailable() {
+			return c.Status(fiber.StatusBadRequest).SendString("Reloading jails is not available as fail2ban-client was not found")
+		}
+
+		data := &controlData{
+			baseData: baseData{
+				Version:         configuration.Version,
+				Fail2BanVersion: dataStore.Fail2BanVersion(),
+				BasePath:        basePath,
+				Static:          true,
+				SessionUser:     sessionUser(c),
+				CSRFToken:       csrf.TokenFromContext(c),
+			},
+			Request:   request,
+			Confirmed: c.FormValue("confirm") == "yes",
+			Return:    basePath,
+		}
+
+		if data.Confirmed {
+			response, err := request.run(dataStore)
+			data.Response = response
+			if err != nil {
+				log.Errorf("Could not %s: %s", strings.ToLower(request.Description()), err)
+				data.Error = err.Error()
+			} else {
+				log.Infof("%s: %s", request.Description(), response)
+			}
+		}
+
+		// stopped jails disappear from fail2ban, the way back leads to the overview then
+		if _, exists := dataStore.GetJailByName(request.Jail); exists {
+			data.Return = basePath + request.Jail
+		}
+		data.Connection = newConnectionData(dataStore.ConnectionState())
+		data.Refresh = newRefreshData(dataStore.ConnectionState(), visibleRefresh(currentUser(c), dataStore.RefreshStatus()))
+
+		var sb strings.Builder
+		err := controlTemplate.Execute(&sb, data)
+		if err != nil {
+			return err
+		}
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTML)
+		return c.SendString(sb.String())
+	}
+}
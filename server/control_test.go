@@ -0,0 +1,240 @@
+package server
+
+import (
+	"html/template"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/webishdev/fail2ban-dashboard/auth"
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+	"github.com/webishdev/fail2ban-dashboard/fail2bantest"
+	"github.com/webishdev/fail2ban-dashboard/geoip"
+	"github.com/webishdev/fail2ban-dashboard/store"
+)
+
+type mockJailController struct {
+	called string
+}
+
+func (m *mockJailController) StartJail(jailName string) (string, error) {
+	m.called = "start " + jailName
+	return "OK", nil
+}
+
+func (m *mockJailController) StopJail(jailName string) (string, error) {
+	m.called = "stop " + jailName
+	return "OK", nil
+}
+
+func (m *mockJailController) ReloadJail(jailName string) (string, error) {
+	m.called = "reload " + jailName
+	return "OK", nil
+}
+
+func (m *mockJailController) Reload() (string, error) {
+	m.called = "reload"
+	return "OK", nil
+}
+
+func TestParseControlRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		action      string
+		jail        string
+		called      string
+		description string
+		wantErr     bool
+	}{
+		{name: "start", action: "start", jail: "sshd", called: "start sshd", description: "Start jail sshd"},
+		{name: "stop", action: "stop", jail: " sshd ", called: "stop sshd", description: "Stop jail sshd"},
+		{name: "reload jail", action: "reload", jail: "nginx-http-auth", called: "reload nginx-http-auth", description: "Reload jail nginx-http-auth"},
+		{name: "reload all", action: "reload", called: "reload", description: "Reload all jails"},
+		{name: "stop without jail", action: "stop", wantErr: true},
+		{name: "unknown action", action: "restart", jail: "sshd", wantErr: true},
+		{name: "option as jail", action: "reload", jail: "--all", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := parseControlRequest(tt.action, tt.jail)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseControlRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if description := request.Description(); description != tt.description {
+				t.Errorf("Description() = %q, want %q", description, tt.description)
+			}
+			controller := &mockJailController{}
+			if _, runErr := request.run(controller); runErr != nil {
+				t.Fatalf("run() error = %v", runErr)
+			}
+			if controller.called != tt.called {
+				t.Errorf("run() called %q, want %q", controller.called, tt.called)
+			}
+		})
+	}
+}
+
+func TestControlTemplate(t *testing.T) {
+	tmpl := template.Must(template.New("control").Parse(string(controlHtml)))
+	template.Must(tmpl.New("head").Parse(`<head></head>`))
+	template.Must(tmpl.New("header").Parse(`<header></header>`))
+
+	tests := []struct {
+		name       string
+		data       controlData
+		expected   []string
+		unexpected []string
+	}{
+		{
+			name: "confirmation",
+			data: controlData{
+				baseData: baseData{BasePath: "/", CSRFToken: "token"},
+				Request:  controlRequest{Action: "stop", Jail: "sshd"},
+				Return:   "/sshd",
+			},
+			expected:   []string{"Stop jail sshd", `name="confirm" value="yes"`, `name="_csrf" value="token"`, `href="/sshd"`},
+			unexpected: []string{"Response of fail2ban"},
+		},
+		{
+			name: "response",
+			data: controlData{
+				baseData:  baseData{BasePath: "/"},
+				Request:   controlRequest{Action: "reload"},
+				Confirmed: true,
+				Response:  "OK",
+			},
+			expected:   []string{"Reload all jails", "fail2ban completed the reload", "Response of fail2ban"},
+			unexpected: []string{`name="confirm"`},
+		},
+		{
+			name: "error",
+			data: controlData{
+				baseData:  baseData{BasePath: "/"},
+				Request:   controlRequest{Action: "reload", Jail: "sshd"},
+				Confirmed: true,
+				Response:  "ERROR  Failed during configuration",
+				Error:     "fail2ban-client failed: exit status 255",
+			},
+			expected: []string{"fail2ban could not reload jail <strong>sshd</strong>: fail2ban-client failed: exit status 255", "ERROR  Failed during configuration"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			if err := tmpl.Execute(&sb, tt.data); err != nil {
+				t.Fatalf("Failed to execute control template: %v", err)
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(sb.String(), expected) {
+					t.Errorf("Expected %q in %s", expected, sb.String())
+				}
+			}
+			for _, unexpected := range tt.unexpected {
+				if strings.Contains(sb.String(), unexpected) {
+					t.Errorf("Did not expect %q in %s", unexpected, sb.String())
+				}
+			}
+		})
+	}
+}
+
+func TestControlRequestAllowed(t *testing.T) {
+	restricted := &auth.User{Name: "bob", Role: auth.RoleAdmin, Jails: []string{"sshd"}}
+	unrestricted := &auth.User{Name: "alice", Role: auth.RoleAdmin}
+
+	tests := []struct {
+		name     string
+		request  controlRequest
+		user     *auth.User
+		expected bool
+	}{
+		{"own jail", controlRequest{Action: controlStop, Jail: "sshd"}, restricted, true},
+		{"other jail", controlRequest{Action: controlStart, Jail: "postfix"}, restricted, false},
+		{"reload all restricted", controlRequest{Action: controlReload}, restricted, false},
+		{"reload all", controlRequest{Action: controlReload}, unrestricted, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if allowed := tt.request.allowed(tt.user); allowed != tt.expected {
+				t.Errorf("allowed() = %v, want %v", allowed, tt.expected)
+			}
+		})
+	}
+}
+
+func TestControlWithoutFail2BanClient(t *testing.T) {
+	fail2ban := fail2bantest.Start(t, fail2bantest.Version1_1)
+	fail2ban.AddJail(fail2bantest.Jail{Name: "sshd", BanTime: 600})
+	fail2ban.AddJail(fail2bantest.Jail{Name: "postfix", BanTime: 600})
+	f2bc, err := client.NewFail2BanClient(fail2ban.Address())
+	if err != nil {
+		t.Fatalf("NewFail2BanClient() error = %v", err)
+	}
+	t.Cleanup(func() { _ = f2bc.Close() })
+	_ = f2bc.SetExecutable(filepath.Join(t.TempDir(), "fail2ban-client"))
+	dataStore := store.NewDataStore(f2bc, 30)
+	if err = dataStore.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	app := fiber.New(fiber.Config{})
+	if err = RegisterDashboardEndpoints(app, dataStore, &geoip.GeoIP{}, &Configuration{BasePath: "/", AuthUser: "admin", AuthPassword: "secret"}); err != nil {
+		t.Fatalf("Failed to register endpoints: %v", err)
+	}
+	cookie := csrfCookie(t, app)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.SetBasicAuth("admin", "secret")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to make request: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if strings.Contains(string(body), "Reload all jails") || !strings.Contains(string(body), "Reloading jails is not available") {
+		t.Error("Expected the reload to be hidden without fail2ban-client")
+	}
+
+	tests := []struct {
+		name         string
+		action       string
+		jail         string
+		expectedCode int
+	}{
+		{"reload all jails", controlReload, "", fiber.StatusBadRequest},
+		{"reload jail", controlReload, "sshd", fiber.StatusBadRequest},
+		{"stop jail", controlStop, "postfix", fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"action": {tt.action}, "jail": {tt.jail}, "confirm": {"yes"}, "_csrf": {cookie.Value}}
+			req := httptest.NewRequest("POST", "/control", strings.NewReader(form.Encode()))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationForm)
+			req.SetBasicAuth("admin", "secret")
+			req.AddCookie(cookie)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to make request: %v", err)
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode != tt.expectedCode {
+				t.Errorf("Expected status code %d, got %d", tt.expectedCode, resp.StatusCode)
+			}
+		})
+	}
+
+	if _, exists := fail2ban.Jail("postfix"); exists {
+		t.Error("Expected postfix to be stopped over the socket")
+	}
+}
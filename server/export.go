@@ -0,0 +1,41 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/webishdev/fail2ban-dashboard/export"
+	"github.com/webishdev/fail2ban-dashboard/geoip"
+	"github.com/webishdev/fail2ban-dashboard/store"
+)
+
+func registerExportEndpoints(router fiber.Router, dataStore *store.DataStore, geoIP *geoip.GeoIP, configuration *Configuration) {
+	router.Get("/export/:format", func(c fiber.Ctx) error {
+		formatName := c.Params("format")
+		accessLog(configuration.TrustProxyHeaders, "export "+formatName, c)
+
+		format, err := export.ParseFormat(formatName)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).SendString(err.Error())
+		}
+
+		options := export.Options{
+			JailName:    c.Query("jail"),
+			CountryCode: c.Query("country"),
+			Name:        c.Query("name"),
+		}
+		if err = options.Validate(); err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+		}
+
+		banned := export.Collect(visibleJails(currentUser(c), dataStore.GetJails()), geoIP.Lookup, format, options)
+
+		var sb strings.Builder
+		err = export.Write(&sb, format, banned, options)
+		if err != nil {
+			return err
+		}
+		c.Set(fiber.HeaderContentType, format.ContentType())
+		return c.SendString(sb.String())
+	})
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/webishdev/fail2ban-dashboard/geoip"
+)
+
+func TestExportEndpoints(t *testing.T) {
+	app := fiber.New(fiber.Config{})
+	err := RegisterDashboardEndpoints(app, newDisconnectedDataStore(t), &geoip.GeoIP{}, &Configuration{BasePath: "/"})
+	if err != nil {
+		t.Fatalf("Failed to register endpoints: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		expectedCode int
+		expectedType string
+		expectedBody string
+	}{
+		{"text", "/export/text?jail=sshd", fiber.StatusOK, "text/plain; charset=utf-8", ""},
+		{"csv", "/export/csv?country=DE", fiber.StatusOK, "text/csv; charset=utf-8", "address,jail,country,bannedAt,banEndsAt,penalty\n"},
+		{"json", "/export/json", fiber.StatusOK, "application/json; charset=utf-8", "[]\n"},
+		{"iptables", "/export/iptables?name=blocklist", fiber.StatusOK, "text/plain; charset=utf-8", "*filter\n:blocklist - [0:0]\nCOMMIT\n"},
+		{"invalid name", "/export/ipset?name=a%20b", fiber.StatusBadRequest, "", "name may only contain letters, digits, - and _"},
+		{"unknown format", "/export/pf", fiber.StatusNotFound, "", "unknown export format pf, supported formats are text, csv, json, nginx, iptables, ipset, cidr"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", tt.path, nil))
+			if err != nil {
+				t.Fatalf("Failed to make request: %v", err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+
+			if resp.StatusCode != tt.expectedCode {
+				t.Errorf("Expected status code %d, got %d", tt.expectedCode, resp.StatusCode)
+			}
+			if tt.expectedType != "" && resp.Header.Get("Content-Type") != tt.expectedType {
+				t.Errorf("Expected content type %s, got %s", tt.expectedType, resp.Header.Get("Content-Type"))
+			}
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("Failed to read response body: %v", err)
+			}
+			if string(body) != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q", tt.expectedBody, string(body))
+			}
+		})
+	}
+}
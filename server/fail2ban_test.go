@@ -0,0 +1,288 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/webishdev/fail2ban-dashboard/auth"
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+	"github.com/webishdev/fail2ban-dashboard/fail2bantest"
+	"github.com/webishdev/fail2ban-dashboard/geoip"
+	"github.com/webishdev/fail2ban-dashboard/store"
+)
+
+func TestAPIWithFail2Ban(t *testing.T) {
+	fail2ban := fail2bantest.Start(t, fail2bantest.Version1_1)
+	fail2ban.AddJail(fail2bantest.Jail{
+		Name:        "sshd",
+		BanTime:     600,
+		MaxRetry:    5,
+		TotalBanned: 1,
+		Bans:        []fail2bantest.Ban{{Address: "192.0.2.10", BannedAt: time.Now(), BanTime: 600}},
+	})
+	f2bc, err := client.NewFail2BanClient(fail2ban.Address())
+	if err != nil {
+		t.Fatalf("NewFail2BanClient() error = %v", err)
+	}
+	t.Cleanup(func() { _ = f2bc.Close() })
+	dataStore := store.NewDataStore(f2bc, 30)
+	if err = dataStore.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	app := fiber.New(fiber.Config{})
+	if err = RegisterDashboardEndpoints(app, dataStore, &geoip.GeoIP{}, &Configuration{BasePath: "/", AuthUser: "admin", AuthPassword: "secret"}); err != nil {
+		t.Fatalf("Failed to register endpoints: %v", err)
+	}
+
+	bannedAddresses := func(method string, path string, body string) []string {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		req.SetBasicAuth("admin", "secret")
+		resp, requestErr := app.Test(req)
+		if requestErr != nil {
+			t.Fatalf("Failed to make request: %v", requestErr)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("%s %s returned status code %d", method, path, resp.StatusCode)
+		}
+		var bans []client.BanEntry
+		if decodeErr := json.NewDecoder(resp.Body).Decode(&bans); decodeErr != nil {
+			t.Fatalf("Failed to decode response: %v", decodeErr)
+		}
+		addresses := make([]string, 0, len(bans))
+		for _, entry := range bans {
+			addresses = append(addresses, entry.Address)
+		}
+		return addresses
+	}
+
+	if addresses := bannedAddresses("GET", "/api/v1/bans?jail=sshd", ""); strings.Join(addresses, ",") != "192.0.2.10" {
+		t.Errorf("Expected the ban of fail2ban, got %v", addresses)
+	}
+
+	bannedAddresses("POST", "/api/v1/jails/sshd/bans", `{"address":"203.0.113.5"}`)
+	bannedAddresses("DELETE", "/api/v1/jails/sshd/bans/192.0.2.10", "")
+	jail, _ := fail2ban.Jail("sshd")
+	if len(jail.Bans) != 1 || jail.Bans[0].Address != "203.0.113.5" || jail.TotalBanned != 2 {
+		t.Errorf("Expected fail2ban to have the changed bans, got %+v", jail.Bans)
+	}
+	if addresses := bannedAddresses("GET", "/api/v1/bans?jail=sshd", ""); strings.Join(addresses, ",") != "203.0.113.5" {
+		t.Errorf("Expected the refreshed bans, got %v", addresses)
+	}
+
+	// the dropped command closes the connection, the refresh after reconnecting may already
+	// have replaced the failed jail with the missing connection when the status is requested
+	fail2ban.DropCommand("get sshd banip", true)
+	if err = dataStore.Refresh(); err == nil || !strings.Contains(err.Error(), "jail sshd") {
+		t.Errorf("Expected the failed jail to be reported, got %v", err)
+	}
+	req := httptest.NewRequest("GET", "/api/v1/status", nil)
+	req.SetBasicAuth("admin", "secret")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to make request: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var status apiStatus
+	if err = json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !status.Stale || (status.Refresh.FailedJails["sshd"] == "" && status.Refresh.LastError == "") {
+		t.Errorf("Expected the failed refresh to be reported, got %+v", status)
+	}
+}
+
+func TestBanFormWithFail2Ban(t *testing.T) {
+	fail2ban := fail2bantest.Start(t, fail2bantest.Version1_1)
+	fail2ban.AddJail(fail2bantest.Jail{Name: "sshd", BanTime: 600})
+	f2bc, err := client.NewFail2BanClient(fail2ban.Address())
+	if err != nil {
+		t.Fatalf("NewFail2BanClient() error = %v", err)
+	}
+	t.Cleanup(func() { _ = f2bc.Close() })
+	dataStore := store.NewDataStore(f2bc, 30)
+	if err = dataStore.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	app := fiber.New(fiber.Config{})
+	if err = RegisterDashboardEndpoints(app, dataStore, &geoip.GeoIP{}, &Configuration{BasePath: "/", AuthUser: "admin", AuthPassword: "secret"}); err != nil {
+		t.Fatalf("Failed to register endpoints: %v", err)
+	}
+	cookie := csrfCookie(t, app)
+
+	form := url.Values{"addresses": {"203.0.113.5 foo"}, "_csrf": {cookie.Value}}
+	req := httptest.NewRequest("POST", "/sshd/ban", strings.NewReader(form.Encode()))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationForm)
+	req.SetBasicAuth("admin", "secret")
+	req.AddCookie(cookie)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to make request: %v", err)
+	}
+	_ = resp.Body.Close()
+	location := resp.Header.Get(fiber.HeaderLocation)
+	if resp.StatusCode != fiber.StatusSeeOther || !strings.HasPrefix(location, "/sshd?ban=") {
+		t.Fatalf("Expected a redirect to the jail, got %d %q", resp.StatusCode, location)
+	}
+	if jail, _ := fail2ban.Jail("sshd"); len(jail.Bans) != 1 || jail.Bans[0].Address != "203.0.113.5" {
+		t.Errorf("Expected fail2ban to have the ban, got %+v", jail.Bans)
+	}
+
+	detail := func() string {
+		t.Helper()
+		detailReq := httptest.NewRequest("GET", location, nil)
+		detailReq.SetBasicAuth("admin", "secret")
+		detailResp, requestErr := app.Test(detailReq)
+		if requestErr != nil {
+			t.Fatalf("Failed to make request: %v", requestErr)
+		}
+		defer func() { _ = detailResp.Body.Close() }()
+		body, _ := io.ReadAll(detailResp.Body)
+		return string(body)
+	}
+
+	body := detail()
+	if !strings.Contains(body, "<strong>203.0.113.5</strong> has been banned") || !strings.Contains(body, "<strong>foo</strong> could not be banned") {
+		t.Errorf("Expected the ban results on the detail page, got %s", body)
+	}
+	if body = detail(); strings.Contains(body, "has been banned") {
+		t.Error("Expected the ban results to be shown only once")
+	}
+}
+
+func TestReservedJailNameWithFail2Ban(t *testing.T) {
+	fail2ban := fail2bantest.Start(t, fail2bantest.Version1_1)
+	fail2ban.AddJail(fail2bantest.Jail{Name: "sshd", BanTime: 600})
+	fail2ban.AddJail(fail2bantest.Jail{Name: "ip", BanTime: 600})
+	f2bc, err := client.NewFail2BanClient(fail2ban.Address())
+	if err != nil {
+		t.Fatalf("NewFail2BanClient() error = %v", err)
+	}
+	t.Cleanup(func() { _ = f2bc.Close() })
+	dataStore := store.NewDataStore(f2bc, 30)
+	if err = dataStore.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	app := fiber.New(fiber.Config{})
+	if err = RegisterDashboardEndpoints(app, dataStore, &geoip.GeoIP{}, &Configuration{BasePath: "/", AuthUser: "admin", AuthPassword: "secret"}); err != nil {
+		t.Fatalf("Failed to register endpoints: %v", err)
+	}
+
+	request := func(path string) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		req.SetBasicAuth("admin", "secret")
+		resp, requestErr := app.Test(req)
+		if requestErr != nil {
+			t.Fatalf("Failed to make request: %v", requestErr)
+		}
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp
+	}
+
+	if resp := request("/ip"); resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("Expected the jail named like a page to be rejected, got %d", resp.StatusCode)
+	}
+	var jails []store.Jail
+	if err = json.NewDecoder(request("/api/v1/jails").Body).Decode(&jails); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(jails) != 1 || jails[0].Name != "sshd" {
+		t.Errorf("Expected only sshd, got %+v", jails)
+	}
+}
+
+func TestScopedTokenWithFail2Ban(t *testing.T) {
+	fail2ban := fail2bantest.Start(t, fail2bantest.Version1_1)
+	fail2ban.AddJail(fail2bantest.Jail{
+		Name:    "sshd",
+		BanTime: 600,
+		Bans:    []fail2bantest.Ban{{Address: "192.0.2.10", BannedAt: time.Now(), BanTime: 600}},
+	})
+	fail2ban.AddJail(fail2bantest.Jail{
+		Name:    "postfix",
+		BanTime: 600,
+		Bans:    []fail2bantest.Ban{{Address: "198.51.100.7", BannedAt: time.Now(), BanTime: 600}},
+	})
+	f2bc, err := client.NewFail2BanClient(fail2ban.Address())
+	if err != nil {
+		t.Fatalf("NewFail2BanClient() error = %v", err)
+	}
+	t.Cleanup(func() { _ = f2bc.Close() })
+	dataStore := store.NewDataStore(f2bc, 30)
+	if err = dataStore.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	tokens, err := auth.NewTokenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, secret, err := tokens.Create("sshd-only", []string{auth.ScopeRead, auth.ScopeBan, auth.ScopeExport}, []string{"sshd"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New(fiber.Config{})
+	if err = RegisterDashboardEndpoints(app, dataStore, &geoip.GeoIP{}, &Configuration{BasePath: "/", AuthUser: "admin", AuthPassword: "secret", Tokens: tokens}); err != nil {
+		t.Fatalf("Failed to register endpoints: %v", err)
+	}
+
+	request := func(method string, path string, body string) (int, string) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+secret)
+		resp, requestErr := app.Test(req)
+		if requestErr != nil {
+			t.Fatalf("Failed to make request: %v", requestErr)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		content, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(content)
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		body         string
+		expectedCode int
+		hidden       string
+	}{
+		{name: "jails", method: "GET", path: "/api/v1/jails", expectedCode: fiber.StatusOK, hidden: "postfix"},
+		{name: "bans", method: "GET", path: "/api/v1/bans", expectedCode: fiber.StatusOK, hidden: "198.51.100.7"},
+		{name: "export", method: "GET", path: "/export/text", expectedCode: fiber.StatusOK, hidden: "198.51.100.7"},
+		{name: "own jail", method: "GET", path: "/api/v1/jails/sshd", expectedCode: fiber.StatusOK},
+		{name: "other jail", method: "GET", path: "/api/v1/jails/postfix", expectedCode: fiber.StatusNotFound},
+		{name: "ban in other jail", method: "POST", path: "/api/v1/jails/postfix/bans", body: `{"address":"203.0.113.5"}`, expectedCode: fiber.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := request(tt.method, tt.path, tt.body)
+			if code != tt.expectedCode {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.expectedCode, code, body)
+			}
+			if tt.hidden != "" && strings.Contains(body, tt.hidden) {
+				t.Errorf("Expected %s to be hidden from the token, got %s", tt.hidden, body)
+			}
+		})
+	}
+
+	if jail, _ := fail2ban.Jail("postfix"); len(jail.Bans) != 1 {
+		t.Errorf("Expected no ban in postfix, got %+v", jail.Bans)
+	}
+}
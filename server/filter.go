@@ -0,0 +1,261 @@
+package server
+
+import (
+	"errors"
+	"html/template"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// banFilter selects the rows of the banned tables, empty values match everything,
+// the raw values are kept to fill the filter form and the links of the table
+type banFilter struct {
+	Address     string
+	CountryCode string
+	JailName    string
+	PenaltyMin  string
+	PenaltyMax  string
+	EndsWithin  string
+	prefix      netip.Prefix
+	penaltyMin  int64
+	penaltyMax  int64
+	endsBefore  time.Time
+}
+
+// pagination describes the shown page of a banned table, the links are empty when there is no such page
+type pagination struct {
+	Page     int
+	Pages    int
+	PageSize int
+	Total    int
+	First    int
+	Last     int
+	Previous template.URL
+	Next     template.URL
+}
+
+// filterData is what the filter form and the pagination below a banned table need
+type filterData struct {
+	Filter      banFilter
+	FilterQuery template.URL
+	Filtered    bool
+	LiveRows    bool
+	JailNames   []string
+	Sorting     string
+	Order       string
+	Pagination  pagination
+}
+
+// parseBanFilter reads the filter from the query parameters address, country, jail, penaltyMin, penaltyMax and endsWithin
+func parseBanFilter(c fiber.Ctx, now time.Time) (banFilter, error) {
+	filter := banFilter{
+		Address:     strings.TrimSpace(c.Query("address")),
+		CountryCode: strings.TrimSpace(c.Query("country")),
+		JailName:    strings.TrimSpace(c.Query("jail")),
+		PenaltyMin:  strings.TrimSpace(c.Query("penaltyMin")),
+		PenaltyMax:  strings.TrimSpace(c.Query("penaltyMax")),
+		EndsWithin:  strings.TrimSpace(c.Query("endsWithin")),
+	}
+
+	if strings.Contains(filter.Address, "/") {
+		prefix, err := netip.ParsePrefix(filter.Address)
+		if err != nil {
+			return banFilter{}, errors.New("address must be part of an address or a CIDR range")
+		}
+		filter.prefix = prefix.Masked()
+	}
+
+	if filter.PenaltyMin != "" {
+		penaltyMin, err := strconv.ParseInt(filter.PenaltyMin, 10, 64)
+		if err != nil {
+			return banFilter{}, errors.New("penaltyMin must be a number of seconds")
+		}
+		filter.penaltyMin = penaltyMin
+	}
+
+	if filter.PenaltyMax != "" {
+		penaltyMax, err := strconv.ParseInt(filter.PenaltyMax, 10, 64)
+		if err != nil {
+			return banFilter{}, errors.New("penaltyMax must be a number of seconds")
+		}
+		filter.penaltyMax = penaltyMax
+	}
+
+	if filter.EndsWithin != "" {
+		minutes, err := strconv.ParseUint(filter.EndsWithin, 10, 32)
+		if err != nil {
+			return banFilter{}, errors.New("endsWithin must be a number of minutes")
+		}
+		filter.endsBefore = now.Add(time.Duration(minutes) * time.Minute)
+	}
+
+	return filter, nil
+}
+
+// active is true when at least one value of the filter is set
+func (filter banFilter) active() bool {
+	return filter.Address != "" || filter.CountryCode != "" || filter.JailName != "" ||
+		filter.PenaltyMin != "" || filter.PenaltyMax != "" || filter.EndsWithin != ""
+}
+
+// matches checks a single ban, permanent bans are above every penalty and never end
+func (filter banFilter) matches(ban client.BanEntry) bool {
+	if filter.JailName != "" && ban.JailName != filter.JailName {
+		return false
+	}
+	if filter.CountryCode != "" && !strings.EqualFold(ban.CountryCode, filter.CountryCode) {
+		return false
+	}
+	if filter.Address != "" && !filter.matchesAddress(ban.Address) {
+		return false
+	}
+
+	permanent := ban.CurrenPenalty == "-1"
+	penalty := penaltyToUint64(ban.CurrenPenalty)
+	if filter.PenaltyMin != "" && !permanent && penalty < filter.penaltyMin {
+		return false
+	}
+	if filter.PenaltyMax != "" && (permanent || penalty > filter.penaltyMax) {
+		return false
+	}
+	if filter.EndsWithin != "" && (permanent || ban.BanEndsAt.After(filter.endsBefore)) {
+		return false
+	}
+	return true
+}
+
+// matchesAddress matches a CIDR range against addresses and overlapping ranges, other values as substring
+func (filter banFilter) matchesAddress(address string) bool {
+	if filter.prefix.IsValid() {
+		if addr, err := netip.ParseAddr(address); err == nil {
+			return filter.prefix.Contains(addr)
+		}
+		if prefix, err := netip.ParsePrefix(address); err == nil {
+			return filter.prefix.Overlaps(prefix)
+		}
+		return false
+	}
+	return strings.Contains(strings.ToLower(address), strings.ToLower(filter.Address))
+}
+
+func (filter banFilter) apply(banned []client.BanEntry) []client.BanEntry {
+	if !filter.active() {
+		return banned
+	}
+	result := make([]client.BanEntry, 0, len(banned))
+	for _, ban := range banned {
+		if filter.matches(ban) {
+			result = append(result, ban)
+		}
+	}
+	return result
+}
+
+// values are the query parameters of the set filter values
+func (filter banFilter) values() url.Values {
+	values := url.Values{}
+	for key, value := range map[string]string{
+		"address":    filter.Address,
+		"country":    filter.CountryCode,
+		"jail":       filter.JailName,
+		"penaltyMin": filter.PenaltyMin,
+		"penaltyMax": filter.PenaltyMax,
+		"endsWithin": filter.EndsWithin,
+	} {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	return values
+}
+
+// parsePageSize reads the pageSize query parameter, invalid values fall back to the configured page size
+func parsePageSize(c fiber.Ctx, configured int) int {
+	if configured <= 0 || configured > maxPageSize {
+		configured = defaultPageSize
+	}
+	pageSize, err := strconv.Atoi(c.Query("pageSize"))
+	if err != nil || pageSize <= 0 {
+		return configured
+	}
+	return min(pageSize, maxPageSize)
+}
+
+// paginate returns the rows of the page, pages beyond the last one show the last page
+func paginate(banned []client.BanEntry, page int, pageSize int, query url.Values) ([]client.BanEntry, pagination) {
+	result := pagination{
+		Page:     1,
+		Pages:    max(1, (len(banned)+pageSize-1)/pageSize),
+		PageSize: pageSize,
+		Total:    len(banned),
+	}
+	result.Page = min(max(page, 1), result.Pages)
+
+	start := (result.Page - 1) * pageSize
+	end := min(start+pageSize, len(banned))
+	if len(banned) > 0 {
+		result.First = start + 1
+		result.Last = end
+	}
+
+	pageLink := func(page int) template.URL {
+		values := url.Values{}
+		for key, value := range query {
+			values[key] = value
+		}
+		values.Set("page", strconv.Itoa(page))
+		return template.URL("?" + values.Encode())
+	}
+	if result.Page > 1 {
+		result.Previous = pageLink(result.Page - 1)
+	}
+	if result.Page < result.Pages {
+		result.Next = pageLink(result.Page + 1)
+	}
+
+	return banned[start:end], result
+}
+
+// newFilterData filters, sorts and paginates the banned entries for a table,
+// the sorting links keep the filter and page size but start again at the first page
+func newFilterData(c fiber.Ctx, banned []client.BanEntry, filter banFilter, sorting string, order string, configuredPageSize int) ([]client.BanEntry, filterData) {
+	pageSize := parsePageSize(c, configuredPageSize)
+
+	query := filter.values()
+	if c.Query("pageSize") != "" {
+		query.Set("pageSize", strconv.Itoa(pageSize))
+	}
+
+	filterQuery := ""
+	if len(query) > 0 {
+		filterQuery = "&" + query.Encode()
+	}
+
+	query.Set("sorting", sorting)
+	query.Set("order", order)
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	filtered := filter.apply(banned)
+	rows, pages := paginate(filtered, page, pageSize, query)
+
+	return rows, filterData{
+		Filter:      filter,
+		FilterQuery: template.URL(filterQuery),
+		Filtered:    filter.active(),
+		LiveRows:    !filter.active() && pages.Pages == 1,
+		Sorting:     sorting,
+		Order:       order,
+		Pagination:  pages,
+	}
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"html/template"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+	"github.com/webishdev/fail2ban-dashboard/geoip"
+)
+
+// parseTestFilter runs parseBanFilter for the query inside a request
+func parseTestFilter(t *testing.T, query string, now time.Time) (banFilter, error) {
+	t.Helper()
+	var filter banFilter
+	var filterErr error
+	app := fiber.New(fiber.Config{})
+	app.Get("/", func(c fiber.Ctx) error {
+		filter, filterErr = parseBanFilter(c, now)
+		return nil
+	})
+	resp, err := app.Test(httptest.NewRequest("GET", "/?"+query, nil))
+	if err != nil {
+		t.Fatalf("Failed to make request: %v", err)
+	}
+	_ = resp.Body.Close()
+	return filter, filterErr
+}
+
+func TestBanFilter(t *testing.T) {
+	now := time.Now()
+	banned := []client.BanEntry{
+		createTestBanEntry("192.168.1.1", "sshd", "600", now, now.Add(10*time.Minute)),
+		createTestBanEntry("192.168.2.1", "postfix", "3600", now, now.Add(time.Hour)),
+		createTestBanEntry("10.0.0.0/24", "sshd", "1200", now, now.Add(20*time.Minute)),
+		createTestBanEntry("2001:db8::1", "sshd", "-1", now, time.Time{}),
+	}
+	banned[0].CountryCode = "DE"
+	banned[1].CountryCode = "US"
+	banned[2].CountryCode = "unknown"
+	banned[3].CountryCode = "unknown"
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{"no filter", "", []string{"192.168.1.1", "192.168.2.1", "10.0.0.0/24", "2001:db8::1"}},
+		{"address substring", "address=192.168.", []string{"192.168.1.1", "192.168.2.1"}},
+		{"address substring ignores case", "address=DB8", []string{"2001:db8::1"}},
+		{"address range", "address=192.168.2.0/24", []string{"192.168.2.1"}},
+		{"overlapping range", "address=10.0.0.0/16", []string{"10.0.0.0/24"}},
+		{"country", "country=de", []string{"192.168.1.1"}},
+		{"jail", "jail=postfix", []string{"192.168.2.1"}},
+		{"penalty from", "penaltyMin=1200", []string{"192.168.2.1", "10.0.0.0/24", "2001:db8::1"}},
+		{"penalty to", "penaltyMax=1200", []string{"192.168.1.1", "10.0.0.0/24"}},
+		{"penalty range", "penaltyMin=1000&penaltyMax=2000", []string{"10.0.0.0/24"}},
+		{"ends within", "endsWithin=30", []string{"192.168.1.1", "10.0.0.0/24"}},
+		{"combined", "jail=sshd&endsWithin=15", []string{"192.168.1.1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := parseTestFilter(t, tt.query, now)
+			if err != nil {
+				t.Fatalf("Failed to parse filter: %v", err)
+			}
+			result := filter.apply(banned)
+			if len(result) != len(tt.expected) {
+				t.Fatalf("Expected %d bans, got %d", len(tt.expected), len(result))
+			}
+			for index, address := range tt.expected {
+				if result[index].Address != address {
+					t.Errorf("Expected %s at position %d, got %s", address, index, result[index].Address)
+				}
+			}
+		})
+	}
+}
+
+func TestParseBanFilterErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{"invalid range", "address=192.168.1.0/33", "address must be part of an address or a CIDR range"},
+		{"invalid penalty from", "penaltyMin=ten", "penaltyMin must be a number of seconds"},
+		{"invalid penalty to", "penaltyMax=ten", "penaltyMax must be a number of seconds"},
+		{"negative ends within", "endsWithin=-5", "endsWithin must be a number of minutes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTestFilter(t, tt.query, time.Now())
+			if err == nil || err.Error() != tt.expected {
+				t.Errorf("Expected error %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	now := time.Now()
+	banned := make([]client.BanEntry, 5)
+	for index := range banned {
+		banned[index] = createTestBanEntry("192.168.1."+string(rune('1'+index)), "sshd", "600", now, now)
+	}
+	query := url.Values{"sorting": {"address"}, "order": {"asc"}}
+
+	tests := []struct {
+		name             string
+		page             int
+		expectedPage     int
+		expectedFirst    string
+		expectedRows     int
+		expectedPrevious string
+		expectedNext     string
+	}{
+		{"first page", 1, 1, "192.168.1.1", 2, "", "?order=asc&page=2&sorting=address"},
+		{"middle page", 2, 2, "192.168.1.3", 2, "?order=asc&page=1&sorting=address", "?order=asc&page=3&sorting=address"},
+		{"last page", 3, 3, "192.168.1.5", 1, "?order=asc&page=2&sorting=address", ""},
+		{"beyond last page", 10, 3, "192.168.1.5", 1, "?order=asc&page=2&sorting=address", ""},
+		{"invalid page", 0, 1, "192.168.1.1", 2, "", "?order=asc&page=2&sorting=address"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, pages := paginate(banned, tt.page, 2, query)
+			if pages.Page != tt.expectedPage || pages.Pages != 3 || pages.Total != 5 {
+				t.Errorf("Expected page %d of 3 with 5 bans, got %+v", tt.expectedPage, pages)
+			}
+			if len(rows) != tt.expectedRows || rows[0].Address != tt.expectedFirst {
+				t.Errorf("Expected %d rows starting with %s, got %+v", tt.expectedRows, tt.expectedFirst, rows)
+			}
+			if string(pages.Previous) != tt.expectedPrevious || string(pages.Next) != tt.expectedNext {
+				t.Errorf("Expected links %q and %q, got %q and %q", tt.expectedPrevious, tt.expectedNext, pages.Previous, pages.Next)
+			}
+		})
+	}
+
+	rows, pages := paginate(nil, 1, 2, query)
+	if len(rows) != 0 || pages.Pages != 1 || pages.First != 0 || pages.Last != 0 {
+		t.Errorf("Expected a single empty page, got %+v", pages)
+	}
+}
+
+func TestInvalidFilterRouteHandlers(t *testing.T) {
+	app := fiber.New(fiber.Config{})
+	err := RegisterDashboardEndpoints(app, newDisconnectedDataStore(t), &geoip.GeoIP{}, &Configuration{BasePath: "/"})
+	if err != nil {
+		t.Fatalf("Failed to register endpoints: %v", err)
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/?penaltyMin=ten", nil))
+	if err != nil {
+		t.Fatalf("Failed to make request: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", fiber.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestParsePageSize(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		configured int
+		expected   int
+	}{
+		{"configured", "", 50, 50},
+		{"not configured", "", 0, defaultPageSize},
+		{"from query", "pageSize=20", 50, 20},
+		{"invalid query", "pageSize=all", 50, 50},
+		{"above maximum", "pageSize=5000", 50, maxPageSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pageSize int
+			app := fiber.New(fiber.Config{})
+			app.Get("/", func(c fiber.Ctx) error {
+				pageSize = parsePageSize(c, tt.configured)
+				return nil
+			})
+			resp, err := app.Test(httptest.NewRequest("GET", "/?"+tt.query, nil))
+			if err != nil {
+				t.Fatalf("Failed to make request: %v", err)
+			}
+			_ = resp.Body.Close()
+			if pageSize != tt.expected {
+				t.Errorf("Expected page size %d, got %d", tt.expected, pageSize)
+			}
+		})
+	}
+}
+
+func TestFilterTemplates(t *testing.T) {
+	data := filterData{
+		Filter:     banFilter{Address: "192.168.", JailName: "sshd"},
+		Filtered:   true,
+		JailNames:  []string{"postfix", "sshd"},
+		Sorting:    "ends",
+		Order:      "asc",
+		Pagination: pagination{Page: 2, Pages: 3, PageSize: 10, Total: 25, First: 11, Last: 20, Previous: "?page=1", Next: "?page=3"},
+	}
+
+	var sb strings.Builder
+	tmpl := template.Must(template.New("filter").Parse(string(filterHtml)))
+	template.Must(tmpl.New("pagination").Parse(string(paginationHtml)))
+	if err := tmpl.ExecuteTemplate(&sb, "filter", data); err != nil {
+		t.Fatalf("Failed to execute filter template: %v", err)
+	}
+	if err := tmpl.ExecuteTemplate(&sb, "pagination", data); err != nil {
+		t.Fatalf("Failed to execute pagination template: %v", err)
+	}
+
+	for _, expected := range []string{`value="192.168."`, `<option value="sshd" selected>`, "11 - 20 of 25 matching", "Page 2 of 3"} {
+		if !strings.Contains(sb.String(), expected) {
+			t.Errorf("Expected %q in %s", expected, sb.String())
+		}
+	}
+}
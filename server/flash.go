@@ -0,0 +1,63 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// banFlashLifetime is how long the results of a ban wait for the redirected detail page
+const banFlashLifetime = time.Minute
+
+// banFlashes keeps the results of the ban form until the detail page shows them after the redirect,
+// only the id is sent in the query as the results of many addresses don't fit into a URL or cookie
+type banFlashes struct {
+	mutex   sync.Mutex
+	entries map[string]banFlash
+}
+
+type banFlash struct {
+	jailName string
+	results  []banResult
+	expires  time.Time
+}
+
+func newBanFlashes() *banFlashes {
+	return &banFlashes{entries: make(map[string]banFlash)}
+}
+
+// add keeps the results and returns their id, expired results are dropped
+func (flashes *banFlashes) add(jailName string, results []banResult, now time.Time) string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	id := hex.EncodeToString(b)
+
+	flashes.mutex.Lock()
+	defer flashes.mutex.Unlock()
+	for key, flash := range flashes.entries {
+		if now.After(flash.expires) {
+			delete(flashes.entries, key)
+		}
+	}
+	flashes.entries[id] = banFlash{jailName: jailName, results: results, expires: now.Add(banFlashLifetime)}
+	return id
+}
+
+// take returns the results only once and only for the jail they were created for
+func (flashes *banFlashes) take(id string, jailName string, now time.Time) []banResult {
+	if id == "" {
+		return nil
+	}
+	flashes.mutex.Lock()
+	defer flashes.mutex.Unlock()
+	flash, exists := flashes.entries[id]
+	if !exists || flash.jailName != jailName {
+		return nil
+	}
+	delete(flashes.entries, id)
+	if now.After(flash.expires) {
+		return nil
+	}
+	return flash.results
+}
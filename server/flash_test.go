@@ -0,0 +1,38 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBanFlashes(t *testing.T) {
+	now := time.Now()
+	results := []banResult{{Address: "192.168.1.1"}, {Address: "foo", Error: "not a valid IP address or CIDR range"}}
+
+	tests := []struct {
+		name     string
+		id       func(flashes *banFlashes) string
+		jailName string
+		now      time.Time
+		expected int
+	}{
+		{"results of the jail", func(flashes *banFlashes) string { return flashes.add("sshd", results, now) }, "sshd", now, 2},
+		{"results of another jail", func(flashes *banFlashes) string { return flashes.add("postfix", results, now) }, "sshd", now, 0},
+		{"expired results", func(flashes *banFlashes) string { return flashes.add("sshd", results, now) }, "sshd", now.Add(2 * banFlashLifetime), 0},
+		{"unknown id", func(flashes *banFlashes) string { return "unknown" }, "sshd", now, 0},
+		{"without id", func(flashes *banFlashes) string { return "" }, "sshd", now, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flashes := newBanFlashes()
+			id := tt.id(flashes)
+			if taken := flashes.take(id, tt.jailName, tt.now); len(taken) != tt.expected {
+				t.Errorf("Expected %d results, got %+v", tt.expected, taken)
+			}
+			if taken := flashes.take(id, tt.jailName, tt.now); len(taken) != 0 {
+				t.Errorf("Expected the results to be shown only once, got %+v", taken)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/log"
+	"github.com/webishdev/fail2ban-dashboard/auth"
+	"github.com/webishdev/fail2ban-dashboard/geoip"
+	"github.com/webishdev/fail2ban-dashboard/store"
+)
+
+const (
+	healthOK          = "ok"
+	healthUnavailable = "unavailable"
+	// staleRefreshes is how many scheduled refreshes may fail until the data is not ready anymore
+	staleRefreshes = 3
+)
+
+// healthResponse is the state reported by /healthz and /readyz
+type healthResponse struct {
+	Status            string     `json:"status"`
+	Reasons           []string   `json:"reasons,omitempty"`
+	Version           string     `json:"version"`
+	Fail2BanVersion   string     `json:"fail2banVersion"`
+	Connected         bool       `json:"connected"`
+	ConnectionChanged *time.Time `json:"connectionChanged,omitempty"`
+	LastError         string     `json:"lastError,omitempty"`
+	Warning           string     `json:"warning,omitempty"`
+	LastRefresh       *time.Time `json:"lastRefresh,omitempty"`
+	GeoIPUpdated      *time.Time `json:"geoipUpdated,omitempty"`
+	GeoIPStale        bool       `json:"geoipStale"`
+}
+
+// registerHealthEndpoints adds the endpoints for orchestrators, they are registered before the authentication
+// and are only reachable from the allowed ranges. /healthz fails while the socket is not connected, /readyz
+// also fails until the data was fetched, when it is not refreshed anymore and for an unsupported fail2ban
+func registerHealthEndpoints(router fiber.Router, dataStore *store.DataStore, geoIP *geoip.GeoIP, configuration *Configuration) {
+	allowed := func(c fiber.Ctx) error {
+		remoteAddress := c.RequestCtx().RemoteIP().String()
+		if !auth.PrefixesContain(configuration.HealthAllowed, remoteAddress) {
+			log.Debugf("Rejected health request from %s", remoteAddress)
+			return c.Status(fiber.StatusForbidden).JSON(apiError{Error: "health endpoints are not available from this address"})
+		}
+		return c.Next()
+	}
+
+	router.Get("/healthz", allowed, func(c fiber.Ctx) error {
+		response := newHealthResponse(dataStore, geoIP, configuration)
+		return sendHealth(c, response, response.connectionReasons())
+	})
+
+	router.Get("/readyz", allowed, func(c fiber.Ctx) error {
+		response := newHealthResponse(dataStore, geoIP, configuration)
+		reasons := response.connectionReasons()
+		if response.Warning != "" {
+			reasons = append(reasons, response.Warning)
+		}
+		interval := dataStore.RefreshInterval()
+		switch {
+		case response.LastRefresh == nil:
+			reasons = append(reasons, "no data fetched from fail2ban yet")
+		case interval > 0 && time.Since(*response.LastRefresh) > staleRefreshes*interval:
+			reasons = append(reasons, fmt.Sprintf("data from fail2ban was not refreshed since %s", formatTime(*response.LastRefresh)))
+		}
+		return sendHealth(c, response, reasons)
+	})
+}
+
+func newHealthResponse(dataStore *store.DataStore, geoIP *geoip.GeoIP, configuration *Configuration) healthResponse {
+	state := dataStore.ConnectionState()
+	response := healthResponse{
+		Version:         configuration.Version,
+		Fail2BanVersion: dataStore.Fail2BanVersion(),
+		Connected:       state.Connected,
+		LastError:       state.LastError,
+		Warning:         state.Warning,
+		GeoIPStale:      geoIP.Stale(),
+	}
+	if !state.Since.IsZero() {
+		response.ConnectionChanged = &state.Since
+	}
+	if lastRefresh := dataStore.LastRefresh(); !lastRefresh.IsZero() {
+		response.LastRefresh = &lastRefresh
+	}
+	if updated := geoIP.Updated(); !updated.IsZero() {
+		response.GeoIPUpdated = &updated
+	}
+	return response
+}
+
+func (response healthResponse) connectionReasons() []string {
+	if response.Connected {
+		return nil
+	}
+	return []string{"not connected to the fail2ban socket"}
+}
+
+// sendHealth answers with 503 when there are reasons, GeoIP data is reported but not required
+func sendHealth(c fiber.Ctx, response healthResponse, reasons []string) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	if len(reasons) > 0 {
+		response.Status = healthUnavailable
+		response.Reasons = reasons
+		return c.Status(fiber.StatusServiceUnavailable).JSON(response)
+	}
+	response.Status = healthOK
+	return c.JSON(response)
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+	"github.com/webishdev/fail2ban-dashboard/fail2bantest"
+	"github.com/webishdev/fail2ban-dashboard/geoip"
+	"github.com/webishdev/fail2ban-dashboard/store"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		allowed        []netip.Prefix
+		expectedCode   int
+		expectedReason string
+	}{
+		{"healthz not allowed", "/healthz", []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}, fiber.StatusForbidden, ""},
+		{"readyz without allowed ranges", "/readyz", nil, fiber.StatusForbidden, ""},
+		{"healthz without connection", "/healthz", []netip.Prefix{netip.MustParsePrefix("0.0.0.0/32")}, fiber.StatusServiceUnavailable, "not connected to the fail2ban socket"},
+		{"readyz without data", "/readyz", []netip.Prefix{netip.MustParsePrefix("0.0.0.0/32")}, fiber.StatusServiceUnavailable, "no data fetched from fail2ban yet"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New(fiber.Config{})
+			configuration := &Configuration{
+				Version:       "1.0.0",
+				BasePath:      "/",
+				AuthUser:      "admin",
+				AuthPassword:  "secret",
+				HealthAllowed: tt.allowed,
+			}
+			err := RegisterDashboardEndpoints(app, newDisconnectedDataStore(t), &geoip.GeoIP{}, configuration)
+			if err != nil {
+				t.Fatalf("Failed to register endpoints: %v", err)
+			}
+
+			// no credentials are sent, the health endpoints don't need authentication
+			resp, err := app.Test(httptest.NewRequest("GET", tt.path, nil))
+			if err != nil {
+				t.Fatalf("Failed to make request: %v", err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+
+			if resp.StatusCode != tt.expectedCode {
+				t.Fatalf("Expected status code %d, got %d", tt.expectedCode, resp.StatusCode)
+			}
+			if tt.expectedReason == "" {
+				return
+			}
+
+			var health healthResponse
+			if err = json.NewDecoder(resp.Body).Decode(&health); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if health.Status != healthUnavailable || health.Connected || health.Fail2BanVersion != "unknown" || !health.GeoIPStale {
+				t.Errorf("Unexpected health response %+v", health)
+			}
+			found := false
+			for _, reason := range health.Reasons {
+				found = found || reason == tt.expectedReason
+			}
+			if !found {
+				t.Errorf("Expected reason %q, got %v", tt.expectedReason, health.Reasons)
+			}
+			if cacheControl := resp.Header.Get(fiber.HeaderCacheControl); cacheControl != "no-store" {
+				t.Errorf("Expected no-store, got %q", cacheControl)
+			}
+		})
+	}
+}
+
+func TestReadyUnsupportedVersion(t *testing.T) {
+	fail2ban := fail2bantest.Start(t, "0.10.6")
+	fail2ban.AddJail(fail2bantest.Jail{Name: "sshd", BanTime: 600})
+	f2bc, err := client.NewFail2BanClient(fail2ban.Address())
+	if err != nil {
+		t.Fatalf("NewFail2BanClient() error = %v", err)
+	}
+	t.Cleanup(func() { _ = f2bc.Close() })
+	f2bc.SetWarning("fail2ban version 0.10.6 is not supported")
+	dataStore := store.NewDataStore(f2bc, 30)
+	if err = dataStore.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	app := fiber.New(fiber.Config{})
+	configuration := &Configuration{BasePath: "/", HealthAllowed: []netip.Prefix{netip.MustParsePrefix("0.0.0.0/32")}}
+	if err = RegisterDashboardEndpoints(app, dataStore, &geoip.GeoIP{}, configuration); err != nil {
+		t.Fatalf("Failed to register endpoints: %v", err)
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/readyz", nil))
+	if err != nil {
+		t.Fatalf("Failed to make request: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var health healthResponse
+	if err = json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusServiceUnavailable || health.Warning == "" || len(health.Reasons) != 1 || health.Reasons[0] != health.Warning {
+		t.Errorf("Expected the unsupported version as reason, got %d %+v", resp.StatusCode, health)
+	}
+}
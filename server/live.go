@@ -0,0 +1,205 @@
+package server
+
+import (
+	"html/template"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/log"
+	"github.com/gofiber/fiber/v3/middleware/csrf"
+	"github.com/gofiber/fiber/v3/middleware/sse"
+	"github.com/webishdev/fail2ban-dashboard/auth"
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+	"github.com/webishdev/fail2ban-dashboard/geoip"
+	"github.com/webishdev/fail2ban-dashboard/store"
+)
+
+// liveChange is what changed between two updates of the data store
+type liveChange struct {
+	Connection client.ConnectionState
+	Refresh    store.RefreshStatus
+	Jails      []store.Jail
+	Added      []client.BanEntry
+	Removed    []client.BanEntry
+}
+
+type liveJails struct {
+	Connection connectionData `json:"connection"`
+	Refresh    refreshData    `json:"refresh"`
+	BannedSum  int            `json:"bannedSum"`
+	Jails      []store.Jail   `json:"jails"`
+}
+
+type liveBan struct {
+	ID          string `json:"id"`
+	Jail        string `json:"jail"`
+	CountryCode string `json:"countryCode,omitempty"`
+	HTML        string `json:"html,omitempty"`
+}
+
+// liveBroker distributes the changes of the data store to connected browsers
+type liveBroker struct {
+	mutex       sync.Mutex
+	subscribers map[chan liveChange]struct{}
+}
+
+type liveDataStore interface {
+	Subscribe(handler store.EventHandler)
+	GetJails() []store.Jail
+	ConnectionState() client.ConnectionState
+	RefreshStatus() store.RefreshStatus
+}
+
+func newLiveBroker(dataStore liveDataStore) *liveBroker {
+	broker := &liveBroker{
+		subscribers: make(map[chan liveChange]struct{}),
+	}
+	dataStore.Subscribe(func(update store.Update) {
+		broker.publish(newLiveChange(update, dataStore.GetJails(), dataStore.ConnectionState(), dataStore.RefreshStatus()))
+	})
+	return broker
+}
+
+// newLiveChange turns the events into rows to add and remove, an extended ban replaces its row
+func newLiveChange(update store.Update, jails []store.Jail, connection client.ConnectionState, refresh store.RefreshStatus) liveChange {
+	change := liveChange{
+		Connection: connection,
+		Refresh:    refresh,
+		Jails:      make([]store.Jail, len(jails)),
+	}
+
+	for index, jail := range jails {
+		jail.BannedEntries = nil
+		change.Jails[index] = jail
+	}
+
+	for _, event := range update.Events {
+		switch event.Type {
+		case store.BanAdded:
+			change.Added = append(change.Added, *event.Ban)
+		case store.BanRemoved:
+			change.Removed = append(change.Removed, *event.Ban)
+		case store.BanExtended:
+			change.Removed = append(change.Removed, *event.Previous)
+			change.Added = append(change.Added, *event.Ban)
+		}
+	}
+
+	return change
+}
+
+func (broker *liveBroker) publish(change liveChange) {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+	for subscriber := range broker.subscribers {
+		select {
+		case subscriber <- change:
+		default:
+			log.Debug("Live update subscriber is too slow, skipping update")
+		}
+	}
+}
+
+func (broker *liveBroker) subscribe() chan liveChange {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+	subscriber := make(chan liveChange, 8)
+	broker.subscribers[subscriber] = struct{}{}
+	return subscriber
+}
+
+func (broker *liveBroker) unsubscribe(subscriber chan liveChange) {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+	delete(broker.subscribers, subscriber)
+}
+
+func liveBanID(ban client.BanEntry) string {
+	return ban.JailName + "|" + ban.Address
+}
+
+// liveViewer is a single browser, it only receives the jails its user may access
+type liveViewer struct {
+	jailName  string
+	csrfToken string
+	user      *auth.User
+	canChange bool
+}
+
+// liveHandler streams the changes to a single browser, rows are rendered with the csrf token of that browser
+func liveHandler(broker *liveBroker, rowTemplate *template.Template, geoIP *geoip.GeoIP, basePath string) fiber.Handler {
+	return sse.New(sse.Config{
+		Handler: func(c fiber.Ctx, stream *sse.Stream) error {
+			viewer := liveViewer{
+				jailName:  c.Query("jail"),
+				csrfToken: csrf.TokenFromContext(c),
+				user:      currentUser(c),
+				canChange: mayChange(c),
+			}
+
+			subscriber := broker.subscribe()
+			defer broker.unsubscribe(subscriber)
+
+			for {
+				select {
+				case <-stream.Done():
+					return nil
+				case change := <-subscriber:
+					err := sendLiveChange(stream, change, viewer, rowTemplate, geoIP, basePath)
+					if err != nil {
+						return err
+					}
+				}
+			}
+		},
+	})
+}
+
+func sendLiveChange(stream *sse.Stream, change liveChange, viewer liveViewer, rowTemplate *template.Template, geoIP *geoip.GeoIP, basePath string) error {
+	jails := visibleJails(viewer.user, change.Jails)
+	sum := 0
+	for _, jail := range jails {
+		sum += jail.BannedCount
+	}
+	err := stream.Event(sse.Event{Name: "jails", Data: liveJails{
+		Connection: newConnectionData(change.Connection),
+		Refresh:    newRefreshData(change.Connection, visibleRefresh(viewer.user, change.Refresh)),
+		BannedSum:  sum,
+		Jails:      jails,
+	}})
+	if err != nil {
+		return err
+	}
+
+	for _, ban := range visibleBans(viewer.user, change.Removed) {
+		if viewer.jailName != "" && ban.JailName != viewer.jailName {
+			continue
+		}
+		err = stream.Event(sse.Event{Name: "ban-removed", Data: liveBan{ID: liveBanID(ban), Jail: ban.JailName}})
+		if err != nil {
+			return err
+		}
+	}
+
+	added := visibleBans(viewer.user, filterBans(change.Added, viewer.jailName, ""))
+	lookupCountryCodes(geoIP, added)
+	for _, entry := range toBannedEntries(added, basePath, viewer.csrfToken, viewer.jailName == "", viewer.canChange) {
+		var sb strings.Builder
+		err = rowTemplate.ExecuteTemplate(&sb, "banned", entry)
+		if err != nil {
+			return err
+		}
+		err = stream.Event(sse.Event{Name: "ban-added", Data: liveBan{
+			ID:          liveBanID(entry.BanEntry),
+			Jail:        entry.JailName,
+			CountryCode: entry.CountryCode,
+			HTML:        sb.String(),
+		}})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
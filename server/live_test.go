@@ -0,0 +1,117 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+	"github.com/webishdev/fail2ban-dashboard/store"
+)
+
+type mockLiveDataStore struct {
+	jails      []store.Jail
+	connection client.ConnectionState
+	refresh    store.RefreshStatus
+	handler    store.EventHandler
+}
+
+func (m *mockLiveDataStore) Subscribe(handler store.EventHandler) {
+	m.handler = handler
+}
+
+func (m *mockLiveDataStore) GetJails() []store.Jail {
+	return m.jails
+}
+
+func (m *mockLiveDataStore) ConnectionState() client.ConnectionState {
+	return m.connection
+}
+
+func (m *mockLiveDataStore) RefreshStatus() store.RefreshStatus {
+	return m.refresh
+}
+
+func receiveChange(t *testing.T, subscriber chan liveChange) liveChange {
+	t.Helper()
+	select {
+	case change := <-subscriber:
+		return change
+	case <-time.After(time.Second):
+		t.Fatal("Expected a change to be published")
+	}
+	return liveChange{}
+}
+
+func TestLiveBroker(t *testing.T) {
+	now := time.Now()
+	first := createTestBanEntry("192.168.1.1", "sshd", "600", now, now.Add(time.Hour))
+
+	mock := &mockLiveDataStore{
+		jails:      []store.Jail{createTestJail("sshd", []client.BanEntry{first})},
+		connection: client.ConnectionState{Connected: true, Since: now},
+	}
+	broker := newLiveBroker(mock)
+	if mock.handler == nil {
+		t.Fatal("Expected broker to subscribe to the data store")
+	}
+
+	subscriber := broker.subscribe()
+
+	t.Run("publishes counters and connection", func(t *testing.T) {
+		mock.handler(store.Update{Time: now, Events: []store.Event{{Type: store.BanAdded, JailName: "sshd", Ban: &first}}})
+		change := receiveChange(t, subscriber)
+		if len(change.Added) != 1 || change.Added[0].Address != "192.168.1.1" {
+			t.Errorf("Expected ban to be added, got %+v", change.Added)
+		}
+		if len(change.Jails) != 1 || change.Jails[0].BannedEntries != nil {
+			t.Errorf("Expected jail counters without bans, got %+v", change.Jails)
+		}
+		if !change.Connection.Connected {
+			t.Errorf("Expected connection state to be published, got %+v", change.Connection)
+		}
+		if len(mock.jails[0].BannedEntries) != 1 {
+			t.Error("Expected data store jails not to be modified")
+		}
+	})
+
+	t.Run("unsubscribed", func(t *testing.T) {
+		broker.unsubscribe(subscriber)
+		mock.handler(store.Update{Time: now})
+		select {
+		case change := <-subscriber:
+			t.Errorf("Expected no change after unsubscribe, got %+v", change)
+		default:
+		}
+	})
+}
+
+func TestNewLiveChange(t *testing.T) {
+	now := time.Now()
+	first := createTestBanEntry("192.168.1.1", "sshd", "600", now, now.Add(time.Hour))
+	second := createTestBanEntry("192.168.1.2", "sshd", "600", now, now.Add(time.Hour))
+	extended := createTestBanEntry("192.168.1.2", "sshd", "1200", now, now.Add(2*time.Hour))
+
+	update := store.Update{
+		Time: now,
+		Events: []store.Event{
+			{Type: store.JailAppeared, JailName: "sshd"},
+			{Type: store.BanRemoved, JailName: "sshd", Ban: &first},
+			{Type: store.BanExtended, JailName: "sshd", Ban: &extended, Previous: &second},
+		},
+	}
+
+	change := newLiveChange(update, nil, client.ConnectionState{}, store.RefreshStatus{})
+	if len(change.Removed) != 2 || change.Removed[0].Address != "192.168.1.1" || change.Removed[1].CurrenPenalty != "600" {
+		t.Errorf("Expected removed ban and previous row of extended ban, got %+v", change.Removed)
+	}
+	if len(change.Added) != 1 || change.Added[0].CurrenPenalty != "1200" {
+		t.Errorf("Expected extended ban to be added again, got %+v", change.Added)
+	}
+}
+
+func TestLiveBanID(t *testing.T) {
+	ban := createTestBanEntry("2001:db8::1", "sshd", "600", time.Now(), time.Now())
+	if id := liveBanID(ban); id != "sshd|2001:db8::1" {
+		t.Errorf("Expected id sshd|2001:db8::1, got %s", id)
+	}
+}
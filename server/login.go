@@ -0,0 +1,155 @@
+package server
+
+import (
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/log"
+	"github.com/webishdev/fail2ban-dashboard/auth"
+	"github.com/webishdev/fail2ban-dashboard/store"
+)
+
+// sessionKey marks requests authenticated with a session cookie, only these users can log out
+type sessionKey struct{}
+
+// loginData is the login page, Error is shown after a failed login
+type loginData struct {
+	baseData
+	Error string
+}
+
+// registerLoginEndpoints adds the OpenID Connect login, these pages are reachable without session
+func registerLoginEndpoints(router fiber.Router, provider *auth.OIDC, dataStore *store.DataStore, loginTemplate *template.Template, configuration *Configuration, basePath string) {
+	renderLogin := func(c fiber.Ctx, status int, loginError string) error {
+		data := &loginData{
+			baseData: baseData{
+				Version:         configuration.Version,
+				Fail2BanVersion: dataStore.Fail2BanVersion(),
+				Connection:      newConnectionData(dataStore.ConnectionState()),
+				BasePath:        basePath,
+				Static:          true,
+			},
+			Error: loginError,
+		}
+
+		var sb strings.Builder
+		err := loginTemplate.Execute(&sb, data)
+		if err != nil {
+			return err
+		}
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTML)
+		return c.Status(status).SendString(sb.String())
+	}
+
+	router.Get("/login", func(c fiber.Ctx) error {
+		accessLog(configuration.TrustProxyHeaders, "login", c)
+		return renderLogin(c, fiber.StatusOK, "")
+	})
+
+	router.Get("/login/oidc", func(c fiber.Ctx) error {
+		accessLog(configuration.TrustProxyHeaders, "login redirect", c)
+
+		authURL, loginCookie, expires, err := provider.Start(provider.RedirectURL(c.BaseURL() + basePath + "login/callback"))
+		if err != nil {
+			return err
+		}
+		setAuthCookie(c, auth.LoginCookieName, loginCookie, expires, basePath, secureCookie(c, provider))
+		return c.Redirect().Status(fiber.StatusSeeOther).To(authURL)
+	})
+
+	router.Get("/login/callback", func(c fiber.Ctx) error {
+		accessLog(configuration.TrustProxyHeaders, "login callback", c)
+
+		loginCookie := c.Cookies(auth.LoginCookieName)
+		clearAuthCookie(c, auth.LoginCookieName, basePath)
+
+		if providerError := c.Query("error"); providerError != "" {
+			log.Warnf("Login refused by the identity provider: %s %s", providerError, c.Query("error_description"))
+			return renderLogin(c, fiber.StatusUnauthorized, "The identity provider refused the login")
+		}
+
+		user, session, expires, err := provider.Finish(c.Context(), loginCookie, c.Query("state"), c.Query("code"))
+		if err != nil {
+			log.Warnf("Login from %s failed: %s", c.IP(), err)
+			return renderLogin(c, fiber.StatusUnauthorized, "The login failed, please try again")
+		}
+
+		log.Infof("User %s logged in as %s from %s", user.Name, user.Role, c.IP())
+		setAuthCookie(c, auth.SessionCookieName, session, expires, basePath, secureCookie(c, provider))
+		return c.Redirect().Status(fiber.StatusSeeOther).To(basePath)
+	})
+}
+
+// sessionAuthenticator requires a valid session cookie, pages redirect to the login and the API answers with 401
+func sessionAuthenticator(provider *auth.OIDC, basePath string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if tokenAuthenticated(c) {
+			return c.Next()
+		}
+
+		user, ok := provider.Session(c.Cookies(auth.SessionCookieName))
+		if ok {
+			c.Locals(userKey{}, &user)
+			c.Locals(sessionKey{}, true)
+			return c.Next()
+		}
+
+		if strings.HasPrefix(c.Path(), basePath+"api/") {
+			return c.Status(fiber.StatusUnauthorized).JSON(apiError{Error: "login required"})
+		}
+		if c.Method() != fiber.MethodGet {
+			return c.Status(fiber.StatusUnauthorized).SendString("Login required")
+		}
+		return c.Redirect().Status(fiber.StatusSeeOther).To(basePath + "login")
+	}
+}
+
+// logoutHandler removes the session cookie, the session of the identity provider is kept
+func logoutHandler(configuration *Configuration, basePath string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		accessLog(configuration.TrustProxyHeaders, "logout", c)
+		if user := currentUser(c); user != nil {
+			log.Infof("User %s logged out", user.Name)
+		}
+		clearAuthCookie(c, auth.SessionCookieName, basePath)
+		return c.Redirect().Status(fiber.StatusSeeOther).To(basePath + "login")
+	}
+}
+
+// sessionUser is the name shown next to the logout button
+func sessionUser(c fiber.Ctx) string {
+	if session, _ := c.Locals(sessionKey{}).(bool); !session {
+		return ""
+	}
+	return currentUser(c).Name
+}
+
+// secureCookie is true for HTTPS, behind a proxy the scheme of the configured redirect URL tells
+func secureCookie(c fiber.Ctx, provider *auth.OIDC) bool {
+	return c.Protocol() == "https" || strings.HasPrefix(provider.RedirectURL(""), "https://")
+}
+
+// setAuthCookie is sent back on the redirect of the identity provider, which is a top level navigation allowed by Lax
+func setAuthCookie(c fiber.Ctx, name string, value string, expires time.Time, basePath string, secure bool) {
+	c.Cookie(&fiber.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     basePath,
+		Expires:  expires,
+		HTTPOnly: true,
+		Secure:   secure,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
+
+func clearAuthCookie(c fiber.Ctx, name string, basePath string) {
+	c.Cookie(&fiber.Cookie{
+		Name:     name,
+		Path:     basePath,
+		Expires:  time.Unix(0, 0),
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
@@ -0,0 +1,391 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/webishdev/fail2ban-dashboard/auth"
+	"github.com/webishdev/fail2ban-dashboard/geoip"
+)
+
+// oidcStandIn is a minimal OpenID Connect provider, it logs in the configured user without asking
+type oidcStandIn struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	clientID string
+	username string
+	groups   []string
+
+	mutex sync.Mutex
+	codes map[string]authorization
+}
+
+// authorization is remembered between the authorize and the token request
+type authorization struct {
+	nonce     string
+	challenge string
+}
+
+func newOIDCStandIn(t *testing.T, username string, groups ...string) *oidcStandIn {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	standIn := &oidcStandIn{key: key, clientID: "dashboard", username: username, groups: groups, codes: make(map[string]authorization)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", standIn.discovery)
+	mux.HandleFunc("/keys", standIn.keys)
+	mux.HandleFunc("/authorize", standIn.authorize)
+	mux.HandleFunc("/token", standIn.token)
+	standIn.server = httptest.NewServer(mux)
+	t.Cleanup(standIn.server.Close)
+	return standIn
+}
+
+func (s *oidcStandIn) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, map[string]any{
+		"issuer":                                s.server.URL,
+		"authorization_endpoint":                s.server.URL + "/authorize",
+		"token_endpoint":                        s.server.URL + "/token",
+		"jwks_uri":                              s.server.URL + "/keys",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (s *oidcStandIn) keys(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": "stand-in",
+			"n":   base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (s *oidcStandIn) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != s.clientID || query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	code := base64.RawURLEncoding.EncodeToString([]byte(query.Get("state")))
+	s.mutex.Lock()
+	s.codes[code] = authorization{nonce: query.Get("nonce"), challenge: query.Get("code_challenge")}
+	s.mutex.Unlock()
+
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect", http.StatusBadRequest)
+		return
+	}
+	values := redirect.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	redirect.RawQuery = values.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *oidcStandIn) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	s.mutex.Lock()
+	granted, ok := s.codes[r.PostForm.Get("code")]
+	delete(s.codes, r.PostForm.Get("code"))
+	s.mutex.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(verifier[:]) != granted.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken, err := s.sign(map[string]any{
+		"iss":                s.server.URL,
+		"sub":                "subject-" + s.username,
+		"aud":                s.clientID,
+		"iat":                time.Now().Unix(),
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"nonce":              granted.nonce,
+		"preferred_username": s.username,
+		"groups":             s.groups,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]any{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func (s *oidcStandIn) sign(claims map[string]any) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": "stand-in", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+// login runs the authorization code flow against the stand-in and returns the session cookie
+func login(t *testing.T, app *fiber.App, basePath string) *http.Cookie {
+	resp, err := app.Test(httptest.NewRequest("GET", basePath+"login/oidc", nil))
+	if err != nil {
+		t.Fatalf("Failed to start login: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != fiber.StatusSeeOther {
+		t.Fatalf("Expected redirect to the provider, got %d", resp.StatusCode)
+	}
+	loginCookie := findCookie(resp.Cookies(), auth.LoginCookieName)
+	if loginCookie == nil || loginCookie.Path != basePath || !loginCookie.HttpOnly {
+		t.Fatalf("Expected login cookie for %s, got %+v", basePath, loginCookie)
+	}
+
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	providerResp, err := noRedirect.Get(resp.Header.Get(fiber.HeaderLocation))
+	if err != nil {
+		t.Fatalf("Failed to authorize: %v", err)
+	}
+	_ = providerResp.Body.Close()
+	callback, err := url.Parse(providerResp.Header.Get(fiber.HeaderLocation))
+	if err != nil || callback.Path != basePath+"login/callback" {
+		t.Fatalf("Expected redirect to the callback, got %q", providerResp.Header.Get(fiber.HeaderLocation))
+	}
+
+	req := httptest.NewRequest("GET", callback.RequestURI(), nil)
+	req.AddCookie(loginCookie)
+	resp, err = app.Test(req, fiber.TestConfig{Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("Failed to finish login: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != fiber.StatusSeeOther || resp.Header.Get(fiber.HeaderLocation) != basePath {
+		t.Fatalf("Expected redirect to %s, got %d %q", basePath, resp.StatusCode, resp.Header.Get(fiber.HeaderLocation))
+	}
+	sessionCookie := findCookie(resp.Cookies(), auth.SessionCookieName)
+	if sessionCookie == nil || sessionCookie.Value == "" {
+		t.Fatal("Expected session cookie")
+	}
+	return sessionCookie
+}
+
+func findCookie(cookies []*http.Cookie, name string) *http.Cookie {
+	for _, cookie := range cookies {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func newOIDCApp(t *testing.T, standIn *oidcStandIn, basePath string) *fiber.App {
+	provider, err := auth.NewOIDC(context.Background(), auth.OIDCConfiguration{
+		Issuer:        standIn.server.URL,
+		ClientID:      standIn.clientID,
+		ClientSecret:  "client-secret",
+		Roles:         auth.GroupRoles{AdminGroups: []string{"admins"}, ViewerGroups: []string{"admins", "ops"}},
+		SessionSecret: "session-secret",
+	})
+	if err != nil {
+		t.Fatalf("Failed to set up OpenID Connect: %v", err)
+	}
+
+	app := fiber.New(fiber.Config{})
+	err = RegisterDashboardEndpoints(app, newDisconnectedDataStore(t), &geoip.GeoIP{}, &Configuration{BasePath: basePath, OIDC: provider})
+	if err != nil {
+		t.Fatalf("Failed to register endpoints: %v", err)
+	}
+	return app
+}
+
+func TestOIDCLogin(t *testing.T) {
+	tests := []struct {
+		name         string
+		groups       []string
+		method       string
+		path         string
+		expectedCode int
+	}{
+		{name: "viewer reads", groups: []string{"ops"}, method: "GET", path: "api/v1/jails", expectedCode: fiber.StatusOK},
+		{name: "viewer can't change", groups: []string{"ops"}, method: "DELETE", path: "api/v1/jails/sshd/ignoreip/192.168.1.1", expectedCode: fiber.StatusForbidden},
+		{name: "admin changes", groups: []string{"dev", "admins"}, method: "DELETE", path: "api/v1/jails/sshd/ignoreip/192.168.1.1", expectedCode: fiber.StatusNotFound},
+	}
+
+	for _, basePath := range []string{"/", "/dashboard/"} {
+		for _, tt := range tests {
+			t.Run(basePath+" "+tt.name, func(t *testing.T) {
+				standIn := newOIDCStandIn(t, "alice", tt.groups...)
+				app := newOIDCApp(t, standIn, basePath)
+				sessionCookie := login(t, app, basePath)
+
+				req := httptest.NewRequest(tt.method, basePath+tt.path, nil)
+				req.AddCookie(sessionCookie)
+				resp, err := app.Test(req)
+				if err != nil {
+					t.Fatalf("Failed to make request: %v", err)
+				}
+				defer func() { _ = resp.Body.Close() }()
+
+				if resp.StatusCode != tt.expectedCode {
+					t.Errorf("Expected status %d, got %d", tt.expectedCode, resp.StatusCode)
+				}
+			})
+		}
+	}
+}
+
+func TestOIDCLoginRefused(t *testing.T) {
+	standIn := newOIDCStandIn(t, "mallory", "dev")
+	app := newOIDCApp(t, standIn, "/")
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/login/oidc", nil))
+	if err != nil {
+		t.Fatalf("Failed to start login: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	providerResp, err := noRedirect.Get(resp.Header.Get(fiber.HeaderLocation))
+	if err != nil {
+		t.Fatalf("Failed to authorize: %v", err)
+	}
+	_ = providerResp.Body.Close()
+	callback, err := url.Parse(providerResp.Header.Get(fiber.HeaderLocation))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", callback.RequestURI(), nil)
+	req.AddCookie(findCookie(resp.Cookies(), auth.LoginCookieName))
+	resp, err = app.Test(req, fiber.TestConfig{Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("Failed to finish login: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != fiber.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d", fiber.StatusUnauthorized, resp.StatusCode)
+	}
+	if findCookie(resp.Cookies(), auth.SessionCookieName) != nil {
+		t.Error("Expected no session cookie for a user without group")
+	}
+}
+
+func TestOIDCSessionRequired(t *testing.T) {
+	standIn := newOIDCStandIn(t, "alice", "admins")
+
+	tests := []struct {
+		name             string
+		method           string
+		path             string
+		cookie           *http.Cookie
+		expectedCode     int
+		expectedLocation string
+	}{
+		{name: "login page", method: "GET", path: "/dashboard/login", expectedCode: fiber.StatusOK},
+		{name: "static assets", method: "GET", path: "/dashboard/css/main.css", expectedCode: fiber.StatusOK},
+		{name: "page redirects", method: "GET", path: "/dashboard/", expectedCode: fiber.StatusSeeOther, expectedLocation: "/dashboard/login"},
+		{name: "api", method: "GET", path: "/dashboard/api/v1/jails", expectedCode: fiber.StatusUnauthorized},
+		{name: "invalid session", method: "GET", path: "/dashboard/api/v1/jails", cookie: &http.Cookie{Name: auth.SessionCookieName, Value: "forged"}, expectedCode: fiber.StatusUnauthorized},
+		{name: "post", method: "POST", path: "/dashboard/control", expectedCode: fiber.StatusUnauthorized},
+	}
+
+	app := newOIDCApp(t, standIn, "/dashboard/")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to make request: %v", err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+
+			if resp.StatusCode != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d", tt.expectedCode, resp.StatusCode)
+			}
+			if location := resp.Header.Get(fiber.HeaderLocation); location != tt.expectedLocation {
+				t.Errorf("Expected location %q, got %q", tt.expectedLocation, location)
+			}
+		})
+	}
+}
+
+func TestOIDCLogout(t *testing.T) {
+	standIn := newOIDCStandIn(t, "alice", "admins")
+	app := newOIDCApp(t, standIn, "/")
+	sessionCookie := login(t, app, "/")
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(sessionCookie)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to make request: %v", err)
+	}
+	_ = resp.Body.Close()
+	csrfCookie := findCookie(resp.Cookies(), "csrf_")
+	if resp.StatusCode != fiber.StatusOK || csrfCookie == nil {
+		t.Fatalf("Expected dashboard with CSRF cookie, got %d", resp.StatusCode)
+	}
+
+	req = httptest.NewRequest("POST", "/logout", strings.NewReader("_csrf="+url.QueryEscape(csrfCookie.Value)))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationForm)
+	req.AddCookie(sessionCookie)
+	req.AddCookie(csrfCookie)
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to log out: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != fiber.StatusSeeOther || resp.Header.Get(fiber.HeaderLocation) != "/login" {
+		t.Errorf("Expected redirect to the login, got %d %q", resp.StatusCode, resp.Header.Get(fiber.HeaderLocation))
+	}
+	cleared := findCookie(resp.Cookies(), auth.SessionCookieName)
+	if cleared == nil || cleared.Value != "" {
+		t.Errorf("Expected cleared session cookie, got %+v", cleared)
+	}
+}
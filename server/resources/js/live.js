@@ -0,0 +1,84 @@
+// Live updates of the dashboard using server-sent events.
+// Counters and banned rows are updated in place, when jails appear or disappear the page is reloaded.
+(() => {
+    const script = document.currentScript;
+    if (!script || !('EventSource' in window)) {
+        return;
+    }
+
+    const jail = script.dataset.jail;
+    const url = script.dataset.events + (jail ? '?jail=' + encodeURIComponent(jail) : '');
+
+    const loadedFlags = new Set();
+    document.querySelectorAll('link[href*="flags.css?c="]').forEach((link) => {
+        const codes = new URL(link.href).searchParams.get('c') || '';
+        codes.split(',').forEach((code) => loadedFlags.add(code));
+    });
+
+    const loadFlag = (countryCode) => {
+        if (!countryCode || countryCode === 'unknown' || loadedFlags.has(countryCode)) {
+            return;
+        }
+        loadedFlags.add(countryCode);
+        const link = document.createElement('link');
+        link.rel = 'stylesheet';
+        link.type = 'text/css';
+        link.href = script.dataset.flags + '?c=' + encodeURIComponent(countryCode);
+        document.head.appendChild(link);
+    };
+
+    const setCounter = (parent, name, value) => {
+        parent.querySelectorAll(`[data-counter="${name}"]`).forEach((element) => {
+            element.textContent = value;
+        });
+    };
+
+    const events = new EventSource(url);
+
+    events.addEventListener('jails', (event) => {
+        const data = JSON.parse(event.data);
+        const names = data.jails.map((entry) => entry.name);
+        if (jail ? !names.includes(jail) : document.querySelectorAll('[data-jail]').length !== names.length) {
+            window.location.reload();
+            return;
+        }
+        document.querySelectorAll('[data-connection]').forEach((element) => {
+            element.textContent = data.connection.label;
+            element.classList.toggle('badge-success', data.connection.connected);
+            element.classList.toggle('hidden', data.connection.connected);
+            element.classList.toggle('sm:inline', data.connection.connected);
+            element.classList.toggle('badge-error', !data.connection.connected);
+        });
+        document.querySelectorAll('[data-refresh]').forEach((element) => {
+            element.classList.toggle('hidden', !data.refresh.stale);
+            element.querySelectorAll('[data-refresh-message]').forEach((message) => {
+                message.textContent = data.refresh.message;
+            });
+        });
+        setCounter(document, 'bannedSum', data.bannedSum);
+        data.jails.forEach((entry) => {
+            document.querySelectorAll(`[data-jail="${CSS.escape(entry.name)}"]`).forEach((element) => {
+                Object.keys(entry).forEach((key) => setCounter(element, key, entry[key]));
+            });
+        });
+    });
+
+    events.addEventListener('ban-removed', (event) => {
+        const data = JSON.parse(event.data);
+        document.querySelectorAll(`tr[data-ban="${CSS.escape(data.id)}"]`).forEach((row) => row.remove());
+    });
+
+    events.addEventListener('ban-added', (event) => {
+        const data = JSON.parse(event.data);
+        if (document.querySelector(`tr[data-ban="${CSS.escape(data.id)}"]`)) {
+            return;
+        }
+        const table = document.querySelector('tbody[data-banned]');
+        if (!table) {
+            window.location.reload();
+            return;
+        }
+        loadFlag(data.countryCode);
+        table.insertAdjacentHTML('beforeend', data.html);
+    });
+})();
@@ -2,22 +2,29 @@ package server
 
 import (
 	"crypto/rand"
+	"crypto/tls"
 	_ "embed"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"html/template"
 	"net"
+	"net/netip"
+	"net/url"
 	"path"
 	"sort"
 	"strconv"
 	"strings"
 	textTemplate "text/template"
 	"time"
+	"unicode"
 
 	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/extractors"
 	"github.com/gofiber/fiber/v3/log"
 	"github.com/gofiber/fiber/v3/middleware/basicauth"
+	"github.com/gofiber/fiber/v3/middleware/csrf"
+	"github.com/webishdev/fail2ban-dashboard/auth"
 	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
 	"github.com/webishdev/fail2ban-dashboard/geoip"
 	"github.com/webishdev/fail2ban-dashboard/store"
@@ -35,6 +42,9 @@ var mainCSSFile []byte
 //go:embed resources/js/browser@4.js
 var tailwindJSFile []byte
 
+//go:embed resources/js/live.js
+var liveJSFile []byte
+
 //go:embed resources/images/favicon.ico
 var faviconICOFile []byte
 
@@ -47,15 +57,36 @@ var indexHtml []byte
 //go:embed resources/detail.html
 var detailHtml []byte
 
+//go:embed resources/address.html
+var addressHtml []byte
+
+//go:embed resources/control.html
+var controlHtml []byte
+
+//go:embed resources/login.html
+var loginHtml []byte
+
 //go:embed resources/partial_jailcard.html
 var jailCardHtml []byte
 
 //go:embed resources/partial_jaildetail.html
 var jailDetailHtml []byte
 
+//go:embed resources/partial_jailconfig.html
+var jailConfigHtml []byte
+
+//go:embed resources/partial_ignorelist.html
+var ignoreListHtml []byte
+
 //go:embed resources/partial_banned.html
 var bannedHtml []byte
 
+//go:embed resources/partial_filter.html
+var filterHtml []byte
+
+//go:embed resources/partial_pagination.html
+var paginationHtml []byte
+
 //go:embed resources/partial_head.html
 var headHtml []byte
 
@@ -69,39 +100,100 @@ type Configuration struct {
 	Address           string
 	AuthUser          string
 	AuthPassword      string
+	Users             *auth.Users
+	ProxyAuth         *auth.ProxyAuth
+	OIDC              *auth.OIDC
+	Tokens            *auth.TokenStore
 	BasePath          string
 	TrustProxyHeaders bool
-	Fail2BanVersion   string
 	Version           string
+	PageSize          int
+	TLSConfig         *tls.Config
+	HealthAllowed     []netip.Prefix
 }
 
+const (
+	csrfFormField   = "_csrf"
+	maxBanAddresses = 100
+	maxAuditRows    = 10
+)
+
 type Sorted struct {
 	Order string
 	Class string
 }
 
+// bannedEntry is a single row of the banned table, it carries what the row needs to render its actions
+type bannedEntry struct {
+	client.BanEntry
+	BasePath  string
+	CSRFToken string
+	ShowJail  bool
+	Return    string
+	CanChange bool
+}
+
+// connectionData is the fail2ban socket state shown in the header
+type connectionData struct {
+	Connected bool   `json:"connected"`
+	Label     string `json:"label"`
+}
+
+// refreshData is the warning shown below the header when the data is outdated
+type refreshData struct {
+	Stale   bool   `json:"stale"`
+	Message string `json:"message"`
+}
+
 type baseData struct {
 	Version         string
 	Fail2BanVersion string
+	Connection      connectionData
+	Refresh         refreshData
 	BasePath        string
+	LiveJail        string
+	Static          bool
+	Admin           bool
+	CanReload       bool
+	CanChange       bool
+	SessionUser     string
+	CSRFToken       string
 	CountryCodes    template.URL
 	HasBanned       bool
-	Banned          []client.BanEntry
+	Banned          []bannedEntry
+}
+
+type sortingData struct {
+	OrderAddress Sorted
+	OrderJail    Sorted
+	OrderPenalty Sorted
+	OrderStarted Sorted
+	OrderEnds    Sorted
 }
 
 type indexData struct {
 	baseData
+	sortingData
+	filterData
 	BannedSum int
 	Jails     []store.Jail
 }
 
 type detailData struct {
 	baseData
-	OrderAddress Sorted
-	OrderPenalty Sorted
-	OrderStarted Sorted
-	OrderEnds    Sorted
-	Jail         store.Jail
+	sortingData
+	filterData
+	Jail        store.Jail
+	Config      *client.JailConfig
+	ConfigError string
+	BanResults  []banResult
+	Audit       []store.AuditEntry
+}
+
+// banResult is the outcome of banning a single address from the detail page
+type banResult struct {
+	Address string
+	Error   string
 }
 
 func generateRandomPassword() string {
@@ -122,12 +214,16 @@ func RegisterDashboardEndpoints(app *fiber.App, dataStore *store.DataStore, geoI
 		"time": func(t time.Time) string {
 			return formatTime(t)
 		},
+		"pathEscape": func(s string) string {
+			return url.PathEscape(s)
+		},
 		"formatPenalty": func(p string) string {
 			if p == "-1" {
 				return "permanent"
 			}
 			return p
 		},
+		"formatSeconds": formatSeconds,
 	}
 
 	indexTemplate, indexTemplateError := template.New("index").Funcs(templateFunctions).Parse(string(indexHtml))
@@ -140,6 +236,21 @@ func RegisterDashboardEndpoints(app *fiber.App, dataStore *store.DataStore, geoI
 		return detailTemplateError
 	}
 
+	addressTemplate, addressTemplateError := template.New("address").Funcs(templateFunctions).Parse(string(addressHtml))
+	if addressTemplateError != nil {
+		return addressTemplateError
+	}
+
+	controlTemplate, controlTemplateError := template.New("control").Funcs(templateFunctions).Parse(string(controlHtml))
+	if controlTemplateError != nil {
+		return controlTemplateError
+	}
+
+	loginTemplate, loginTemplateError := template.New("login").Funcs(templateFunctions).Parse(string(loginHtml))
+	if loginTemplateError != nil {
+		return loginTemplateError
+	}
+
 	flagsTemplate, flagsTemplateError := textTemplate.New("flags").Parse(string(flagsCss))
 	if flagsTemplateError != nil {
 		return flagsTemplateError
@@ -157,6 +268,18 @@ func RegisterDashboardEndpoints(app *fiber.App, dataStore *store.DataStore, geoI
 		return indexBannedTemplateError
 	}
 
+	// value isn't needed in code as it is used in the index template
+	_, indexFilterTemplateError := indexTemplate.New("filter").Parse(string(filterHtml))
+	if indexFilterTemplateError != nil {
+		return indexFilterTemplateError
+	}
+
+	// value isn't needed in code as it is used in the index template
+	_, indexPaginationTemplateError := indexTemplate.New("pagination").Parse(string(paginationHtml))
+	if indexPaginationTemplateError != nil {
+		return indexPaginationTemplateError
+	}
+
 	// value isn't needed in code as it is used in the index template
 	_, indexHeadTemplateError := indexTemplate.New("head").Parse(string(headHtml))
 	if indexHeadTemplateError != nil {
@@ -193,28 +316,111 @@ func RegisterDashboardEndpoints(app *fiber.App, dataStore *store.DataStore, geoI
 		return detailJailDetailTemplateError
 	}
 
+	// value isn't needed in code as it is used in the detail template
+	_, detailJailConfigTemplateError := detailTemplate.New("jailConfig").Parse(string(jailConfigHtml))
+	if detailJailConfigTemplateError != nil {
+		return detailJailConfigTemplateError
+	}
+
+	// value isn't needed in code as it is used in the detail template
+	_, detailIgnoreListTemplateError := detailTemplate.New("ignoreList").Parse(string(ignoreListHtml))
+	if detailIgnoreListTemplateError != nil {
+		return detailIgnoreListTemplateError
+	}
+
 	// value isn't needed in code as it is used in the index template
 	_, detailBannedTemplateError := detailTemplate.New("banned").Parse(string(bannedHtml))
 	if detailBannedTemplateError != nil {
 		return detailBannedTemplateError
 	}
 
-	if configuration.AuthUser != "" || configuration.AuthPassword != "" {
-		log.Info("Basic authentication enabled")
-		if configuration.AuthUser == "" {
-			configuration.AuthUser = "admin"
+	// value isn't needed in code as it is used in the detail template
+	_, detailFilterTemplateError := detailTemplate.New("filter").Parse(string(filterHtml))
+	if detailFilterTemplateError != nil {
+		return detailFilterTemplateError
+	}
+
+	// value isn't needed in code as it is used in the detail template
+	_, detailPaginationTemplateError := detailTemplate.New("pagination").Parse(string(paginationHtml))
+	if detailPaginationTemplateError != nil {
+		return detailPaginationTemplateError
+	}
+
+	// value isn't needed in code as it is used in the address template
+	_, addressHeadTemplateError := addressTemplate.New("head").Parse(string(headHtml))
+	if addressHeadTemplateError != nil {
+		return addressHeadTemplateError
+	}
+
+	// value isn't needed in code as it is used in the address template
+	_, addressHeaderTemplateError := addressTemplate.New("header").Parse(string(headerHtml))
+	if addressHeaderTemplateError != nil {
+		return addressHeaderTemplateError
+	}
+
+	// value isn't needed in code as it is used in the address template
+	_, addressBannedTemplateError := addressTemplate.New("banned").Parse(string(bannedHtml))
+	if addressBannedTemplateError != nil {
+		return addressBannedTemplateError
+	}
+
+	// value isn't needed in code as it is used in the control template
+	_, controlHeadTemplateError := controlTemplate.New("head").Parse(string(headHtml))
+	if controlHeadTemplateError != nil {
+		return controlHeadTemplateError
+	}
+
+	// value isn't needed in code as it is used in the control template
+	_, controlHeaderTemplateError := controlTemplate.New("header").Parse(string(headerHtml))
+	if controlHeaderTemplateError != nil {
+		return controlHeaderTemplateError
+	}
+
+	// value isn't needed in code as it is used in the login template
+	_, loginHeadTemplateError := loginTemplate.New("head").Parse(string(headHtml))
+	if loginHeadTemplateError != nil {
+		return loginHeadTemplateError
+	}
+
+	// value isn't needed in code as it is used in the login template
+	_, loginHeaderTemplateError := loginTemplate.New("header").Parse(string(headerHtml))
+	if loginHeaderTemplateError != nil {
+		return loginHeaderTemplateError
+	}
+
+	// health endpoints are reachable without authentication
+	registerHealthEndpoints(app.Group(path.Clean(configuration.BasePath)), dataStore, geoIP, configuration)
+
+	// tokens are checked first, requests with a token skip the other authentication
+	if configuration.Tokens != nil {
+		app.Use(tokenAuthenticator(configuration.Tokens, cleanBasePathForTemplate(path.Clean(configuration.BasePath))))
+	}
+
+	switch {
+	case configuration.ProxyAuth != nil:
+		if configuration.Users != nil || configuration.AuthUser != "" || configuration.AuthPassword != "" {
+			log.Warn("Header authentication is enabled, users of basic authentication are ignored")
 		}
-		log.Infof("Basic authentication username set to %s", configuration.AuthUser)
-		if configuration.AuthPassword == "" {
-			configuration.AuthPassword = generateRandomPassword()
-			log.Infof("Basic authentication password set to %s", configuration.AuthPassword)
+		log.Infof("Header authentication enabled with header %s from %d trusted proxies", configuration.ProxyAuth.UserHeader, len(configuration.ProxyAuth.TrustedProxies))
+		app.Use(proxyAuthenticator(configuration.ProxyAuth))
+	case configuration.OIDC != nil:
+		// the session is checked below, the login page and its assets are reachable without session
+		if configuration.Users != nil || configuration.AuthUser != "" || configuration.AuthPassword != "" {
+			log.Warn("OpenID Connect login is enabled, users of basic authentication are ignored")
+		}
+		log.Info("OpenID Connect login enabled")
+	default:
+		users, usersErr := basicAuthUsers(configuration)
+		if usersErr != nil {
+			return usersErr
+		}
+		if users != nil && users.Len() > 0 {
+			log.Infof("Basic authentication enabled for %d users", users.Len())
+			app.Use(basicauth.New(basicauth.Config{
+				Next:       tokenAuthenticated,
+				Authorizer: authorizer(users),
+			}))
 		}
-
-		app.Use(basicauth.New(basicauth.Config{
-			Authorizer: func(user, password string, c fiber.Ctx) bool {
-				return user == configuration.AuthUser && password == configuration.AuthPassword
-			},
-		}))
 	}
 
 	cleanedBasePath := path.Clean(configuration.BasePath)
@@ -272,42 +478,82 @@ func RegisterDashboardEndpoints(app *fiber.App, dataStore *store.DataStore, geoI
 		return c.Send(tailwindJSFile)
 	})
 
+	dashboard.Get("js/live.js", func(c fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJavaScript)
+		return c.Send(liveJSFile)
+	})
+
+	if configuration.OIDC != nil {
+		registerLoginEndpoints(dashboard, configuration.OIDC, dataStore, loginTemplate, configuration, cleanBasePathForTemplate(cleanedBasePath))
+		dashboard.Use(sessionAuthenticator(configuration.OIDC, cleanBasePathForTemplate(cleanedBasePath)))
+	}
+
+	registerAPIEndpoints(dashboard, dataStore, geoIP, configuration)
+	registerExportEndpoints(dashboard, dataStore, geoIP, configuration)
+
+	// pages and actions below are protected against cross site request forgery
+	dashboard.Use(csrf.New(csrf.Config{
+		CookiePath:     cleanBasePathForTemplate(cleanedBasePath),
+		CookieHTTPOnly: true,
+		Extractor:      extractors.FromForm(csrfFormField),
+	}))
+
+	broker := newLiveBroker(dataStore)
+	dataStore.Subscribe(warnReservedJails)
+	dashboard.Get("/events", liveHandler(broker, indexTemplate, geoIP, cleanBasePathForTemplate(cleanedBasePath)))
+
 	dashboard.Get("/", func(c fiber.Ctx) error {
 		accessLog(configuration.TrustProxyHeaders, "overview", c)
-		jails := dataStore.GetJails()
+		jails := visibleJails(currentUser(c), dataStore.GetJails())
 
 		sum := 0
 
 		banned := make([]client.BanEntry, 0)
-		for _, jail := range jails {
+		jailNames := make([]string, len(jails))
+		for index, jail := range jails {
 			sum += len(jail.BannedEntries)
 			banned = append(banned, jail.BannedEntries...)
+			jailNames[index] = jail.Name
 		}
 
-		countryCodes := make([]string, 0)
-
-		for index, ban := range banned {
-			countryCode, exists := geoIP.Lookup(ban.Address)
-			if exists {
-				ban.CountryCode = countryCode
-				countryCodes = append(countryCodes, countryCode)
-			} else {
-				ban.CountryCode = "unknown"
-			}
-			banned[index] = ban
+		filter, filterErr := parseBanFilter(c, time.Now())
+		if filterErr != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(filterErr.Error())
 		}
 
+		countryCodes := lookupCountryCodes(geoIP, banned)
+
+		sorting := c.Query("sorting", "ends")
+		order := c.Query("order", "asc")
+
+		sort.Slice(banned, sortSlice(sorting, order, banned))
+
+		rows, filtering := newFilterData(c, banned, filter, sorting, order, configuration.PageSize)
+		filtering.JailNames = jailNames
+
+		basePath := cleanBasePathForTemplate(cleanedBasePath)
+		csrfToken := csrf.TokenFromContext(c)
+
 		data := &indexData{
 			baseData: baseData{
 				Version:         configuration.Version,
-				Fail2BanVersion: configuration.Fail2BanVersion,
-				BasePath:        cleanBasePathForTemplate(cleanedBasePath),
+				Fail2BanVersion: dataStore.Fail2BanVersion(),
+				Connection:      newConnectionData(dataStore.ConnectionState()),
+				Refresh:         newRefreshData(dataStore.ConnectionState(), visibleRefresh(currentUser(c), dataStore.RefreshStatus())),
+				BasePath:        basePath,
+				Admin:           mayControl(c),
+				CanReload:       mayControl(c) && dataStore.ReloadAvailable(),
+				CanChange:       mayChange(c),
+				SessionUser:     sessionUser(c),
+				CSRFToken:       csrfToken,
 				CountryCodes:    template.URL("flags.css?c=" + strings.Join(countryCodes, ",")),
 				HasBanned:       len(banned) > 0,
-				Banned:          banned,
+				Banned:          toBannedEntries(rows, basePath, csrfToken, true, mayChange(c)),
 			},
-			BannedSum: sum,
-			Jails:     jails,
+			sortingData: newSortingData(sorting, order),
+			filterData:  filtering,
+			BannedSum:   sum,
+			Jails:       jails,
 		}
 
 		var sb strings.Builder
@@ -319,52 +565,110 @@ func RegisterDashboardEndpoints(app *fiber.App, dataStore *store.DataStore, geoI
 		return c.SendString(sb.String())
 	})
 
-	dashboard.Get("/:jail", func(c fiber.Ctx) error {
-		jailName := c.Params("jail")
-		name := fmt.Sprintf("%s details", jailName)
+	dashboard.Get("/ip/:address", func(c fiber.Ctx) error {
+		value, unescapeErr := url.PathUnescape(c.Params("address"))
+		name := fmt.Sprintf("%s address", value)
 		accessLog(configuration.TrustProxyHeaders, name, c)
 
-		jailByName, exists := dataStore.GetJailByName(jailName)
+		address, valid := normalizeAddress(value)
+		if unescapeErr != nil || !valid {
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid address")
+		}
 
-		if !exists {
-			return c.Status(404).SendString("Jail not found")
+		detail := newAddressDetail(dataStore, geoIP, address, currentUser(c))
+
+		basePath := cleanBasePathForTemplate(cleanedBasePath)
+		csrfToken := csrf.TokenFromContext(c)
+
+		banned := toBannedEntries(detail.Bans, basePath, csrfToken, true, mayChange(c))
+		for index := range banned {
+			banned[index].Return = "address"
+		}
+
+		data := &addressData{
+			baseData: baseData{
+				Version:         configuration.Version,
+				Fail2BanVersion: dataStore.Fail2BanVersion(),
+				Connection:      newConnectionData(dataStore.ConnectionState()),
+				Refresh:         newRefreshData(dataStore.ConnectionState(), visibleRefresh(currentUser(c), dataStore.RefreshStatus())),
+				BasePath:        basePath,
+				Static:          true,
+				CanChange:       mayChange(c),
+				SessionUser:     sessionUser(c),
+				CSRFToken:       csrfToken,
+				CountryCodes:    template.URL("flags.css?c=" + detail.CountryCode),
+				HasBanned:       len(banned) > 0,
+				Banned:          banned,
+			},
+			Detail: detail,
+		}
+
+		var sb strings.Builder
+		err := addressTemplate.Execute(&sb, data)
+		if err != nil {
+			return err
 		}
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTML)
+		return c.SendString(sb.String())
+	})
+
+	flashes := newBanFlashes()
 
+	renderDetail := func(c fiber.Ctx, jailByName store.Jail, banResults []banResult) error {
 		banned := make([]client.BanEntry, 0)
 		banned = append(banned, jailByName.BannedEntries...)
 
-		countryCodes := make([]string, 0)
-
-		for index, ban := range banned {
-			countryCode, exists := geoIP.Lookup(ban.Address)
-			if exists {
-				ban.CountryCode = countryCode
-				countryCodes = append(countryCodes, countryCode)
-			} else {
-				ban.CountryCode = "unknown"
-			}
-			banned[index] = ban
+		filter, filterErr := parseBanFilter(c, time.Now())
+		if filterErr != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(filterErr.Error())
 		}
+		// the detail page only shows a single jail
+		filter.JailName = ""
+
+		countryCodes := lookupCountryCodes(geoIP, banned)
 
 		sorting := c.Query("sorting", "ends")
 		order := c.Query("order", "asc")
 
 		sort.Slice(banned, sortSlice(sorting, order, banned))
 
+		rows, filtering := newFilterData(c, banned, filter, sorting, order, configuration.PageSize)
+
+		// the configuration is shown even when it is incomplete, the page must not fail because of it
+		jailConfig, configErr := dataStore.GetJailConfig(jailByName.Name)
+		configError := ""
+		if configErr != nil {
+			log.Errorf("Could not read configuration of %s: %s", jailByName.Name, configErr)
+			configError = configErr.Error()
+		}
+
+		basePath := cleanBasePathForTemplate(cleanedBasePath)
+		csrfToken := csrf.TokenFromContext(c)
+
 		detail := &detailData{
 			baseData: baseData{
 				Version:         configuration.Version,
-				Fail2BanVersion: configuration.Fail2BanVersion,
-				BasePath:        cleanBasePathForTemplate(cleanedBasePath),
+				Fail2BanVersion: dataStore.Fail2BanVersion(),
+				Connection:      newConnectionData(dataStore.ConnectionState()),
+				Refresh:         newRefreshData(dataStore.ConnectionState(), visibleRefresh(currentUser(c), dataStore.RefreshStatus())),
+				BasePath:        basePath,
+				LiveJail:        jailByName.Name,
+				Admin:           mayControl(c),
+				CanReload:       mayControl(c) && dataStore.ReloadAvailable(),
+				CanChange:       mayChange(c),
+				SessionUser:     sessionUser(c),
+				CSRFToken:       csrfToken,
 				CountryCodes:    template.URL("flags.css?c=" + strings.Join(countryCodes, ",")),
 				HasBanned:       len(banned) > 0,
-				Banned:          banned,
+				Banned:          toBannedEntries(rows, basePath, csrfToken, false, mayChange(c)),
 			},
-			OrderAddress: toggleSortOrder("address", sorting, order),
-			OrderPenalty: toggleSortOrder("penalty", sorting, order),
-			OrderStarted: toggleSortOrder("started", sorting, order),
-			OrderEnds:    toggleSortOrder("ends", sorting, order),
-			Jail:         jailByName,
+			sortingData: newSortingData(sorting, order),
+			filterData:  filtering,
+			Jail:        jailByName,
+			Config:      jailConfig,
+			ConfigError: configError,
+			BanResults:  banResults,
+			Audit:       recentAuditEntries(dataStore, jailByName.Name),
 		}
 
 		var sb strings.Builder
@@ -374,6 +678,169 @@ func RegisterDashboardEndpoints(app *fiber.App, dataStore *store.DataStore, geoI
 		}
 		c.Set(fiber.HeaderContentType, fiber.MIMETextHTML)
 		return c.SendString(sb.String())
+	}
+
+	dashboard.Get("/:jail", func(c fiber.Ctx) error {
+		jailName := c.Params("jail")
+		name := fmt.Sprintf("%s details", jailName)
+		accessLog(configuration.TrustProxyHeaders, name, c)
+
+		jailByName, exists := lookupJail(c, dataStore, jailName)
+
+		if !exists {
+			return c.Status(404).SendString("Jail not found")
+		}
+
+		return renderDetail(c, jailByName, flashes.take(c.Query("ban"), jailByName.Name, time.Now()))
+	})
+
+	dashboard.Post("/logout", logoutHandler(configuration, cleanBasePathForTemplate(cleanedBasePath)))
+
+	dashboard.Post("/control", adminOnly, controlHandler(dataStore, controlTemplate, configuration, cleanBasePathForTemplate(cleanedBasePath)))
+
+	dashboard.Post("/:jail/ban", adminOnly, func(c fiber.Ctx) error {
+		jailName := c.Params("jail")
+		name := fmt.Sprintf("%s ban", jailName)
+		accessLog(configuration.TrustProxyHeaders, name, c)
+
+		if _, exists := lookupJail(c, dataStore, jailName); !exists {
+			return c.Status(fiber.StatusNotFound).SendString("Jail not found")
+		}
+
+		values := splitAddresses(c.FormValue("addresses"))
+		if len(values) > maxBanAddresses {
+			return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("At most %d addresses can be banned at once", maxBanAddresses))
+		}
+
+		banResults := make([]banResult, len(values))
+		addresses := make([]string, 0, len(values))
+		for index, value := range values {
+			banResults[index].Address = value
+			address, valid := normalizeAddress(value)
+			if !valid {
+				banResults[index].Error = "not a valid IP address or CIDR range"
+				continue
+			}
+			banResults[index].Address = address
+			addresses = append(addresses, address)
+		}
+
+		if len(addresses) > 0 {
+			banErrs := dataStore.Ban(jailName, addresses...)
+			banIndex := 0
+			for index := range banResults {
+				if banResults[index].Error != "" {
+					continue
+				}
+				if banErrs[banIndex] != nil {
+					banResults[index].Error = banErrs[banIndex].Error()
+					log.Errorf("Could not ban %s in %s: %s", banResults[index].Address, jailName, banErrs[banIndex])
+				} else {
+					log.Infof("Banned %s in %s", banResults[index].Address, jailName)
+				}
+				banIndex++
+			}
+		}
+
+		// the results are shown by the detail page, reloading it does not send the form again
+		target := cleanBasePathForTemplate(cleanedBasePath) + url.PathEscape(jailName)
+		if len(banResults) > 0 {
+			target += "?ban=" + flashes.add(jailName, banResults, time.Now())
+		}
+		return c.Redirect().Status(fiber.StatusSeeOther).To(target)
+	})
+
+	dashboard.Post("/:jail/unban", adminOnly, func(c fiber.Ctx) error {
+		jailName := c.Params("jail")
+		address := c.FormValue("address")
+		name := fmt.Sprintf("%s unban %s", jailName, address)
+		accessLog(configuration.TrustProxyHeaders, name, c)
+
+		if _, exists := lookupJail(c, dataStore, jailName); !exists {
+			return c.Status(fiber.StatusNotFound).SendString("Jail not found")
+		}
+
+		address, valid := normalizeAddress(address)
+		if !valid {
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid address")
+		}
+
+		unbanErr := dataStore.Unban(jailName, address)
+		if unbanErr != nil {
+			log.Errorf("Could not unban %s from %s: %s", address, jailName, unbanErr)
+			return c.Status(fiber.StatusBadGateway).SendString(fmt.Sprintf("Could not unban %s: %s", address, unbanErr))
+		}
+		log.Infof("Unbanned %s from %s", address, jailName)
+
+		return redirectAfterUnban(c, cleanBasePathForTemplate(cleanedBasePath), jailName, address)
+	})
+
+	dashboard.Post("/:jail/whitelist", adminOnly, func(c fiber.Ctx) error {
+		jailName := c.Params("jail")
+		address := c.FormValue("address")
+		name := fmt.Sprintf("%s unban and whitelist %s", jailName, address)
+		accessLog(configuration.TrustProxyHeaders, name, c)
+
+		if _, exists := lookupJail(c, dataStore, jailName); !exists {
+			return c.Status(fiber.StatusNotFound).SendString("Jail not found")
+		}
+
+		address, valid := normalizeAddress(address)
+		if !valid {
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid address")
+		}
+
+		whitelistErr := dataStore.UnbanAndIgnore(actorFromContext(c), jailName, address)
+		if whitelistErr != nil {
+			log.Errorf("Could not unban and whitelist %s in %s: %s", address, jailName, whitelistErr)
+			return c.Status(fiber.StatusBadGateway).SendString(fmt.Sprintf("Could not unban and whitelist %s: %s", address, whitelistErr))
+		}
+
+		return redirectAfterUnban(c, cleanBasePathForTemplate(cleanedBasePath), jailName, address)
+	})
+
+	dashboard.Post("/:jail/ignore", adminOnly, func(c fiber.Ctx) error {
+		jailName := c.Params("jail")
+		address := c.FormValue("address")
+		name := fmt.Sprintf("%s ignore %s", jailName, address)
+		accessLog(configuration.TrustProxyHeaders, name, c)
+
+		if _, exists := lookupJail(c, dataStore, jailName); !exists {
+			return c.Status(fiber.StatusNotFound).SendString("Jail not found")
+		}
+
+		address, valid := normalizeAddress(address)
+		if !valid {
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid address")
+		}
+
+		if _, ignoreErr := dataStore.AddIgnoreIP(actorFromContext(c), jailName, address); ignoreErr != nil {
+			return c.Status(fiber.StatusBadGateway).SendString(fmt.Sprintf("Could not add %s to the ignore list: %s", address, ignoreErr))
+		}
+
+		return c.Redirect().Status(fiber.StatusSeeOther).To(cleanBasePathForTemplate(cleanedBasePath) + url.PathEscape(jailName))
+	})
+
+	dashboard.Post("/:jail/unignore", adminOnly, func(c fiber.Ctx) error {
+		jailName := c.Params("jail")
+		address := c.FormValue("address")
+		name := fmt.Sprintf("%s unignore %s", jailName, address)
+		accessLog(configuration.TrustProxyHeaders, name, c)
+
+		if _, exists := lookupJail(c, dataStore, jailName); !exists {
+			return c.Status(fiber.StatusNotFound).SendString("Jail not found")
+		}
+
+		address, valid := ignoreEntry(address)
+		if !valid {
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid ignore list entry")
+		}
+
+		if _, ignoreErr := dataStore.DelIgnoreIP(actorFromContext(c), jailName, address); ignoreErr != nil {
+			return c.Status(fiber.StatusBadGateway).SendString(fmt.Sprintf("Could not remove %s from the ignore list: %s", address, ignoreErr))
+		}
+
+		return c.Redirect().Status(fiber.StatusSeeOther).To(cleanBasePathForTemplate(cleanedBasePath) + url.PathEscape(jailName))
 	})
 
 	dataStore.Start()
@@ -381,6 +848,127 @@ func RegisterDashboardEndpoints(app *fiber.App, dataStore *store.DataStore, geoI
 	return nil
 }
 
+// basicAuthUsers adds the user of --auth-user to the configured users, it is an admin of all jails
+func basicAuthUsers(configuration *Configuration) (*auth.Users, error) {
+	users := configuration.Users
+	if configuration.AuthUser == "" && configuration.AuthPassword == "" {
+		return users, nil
+	}
+
+	if configuration.AuthUser == "" {
+		configuration.AuthUser = "admin"
+	}
+	log.Infof("Basic authentication username set to %s", configuration.AuthUser)
+	if configuration.AuthPassword == "" {
+		configuration.AuthPassword = generateRandomPassword()
+		log.Infof("Basic authentication password set to %s", configuration.AuthPassword)
+	}
+
+	if users == nil {
+		users, _ = auth.NewUsers(nil)
+	}
+	authUserErr := users.AddPassword(configuration.AuthUser, configuration.AuthPassword)
+	if authUserErr != nil {
+		return nil, authUserErr
+	}
+	return users, nil
+}
+
+// recentAuditEntries are the latest changes of the jail shown on the detail page
+func recentAuditEntries(dataStore *store.DataStore, jailName string) []store.AuditEntry {
+	trail := dataStore.Audit()
+	if trail == nil {
+		return nil
+	}
+	entries := trail.Entries(jailName)
+	return entries[:min(len(entries), maxAuditRows)]
+}
+
+// redirectAfterUnban returns to the page the unban was started from
+func redirectAfterUnban(c fiber.Ctx, basePath string, jailName string, address string) error {
+	switch c.FormValue("return") {
+	case "overview":
+		return c.Redirect().Status(fiber.StatusSeeOther).To(basePath)
+	case "address":
+		return c.Redirect().Status(fiber.StatusSeeOther).To(basePath + "ip/" + url.PathEscape(address))
+	}
+	return c.Redirect().Status(fiber.StatusSeeOther).To(basePath + url.PathEscape(jailName))
+}
+
+func lookupCountryCodes(geoIP *geoip.GeoIP, banned []client.BanEntry) []string {
+	countryCodes := make([]string, 0)
+
+	for index, ban := range banned {
+		countryCode, exists := geoIP.Lookup(ban.Address)
+		if exists {
+			ban.CountryCode = countryCode
+			countryCodes = append(countryCodes, countryCode)
+		} else {
+			ban.CountryCode = "unknown"
+		}
+		banned[index] = ban
+	}
+
+	return countryCodes
+}
+
+func toBannedEntries(banned []client.BanEntry, basePath string, csrfToken string, overview bool, canChange bool) []bannedEntry {
+	result := make([]bannedEntry, len(banned))
+	returnTo := "detail"
+	if overview {
+		returnTo = "overview"
+	}
+	for index, ban := range banned {
+		result[index] = bannedEntry{
+			BanEntry:  ban,
+			BasePath:  basePath,
+			CSRFToken: csrfToken,
+			ShowJail:  overview,
+			Return:    returnTo,
+			CanChange: canChange,
+		}
+	}
+	return result
+}
+
+func newSortingData(sorting string, order string) sortingData {
+	return sortingData{
+		OrderAddress: toggleSortOrder("address", sorting, order),
+		OrderJail:    toggleSortOrder("jail", sorting, order),
+		OrderPenalty: toggleSortOrder("penalty", sorting, order),
+		OrderStarted: toggleSortOrder("started", sorting, order),
+		OrderEnds:    toggleSortOrder("ends", sorting, order),
+	}
+}
+
+// normalizeAddress validates a single IP address or CIDR range and returns it in canonical form
+func normalizeAddress(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if ip := net.ParseIP(value); ip != nil {
+		return ip.String(), true
+	}
+	if prefix, err := netip.ParsePrefix(value); err == nil {
+		return prefix.Masked().String(), true
+	}
+	return "", false
+}
+
+// ignoreEntry checks an entry of an ignore list, entries are kept as configured as they may also be hostnames
+func ignoreEntry(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" || len(value) > 255 || strings.HasPrefix(value, "-") || strings.IndexFunc(value, unicode.IsSpace) >= 0 {
+		return "", false
+	}
+	return value, true
+}
+
+// splitAddresses splits user input separated by whitespace, commas or semicolons
+func splitAddresses(input string) []string {
+	return strings.FieldsFunc(input, func(r rune) bool {
+		return r == ',' || r == ';' || unicode.IsSpace(r)
+	})
+}
+
 func sortSlice(sorting string, order string, banned []client.BanEntry) func(i, j int) bool {
 	switch {
 	case sorting == "address" && order == "desc":
@@ -446,6 +1034,44 @@ func toggleSortOrder(current string, sorting string, order string) Sorted {
 	return Sorted{"asc", "arrows-up-down"}
 }
 
+func newConnectionData(state client.ConnectionState) connectionData {
+	switch {
+	case state.Connected:
+		return connectionData{Connected: true, Label: "connected"}
+	case state.Since.IsZero():
+		return connectionData{Label: "not connected"}
+	default:
+		return connectionData{Label: "reconnecting since " + formatTime(state.Since)}
+	}
+}
+
+// newRefreshData explains why the data may be outdated, a broken socket, an unsupported fail2ban or jails which could not be fetched
+func newRefreshData(state client.ConnectionState, status store.RefreshStatus) refreshData {
+	dataFrom := ""
+	if !status.LastSuccess.IsZero() {
+		dataFrom = ", the data is from " + formatTime(status.LastSuccess)
+	}
+	switch {
+	case !state.Connected && status.LastSuccess.IsZero():
+		return refreshData{Stale: true, Message: "Not connected to fail2ban, no data could be fetched yet"}
+	case !state.Connected:
+		return refreshData{Stale: true, Message: "Not connected to fail2ban" + dataFrom}
+	case state.Warning != "":
+		return refreshData{Stale: true, Message: "The connected " + state.Warning}
+	case status.LastError != "":
+		return refreshData{Stale: true, Message: fmt.Sprintf("Could not refresh the fail2ban data%s: %s", dataFrom, status.LastError)}
+	case len(status.FailedJails) > 0:
+		failed := make([]string, 0, len(status.FailedJails))
+		for jailName, jailErr := range status.FailedJails {
+			failed = append(failed, jailName+" ("+jailErr+")")
+		}
+		sort.Strings(failed)
+		return refreshData{Stale: true, Message: "Could not refresh the jails " + strings.Join(failed, ", ") + ", their data may be outdated"}
+	default:
+		return refreshData{}
+	}
+}
+
 func formatTime(t time.Time) string {
 	now := time.Now()
 
@@ -501,6 +1127,15 @@ func cleanBasePathForTemplate(basePath string) string {
 	return basePath
 }
 
+// actorFromContext is the authenticated user making a change, recorded in the audit trail
+func actorFromContext(c fiber.Ctx) store.Actor {
+	actor := store.Actor{RemoteAddress: c.IP()}
+	if user := currentUser(c); user != nil {
+		actor.User = user.Name
+	}
+	return actor
+}
+
 func accessLog(trustProxyHeaders bool, name string, c fiber.Ctx) {
 	remoteIP := c.IP()
 	additionalInfo := ""
@@ -518,3 +1153,30 @@ func accessLog(trustProxyHeaders bool, name string, c fiber.Ctx) {
 	}
 	log.Infof("Access %s at %s%s for %s %s", name, c.BaseURL(), c.OriginalURL(), remoteIP, additionalInfo)
 }
+
+// formatSeconds renders durations like 1d 2h or 10m, negative ban times are permanent
+func formatSeconds(seconds int) string {
+	if seconds < 0 {
+		return "permanent"
+	}
+	if seconds == 0 {
+		return "0s"
+	}
+	units := []struct {
+		suffix  string
+		seconds int
+	}{
+		{"d", 86400},
+		{"h", 3600},
+		{"m", 60},
+		{"s", 1},
+	}
+	parts := make([]string, 0, len(units))
+	for _, unit := range units {
+		if seconds >= unit.seconds {
+			parts = append(parts, fmt.Sprintf("%d%s", seconds/unit.seconds, unit.suffix))
+			seconds %= unit.seconds
+		}
+	}
+	return strings.Join(parts, " ")
+}
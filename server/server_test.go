@@ -1,13 +1,19 @@
 package server
 
 import (
+	"html/template"
 	"io"
+	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gofiber/fiber/v3"
 	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+	"github.com/webishdev/fail2ban-dashboard/geoip"
 	"github.com/webishdev/fail2ban-dashboard/store"
 )
 
@@ -279,6 +285,185 @@ func TestFormatTime(t *testing.T) {
 	}
 }
 
+func TestNewRefreshData(t *testing.T) {
+	lastSuccess := time.Now().Add(-time.Minute)
+	connected := client.ConnectionState{Connected: true}
+
+	tests := []struct {
+		name       string
+		connection client.ConnectionState
+		status     store.RefreshStatus
+		expected   refreshData
+	}{
+		{
+			name:       "fresh data",
+			connection: connected,
+			status:     store.RefreshStatus{LastSuccess: lastSuccess},
+			expected:   refreshData{},
+		},
+		{
+			name:     "never connected",
+			expected: refreshData{Stale: true, Message: "Not connected to fail2ban, no data could be fetched yet"},
+		},
+		{
+			name:     "connection lost",
+			status:   store.RefreshStatus{LastSuccess: lastSuccess},
+			expected: refreshData{Stale: true, Message: "Not connected to fail2ban, the data is from " + formatTime(lastSuccess)},
+		},
+		{
+			name:       "unsupported version",
+			connection: client.ConnectionState{Connected: true, Warning: "fail2ban version 0.10.6 is not supported"},
+			status:     store.RefreshStatus{LastSuccess: lastSuccess},
+			expected:   refreshData{Stale: true, Message: "The connected fail2ban version 0.10.6 is not supported"},
+		},
+		{
+			name:       "jails not listed",
+			connection: connected,
+			status:     store.RefreshStatus{LastSuccess: lastSuccess, LastError: "timeout"},
+			expected:   refreshData{Stale: true, Message: "Could not refresh the fail2ban data, the data is from " + formatTime(lastSuccess) + ": timeout"},
+		},
+		{
+			name:       "jails failed",
+			connection: connected,
+			status:     store.RefreshStatus{LastSuccess: lastSuccess, FailedJails: map[string]string{"sshd": "timeout", "nginx": "broken pipe"}},
+			expected:   refreshData{Stale: true, Message: "Could not refresh the jails nginx (broken pipe), sshd (timeout), their data may be outdated"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := newRefreshData(tt.connection, tt.status); result != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestFormatSeconds(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    int
+		expected string
+	}{
+		{"permanent", -1, "permanent"},
+		{"zero", 0, "0s"},
+		{"seconds", 45, "45s"},
+		{"minutes", 600, "10m"},
+		{"hours and minutes", 5400, "1h 30m"},
+		{"days", 604800, "7d"},
+		{"mixed", 90061, "1d 1h 1m 1s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := formatSeconds(tt.input)
+			if result != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestJailConfigTemplate(t *testing.T) {
+	tmpl := template.Must(template.New("jailConfig").Funcs(template.FuncMap{"formatSeconds": formatSeconds}).Parse(string(jailConfigHtml)))
+
+	tests := []struct {
+		name     string
+		data     detailData
+		expected []string
+	}{
+		{
+			name: "configuration",
+			data: detailData{Config: &client.JailConfig{
+				BanTime:          -1,
+				FindTime:         600,
+				MaxRetry:         5,
+				FailRegex:        []string{"^Failed password for .* from <HOST>"},
+				IgnoreIPs:        []string{"127.0.0.1/8"},
+				BanTimeIncrement: client.BanTimeIncrement{Enabled: true, Factor: "2"},
+			}},
+			expected: []string{"permanent", "10m", "^Failed password for .* from &lt;HOST&gt;", "127.0.0.1/8", "Factor: <span class=\"font-mono\">2</span>"},
+		},
+		{
+			name:     "error",
+			data:     detailData{ConfigError: "not connected to fail2ban"},
+			expected: []string{"The configuration could not be read from fail2ban: not connected to fail2ban"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			if err := tmpl.Execute(&sb, tt.data); err != nil {
+				t.Fatalf("Failed to execute jail config template: %v", err)
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(sb.String(), expected) {
+					t.Errorf("Expected %q in %s", expected, sb.String())
+				}
+			}
+		})
+	}
+}
+
+func TestIgnoreEntry(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+		valid    bool
+	}{
+		{"address", " 192.168.1.1 ", "192.168.1.1", true},
+		{"range kept as configured", "127.0.0.1/8", "127.0.0.1/8", true},
+		{"hostname", "monitoring.example.com", "monitoring.example.com", true},
+		{"empty", "", "", false},
+		{"option", "-all", "", false},
+		{"whitespace", "192.168.1.1 192.168.1.2", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, valid := ignoreEntry(tt.value)
+			if entry != tt.expected || valid != tt.valid {
+				t.Errorf("ignoreEntry(%q) = %q, %v, want %q, %v", tt.value, entry, valid, tt.expected, tt.valid)
+			}
+		})
+	}
+}
+
+func TestIgnoreListTemplate(t *testing.T) {
+	tmpl := template.Must(template.New("ignoreList").Funcs(template.FuncMap{"time": formatTime}).Parse(string(ignoreListHtml)))
+
+	data := detailData{
+		baseData: baseData{BasePath: "/", CSRFToken: "token", CanChange: true},
+		Jail:     store.Jail{Name: "sshd"},
+		Config:   &client.JailConfig{IgnoreIPs: []string{"127.0.0.1/8"}},
+		Audit: []store.AuditEntry{
+			{Time: time.Now(), User: "admin", Action: store.AuditAddIgnoreIP, JailName: "sshd", Address: "127.0.0.1/8"},
+		},
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		t.Fatalf("Failed to execute ignore list template: %v", err)
+	}
+	for _, expected := range []string{`action="/sshd/unignore"`, `name="address" value="127.0.0.1/8"`, `action="/sshd/ignore"`, "<td>admin</td>", "addignoreip"} {
+		if !strings.Contains(sb.String(), expected) {
+			t.Errorf("Expected %q in %s", expected, sb.String())
+		}
+	}
+
+	// viewers see the ignore list without the forms to change it
+	data.CanChange = false
+	sb.Reset()
+	if err := tmpl.Execute(&sb, data); err != nil {
+		t.Fatalf("Failed to execute ignore list template: %v", err)
+	}
+	if strings.Contains(sb.String(), "<form") || !strings.Contains(sb.String(), "127.0.0.1/8") {
+		t.Errorf("Expected the ignore list without forms for viewers, got %s", sb.String())
+	}
+}
+
 func TestIpToUint32(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -766,3 +951,216 @@ func TestSortSliceEdgeCases(t *testing.T) {
 		t.Error("Default sort should sort by end time ascending")
 	}
 }
+
+// Tests for actions
+
+func csrfCookie(t *testing.T, app *fiber.App) *http.Cookie {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.SetBasicAuth("admin", "secret")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to make request: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == "csrf_" {
+			return cookie
+		}
+	}
+	t.Fatal("Expected csrf cookie to be set")
+	return nil
+}
+
+func TestActionRouteHandlers(t *testing.T) {
+	app := fiber.New(fiber.Config{})
+	err := RegisterDashboardEndpoints(app, newDisconnectedDataStore(t), &geoip.GeoIP{}, &Configuration{BasePath: "/", AuthUser: "admin", AuthPassword: "secret"})
+	if err != nil {
+		t.Fatalf("Failed to register endpoints: %v", err)
+	}
+
+	cookie := csrfCookie(t, app)
+
+	tests := []struct {
+		name         string
+		path         string
+		form         url.Values
+		withCookie   bool
+		expectedCode int
+	}{
+		{
+			name:         "missing csrf token",
+			path:         "/sshd/unban",
+			form:         url.Values{"address": {"192.168.1.1"}},
+			withCookie:   true,
+			expectedCode: fiber.StatusForbidden,
+		},
+		{
+			name:         "missing csrf cookie",
+			path:         "/sshd/unban",
+			form:         url.Values{"address": {"192.168.1.1"}, "_csrf": {cookie.Value}},
+			withCookie:   false,
+			expectedCode: fiber.StatusForbidden,
+		},
+		{
+			name:         "valid csrf token but unknown jail",
+			path:         "/sshd/unban",
+			form:         url.Values{"address": {"192.168.1.1"}, "_csrf": {cookie.Value}},
+			withCookie:   true,
+			expectedCode: fiber.StatusNotFound,
+		},
+		{
+			name:         "ban missing csrf token",
+			path:         "/sshd/ban",
+			form:         url.Values{"addresses": {"192.168.1.1"}},
+			withCookie:   true,
+			expectedCode: fiber.StatusForbidden,
+		},
+		{
+			name:         "ban in unknown jail",
+			path:         "/sshd/ban",
+			form:         url.Values{"addresses": {"192.168.1.1"}, "_csrf": {cookie.Value}},
+			withCookie:   true,
+			expectedCode: fiber.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.SetBasicAuth("admin", "secret")
+			if tt.withCookie {
+				req.AddCookie(cookie)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to make request: %v", err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+
+			if resp.StatusCode != tt.expectedCode {
+				t.Errorf("Expected status code %d, got %d", tt.expectedCode, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestActionsWithoutAuthentication(t *testing.T) {
+	app := fiber.New(fiber.Config{})
+	err := RegisterDashboardEndpoints(app, newDisconnectedDataStore(t), &geoip.GeoIP{}, &Configuration{BasePath: "/"})
+	if err != nil {
+		t.Fatalf("Failed to register endpoints: %v", err)
+	}
+	cookie := csrfCookie(t, app)
+
+	for _, path := range []string{"/sshd/unban", "/sshd/ban"} {
+		form := url.Values{"address": {"192.168.1.1"}, "addresses": {"192.168.1.1"}, "_csrf": {cookie.Value}}
+		req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(cookie)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Failed to make request: %v", err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != fiber.StatusForbidden {
+			t.Errorf("POST %s without authentication returned status code %d, want %d", path, resp.StatusCode, fiber.StatusForbidden)
+		}
+	}
+}
+
+func TestNewConnectionData(t *testing.T) {
+	since := time.Now()
+	tests := []struct {
+		name     string
+		state    client.ConnectionState
+		expected connectionData
+	}{
+		{"connected", client.ConnectionState{Connected: true, Since: since}, connectionData{Connected: true, Label: "connected"}},
+		{"reconnecting", client.ConnectionState{Since: since}, connectionData{Label: "reconnecting since " + formatTime(since)}},
+		{"never connected", client.ConnectionState{}, connectionData{Label: "not connected"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := newConnectionData(tt.state); result != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestToBannedEntries(t *testing.T) {
+	now := time.Now()
+	banned := []client.BanEntry{
+		createTestBanEntry("192.168.1.1", "sshd", "600", now, now.Add(time.Hour)),
+	}
+
+	overview := toBannedEntries(banned, "/", "token", true, true)
+	if !overview[0].ShowJail || overview[0].Return != "overview" {
+		t.Errorf("Expected overview entry to show jail and return to overview, got %+v", overview[0])
+	}
+	if overview[0].CSRFToken != "token" || overview[0].Address != "192.168.1.1" || !overview[0].CanChange {
+		t.Errorf("Unexpected overview entry %+v", overview[0])
+	}
+
+	detail := toBannedEntries(banned, "/", "token", false, false)
+	if detail[0].ShowJail || detail[0].Return != "detail" || detail[0].CanChange {
+		t.Errorf("Expected detail entry to hide jail and return to detail, got %+v", detail[0])
+	}
+}
+
+func TestNormalizeAddress(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		valid    bool
+	}{
+		{"192.168.1.1", "192.168.1.1", true},
+		{" 10.0.0.1 ", "10.0.0.1", true},
+		{"2001:db8::1", "2001:db8::1", true},
+		{"10.0.0.0/24", "10.0.0.0/24", true},
+		{"10.0.0.17/24", "10.0.0.0/24", true},
+		{"2001:db8::/32", "2001:db8::/32", true},
+		{"", "", false},
+		{"not-an-ip", "", false},
+		{"10.0.0.0/33", "", false},
+		{"192.168.1.1; rm -rf /", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, valid := normalizeAddress(tt.input)
+			if valid != tt.valid || result != tt.expected {
+				t.Errorf("normalizeAddress(%q) = (%q, %v), expected (%q, %v)", tt.input, result, valid, tt.expected, tt.valid)
+			}
+		})
+	}
+}
+
+func TestSplitAddresses(t *testing.T) {
+	input := "192.168.1.1, 10.0.0.0/24\n2001:db8::1;\t 8.8.8.8"
+	expected := []string{"192.168.1.1", "10.0.0.0/24", "2001:db8::1", "8.8.8.8"}
+
+	result := splitAddresses(input)
+	if len(result) != len(expected) {
+		t.Fatalf("Expected %d addresses, got %d: %v", len(expected), len(result), result)
+	}
+	for index := range expected {
+		if result[index] != expected[index] {
+			t.Errorf("Expected %s at position %d, got %s", expected[index], index, result[index])
+		}
+	}
+}
+
+// newDisconnectedDataStore uses a client whose socket does not exist, the dashboard shows no jails then
+func newDisconnectedDataStore(tb testing.TB) *store.DataStore {
+	tb.Helper()
+	f2bc, err := client.NewFail2BanClient(filepath.Join(tb.TempDir(), "fail2ban.sock"))
+	if err == nil {
+		tb.Fatal("NewFail2BanClient() should fail without socket")
+	}
+	tb.Cleanup(func() { _ = f2bc.Close() })
+	return store.NewDataStore(f2bc, 30)
+}
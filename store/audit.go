@@ -0,0 +1,189 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3/log"
+)
+
+const (
+	auditFileName = "audit.jsonl"
+	// maxAuditEntries are kept in memory for the dashboard, the file keeps every entry
+	maxAuditEntries = 1000
+)
+
+const (
+	AuditAddIgnoreIP = "addignoreip"
+	AuditDelIgnoreIP = "delignoreip"
+	AuditUnban       = "unban"
+)
+
+// AuditEntry is a change made through the dashboard, User is empty without basic authentication
+type AuditEntry struct {
+	Time          time.Time `json:"time"`
+	User          string    `json:"user,omitempty"`
+	RemoteAddress string    `json:"remoteAddress,omitempty"`
+	Action        string    `json:"action"`
+	JailName      string    `json:"jail"`
+	Address       string    `json:"address,omitempty"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// Actor is who made a change through the dashboard
+type Actor struct {
+	User          string
+	RemoteAddress string
+}
+
+// AuditTrail appends each change to a file in the cache directory, unlike the history it is never compacted
+type AuditTrail struct {
+	mutex   sync.RWMutex
+	path    string
+	file    *os.File
+	entries []AuditEntry
+}
+
+func NewAuditTrail(directory string) (*AuditTrail, error) {
+	trail := &AuditTrail{
+		path:    filepath.Join(directory, auditFileName),
+		entries: make([]AuditEntry, 0),
+	}
+
+	err := trail.load()
+	if err != nil {
+		return nil, err
+	}
+
+	trail.file, err = os.OpenFile(trail.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Audit trail at %s", trail.path)
+	return trail, nil
+}
+
+func (trail *AuditTrail) load() error {
+	file, err := os.Open(trail.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry AuditEntry
+		if unmarshalErr := json.Unmarshal(scanner.Bytes(), &entry); unmarshalErr != nil {
+			log.Errorf("Skipping invalid audit trail line: %s", unmarshalErr)
+			continue
+		}
+		trail.keep(entry)
+	}
+	return scanner.Err()
+}
+
+// Record appends the entry, a missing time is set to now
+func (trail *AuditTrail) Record(entry AuditEntry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	trail.mutex.Lock()
+	defer trail.mutex.Unlock()
+	trail.keep(entry)
+
+	if trail.file == nil {
+		return
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Errorf("Could not encode audit trail entry: %s", err)
+		return
+	}
+	_, err = trail.file.Write(append(line, '\n'))
+	if err != nil {
+		log.Errorf("Could not write audit trail: %s", err)
+	}
+}
+
+func (trail *AuditTrail) keep(entry AuditEntry) {
+	trail.entries = append(trail.entries, entry)
+	if len(trail.entries) > maxAuditEntries {
+		trail.entries = trail.entries[len(trail.entries)-maxAuditEntries:]
+	}
+}
+
+// Entries returns the latest entries first, an empty jail name returns the entries of all jails
+func (trail *AuditTrail) Entries(jailName string) []AuditEntry {
+	trail.mutex.RLock()
+	defer trail.mutex.RUnlock()
+	result := make([]AuditEntry, 0)
+	for index := len(trail.entries) - 1; index >= 0; index-- {
+		if jailName == "" || trail.entries[index].JailName == jailName {
+			result = append(result, trail.entries[index])
+		}
+	}
+	return result
+}
+
+// Close closes the file, later entries are only kept in memory
+func (trail *AuditTrail) Close() error {
+	trail.mutex.Lock()
+	defer trail.mutex.Unlock()
+	if trail.file == nil {
+		return nil
+	}
+	err := trail.file.Close()
+	trail.file = nil
+	return err
+}
+
+// recordAudit adds the change to the audit trail when it is enabled, failed changes are recorded with their error
+func (dataStore *DataStore) recordAudit(actor Actor, action string, jailName string, address string, err error) {
+	entry := AuditEntry{
+		User:          actor.User,
+		RemoteAddress: actor.RemoteAddress,
+		Action:        action,
+		JailName:      jailName,
+		Address:       address,
+	}
+	user := entry.User
+	if user == "" {
+		user = "anonymous"
+	}
+	if err != nil {
+		entry.Error = err.Error()
+		log.Warnf("Audit: %s %s in %s by %s from %s failed: %s", action, address, jailName, user, actor.RemoteAddress, err)
+	} else {
+		log.Infof("Audit: %s %s in %s by %s from %s", action, address, jailName, user, actor.RemoteAddress)
+	}
+	if trail := dataStore.Audit(); trail != nil {
+		trail.Record(entry)
+	}
+}
+
+// EnableAudit records changes made through the dashboard in the audit trail
+func (dataStore *DataStore) EnableAudit(trail *AuditTrail) {
+	dataStore.mutex.Lock()
+	defer dataStore.mutex.Unlock()
+	dataStore.audit = trail
+}
+
+// Audit returns the audit trail or nil when it is disabled
+func (dataStore *DataStore) Audit() *AuditTrail {
+	dataStore.mutex.RLock()
+	defer dataStore.mutex.RUnlock()
+	return dataStore.audit
+}
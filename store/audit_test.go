@@ -0,0 +1,102 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func createAuditTrail(t *testing.T, dir string) *AuditTrail {
+	t.Helper()
+	trail, err := NewAuditTrail(dir)
+	if err != nil {
+		t.Fatalf("NewAuditTrail() error = %v", err)
+	}
+	t.Cleanup(func() { _ = trail.Close() })
+	return trail
+}
+
+func TestAuditTrail_Record(t *testing.T) {
+	dir := t.TempDir()
+	trail := createAuditTrail(t, dir)
+	now := time.Now().Truncate(time.Second)
+
+	trail.Record(AuditEntry{Time: now, User: "admin", Action: AuditAddIgnoreIP, JailName: "sshd", Address: "192.168.1.1"})
+	trail.Record(AuditEntry{Time: now.Add(time.Minute), User: "admin", Action: AuditUnban, JailName: "postfix", Address: "192.168.1.2"})
+	trail.Record(AuditEntry{Action: AuditDelIgnoreIP, JailName: "sshd", Address: "192.168.1.1", Error: "not connected"})
+
+	entries := trail.Entries("")
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+	if entries[0].Action != AuditDelIgnoreIP || entries[0].Time.IsZero() {
+		t.Errorf("Expected latest entry first with time set, got %+v", entries[0])
+	}
+
+	sshd := trail.Entries("sshd")
+	if len(sshd) != 2 {
+		t.Errorf("Expected 2 entries for sshd, got %d", len(sshd))
+	}
+
+	if err := trail.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if lines := countLines(t, filepath.Join(dir, auditFileName)); lines != 3 {
+		t.Errorf("Expected 3 lines in the audit file, got %d", lines)
+	}
+
+	reloaded := createAuditTrail(t, dir)
+	reloadedEntries := reloaded.Entries("")
+	if len(reloadedEntries) != 3 {
+		t.Fatalf("Expected 3 entries after reload, got %d", len(reloadedEntries))
+	}
+	if reloadedEntries[2].User != "admin" || !reloadedEntries[2].Time.Equal(now) {
+		t.Errorf("Expected first entry to be restored, got %+v", reloadedEntries[2])
+	}
+}
+
+func TestAuditTrail_MaxEntries(t *testing.T) {
+	trail := createAuditTrail(t, t.TempDir())
+	for index := 0; index < maxAuditEntries+10; index++ {
+		trail.Record(AuditEntry{Action: AuditAddIgnoreIP, JailName: "sshd"})
+	}
+	if entries := trail.Entries(""); len(entries) != maxAuditEntries {
+		t.Errorf("Expected %d entries in memory, got %d", maxAuditEntries, len(entries))
+	}
+}
+
+func TestDataStore_RecordAudit(t *testing.T) {
+	ds := newDisconnectedDataStore(t)
+	ds.recordAudit(Actor{User: "admin"}, AuditAddIgnoreIP, "sshd", "192.168.1.1", nil)
+
+	trail := createAuditTrail(t, t.TempDir())
+	ds.EnableAudit(trail)
+	ds.recordAudit(Actor{User: "admin", RemoteAddress: "10.0.0.1"}, AuditDelIgnoreIP, "sshd", "192.168.1.1", errors.New("refused"))
+
+	entries := ds.Audit().Entries("")
+	if len(entries) != 1 {
+		t.Fatalf("Expected only the entry recorded after enabling the audit trail, got %d", len(entries))
+	}
+	expected := AuditEntry{User: "admin", RemoteAddress: "10.0.0.1", Action: AuditDelIgnoreIP, JailName: "sshd", Address: "192.168.1.1", Error: "refused"}
+	entries[0].Time = time.Time{}
+	if entries[0] != expected {
+		t.Errorf("Expected %+v, got %+v", expected, entries[0])
+	}
+}
+
+func TestDataStore_IgnoreIPs(t *testing.T) {
+	ds := newDisconnectedDataStore(t)
+	if _, err := ds.GetIgnoreIPs("sshd"); err == nil {
+		t.Error("Expected error when reading the ignore list without connection")
+	}
+	if _, err := ds.AddIgnoreIP(Actor{}, "sshd", "192.168.1.1"); err == nil {
+		t.Error("Expected error when adding to the ignore list without connection")
+	}
+	if _, err := ds.DelIgnoreIP(Actor{}, "sshd", "192.168.1.1"); err == nil {
+		t.Error("Expected error when removing from the ignore list without connection")
+	}
+	if err := ds.UnbanAndIgnore(Actor{}, "sshd", "192.168.1.1"); err == nil {
+		t.Error("Expected error when unbanning without connection")
+	}
+}
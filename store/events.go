@@ -0,0 +1,198 @@
+package store
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3/log"
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+)
+
+type EventType string
+
+const (
+	BanAdded        EventType = "ban_added"
+	BanRemoved      EventType = "ban_removed"
+	BanExtended     EventType = "ban_extended"
+	JailAppeared    EventType = "jail_appeared"
+	JailDisappeared EventType = "jail_disappeared"
+)
+
+// Event is a single change between two snapshots of fail2ban,
+// Ban is empty for jail events and Previous is only set for extended bans
+type Event struct {
+	Type     EventType        `json:"type"`
+	Time     time.Time        `json:"time"`
+	JailName string           `json:"jail"`
+	Ban      *client.BanEntry `json:"ban,omitempty"`
+	Previous *client.BanEntry `json:"previous,omitempty"`
+}
+
+// Update is sent to subscribers after each refresh and when the connection state changes,
+// Initial marks the first snapshot after start where every ban is reported as added,
+// FailedJails has the error of each jail which could not be fetched by the refresh
+type Update struct {
+	Time        time.Time         `json:"time"`
+	Initial     bool              `json:"initial"`
+	Events      []Event           `json:"events"`
+	FailedJails map[string]string `json:"failedJails,omitempty"`
+}
+
+type EventHandler func(update Update)
+
+// subscriberLagWarning is how many queued updates of a subscriber are logged as warning
+const subscriberLagWarning = 64
+
+// subscriber queues the updates of a handler, the queue is not limited, so a slow handler
+// neither blocks the refresh nor misses updates
+type subscriber struct {
+	mutex   sync.Mutex
+	pending []Update
+	wake    chan struct{}
+}
+
+// Subscribe registers a handler for updates, the handler is called in order of the updates
+// on its own goroutine and must not call Refresh, Ban or Unban
+func (dataStore *DataStore) Subscribe(handler EventHandler) {
+	queue := &subscriber{wake: make(chan struct{}, 1)}
+	go queue.run(handler)
+
+	dataStore.mutex.Lock()
+	defer dataStore.mutex.Unlock()
+	dataStore.subscribers = append(dataStore.subscribers, queue)
+}
+
+func (dataStore *DataStore) dispatch(update Update) {
+	dataStore.mutex.RLock()
+	subscribers := dataStore.subscribers
+	dataStore.mutex.RUnlock()
+
+	if len(update.Events) > 0 {
+		log.Debugf("Dispatching %d events to %d subscribers", len(update.Events), len(subscribers))
+	}
+	for _, queue := range subscribers {
+		queue.push(update)
+	}
+}
+
+// push queues the update without waiting for the handler, updates without events only carry the time
+// and replace a queued update without events, so a stuck handler doesn't collect one per refresh
+func (queue *subscriber) push(update Update) {
+	queue.mutex.Lock()
+	last := len(queue.pending) - 1
+	if last >= 0 && len(update.Events) == 0 && !update.Initial && len(queue.pending[last].Events) == 0 && !queue.pending[last].Initial {
+		queue.pending[last] = update
+	} else {
+		queue.pending = append(queue.pending, update)
+	}
+	pending := len(queue.pending)
+	queue.mutex.Unlock()
+
+	if pending%subscriberLagWarning == 0 {
+		log.Warnf("Event subscriber is %d updates behind", pending)
+	}
+	select {
+	case queue.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (queue *subscriber) run(handler EventHandler) {
+	for range queue.wake {
+		for {
+			queue.mutex.Lock()
+			updates := queue.pending
+			queue.pending = nil
+			queue.mutex.Unlock()
+			if len(updates) == 0 {
+				break
+			}
+			for _, update := range updates {
+				handler(update)
+			}
+		}
+	}
+}
+
+// diffSnapshots compares two snapshots of the jails and returns what changed,
+// the events are sorted by jail and address
+func diffSnapshots(previous map[string]*client.JailEntry, current map[string]*client.JailEntry, now time.Time) []Event {
+	var events []Event
+
+	for _, jailName := range sortedJailNames(previous, current) {
+		previousJail, previousExists := previous[jailName]
+		currentJail, currentExists := current[jailName]
+
+		if currentExists && !previousExists {
+			events = append(events, Event{Type: JailAppeared, Time: now, JailName: jailName})
+		}
+
+		previousBans := bansByAddress(previousJail)
+		currentBans := bansByAddress(currentJail)
+
+		for _, address := range sortedAddresses(previousBans, currentBans) {
+			previousBan, wasBanned := previousBans[address]
+			currentBan, isBanned := currentBans[address]
+			switch {
+			case isBanned && !wasBanned:
+				events = append(events, Event{Type: BanAdded, Time: now, JailName: jailName, Ban: currentBan})
+			case wasBanned && !isBanned:
+				events = append(events, Event{Type: BanRemoved, Time: now, JailName: jailName, Ban: previousBan})
+			case banExtended(previousBan, currentBan):
+				events = append(events, Event{Type: BanExtended, Time: now, JailName: jailName, Ban: currentBan, Previous: previousBan})
+			}
+		}
+
+		if previousExists && !currentExists {
+			events = append(events, Event{Type: JailDisappeared, Time: now, JailName: jailName})
+		}
+	}
+
+	return events
+}
+
+func banExtended(previous *client.BanEntry, current *client.BanEntry) bool {
+	return previous.CurrenPenalty != current.CurrenPenalty || !previous.BanEndsAt.Equal(current.BanEndsAt)
+}
+
+func bansByAddress(jail *client.JailEntry) map[string]*client.BanEntry {
+	result := make(map[string]*client.BanEntry)
+	if jail == nil {
+		return result
+	}
+	for _, ban := range jail.BannedEntries {
+		if ban != nil {
+			result[ban.Address] = ban
+		}
+	}
+	return result
+}
+
+func sortedJailNames(previous map[string]*client.JailEntry, current map[string]*client.JailEntry) []string {
+	names := make([]string, 0, len(current))
+	for name := range current {
+		names = append(names, name)
+	}
+	for name := range previous {
+		if _, exists := current[name]; !exists {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedAddresses(previous map[string]*client.BanEntry, current map[string]*client.BanEntry) []string {
+	addresses := make([]string, 0, len(current))
+	for address := range current {
+		addresses = append(addresses, address)
+	}
+	for address := range previous {
+		if _, exists := current[address]; !exists {
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Strings(addresses)
+	return addresses
+}
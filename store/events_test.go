@@ -0,0 +1,204 @@
+package store
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+)
+
+func createSnapshot(jails map[string][]*client.BanEntry) map[string]*client.JailEntry {
+	result := make(map[string]*client.JailEntry)
+	for name, bans := range jails {
+		result[name] = &client.JailEntry{Name: name, BannedEntries: bans}
+	}
+	return result
+}
+
+func createBan(address string, penalty string, endsAt time.Time) *client.BanEntry {
+	return &client.BanEntry{Address: address, CurrenPenalty: penalty, BanEndsAt: endsAt}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	now := time.Now()
+	endsAt := now.Add(time.Hour)
+
+	tests := []struct {
+		name     string
+		previous map[string]*client.JailEntry
+		current  map[string]*client.JailEntry
+		expected []Event
+	}{
+		{
+			name:     "nothing changed",
+			previous: createSnapshot(map[string][]*client.BanEntry{"sshd": {createBan("192.168.1.1", "600", endsAt)}}),
+			current:  createSnapshot(map[string][]*client.BanEntry{"sshd": {createBan("192.168.1.1", "600", endsAt)}}),
+			expected: nil,
+		},
+		{
+			name:     "first snapshot",
+			previous: nil,
+			current:  createSnapshot(map[string][]*client.BanEntry{"sshd": {createBan("192.168.1.2", "600", endsAt), createBan("192.168.1.1", "600", endsAt)}}),
+			expected: []Event{
+				{Type: JailAppeared, JailName: "sshd"},
+				{Type: BanAdded, JailName: "sshd", Ban: createBan("192.168.1.1", "600", endsAt)},
+				{Type: BanAdded, JailName: "sshd", Ban: createBan("192.168.1.2", "600", endsAt)},
+			},
+		},
+		{
+			name:     "ban added and removed",
+			previous: createSnapshot(map[string][]*client.BanEntry{"sshd": {createBan("192.168.1.1", "600", endsAt)}}),
+			current:  createSnapshot(map[string][]*client.BanEntry{"sshd": {createBan("192.168.1.2", "600", endsAt)}}),
+			expected: []Event{
+				{Type: BanRemoved, JailName: "sshd", Ban: createBan("192.168.1.1", "600", endsAt)},
+				{Type: BanAdded, JailName: "sshd", Ban: createBan("192.168.1.2", "600", endsAt)},
+			},
+		},
+		{
+			name:     "penalty increased",
+			previous: createSnapshot(map[string][]*client.BanEntry{"sshd": {createBan("192.168.1.1", "600", endsAt)}}),
+			current:  createSnapshot(map[string][]*client.BanEntry{"sshd": {createBan("192.168.1.1", "1200", endsAt)}}),
+			expected: []Event{
+				{Type: BanExtended, JailName: "sshd", Ban: createBan("192.168.1.1", "1200", endsAt), Previous: createBan("192.168.1.1", "600", endsAt)},
+			},
+		},
+		{
+			name:     "end time changed",
+			previous: createSnapshot(map[string][]*client.BanEntry{"sshd": {createBan("192.168.1.1", "600", endsAt)}}),
+			current:  createSnapshot(map[string][]*client.BanEntry{"sshd": {createBan("192.168.1.1", "600", endsAt.Add(time.Hour))}}),
+			expected: []Event{
+				{Type: BanExtended, JailName: "sshd", Ban: createBan("192.168.1.1", "600", endsAt.Add(time.Hour)), Previous: createBan("192.168.1.1", "600", endsAt)},
+			},
+		},
+		{
+			name:     "jail replaced",
+			previous: createSnapshot(map[string][]*client.BanEntry{"sshd": {createBan("192.168.1.1", "600", endsAt)}}),
+			current:  createSnapshot(map[string][]*client.BanEntry{"postfix": nil}),
+			expected: []Event{
+				{Type: JailAppeared, JailName: "postfix"},
+				{Type: BanRemoved, JailName: "sshd", Ban: createBan("192.168.1.1", "600", endsAt)},
+				{Type: JailDisappeared, JailName: "sshd"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := diffSnapshots(tt.previous, tt.current, now)
+			if len(events) != len(tt.expected) {
+				t.Fatalf("Expected %d events, got %d: %+v", len(tt.expected), len(events), events)
+			}
+			for index, expected := range tt.expected {
+				event := events[index]
+				if event.Type != expected.Type || event.JailName != expected.JailName {
+					t.Errorf("Expected %s in %s at %d, got %s in %s", expected.Type, expected.JailName, index, event.Type, event.JailName)
+				}
+				if !event.Time.Equal(now) {
+					t.Errorf("Expected event time %v, got %v", now, event.Time)
+				}
+				if (expected.Ban == nil) != (event.Ban == nil) || expected.Ban != nil && *expected.Ban != *event.Ban {
+					t.Errorf("Expected ban %+v at %d, got %+v", expected.Ban, index, event.Ban)
+				}
+				if (expected.Previous == nil) != (event.Previous == nil) || expected.Previous != nil && *expected.Previous != *event.Previous {
+					t.Errorf("Expected previous ban %+v at %d, got %+v", expected.Previous, index, event.Previous)
+				}
+			}
+		})
+	}
+}
+
+func TestDataStore_Subscribe(t *testing.T) {
+	ds := newDisconnectedDataStore(t)
+
+	received := make(chan Update, 3)
+	ds.Subscribe(func(update Update) {
+		received <- update
+	})
+
+	for index := 0; index < 3; index++ {
+		ds.dispatch(Update{Initial: index == 0, Events: make([]Event, index)})
+	}
+
+	for index := 0; index < 3; index++ {
+		select {
+		case update := <-received:
+			if len(update.Events) != index {
+				t.Errorf("Expected update %d to be delivered in order, got %d events", index, len(update.Events))
+			}
+			if update.Initial != (index == 0) {
+				t.Errorf("Expected only the first update to be initial, got %v at %d", update.Initial, index)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected update %d to be delivered", index)
+		}
+	}
+}
+
+func TestDataStore_SubscribeBlockedHandler(t *testing.T) {
+	ds := newDisconnectedDataStore(t)
+
+	blocked := make(chan struct{})
+	defer close(blocked)
+	ds.Subscribe(func(update Update) {
+		<-blocked
+	})
+
+	dispatched := make(chan struct{})
+	go func() {
+		for index := 0; index < 1000; index++ {
+			ds.dispatch(Update{Events: make([]Event, index%3)})
+		}
+		close(dispatched)
+	}()
+
+	select {
+	case <-dispatched:
+	case <-time.After(time.Second):
+		t.Fatal("Dispatching blocked on the handler which does not return")
+	}
+}
+
+func TestDataStore_SubscribeSlowHandler(t *testing.T) {
+	ds := newDisconnectedDataStore(t)
+
+	const updates = 200
+	var mutex sync.Mutex
+	var received []string
+	done := make(chan struct{})
+	ds.Subscribe(func(update Update) {
+		time.Sleep(time.Millisecond)
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, event := range update.Events {
+			received = append(received, event.Ban.Address)
+		}
+		if len(received) == updates {
+			close(done)
+		}
+	})
+
+	start := time.Now()
+	for index := 0; index < updates; index++ {
+		// updates without events in between may be merged, the events must all arrive
+		ds.dispatch(Update{})
+		ds.dispatch(Update{Events: []Event{{Type: BanAdded, JailName: "sshd", Ban: &client.BanEntry{Address: fmt.Sprintf("192.0.2.%d", index)}}}})
+	}
+	if elapsed := time.Since(start); elapsed > updates*time.Millisecond/2 {
+		t.Errorf("Dispatching took %s, it waited for the slow handler", elapsed)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Not every event was delivered")
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	for index, address := range received {
+		if expected := fmt.Sprintf("192.0.2.%d", index); address != expected {
+			t.Fatalf("Expected event %d for %s, got %s", index, expected, address)
+		}
+	}
+}
@@ -0,0 +1,391 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3/log"
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+)
+
+const historyFileName = "history.jsonl"
+
+// HistoryEntry is a single observed ban, RemovedAt is empty as long as the ban is active
+type HistoryEntry struct {
+	Address     string     `json:"address"`
+	JailName    string     `json:"jail"`
+	CountryCode string     `json:"countryCode,omitempty"`
+	BannedAt    time.Time  `json:"bannedAt"`
+	BanEndsAt   time.Time  `json:"banEndsAt"`
+	Penalty     string     `json:"penalty"`
+	RemovedAt   *time.Time `json:"removedAt,omitempty"`
+}
+
+// HistoryFilter selects history entries, empty values match everything
+type HistoryFilter struct {
+	Address  string
+	JailName string
+	Since    time.Time
+}
+
+type HistoryConfiguration struct {
+	Directory          string
+	Retention          time.Duration
+	CompactionInterval time.Duration
+}
+
+type CountryLookup func(address string) string
+
+type historyKey struct {
+	jailName string
+	address  string
+	bannedAt int64
+}
+
+// History keeps every observed ban in an append-only file, each line is the latest state of an entry
+// and replaying the file in order restores the history
+type History struct {
+	mutex     sync.RWMutex
+	path      string
+	file      *os.File
+	retention time.Duration
+	lookup    CountryLookup
+	entries   map[historyKey]*HistoryEntry
+	// unreconciled are the jails which failed on the initial update, their active entries
+	// are checked once the jail is fetched
+	unreconciled map[string]bool
+	lines        int
+	done         chan struct{}
+}
+
+func NewHistory(configuration HistoryConfiguration, lookup CountryLookup) (*History, error) {
+	history := &History{
+		path:      filepath.Join(configuration.Directory, historyFileName),
+		retention: configuration.Retention,
+		lookup:    lookup,
+		entries:   make(map[historyKey]*HistoryEntry),
+		done:      make(chan struct{}),
+	}
+
+	err := history.load()
+	if err != nil {
+		return nil, err
+	}
+
+	err = history.Compact()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Loaded %d ban history entries from %s", len(history.entries), history.path)
+
+	if configuration.CompactionInterval > 0 {
+		go history.scheduleCompaction(configuration.CompactionInterval, history.done)
+	}
+
+	return history, nil
+}
+
+func (history *History) load() error {
+	file, err := os.Open(history.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry HistoryEntry
+		if unmarshalErr := json.Unmarshal(scanner.Bytes(), &entry); unmarshalErr != nil {
+			// a crash while appending leaves an incomplete last line, the remaining history is still usable
+			log.Errorf("Skipping invalid ban history line %d: %s", history.lines+1, unmarshalErr)
+			continue
+		}
+		history.entries[newHistoryKey(entry.JailName, entry.Address, entry.BannedAt)] = &entry
+		history.lines++
+	}
+	return scanner.Err()
+}
+
+// Compact rewrites the file with one line per entry and drops entries which ended before the retention
+func (history *History) Compact() error {
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+
+	if history.retention > 0 {
+		cutoff := time.Now().Add(-history.retention)
+		for key, entry := range history.entries {
+			if entry.endedBefore(cutoff) {
+				delete(history.entries, key)
+			}
+		}
+	}
+
+	temporaryPath := history.path + ".tmp"
+	temporaryFile, err := os.OpenFile(temporaryPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(temporaryFile)
+	encoder := json.NewEncoder(writer)
+	for _, entry := range history.sortedEntries(HistoryFilter{}) {
+		if err = encoder.Encode(entry); err != nil {
+			_ = temporaryFile.Close()
+			return err
+		}
+	}
+	if err = writer.Flush(); err != nil {
+		_ = temporaryFile.Close()
+		return err
+	}
+	if err = temporaryFile.Close(); err != nil {
+		return err
+	}
+
+	if history.file != nil {
+		_ = history.file.Close()
+		history.file = nil
+	}
+	if err = os.Rename(temporaryPath, history.path); err != nil {
+		return err
+	}
+
+	history.file, err = os.OpenFile(history.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	log.Debugf("Compacted ban history from %d to %d lines", history.lines, len(history.entries))
+	history.lines = len(history.entries)
+	return nil
+}
+
+func (history *History) scheduleCompaction(interval time.Duration, done chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := history.Compact(); err != nil {
+				log.Errorf("Could not compact ban history: %s", err)
+			}
+		}
+	}
+}
+
+// Close stops the compaction and closes the file
+func (history *History) Close() error {
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+	if history.done != nil {
+		close(history.done)
+		history.done = nil
+	}
+	if history.file == nil {
+		return nil
+	}
+	err := history.file.Close()
+	history.file = nil
+	return err
+}
+
+// Entries returns the entries matching the filter, latest bans first
+func (history *History) Entries(filter HistoryFilter) []HistoryEntry {
+	history.mutex.RLock()
+	defer history.mutex.RUnlock()
+	sorted := history.sortedEntries(filter)
+	result := make([]HistoryEntry, len(sorted))
+	for index, entry := range sorted {
+		result[index] = *entry
+	}
+	return result
+}
+
+func (history *History) sortedEntries(filter HistoryFilter) []*HistoryEntry {
+	result := make([]*HistoryEntry, 0, len(history.entries))
+	for _, entry := range history.entries {
+		if filter.Address != "" && entry.Address != filter.Address {
+			continue
+		}
+		if filter.JailName != "" && entry.JailName != filter.JailName {
+			continue
+		}
+		if !filter.Since.IsZero() && entry.BannedAt.Before(filter.Since) {
+			continue
+		}
+		result = append(result, entry)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].BannedAt.Equal(result[j].BannedAt) {
+			return result[i].BannedAt.After(result[j].BannedAt)
+		}
+		if result[i].JailName != result[j].JailName {
+			return result[i].JailName < result[j].JailName
+		}
+		return result[i].Address < result[j].Address
+	})
+	return result
+}
+
+// record applies the events of an update, after the initial update active entries
+// which are no longer banned are closed as they ended while the dashboard was not running.
+// Entries of jails which could not be fetched are kept until the jail appears in a later update
+func (history *History) record(update Update) {
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+
+	active := make(map[historyKey]bool)
+	var appeared []string
+	for _, event := range update.Events {
+		switch event.Type {
+		case JailAppeared:
+			appeared = append(appeared, event.JailName)
+		case BanAdded:
+			active[newHistoryKey(event.Ban.JailName, event.Ban.Address, event.Ban.BannedAt)] = true
+			history.add(event.Ban)
+		case BanExtended:
+			if !event.Previous.BannedAt.Equal(event.Ban.BannedAt) {
+				history.remove(event.Previous, event.Time)
+			}
+			history.add(event.Ban)
+		case BanRemoved:
+			history.remove(event.Ban, event.Time)
+		}
+	}
+
+	if update.Initial {
+		history.unreconciled = make(map[string]bool)
+		for jailName := range update.FailedJails {
+			history.unreconciled[jailName] = true
+		}
+		history.closeEnded(update.Time, active, func(jailName string) bool {
+			return !history.unreconciled[jailName]
+		})
+		return
+	}
+
+	reconciled := make(map[string]bool)
+	for _, jailName := range appeared {
+		if history.unreconciled[jailName] {
+			reconciled[jailName] = true
+			delete(history.unreconciled, jailName)
+		}
+	}
+	if len(reconciled) > 0 {
+		history.closeEnded(update.Time, active, func(jailName string) bool {
+			return reconciled[jailName]
+		})
+	}
+}
+
+// closeEnded closes the active entries of the selected jails which are not active anymore,
+// an entry is closed at its end when the ban ended before
+func (history *History) closeEnded(now time.Time, active map[historyKey]bool, selected func(jailName string) bool) {
+	for key, entry := range history.entries {
+		if entry.RemovedAt == nil && !active[key] && selected(entry.JailName) {
+			removedAt := now
+			if !entry.BanEndsAt.IsZero() && entry.BanEndsAt.Before(removedAt) {
+				removedAt = entry.BanEndsAt
+			}
+			entry.RemovedAt = &removedAt
+			history.append(entry)
+		}
+	}
+}
+
+func (history *History) add(ban *client.BanEntry) {
+	key := newHistoryKey(ban.JailName, ban.Address, ban.BannedAt)
+	entry, exists := history.entries[key]
+	if exists && entry.RemovedAt == nil && entry.Penalty == ban.CurrenPenalty && entry.BanEndsAt.Equal(ban.BanEndsAt) {
+		return
+	}
+	if !exists {
+		entry = &HistoryEntry{
+			Address:  ban.Address,
+			JailName: ban.JailName,
+			BannedAt: ban.BannedAt,
+		}
+		if history.lookup != nil {
+			entry.CountryCode = history.lookup(ban.Address)
+		}
+		history.entries[key] = entry
+	}
+	entry.BanEndsAt = ban.BanEndsAt
+	entry.Penalty = ban.CurrenPenalty
+	entry.RemovedAt = nil
+	history.append(entry)
+}
+
+func (history *History) remove(ban *client.BanEntry, removedAt time.Time) {
+	key := newHistoryKey(ban.JailName, ban.Address, ban.BannedAt)
+	entry, exists := history.entries[key]
+	if !exists {
+		entry = &HistoryEntry{
+			Address:   ban.Address,
+			JailName:  ban.JailName,
+			BannedAt:  ban.BannedAt,
+			BanEndsAt: ban.BanEndsAt,
+			Penalty:   ban.CurrenPenalty,
+		}
+		history.entries[key] = entry
+	}
+	entry.RemovedAt = &removedAt
+	history.append(entry)
+}
+
+func (history *History) append(entry *HistoryEntry) {
+	if history.file == nil {
+		return
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Errorf("Could not encode ban history entry: %s", err)
+		return
+	}
+	_, err = history.file.Write(append(line, '\n'))
+	if err != nil {
+		log.Errorf("Could not write ban history: %s", err)
+		return
+	}
+	history.lines++
+}
+
+func (entry *HistoryEntry) endedBefore(cutoff time.Time) bool {
+	if entry.RemovedAt != nil {
+		return entry.RemovedAt.Before(cutoff)
+	}
+	return !entry.BanEndsAt.IsZero() && entry.BanEndsAt.Before(cutoff)
+}
+
+func newHistoryKey(jailName string, address string, bannedAt time.Time) historyKey {
+	return historyKey{jailName: jailName, address: address, bannedAt: bannedAt.Unix()}
+}
+
+// EnableHistory records the events of the data store in the history
+func (dataStore *DataStore) EnableHistory(history *History) {
+	dataStore.mutex.Lock()
+	dataStore.history = history
+	dataStore.mutex.Unlock()
+	dataStore.Subscribe(history.record)
+}
+
+// History returns the ban history or nil when it is disabled
+func (dataStore *DataStore) History() *History {
+	dataStore.mutex.RLock()
+	defer dataStore.mutex.RUnlock()
+	return dataStore.history
+}
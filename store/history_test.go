@@ -0,0 +1,228 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+)
+
+func createHistory(t *testing.T, dir string, retention time.Duration) *History {
+	t.Helper()
+	history, err := NewHistory(HistoryConfiguration{Directory: dir, Retention: retention}, func(address string) string {
+		return "DE"
+	})
+	if err != nil {
+		t.Fatalf("NewHistory() error = %v", err)
+	}
+	t.Cleanup(func() { _ = history.Close() })
+	return history
+}
+
+func createHistoryBan(address string, jailName string, bannedAt time.Time, penalty string) *client.BanEntry {
+	return &client.BanEntry{
+		Address:       address,
+		JailName:      jailName,
+		BannedAt:      bannedAt,
+		BanEndsAt:     bannedAt.Add(10 * time.Minute),
+		CurrenPenalty: penalty,
+	}
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return strings.Count(string(data), "\n")
+}
+
+func TestHistory_Record(t *testing.T) {
+	dir := t.TempDir()
+	history := createHistory(t, dir, 0)
+	now := time.Now().Truncate(time.Second)
+
+	first := createHistoryBan("192.168.1.1", "sshd", now, "600")
+	extended := createHistoryBan("192.168.1.1", "sshd", now, "1200")
+	extended.BanEndsAt = now.Add(20 * time.Minute)
+	second := createHistoryBan("192.168.1.2", "postfix", now.Add(time.Minute), "600")
+
+	history.record(Update{Time: now, Initial: true, Events: []Event{{Type: BanAdded, JailName: "sshd", Ban: first}}})
+	history.record(Update{Time: now, Events: []Event{{Type: BanExtended, JailName: "sshd", Ban: extended, Previous: first}}})
+	history.record(Update{Time: now, Events: []Event{{Type: BanAdded, JailName: "postfix", Ban: second}}})
+	history.record(Update{Time: now.Add(5 * time.Minute), Events: []Event{{Type: BanRemoved, Time: now.Add(5 * time.Minute), JailName: "sshd", Ban: extended}}})
+
+	entries := history.Entries(HistoryFilter{})
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].Address != "192.168.1.2" || entries[0].RemovedAt != nil || entries[0].CountryCode != "DE" {
+		t.Errorf("Expected latest active ban first, got %+v", entries[0])
+	}
+	if entries[1].Penalty != "1200" || entries[1].RemovedAt == nil || !entries[1].RemovedAt.Equal(now.Add(5*time.Minute)) {
+		t.Errorf("Expected extended and removed ban, got %+v", entries[1])
+	}
+
+	t.Run("filter", func(t *testing.T) {
+		if result := history.Entries(HistoryFilter{JailName: "sshd"}); len(result) != 1 || result[0].Address != "192.168.1.1" {
+			t.Errorf("Expected sshd entry, got %+v", result)
+		}
+		if result := history.Entries(HistoryFilter{Address: "192.168.1.2"}); len(result) != 1 || result[0].JailName != "postfix" {
+			t.Errorf("Expected postfix entry, got %+v", result)
+		}
+		if result := history.Entries(HistoryFilter{Since: now.Add(30 * time.Second)}); len(result) != 1 {
+			t.Errorf("Expected 1 entry since, got %+v", result)
+		}
+	})
+
+	t.Run("appended lines", func(t *testing.T) {
+		if lines := countLines(t, filepath.Join(dir, historyFileName)); lines != 4 {
+			t.Errorf("Expected 4 appended lines, got %d", lines)
+		}
+	})
+
+	t.Run("reload", func(t *testing.T) {
+		_ = history.Close()
+		reloaded := createHistory(t, dir, 0)
+		result := reloaded.Entries(HistoryFilter{})
+		if len(result) != 2 || result[1].Penalty != "1200" || result[1].RemovedAt == nil {
+			t.Errorf("Expected history to be restored, got %+v", result)
+		}
+		if lines := countLines(t, filepath.Join(dir, historyFileName)); lines != 2 {
+			t.Errorf("Expected compacted file with 2 lines, got %d", lines)
+		}
+	})
+}
+
+func TestHistory_InitialUpdateClosesEndedBans(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().Truncate(time.Second)
+	ended := createHistoryBan("192.168.1.1", "sshd", now.Add(-time.Hour), "600")
+	active := createHistoryBan("192.168.1.2", "sshd", now.Add(-time.Minute), "600")
+
+	history := createHistory(t, dir, 0)
+	history.record(Update{Time: now.Add(-time.Hour), Initial: true, Events: []Event{
+		{Type: BanAdded, JailName: "sshd", Ban: ended},
+		{Type: BanAdded, JailName: "sshd", Ban: active},
+	}})
+	_ = history.Close()
+
+	// the dashboard was stopped while the first ban expired
+	reloaded := createHistory(t, dir, 0)
+	reloaded.record(Update{Time: now, Initial: true, Events: []Event{{Type: BanAdded, JailName: "sshd", Ban: active}}})
+
+	entries := reloaded.Entries(HistoryFilter{})
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].RemovedAt != nil {
+		t.Errorf("Expected active ban to stay open, got %+v", entries[0])
+	}
+	if entries[1].RemovedAt == nil || !entries[1].RemovedAt.Equal(ended.BanEndsAt) {
+		t.Errorf("Expected ended ban to be closed at its end, got %+v", entries[1])
+	}
+}
+
+func TestHistory_InitialUpdateKeepsFailedJails(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().Truncate(time.Second)
+	sshd := createHistoryBan("192.168.1.1", "sshd", now.Add(-time.Hour), "600")
+	postfix := createHistoryBan("192.168.1.2", "postfix", now.Add(-time.Hour), "600")
+
+	history := createHistory(t, dir, 0)
+	history.record(Update{Time: now.Add(-time.Hour), Initial: true, Events: []Event{
+		{Type: BanAdded, JailName: "sshd", Ban: sshd},
+		{Type: BanAdded, JailName: "postfix", Ban: postfix},
+	}})
+	_ = history.Close()
+
+	// postfix could not be fetched on the first refresh, its bans are unknown
+	reloaded := createHistory(t, dir, 0)
+	reloaded.record(Update{
+		Time:        now,
+		Initial:     true,
+		Events:      []Event{{Type: JailAppeared, JailName: "sshd"}},
+		FailedJails: map[string]string{"postfix": "connection closed"},
+	})
+
+	entries := reloaded.Entries(HistoryFilter{JailName: "sshd"})
+	if len(entries) != 1 || entries[0].RemovedAt == nil {
+		t.Errorf("Expected the ended sshd ban to be closed, got %+v", entries)
+	}
+	entries = reloaded.Entries(HistoryFilter{JailName: "postfix"})
+	if len(entries) != 1 || entries[0].RemovedAt != nil {
+		t.Fatalf("Expected the postfix ban to stay open, got %+v", entries)
+	}
+
+	// the next refresh fetches postfix and the ban has ended meanwhile
+	reloaded.record(Update{Time: now.Add(time.Minute), Events: []Event{{Type: JailAppeared, JailName: "postfix"}}})
+	entries = reloaded.Entries(HistoryFilter{JailName: "postfix"})
+	if len(entries) != 1 || entries[0].RemovedAt == nil || !entries[0].RemovedAt.Equal(postfix.BanEndsAt) {
+		t.Errorf("Expected the postfix ban to be closed at its end, got %+v", entries)
+	}
+}
+
+func TestHistory_Retention(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().Truncate(time.Second)
+	old := createHistoryBan("192.168.1.1", "sshd", now.Add(-72*time.Hour), "600")
+	recent := createHistoryBan("192.168.1.2", "sshd", now.Add(-time.Hour), "600")
+
+	history := createHistory(t, dir, 24*time.Hour)
+	history.record(Update{Time: now, Events: []Event{
+		{Type: BanRemoved, Time: old.BanEndsAt, JailName: "sshd", Ban: old},
+		{Type: BanRemoved, Time: recent.BanEndsAt, JailName: "sshd", Ban: recent},
+	}})
+
+	if err := history.Compact(); err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+
+	entries := history.Entries(HistoryFilter{})
+	if len(entries) != 1 || entries[0].Address != "192.168.1.2" {
+		t.Errorf("Expected only the recent ban to be kept, got %+v", entries)
+	}
+}
+
+func TestHistory_SkipsInvalidLines(t *testing.T) {
+	dir := t.TempDir()
+	content := `{"address":"192.168.1.1","jail":"sshd","bannedAt":"2025-01-01T10:00:00Z","banEndsAt":"2025-01-01T10:10:00Z","penalty":"600"}
+{"address":"192.168.1.2","jail":"ss`
+	if err := os.WriteFile(filepath.Join(dir, historyFileName), []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write history: %v", err)
+	}
+
+	history := createHistory(t, dir, 0)
+	entries := history.Entries(HistoryFilter{})
+	if len(entries) != 1 || entries[0].Address != "192.168.1.1" {
+		t.Errorf("Expected the valid entry to be loaded, got %+v", entries)
+	}
+}
+
+func TestDataStore_EnableHistory(t *testing.T) {
+	ds := newDisconnectedDataStore(t)
+	if ds.History() != nil {
+		t.Error("Expected no history by default")
+	}
+
+	history := createHistory(t, t.TempDir(), 0)
+	ds.EnableHistory(history)
+	if ds.History() != history {
+		t.Error("Expected history to be enabled")
+	}
+
+	ban := createHistoryBan("192.168.1.1", "sshd", time.Now(), "600")
+	ds.dispatch(Update{Time: time.Now(), Initial: true, Events: []Event{{Type: BanAdded, JailName: "sshd", Ban: ban}}})
+
+	deadline := time.Now().Add(time.Second)
+	for len(history.Entries(HistoryFilter{})) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(history.Entries(HistoryFilter{})) != 1 {
+		t.Error("Expected the dispatched ban to be recorded")
+	}
+}
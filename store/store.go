@@ -1,6 +1,8 @@
 package store
 
 import (
+	"errors"
+	"fmt"
 	"sort"
 	"sync"
 	"time"
@@ -10,73 +12,353 @@ import (
 )
 
 type Jail struct {
-	Name            string
-	BannedCount     int
-	BannedEntries   []client.BanEntry
-	CurrentlyFailed int
-	TotalFailed     int
-	CurrentlyBanned int
-	TotalBanned     int
+	Name            string            `json:"name"`
+	BannedCount     int               `json:"bannedCount"`
+	BannedEntries   []client.BanEntry `json:"bannedEntries,omitempty"`
+	CurrentlyFailed int               `json:"currentlyFailed"`
+	TotalFailed     int               `json:"totalFailed"`
+	CurrentlyBanned int               `json:"currentlyBanned"`
+	TotalBanned     int               `json:"totalBanned"`
 }
 
 type UpdateHandler func()
 
+// fetchConcurrency is how many jails are fetched at the same time, the client uses additional
+// connections while its main connection is busy
+const fetchConcurrency = 4
+
+// RefreshStatus is the outcome of the refreshes, jails which could not be fetched keep their previous data
+type RefreshStatus struct {
+	LastAttempt time.Time         `json:"lastAttempt,omitzero"`
+	LastSuccess time.Time         `json:"lastSuccess,omitzero"`
+	LastError   string            `json:"lastError,omitempty"`
+	FailedJails map[string]string `json:"failedJails,omitempty"`
+}
+
+// Failed tells if the last refresh could not fetch all data
+func (status RefreshStatus) Failed() bool {
+	return status.LastError != "" || len(status.FailedJails) > 0
+}
+
+// record keeps the outcome of a refresh, err is set when the jails could not be listed,
+// failedJails has the error of each jail which could not be fetched
+func (status RefreshStatus) record(now time.Time, err error, failedJails map[string]string) RefreshStatus {
+	status.LastAttempt = now
+	status.LastError = ""
+	if err != nil {
+		status.LastError = err.Error()
+	}
+	status.FailedJails = failedJails
+	if !status.Failed() {
+		status.LastSuccess = now
+	}
+	return status
+}
+
 type DataStore struct {
 	mutex         sync.RWMutex
+	refreshMutex  sync.Mutex
 	ticker        *time.Ticker
 	f2bc          *client.Fail2BanClient
 	addressToJail map[string][]string
 	jails         map[string]*client.JailEntry
 	jailInfos     map[string]*client.JailInfo
+	initialized   bool
+	status        RefreshStatus
+	interval      time.Duration
 	handlers      []UpdateHandler
+	subscribers   []*subscriber
+	history       *History
+	audit         *AuditTrail
 }
 
 func NewDataStore(f2bc *client.Fail2BanClient, refreshSeconds int) *DataStore {
-	if f2bc == nil {
-		return &DataStore{}
-	}
 	dataStore := &DataStore{
 		ticker:        time.NewTicker(time.Duration(refreshSeconds) * time.Second),
+		interval:      time.Duration(refreshSeconds) * time.Second,
 		f2bc:          f2bc,
 		addressToJail: make(map[string][]string),
 		jails:         make(map[string]*client.JailEntry),
 	}
 
+	f2bc.RegisterStateHandler(func(state client.ConnectionState) {
+		if !state.Connected {
+			dataStore.mutex.RLock()
+			dataStore.notifyHandlers()
+			dataStore.mutex.RUnlock()
+			dataStore.dispatch(Update{Time: time.Now()})
+			return
+		}
+		err := dataStore.Refresh()
+		if err != nil {
+			log.Errorf("Could not refresh data after reconnect: %s", err)
+		}
+	})
+
 	return dataStore
 }
 
 func (dataStore *DataStore) start() {
 	defer dataStore.ticker.Stop()
 	for {
-		log.Debug("Fetching fail2ban data")
-		names, err := dataStore.f2bc.GetJailNames()
+		err := dataStore.Refresh()
 		if err != nil {
-			break
+			log.Errorf("Could not fetch fail2ban data: %s", err)
 		}
-		dataStore.initialize(names)
 		<-dataStore.ticker.C
 	}
 }
 
-func (dataStore *DataStore) initialize(names []string) {
-	dataStore.mutex.Lock()
-	defer dataStore.mutex.Unlock()
-	dataStore.jails = make(map[string]*client.JailEntry)
-	dataStore.jailInfos = make(map[string]*client.JailInfo)
-	for _, jailName := range names {
-		jailEntry, getBannedErr := dataStore.f2bc.GetBanned(jailName)
-		if getBannedErr != nil {
-			break
-		}
-		jailInfo, getInfoErr := dataStore.f2bc.GetJailInfo(jailName)
-		if getInfoErr != nil {
-			break
+// Refresh fetches the fail2ban data immediately instead of waiting for the next tick
+func (dataStore *DataStore) Refresh() error {
+	// refreshes must not overlap, otherwise snapshots would be compared out of order
+	dataStore.refreshMutex.Lock()
+	defer dataStore.refreshMutex.Unlock()
+
+	log.Debug("Fetching fail2ban data")
+	names, err := dataStore.f2bc.GetJailNames()
+	if err != nil {
+		now := time.Now()
+		dataStore.mutex.Lock()
+		dataStore.status = dataStore.status.record(now, err, nil)
+		dataStore.notifyHandlers()
+		dataStore.mutex.Unlock()
+		dataStore.dispatch(Update{Time: now})
+		return err
+	}
+	update, err := dataStore.initialize(names)
+	dataStore.dispatch(update)
+	return err
+}
+
+// Ban adds the addresses to the jail and refreshes the data afterward,
+// the returned errors have the same order as the addresses and are nil for successful bans
+func (dataStore *DataStore) Ban(jailName string, addresses ...string) []error {
+	errs := make([]error, len(addresses))
+	for index, address := range addresses {
+		errs[index] = dataStore.f2bc.Ban(jailName, address)
+	}
+	refreshErr := dataStore.Refresh()
+	if refreshErr != nil {
+		log.Errorf("Could not refresh data after ban: %s", refreshErr)
+	}
+	return errs
+}
+
+// Unban removes the address from the jail and refreshes the data afterward
+func (dataStore *DataStore) Unban(jailName string, address string) error {
+	err := dataStore.f2bc.Unban(jailName, address)
+	if err != nil {
+		return err
+	}
+	refreshErr := dataStore.Refresh()
+	if refreshErr != nil {
+		log.Errorf("Could not refresh data after unban: %s", refreshErr)
+	}
+	return nil
+}
+
+// StartJail starts the jail and refreshes the data afterward, the response of fail2ban is returned
+func (dataStore *DataStore) StartJail(jailName string) (string, error) {
+	return dataStore.control(func(f2bc *client.Fail2BanClient) (string, error) {
+		return f2bc.StartJail(jailName)
+	})
+}
+
+// StopJail stops the jail and refreshes the data afterward, the response of fail2ban is returned
+func (dataStore *DataStore) StopJail(jailName string) (string, error) {
+	return dataStore.control(func(f2bc *client.Fail2BanClient) (string, error) {
+		return f2bc.StopJail(jailName)
+	})
+}
+
+// ReloadJail reloads the jail and refreshes the data afterward, the response of fail2ban is returned
+func (dataStore *DataStore) ReloadJail(jailName string) (string, error) {
+	return dataStore.control(func(f2bc *client.Fail2BanClient) (string, error) {
+		return f2bc.ReloadJail(jailName)
+	})
+}
+
+// Reload reloads all jails and refreshes the data afterward, the response of fail2ban is returned
+func (dataStore *DataStore) Reload() (string, error) {
+	return dataStore.control(func(f2bc *client.Fail2BanClient) (string, error) {
+		return f2bc.Reload()
+	})
+}
+
+// ReloadAvailable tells if jails can be reloaded, reloads need the fail2ban-client command
+func (dataStore *DataStore) ReloadAvailable() bool {
+	return dataStore.f2bc.ReloadAvailable()
+}
+
+// control runs the command and refreshes the data, also after failures as jails may have changed anyway
+func (dataStore *DataStore) control(command func(f2bc *client.Fail2BanClient) (string, error)) (string, error) {
+	response, err := command(dataStore.f2bc)
+	refreshErr := dataStore.Refresh()
+	if refreshErr != nil {
+		log.Errorf("Could not refresh data after jail control: %s", refreshErr)
+	}
+	return response, err
+}
+
+// GetIgnoreIPs reads the ignore list of the jail, it is not cached as it is only shown on request
+func (dataStore *DataStore) GetIgnoreIPs(jailName string) ([]string, error) {
+	return dataStore.f2bc.GetIgnoreIPs(jailName)
+}
+
+// AddIgnoreIP adds the address to the ignore list of the jail and records the change in the audit trail
+func (dataStore *DataStore) AddIgnoreIP(actor Actor, jailName string, address string) ([]string, error) {
+	ignoreIPs, err := dataStore.f2bc.AddIgnoreIP(jailName, address)
+	dataStore.recordAudit(actor, AuditAddIgnoreIP, jailName, address, err)
+	return ignoreIPs, err
+}
+
+// DelIgnoreIP removes the address from the ignore list of the jail and records the change in the audit trail
+func (dataStore *DataStore) DelIgnoreIP(actor Actor, jailName string, address string) ([]string, error) {
+	ignoreIPs, err := dataStore.f2bc.DelIgnoreIP(jailName, address)
+	dataStore.recordAudit(actor, AuditDelIgnoreIP, jailName, address, err)
+	return ignoreIPs, err
+}
+
+// UnbanAndIgnore adds the address to the ignore list before unbanning it, so it is not banned again
+// right away, the address stays banned when it could not be added to the ignore list
+func (dataStore *DataStore) UnbanAndIgnore(actor Actor, jailName string, address string) error {
+	if _, err := dataStore.AddIgnoreIP(actor, jailName, address); err != nil {
+		return err
+	}
+	err := dataStore.f2bc.Unban(jailName, address)
+	dataStore.recordAudit(actor, AuditUnban, jailName, address, err)
+	if err != nil {
+		return err
+	}
+	refreshErr := dataStore.Refresh()
+	if refreshErr != nil {
+		log.Errorf("Could not refresh data after unban: %s", refreshErr)
+	}
+	return nil
+}
+
+// ConnectionState is the state of the fail2ban socket connection, the data is kept while reconnecting
+func (dataStore *DataStore) ConnectionState() client.ConnectionState {
+	return dataStore.f2bc.ConnectionState()
+}
+
+// Fail2BanVersion is the version of the connected fail2ban, unknown until it was read from the socket
+func (dataStore *DataStore) Fail2BanVersion() string {
+	if version := dataStore.f2bc.Version(); version != "" {
+		return version
+	}
+	return "unknown"
+}
+
+// initialize fetches all jails and swaps in the new snapshot at once, a jail which could not be fetched keeps its previous data,
+// otherwise it would show up as disappeared jail with removed bans
+func (dataStore *DataStore) initialize(names []string) (Update, error) {
+	// only refreshes replace the snapshot and they don't overlap, so it can't change until the swap
+	dataStore.mutex.RLock()
+	previousJails := dataStore.jails
+	previousInfos := dataStore.jailInfos
+	initial := !dataStore.initialized
+	dataStore.mutex.RUnlock()
+
+	results := dataStore.fetchJails(names)
+	jails := make(map[string]*client.JailEntry)
+	jailInfos := make(map[string]*client.JailInfo)
+	var failedJails map[string]string
+	var errs []error
+	for index, jailName := range names {
+		result := results[index]
+		if result.err != nil {
+			if failedJails == nil {
+				failedJails = make(map[string]string)
+			}
+			failedJails[jailName] = result.err.Error()
+			errs = append(errs, fmt.Errorf("jail %s: %w", jailName, result.err))
+			if previous, exists := previousJails[jailName]; exists {
+				jails[jailName] = previous
+				jailInfos[jailName] = previousInfos[jailName]
+			}
+			continue
 		}
 
-		dataStore.jails[jailName] = jailEntry
-		dataStore.jailInfos[jailName] = jailInfo
+		jails[jailName] = result.entry
+		jailInfos[jailName] = result.info
+	}
+	refreshErr := errors.Join(errs...)
+
+	now := time.Now()
+	update := Update{
+		Time:        now,
+		Initial:     initial,
+		Events:      diffSnapshots(previousJails, jails, now),
+		FailedJails: failedJails,
 	}
+	addressToJail := mapAddressToJail(jails)
+
+	dataStore.mutex.Lock()
+	defer dataStore.mutex.Unlock()
+	dataStore.jails = jails
+	dataStore.jailInfos = jailInfos
+	dataStore.addressToJail = addressToJail
+	dataStore.initialized = true
+	dataStore.status = dataStore.status.record(now, nil, failedJails)
 	dataStore.notifyHandlers()
+	return update, refreshErr
+}
+
+type jailResult struct {
+	entry *client.JailEntry
+	info  *client.JailInfo
+	err   error
+}
+
+// fetchJails fetches the jails concurrently without holding the lock, the results have the order of the names
+func (dataStore *DataStore) fetchJails(names []string) []jailResult {
+	results := make([]jailResult, len(names))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range min(fetchConcurrency, len(names)) {
+		wg.Go(func() {
+			for index := range indexes {
+				entry, info, err := dataStore.fetchJail(names[index])
+				results[index] = jailResult{entry: entry, info: info, err: err}
+			}
+		})
+	}
+	for index := range names {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+func (dataStore *DataStore) fetchJail(jailName string) (*client.JailEntry, *client.JailInfo, error) {
+	jailEntry, err := dataStore.f2bc.GetBanned(jailName)
+	if err != nil {
+		return nil, nil, err
+	}
+	jailInfo, err := dataStore.f2bc.GetJailInfo(jailName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return jailEntry, jailInfo, nil
+}
+
+// mapAddressToJail indexes which jails ban an address, the jail names are sorted
+func mapAddressToJail(jails map[string]*client.JailEntry) map[string][]string {
+	result := make(map[string][]string)
+	for jailName, jailEntry := range jails {
+		for _, ban := range jailEntry.BannedEntries {
+			if ban != nil {
+				result[ban.Address] = append(result[ban.Address], jailName)
+			}
+		}
+	}
+	for address := range result {
+		sort.Strings(result[address])
+	}
+	return result
 }
 
 func (dataStore *DataStore) notifyHandlers() {
@@ -92,15 +374,29 @@ func (dataStore *DataStore) RegisterUpdateHandler(handler UpdateHandler) {
 }
 
 func (dataStore *DataStore) Start() {
-	if dataStore.f2bc == nil {
-		return
-	}
 	time.AfterFunc(2*time.Second, func() {
 		go dataStore.start()
 	})
 
 }
 
+// LastRefresh is the time of the last successful refresh, zero before the first one
+func (dataStore *DataStore) LastRefresh() time.Time {
+	return dataStore.RefreshStatus().LastSuccess
+}
+
+// RefreshStatus tells when the data was refreshed and why the last refresh failed
+func (dataStore *DataStore) RefreshStatus() RefreshStatus {
+	dataStore.mutex.RLock()
+	defer dataStore.mutex.RUnlock()
+	return dataStore.status
+}
+
+// RefreshInterval is the time between two scheduled refreshes
+func (dataStore *DataStore) RefreshInterval() time.Duration {
+	return dataStore.interval
+}
+
 func (dataStore *DataStore) GetJails() []Jail {
 	dataStore.mutex.RLock()
 	defer dataStore.mutex.RUnlock()
@@ -135,6 +431,30 @@ func (dataStore *DataStore) GetJailByName(jailName string) (Jail, bool) {
 	return Jail{}, false
 }
 
+// GetJailConfig reads the configuration of the jail from fail2ban, it is not cached as it is only shown on request
+func (dataStore *DataStore) GetJailConfig(jailName string) (*client.JailConfig, error) {
+	return dataStore.f2bc.GetJailConfig(jailName)
+}
+
+// GetBansByAddress returns the bans of the address in all jails sorted by jail name
+func (dataStore *DataStore) GetBansByAddress(address string) []client.BanEntry {
+	dataStore.mutex.RLock()
+	defer dataStore.mutex.RUnlock()
+	bans := make([]client.BanEntry, 0)
+	for _, jailName := range dataStore.addressToJail[address] {
+		jailEntry := dataStore.jails[jailName]
+		if jailEntry == nil {
+			continue
+		}
+		for _, ban := range jailEntry.BannedEntries {
+			if ban != nil && ban.Address == address {
+				bans = append(bans, *ban)
+			}
+		}
+	}
+	return bans
+}
+
 func createJail(entry *client.JailEntry, info *client.JailInfo) Jail {
 	result := Jail{}
 	if entry != nil {
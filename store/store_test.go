@@ -3,10 +3,13 @@ package store
 import (
 	"errors"
 	"fmt"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
 	client "github.com/webishdev/fail2ban-dashboard/fail2ban-client"
+	"github.com/webishdev/fail2ban-dashboard/fail2bantest"
 )
 
 // MockFail2BanClient is a mock implementation of the Fail2BanClient
@@ -77,64 +80,41 @@ func (m *MockFail2BanClient) SetBannedError(err error) {
 	m.bannedError = err
 }
 
-func TestNewDataStore(t *testing.T) {
-	t.Run("with nil client", func(t *testing.T) {
-		ds := NewDataStore(nil, 30)
-		if ds == nil {
-			t.Error("NewDataStore should not return nil")
-		}
-		if ds.f2bc != nil {
-			t.Error("Expected nil f2bc client")
-		}
-		if ds.ticker != nil {
-			t.Error("Expected nil ticker for nil client")
-		}
-	})
-
-	t.Run("with valid client", func(t *testing.T) {
-		ds := &DataStore{
-			ticker:        time.NewTicker(30 * time.Second),
-			addressToJail: make(map[string][]string),
-			jails:         make(map[string]*client.JailEntry),
-		}
-		defer ds.ticker.Stop()
+// newDisconnectedDataStore uses a client whose socket does not exist, so every command fails as not connected
+func newDisconnectedDataStore(tb testing.TB) *DataStore {
+	tb.Helper()
+	f2bc, err := client.NewFail2BanClient(filepath.Join(tb.TempDir(), "fail2ban.sock"))
+	if err == nil {
+		tb.Fatal("NewFail2BanClient() should fail without socket")
+	}
+	tb.Cleanup(func() { _ = f2bc.Close() })
+	return NewDataStore(f2bc, 30)
+}
 
-		if ds == nil {
-			t.Error("NewDataStore should not return nil")
-		}
-		if ds.ticker == nil {
-			t.Error("Expected non-nil ticker for valid client")
-		}
-		if ds.addressToJail == nil {
-			t.Error("Expected initialized addressToJail map")
-		}
-		if ds.jails == nil {
-			t.Error("Expected initialized jails map")
-		}
-	})
+func TestNewDataStore(t *testing.T) {
+	ds := newDisconnectedDataStore(t)
+	if ds.f2bc == nil {
+		t.Error("Expected the client to be kept")
+	}
+	if ds.ticker == nil {
+		t.Error("Expected non-nil ticker")
+	}
+	if ds.addressToJail == nil {
+		t.Error("Expected initialized addressToJail map")
+	}
+	if ds.jails == nil {
+		t.Error("Expected initialized jails map")
+	}
 }
 
 func TestDataStore_Start(t *testing.T) {
-	t.Run("with nil client", func(t *testing.T) {
-		ds := NewDataStore(nil, 30)
-		// This should not panic and should return immediately
-		ds.Start()
-	})
+	ds := newDisconnectedDataStore(t)
 
-	t.Run("with valid client", func(t *testing.T) {
-		ds := &DataStore{
-			ticker:        time.NewTicker(30 * time.Second),
-			addressToJail: make(map[string][]string),
-			jails:         make(map[string]*client.JailEntry),
-		}
-		defer ds.ticker.Stop()
+	// Start should not panic
+	ds.Start()
 
-		// Start should not panic
-		ds.Start()
-
-		// Give it a moment to start the goroutine
-		time.Sleep(10 * time.Millisecond)
-	})
+	// Give it a moment to start the goroutine
+	time.Sleep(10 * time.Millisecond)
 }
 
 func TestDataStore_GetJails(t *testing.T) {
@@ -480,3 +460,320 @@ func BenchmarkCreateJail(b *testing.B) {
 		_ = jail
 	}
 }
+
+func TestDataStore_Refresh(t *testing.T) {
+	t.Run("without connection", func(t *testing.T) {
+		ds := newDisconnectedDataStore(t)
+		if err := ds.Refresh(); err == nil {
+			t.Error("Expected error when refreshing without connection")
+		}
+	})
+}
+
+func TestDataStore_RefreshFailedJail(t *testing.T) {
+	server := fail2bantest.Start(t, fail2bantest.Version1_1)
+	server.AddJail(fail2bantest.Jail{Name: "postfix", BanTime: 600})
+	server.AddJail(fail2bantest.Jail{Name: "sshd", BanTime: 600})
+	server.DropCommand("get postfix banip", true)
+	f2bc, err := client.NewFail2BanClient(server.Address())
+	if err != nil {
+		t.Fatalf("NewFail2BanClient() error = %v", err)
+	}
+	t.Cleanup(func() { _ = f2bc.Close() })
+	ds := NewDataStore(f2bc, 30)
+
+	updates := make(chan Update, 10)
+	ds.Subscribe(func(update Update) { updates <- update })
+	if err = ds.Refresh(); err == nil {
+		t.Error("Expected the failed jail to be reported")
+	}
+
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case update := <-updates:
+			if !update.Initial {
+				continue
+			}
+			if _, failed := update.FailedJails["postfix"]; !failed || len(update.FailedJails) != 1 {
+				t.Errorf("Expected only postfix as failed jail, got %v", update.FailedJails)
+			}
+			return
+		case <-deadline:
+			t.Fatal("Expected the initial update")
+		}
+	}
+}
+
+func TestDataStore_Ban(t *testing.T) {
+	t.Run("without connection", func(t *testing.T) {
+		ds := newDisconnectedDataStore(t)
+		errs := ds.Ban("sshd", "192.168.1.1", "10.0.0.0/24")
+		if len(errs) != 2 {
+			t.Fatalf("Expected 2 results, got %d", len(errs))
+		}
+		for index, err := range errs {
+			if err == nil {
+				t.Errorf("Expected error for address %d when banning without connection", index)
+			}
+		}
+	})
+}
+
+func TestDataStore_Unban(t *testing.T) {
+	t.Run("without connection", func(t *testing.T) {
+		ds := newDisconnectedDataStore(t)
+		if err := ds.Unban("sshd", "192.168.1.1"); err == nil {
+			t.Error("Expected error when unbanning without connection")
+		}
+	})
+
+	tests := []struct {
+		name  string
+		unban func(ds *DataStore) error
+	}{
+		{name: "unban", unban: func(ds *DataStore) error { return ds.Unban("sshd", "192.168.1.1") }},
+		{name: "unban and ignore", unban: func(ds *DataStore) error { return ds.UnbanAndIgnore(Actor{User: "admin"}, "sshd", "192.168.1.1") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+" when the refresh of another jail fails", func(t *testing.T) {
+			server := fail2bantest.Start(t, fail2bantest.Version1_1)
+			server.AddJail(fail2bantest.Jail{Name: "postfix", BanTime: 600})
+			server.AddJail(fail2bantest.Jail{
+				Name:    "sshd",
+				BanTime: 600,
+				Bans:    []fail2bantest.Ban{{Address: "192.168.1.1", BannedAt: time.Now(), BanTime: 600}},
+			})
+			server.DropCommand("get postfix banip", true)
+			f2bc, err := client.NewFail2BanClient(server.Address())
+			if err != nil {
+				t.Fatalf("NewFail2BanClient() error = %v", err)
+			}
+			t.Cleanup(func() { _ = f2bc.Close() })
+			ds := NewDataStore(f2bc, 30)
+
+			if err = tt.unban(ds); err != nil {
+				t.Errorf("%s error = %v, the failed refresh must not be reported", tt.name, err)
+			}
+			if jail, _ := server.Jail("sshd"); len(jail.Bans) != 0 {
+				t.Errorf("Expected the address to be unbanned, got %+v", jail.Bans)
+			}
+			if !ds.RefreshStatus().Failed() {
+				t.Error("Expected the refresh status to show the failure")
+			}
+		})
+	}
+}
+
+func TestDataStore_JailControl(t *testing.T) {
+	ds := newDisconnectedDataStore(t)
+	tests := []struct {
+		name    string
+		control func() (string, error)
+	}{
+		{name: "start", control: func() (string, error) { return ds.StartJail("sshd") }},
+		{name: "stop", control: func() (string, error) { return ds.StopJail("sshd") }},
+		{name: "reload jail", control: func() (string, error) { return ds.ReloadJail("sshd") }},
+		{name: "reload", control: ds.Reload},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+" without connection", func(t *testing.T) {
+			if _, err := tt.control(); err == nil {
+				t.Errorf("Expected error for %s without connection", tt.name)
+			}
+		})
+	}
+}
+
+func TestDataStore_ConnectionState(t *testing.T) {
+	t.Run("with missing socket", func(t *testing.T) {
+		f2bc, err := client.NewFail2BanClient("/non/existent/socket")
+		if err == nil {
+			t.Fatal("Expected error for missing socket")
+		}
+		defer func() { _ = f2bc.Close() }()
+
+		ds := NewDataStore(f2bc, 30)
+		defer ds.ticker.Stop()
+
+		state := ds.ConnectionState()
+		if state.Connected || state.Since.IsZero() {
+			t.Errorf("Expected reconnecting state, got %+v", state)
+		}
+		if err := ds.Refresh(); !errors.Is(err, client.ErrNotConnected) {
+			t.Errorf("Expected %v, got %v", client.ErrNotConnected, err)
+		}
+	})
+}
+
+func TestDataStore_GetBansByAddress(t *testing.T) {
+	jails := map[string]*client.JailEntry{
+		"sshd": {Name: "sshd", BannedEntries: []*client.BanEntry{
+			{Address: "192.168.1.1", JailName: "sshd"},
+			{Address: "192.168.1.2", JailName: "sshd"},
+		}},
+		"postfix": {Name: "postfix", BannedEntries: []*client.BanEntry{
+			{Address: "192.168.1.1", JailName: "postfix"},
+		}},
+	}
+	ds := &DataStore{
+		jails:         jails,
+		addressToJail: mapAddressToJail(jails),
+	}
+
+	if jailNames := ds.addressToJail["192.168.1.1"]; len(jailNames) != 2 || jailNames[0] != "postfix" || jailNames[1] != "sshd" {
+		t.Errorf("Expected sorted jails postfix and sshd, got %v", jailNames)
+	}
+
+	tests := []struct {
+		name     string
+		address  string
+		expected []string
+	}{
+		{"banned in two jails", "192.168.1.1", []string{"postfix", "sshd"}},
+		{"banned in one jail", "192.168.1.2", []string{"sshd"}},
+		{"not banned", "192.168.1.3", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bans := ds.GetBansByAddress(tt.address)
+			if len(bans) != len(tt.expected) {
+				t.Fatalf("Expected %d bans, got %d", len(tt.expected), len(bans))
+			}
+			for index, jailName := range tt.expected {
+				if bans[index].JailName != jailName || bans[index].Address != tt.address {
+					t.Errorf("Expected ban of %s in %s, got %+v", tt.address, jailName, bans[index])
+				}
+			}
+		})
+	}
+}
+
+func TestRefreshStatus_Record(t *testing.T) {
+	first := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	second := first.Add(time.Minute)
+	third := second.Add(time.Minute)
+
+	status := RefreshStatus{}.record(first, nil, nil)
+	if status.Failed() || !status.LastSuccess.Equal(first) || !status.LastAttempt.Equal(first) {
+		t.Errorf("Expected successful refresh, got %+v", status)
+	}
+
+	status = status.record(second, nil, map[string]string{"sshd": "timeout"})
+	if !status.Failed() || !status.LastSuccess.Equal(first) || !status.LastAttempt.Equal(second) {
+		t.Errorf("Expected failed jail to keep the last success, got %+v", status)
+	}
+
+	status = status.record(third, errors.New("broken pipe"), nil)
+	if !status.Failed() || status.LastError != "broken pipe" || len(status.FailedJails) != 0 || !status.LastSuccess.Equal(first) {
+		t.Errorf("Expected failed refresh, got %+v", status)
+	}
+
+	status = status.record(third.Add(time.Minute), nil, nil)
+	if status.Failed() || status.LastError != "" || !status.LastSuccess.Equal(third.Add(time.Minute)) {
+		t.Errorf("Expected the error to be cleared, got %+v", status)
+	}
+}
+
+func newSlowDataStore(tb testing.TB, jailCount int, delay time.Duration) *DataStore {
+	tb.Helper()
+	server := fail2bantest.Start(tb, fail2bantest.Version1_1)
+	server.SetLatency(delay)
+	for index := range jailCount {
+		server.AddJail(fail2bantest.Jail{
+			Name:            fmt.Sprintf("jail%02d", index),
+			BanTime:         600,
+			CurrentlyFailed: 1,
+			TotalFailed:     10,
+			TotalBanned:     5,
+			Bans:            []fail2bantest.Ban{{Address: "192.168.1.1", BannedAt: time.Now(), BanTime: 600}},
+		})
+	}
+	f2bc, err := client.NewFail2BanClient(server.Address())
+	if err != nil {
+		tb.Fatalf("NewFail2BanClient() error = %v", err)
+	}
+	tb.Cleanup(func() { _ = f2bc.Close() })
+	return NewDataStore(f2bc, 30)
+}
+
+func TestDataStore_RefreshConcurrently(t *testing.T) {
+	const jailCount = 8
+	const delay = 20 * time.Millisecond
+	dataStore := newSlowDataStore(t, jailCount, delay)
+
+	refreshed := make(chan error, 1)
+	start := time.Now()
+	go func() { refreshed <- dataStore.Refresh() }()
+
+	// reading must not wait for the refresh, the snapshot is swapped in when it is complete
+	time.Sleep(2 * delay)
+	dataStore.GetJails()
+	select {
+	case <-refreshed:
+		t.Fatal("Refresh() finished before GetJails() could be checked, the delay is too short")
+	default:
+	}
+
+	if err := <-refreshed; err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	elapsed := time.Since(start)
+	// the status command and two commands per jail one after another
+	sequential := (1 + 2*jailCount) * delay
+	if elapsed >= sequential {
+		t.Errorf("Refresh() took %s, not faster than fetching the jails one after another (%s)", elapsed, sequential)
+	}
+
+	jails := dataStore.GetJails()
+	if len(jails) != jailCount {
+		t.Fatalf("Expected %d jails, got %d", jailCount, len(jails))
+	}
+	for _, jail := range jails {
+		if jail.BannedCount != 1 || jail.TotalBanned != 5 || jail.TotalFailed != 10 {
+			t.Errorf("Unexpected jail %+v", jail)
+		}
+	}
+	if status := dataStore.RefreshStatus(); status.Failed() || status.LastSuccess.IsZero() {
+		t.Errorf("Expected successful refresh, got %+v", status)
+	}
+}
+
+func BenchmarkDataStore_Refresh(b *testing.B) {
+	dataStore := newSlowDataStore(b, 40, time.Millisecond)
+
+	for b.Loop() {
+		if err := dataStore.Refresh(); err != nil {
+			b.Fatalf("Refresh() error = %v", err)
+		}
+	}
+}
+
+func BenchmarkDataStore_GetJailsDuringRefresh(b *testing.B) {
+	dataStore := newSlowDataStore(b, 40, time.Millisecond)
+	if err := dataStore.Refresh(); err != nil {
+		b.Fatalf("Refresh() error = %v", err)
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				_ = dataStore.Refresh()
+			}
+		}
+	})
+
+	for b.Loop() {
+		dataStore.GetJails()
+	}
+	close(done)
+	wg.Wait()
+}